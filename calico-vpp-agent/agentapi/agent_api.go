@@ -0,0 +1,148 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/tomb.v2"
+)
+
+// The agent API is a JSON over HTTP API served on a unix socket, used by the
+// command line tools shipped with the agent to query it.
+
+type errorReply struct {
+	Error string `json:"error"`
+}
+
+// Server serves the agent API
+type Server struct {
+	log    *logrus.Entry
+	socket string
+	mux    *http.ServeMux
+}
+
+func NewAgentAPIServer(socket string, log *logrus.Entry) *Server {
+	return &Server{
+		log:    log,
+		socket: socket,
+		mux:    http.NewServeMux(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, reply interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// Handle registers the handler of the requests POSTed to path. The request
+// body is decoded as a Req, and the handler reply is encoded in the response.
+func Handle[Req, Reply any](s *Server, path string, handler func(request *Req) (*Reply, error)) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, &errorReply{Error: "only POST is supported"})
+			return
+		}
+		request := new(Req)
+		err := json.NewDecoder(r.Body).Decode(request)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorReply{Error: fmt.Sprintf("invalid request: %s", err)})
+			return
+		}
+		reply, err := handler(request)
+		if err != nil {
+			s.log.WithError(err).Debugf("Agent API request to %s failed", path)
+			writeJSON(w, http.StatusInternalServerError, &errorReply{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, reply)
+	})
+}
+
+func (s *Server) ServeAgentAPI(t *tomb.Tomb) error {
+	// Cleanup potentially left over socket
+	err := os.RemoveAll(s.socket)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete socket %s", s.socket)
+	}
+	listener, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return errors.Wrapf(err, "could not bind to unix://%s", s.socket)
+	}
+	server := &http.Server{Handler: s.mux}
+	go func() {
+		<-t.Dying()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+	s.log.Infof("Serving agent API on %s", s.socket)
+	err = server.Serve(listener)
+	if err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "agent API server errored")
+	}
+	return nil
+}
+
+// Call sends the request to path on the agent API served on socket, and
+// decodes the reply
+func Call[Req, Reply any](socket string, path string, request *Req) (*Reply, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode request")
+	}
+	// The host is ignored, requests are sent to the unix socket
+	response, err := client.Post("http://calico-vpp-agent"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot reach agent API on %s", socket)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read reply")
+	}
+	if response.StatusCode != http.StatusOK {
+		errReply := &errorReply{}
+		if json.Unmarshal(data, errReply) != nil || errReply.Error == "" {
+			return nil, errors.Errorf("agent API returned %s", response.Status)
+		}
+		return nil, errors.New(errReply.Error)
+	}
+	reply := new(Reply)
+	err = json.Unmarshal(data, reply)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode reply")
+	}
+	return reply, nil
+}
@@ -34,6 +34,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/agentapi"
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni"
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/connectivity"
@@ -142,6 +143,7 @@ func main() {
 	peerWatcher := watchers.NewPeerWatcher(clientv3, k8sclient, log.WithFields(logrus.Fields{"subcomponent": "peer-watcher"}))
 	bgpFilterWatcher := watchers.NewBGPFilterWatcher(clientv3, k8sclient, log.WithFields(logrus.Fields{"subcomponent": "BGPFilter-watcher"}))
 	netWatcher := watchers.NewNetWatcher(vpp, log.WithFields(logrus.Fields{"component": "net-watcher"}))
+	podWatcher := watchers.NewPodWatcher(k8sclient, log.WithFields(logrus.Fields{"subcomponent": "pod-watcher"}))
 	routingServer := routing.NewRoutingServer(vpp, bgpServer, log.WithFields(logrus.Fields{"component": "routing"}))
 	serviceServer := services.NewServiceServer(vpp, k8sclient, log.WithFields(logrus.Fields{"component": "services"}))
 	prometheusServer := prometheus.NewPrometheusServer(vpp, log.WithFields(logrus.Fields{"component": "prometheus"}))
@@ -156,6 +158,11 @@ func main() {
 	}
 	connectivityServer := connectivity.NewConnectivityServer(vpp, policyServer, clientv3, log.WithFields(logrus.Fields{"subcomponent": "connectivity"}))
 	cniServer := cni.NewCNIServer(vpp, policyServer, log.WithFields(logrus.Fields{"component": "cni"}))
+	cniServer.SetPodLister(podWatcher)
+	cniServer.SetPodEventRecorder(podWatcher)
+	agentAPIServer := agentapi.NewAgentAPIServer(config.AgentAPISocket, log.WithFields(logrus.Fields{"component": "agent-api"}))
+	agentapi.Handle(agentAPIServer, policy.PolicySimulationPath, policyServer.SimulatePolicy)
+	agentapi.Handle(agentAPIServer, cni.PodCapturePath, cniServer.CapturePod)
 
 	/* Pubsub should now be registered */
 
@@ -170,6 +177,8 @@ func main() {
 
 	watchDog := watchdog.NewWatchDog(log.WithFields(logrus.Fields{"component": "watchDog"}), &t)
 	Go(policyServer.ServePolicy)
+	Go(policyServer.ServeFlowLogs)
+	Go(agentAPIServer.ServeAgentAPI)
 	felixConfig := watchDog.Wait(policyServer.FelixConfigChan, "Waiting for FelixConfig to be provided by the calico pod")
 	ourBGPSpec := watchDog.Wait(policyServer.GotOurNodeBGPchan, "Waiting for bgp spec to be provided on node add")
 	// check if the watchDog timer has issued the t.Kill() which would mean we are dead
@@ -215,6 +224,7 @@ func main() {
 	Go(routingServer.ServeRouting)
 	Go(serviceServer.ServeService)
 	Go(cniServer.ServeCNI)
+	Go(podWatcher.WatchPods)
 	Go(prometheusServer.ServePrometheus)
 
 	// watch LocalSID if SRv6 is enabled
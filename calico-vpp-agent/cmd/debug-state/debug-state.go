@@ -16,28 +16,245 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
 	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
 )
 
-func main() {
-	var fname string
-	cniServerStateFile := fmt.Sprintf("%s%d", config.CniServerStateFile, storage.CniServerStateFileVersion)
-	flag.StringVar(&fname, "f", cniServerStateFile, "Pod state path")
-	flag.Parse()
+// debug-state inspects the pod state persisted by the CNI server, e.g.
+// debug-state list -namespace default
+// debug-state show -pod nginx -o json
+// debug-state check -vpp-socket /var/run/vpp/vpp-api.sock
+
+const usage = `Usage: debug-state <command> [flags]
+
+Commands:
+  list   print one line per pod interface
+  show   print the interfaces, routes, VRFs, hostports and PBL clients of pods
+  check  flag the pod interfaces whose interfaces, VRFs, routes, hostports or
+         PBL clients are missing in VPP, exits with 1 when some are
+  dump   print the raw state entries
+
+Run 'debug-state <command> -h' for the flags of a command.
+`
+
+type options struct {
+	fname      string
+	output     string
+	filter     PodFilter
+	vpp        bool
+	vppSocket  string
+	onlyIssues bool
+}
 
-	st, err := storage.LoadCniServerState(fname)
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&opts.fname, "f", config.CniServerStateFile, "Pod state path")
+	if name == "dump" {
+		return flags
+	}
+	flags.StringVar(&opts.output, "o", "table", "Output format: table or json")
+	flags.StringVar(&opts.filter.Namespace, "namespace", "", "Only report the pods of this namespace")
+	flags.StringVar(&opts.filter.Pod, "pod", "", "Only report the pods with this name")
+	flags.StringVar(&opts.filter.Netns, "netns", "", "Only report the pod with this netns path or name")
+	flags.StringVar(&opts.vppSocket, "vpp-socket", config.VppAPISocket, "VPP API socket")
+	if name != "check" {
+		flags.BoolVar(&opts.vpp, "vpp", false, "Check the pods against VPP")
+	}
+	return flags
+}
+
+func loadState(fname string) []storage.LocalPodSpec {
+	specs, err := storage.LoadCniServerState(fname)
 	if err != nil {
-		log.Errorf("LoadCniServerState errored: %v", err)
-		return
+		log.Fatalf("LoadCniServerState errored: %v", err)
 	}
-	for i, s := range st {
+	return specs
+}
+
+func dump(specs []storage.LocalPodSpec) {
+	for i, s := range specs {
 		log.Infof("-------- Elem %d--------\n%s", i, s.FullString())
 	}
-	log.Infof("%d Elts", len(st))
+	log.Infof("%d Elts", len(specs))
+}
+
+func getReports(specs []storage.LocalPodSpec, opts *options) []*PodReport {
+	var state *vppState
+	if opts.vpp {
+		vpp, err := vpplink.NewVppLink(opts.vppSocket, log.WithFields(log.Fields{"component": "vpp-api"}))
+		if err != nil {
+			log.Fatalf("Cannot connect to VPP on %s: %v", opts.vppSocket, err)
+		}
+		defer vpp.Close()
+		state, err = newVppState(vpp)
+		if err != nil {
+			log.Fatalf("Cannot get the VPP state: %v", err)
+		}
+	}
+	reports := make([]*PodReport, 0, len(specs))
+	for i := range specs {
+		report := NewPodReport(&specs[i])
+		if !opts.filter.Matches(report) {
+			continue
+		}
+		if state != nil {
+			err := state.Check(report)
+			if err != nil {
+				log.Fatalf("Cannot check %s: %v", report.Name(), err)
+			}
+		}
+		if opts.onlyIssues && len(report.Issues) == 0 {
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func joinUint32(values ...uint32) string {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == vpplink.InvalidID {
+			strs = append(strs, "-")
+		} else {
+			strs = append(strs, fmt.Sprint(value))
+		}
+	}
+	return strings.Join(strs, "/")
+}
+
+func printList(reports []*PodReport, opts *options) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "NAMESPACE\tPOD\tINTERFACE\tNETWORK\tADDRESSES\tTUN/MEMIF/VHOST/LOOPBACK\tVRF4/VRF6"
+	if opts.vpp {
+		header += "\tISSUES"
+	}
+	fmt.Fprintln(w, header)
+	for _, r := range reports {
+		network := r.Network
+		if network == "" {
+			network = "-"
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s", r.Namespace, r.Pod, r.Interface, network,
+			strings.Join(r.ContainerIPs, ","),
+			joinUint32(r.spec.TunTapSwIfIndex, r.spec.MemifSwIfIndex, r.spec.VhostUserSwIfIndex, r.spec.LoopbackSwIfIndex),
+			joinUint32(r.spec.V4VrfId, r.spec.V6VrfId))
+		if opts.vpp {
+			line += fmt.Sprintf("\t%d", len(r.Issues))
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
+
+func printShow(reports []*PodReport, opts *options) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\n", r.Name())
+		fmt.Fprintf(w, "  netns:\t%s\n", r.Netns)
+		if r.Network != "" {
+			fmt.Fprintf(w, "  network:\t%s\n", r.Network)
+		}
+		fmt.Fprintf(w, "  addresses:\t%s\n", strings.Join(r.ContainerIPs, ", "))
+		fmt.Fprintf(w, "  interfaces:\n")
+		for _, intf := range r.Interfaces {
+			fmt.Fprintf(w, "    %s\tswIfIndex=%d\ttag=%s\t%s\n", intf.Driver, intf.SwIfIndex, intf.Tag, intf.VppName)
+		}
+		fmt.Fprintf(w, "  routes:\n")
+		for _, route := range r.Routes {
+			fmt.Fprintf(w, "    %s\tswIfIndex=%d\ttable=%s\n", route.Dst, route.SwIfIndex, formatTable(route.Table))
+		}
+		fmt.Fprintf(w, "  vrfs:\n")
+		for _, vrf := range r.VRFs {
+			fmt.Fprintf(w, "    %s %s\tid=%d\ttag=%s\n", vrf.Family, vrf.Kind, vrf.ID, vrf.Tag)
+		}
+		if len(r.HostPorts) > 0 {
+			fmt.Fprintf(w, "  hostports:\n")
+			for _, hostPort := range r.HostPorts {
+				fmt.Fprintf(w, "    %s %s:%d\tcport=%d\tentry=%s\n", hostPort.Protocol, hostPort.HostIP,
+					hostPort.HostPort, hostPort.ContainerPort, joinUint32(hostPort.EntryID))
+			}
+		}
+		if len(r.PblClients) > 0 {
+			fmt.Fprintf(w, "  pbl clients:\n")
+			for _, client := range r.PblClients {
+				fmt.Fprintf(w, "    %d\t%s\ttable=%d\tports=%s\n", client.Index, client.Addr, client.Table,
+					strings.Join(client.PortRanges, ","))
+			}
+		}
+		if opts.vpp {
+			fmt.Fprintf(w, "  issues:\n")
+			for _, issue := range r.Issues {
+				fmt.Fprintf(w, "    %s\n", issue)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func main() {
+	args := os.Args[1:]
+	command := "dump"
+	/* Without a command, keep the behavior of the former debug-state */
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	opts := &options{}
+	switch command {
+	case "list", "show", "dump":
+	case "check":
+		opts.vpp = true
+		opts.onlyIssues = true
+	case "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	flags := newFlagSet(command, opts)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: debug-state %s [flags]\n", command)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if opts.output != "" && opts.output != "table" && opts.output != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s\n", opts.output)
+		os.Exit(2)
+	}
+	/* Keep stdout for the output */
+	log.SetOutput(os.Stderr)
+	if command != "dump" {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	specs := loadState(opts.fname)
+	if command == "dump" {
+		dump(specs)
+		return
+	}
+	reports := getReports(specs, opts)
+	if opts.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(reports)
+	} else if command == "list" {
+		printList(reports, opts)
+	} else {
+		printShow(reports, opts)
+	}
+	if command == "check" && len(reports) > 0 {
+		os.Exit(1)
+	}
 }
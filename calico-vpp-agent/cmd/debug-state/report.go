@@ -0,0 +1,267 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// Names of the pod interface drivers, used in the interface tags
+const (
+	tunDriverName       = "tun"
+	memifDriverName     = "memif"
+	loopbackDriverName  = "loopback"
+	vhostUserDriverName = "vhostuser"
+)
+
+type PodInterface struct {
+	Driver    string `json:"driver"`
+	SwIfIndex uint32 `json:"swIfIndex"`
+	Tag       string `json:"tag,omitempty"`
+	// VppName is the name of the interface in VPP, when checked
+	VppName string `json:"vppName,omitempty"`
+}
+
+type PodRoute struct {
+	Dst       string `json:"dst"`
+	SwIfIndex uint32 `json:"swIfIndex"`
+	// Table is unset for secondary networks, whose VRFs are not in the state
+	Table *uint32 `json:"table,omitempty"`
+}
+
+type PodVRF struct {
+	Family string `json:"family"`
+	Kind   string `json:"kind"`
+	ID     uint32 `json:"id"`
+	Tag    string `json:"tag"`
+}
+
+type PodHostPort struct {
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"hostIP"`
+	HostPort      uint16 `json:"hostPort"`
+	ContainerPort uint16 `json:"containerPort"`
+	EntryID       uint32 `json:"entryID"`
+}
+
+type PodPblClient struct {
+	Index      uint32   `json:"index"`
+	Addr       string   `json:"addr"`
+	Table      uint32   `json:"table"`
+	PortRanges []string `json:"portRanges"`
+}
+
+// PodReport is what debug-state knows about a pod interface
+type PodReport struct {
+	Namespace    string         `json:"namespace"`
+	Pod          string         `json:"pod"`
+	Interface    string         `json:"interface"`
+	Netns        string         `json:"netns"`
+	Network      string         `json:"network,omitempty"`
+	ContainerIPs []string       `json:"containerIPs"`
+	Interfaces   []PodInterface `json:"interfaces"`
+	Routes       []PodRoute     `json:"routes"`
+	VRFs         []PodVRF       `json:"vrfs"`
+	HostPorts    []PodHostPort  `json:"hostPorts,omitempty"`
+	PblClients   []PodPblClient `json:"pblClients,omitempty"`
+	// Issues are the differences with VPP, when checked
+	Issues []string `json:"issues,omitempty"`
+
+	spec *storage.LocalPodSpec
+}
+
+func (r *PodReport) Name() string {
+	return fmt.Sprintf("%s/%s %s", r.Namespace, r.Pod, r.Interface)
+}
+
+func (r *PodReport) addIssue(format string, args ...interface{}) {
+	r.Issues = append(r.Issues, fmt.Sprintf(format, args...))
+}
+
+func isValidID(id uint32) bool {
+	return id != 0 && id != types.InvalidID
+}
+
+// getSwIfIndexForType mirrors LocalPodSpec.GetParamsForIfType without
+// depending on the agent configuration
+func getSwIfIndexForType(spec *storage.LocalPodSpec, ifType storage.VppInterfaceType) uint32 {
+	switch ifType {
+	case storage.VppIfTypeTunTap:
+		return spec.TunTapSwIfIndex
+	case storage.VppIfTypeMemif:
+		return spec.MemifSwIfIndex
+	case storage.VppIfTypeVhostUser:
+		return spec.VhostUserSwIfIndex
+	default:
+		return types.InvalidID
+	}
+}
+
+// getPodRoutes returns the routes to the container addresses added by the
+// agent, see cni.RoutePodInterface
+func getPodRoutes(spec *storage.LocalPodSpec) []PodRoute {
+	routes := make([]PodRoute, 0)
+	if spec.EnableVCL {
+		return routes
+	}
+	swIfIndex := getSwIfIndexForType(spec, spec.DefaultIfType)
+	if swIfIndex == types.InvalidID {
+		return routes
+	}
+	inPodVrf := getSwIfIndexForType(spec, spec.PortFilteredIfType) != types.InvalidID
+	for _, containerIP := range spec.GetContainerIps() {
+		route := PodRoute{Dst: containerIP.String(), SwIfIndex: swIfIndex}
+		if spec.NetworkName == "" {
+			table := uint32(0)
+			if inPodVrf {
+				table = spec.GetVrfId(vpplink.IpFamilyFromIPNet(containerIP))
+			}
+			route.Table = &table
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func getPodVRFs(spec *storage.LocalPodSpec) []PodVRF {
+	vrfs := make([]PodVRF, 0)
+	for _, ipFamily := range vpplink.IpFamilies {
+		if id := spec.GetVrfId(ipFamily); isValidID(id) {
+			vrfs = append(vrfs, PodVRF{Family: ipFamily.Str, Kind: "pod", ID: id, Tag: spec.GetVrfTag(ipFamily, "")})
+		}
+		if id := spec.GetRPFVrfId(ipFamily); isValidID(id) {
+			vrfs = append(vrfs, PodVRF{Family: ipFamily.Str, Kind: "rpf", ID: id, Tag: spec.GetVrfTag(ipFamily, "RPF")})
+		}
+	}
+	return vrfs
+}
+
+func getPodHostPorts(spec *storage.LocalPodSpec) []PodHostPort {
+	hostPorts := make([]PodHostPort, 0, len(spec.HostPorts))
+	for _, hostPort := range spec.HostPorts {
+		entries := hostPort.GetEntries()
+		if len(entries) == 0 {
+			/* Not bound on any node address */
+			entries = append(entries, storage.HostPortEntry{HostIP: hostPort.HostIP, EntryID: types.InvalidID})
+		}
+		for _, entry := range entries {
+			hostIP := hostPort.GetHostIPString()
+			if entry.HostIP != nil {
+				hostIP = entry.HostIP.String()
+			}
+			hostPorts = append(hostPorts, PodHostPort{
+				Protocol:      hostPort.Protocol.String(),
+				HostIP:        hostIP,
+				HostPort:      hostPort.HostPort,
+				ContainerPort: hostPort.ContainerPort,
+				EntryID:       entry.EntryID,
+			})
+		}
+	}
+	return hostPorts
+}
+
+// getPodPblClients returns the PBL clients of the pod, one per container
+// address, see cni.RoutePblPortsPodInterface
+func getPodPblClients(spec *storage.LocalPodSpec) []PodPblClient {
+	portRanges := make([]string, 0, len(spec.IfPortConfigs))
+	for _, pc := range spec.IfPortConfigs {
+		portRanges = append(portRanges, pc.String())
+	}
+	clients := make([]PodPblClient, 0, len(spec.PblIndexes))
+	containerIps := spec.GetContainerIps()
+	for i, index := range spec.PblIndexes {
+		client := PodPblClient{Index: index, PortRanges: portRanges}
+		if i < len(containerIps) {
+			client.Addr = containerIps[i].IP.String()
+			client.Table = spec.GetVrfId(vpplink.IpFamilyFromIPNet(containerIps[i]))
+			if spec.EnableVCL {
+				client.Table = common.PuntTableId
+			}
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func NewPodReport(spec *storage.LocalPodSpec) *PodReport {
+	namespace, pod, _ := strings.Cut(spec.WorkloadID, "/")
+	report := &PodReport{
+		Namespace:    namespace,
+		Pod:          pod,
+		Interface:    spec.InterfaceName,
+		Netns:        spec.NetnsName,
+		Network:      spec.NetworkName,
+		ContainerIPs: make([]string, 0, len(spec.ContainerIps)),
+		Interfaces:   make([]PodInterface, 0),
+		Routes:       getPodRoutes(spec),
+		VRFs:         getPodVRFs(spec),
+		HostPorts:    getPodHostPorts(spec),
+		PblClients:   getPodPblClients(spec),
+		spec:         spec,
+	}
+	for _, containerIP := range spec.ContainerIps {
+		report.ContainerIPs = append(report.ContainerIPs, containerIP.IP.String())
+	}
+	for _, intf := range []PodInterface{
+		{Driver: tunDriverName, SwIfIndex: spec.TunTapSwIfIndex, Tag: spec.GetInterfaceTag(tunDriverName)},
+		{Driver: memifDriverName, SwIfIndex: spec.MemifSwIfIndex, Tag: spec.GetInterfaceTag(memifDriverName)},
+		{Driver: vhostUserDriverName, SwIfIndex: spec.VhostUserSwIfIndex, Tag: spec.GetInterfaceTag(vhostUserDriverName)},
+		{Driver: loopbackDriverName, SwIfIndex: spec.LoopbackSwIfIndex},
+	} {
+		if intf.SwIfIndex != types.InvalidID {
+			report.Interfaces = append(report.Interfaces, intf)
+		}
+	}
+	return report
+}
+
+// PodFilter selects the pods to report
+type PodFilter struct {
+	Namespace string
+	Pod       string
+	Netns     string
+}
+
+func (f *PodFilter) Matches(report *PodReport) bool {
+	if f.Namespace != "" && f.Namespace != report.Namespace {
+		return false
+	}
+	if f.Pod != "" && f.Pod != report.Pod {
+		return false
+	}
+	if f.Netns != "" && f.Netns != report.Netns && f.Netns != netnsBaseName(report.Netns) {
+		return false
+	}
+	return true
+}
+
+func netnsBaseName(netns string) string {
+	return netns[strings.LastIndex(netns, "/")+1:]
+}
+
+func formatTable(table *uint32) string {
+	if table == nil {
+		return "-"
+	}
+	return fmt.Sprint(*table)
+}
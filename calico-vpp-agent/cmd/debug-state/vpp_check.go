@@ -0,0 +1,174 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// vppState is the part of the VPP state the pods are checked against
+type vppState struct {
+	vpp        *vpplink.VppLink
+	vrfs       map[string]types.VRF
+	routes     map[string][]types.Route
+	hostPorts  map[uint32]*types.CnatTranslateEntry
+	pblClients map[uint32]*types.PblClient
+}
+
+func vrfKey(table uint32, isIP6 bool) string {
+	return fmt.Sprintf("%d-%t", table, isIP6)
+}
+
+func newVppState(vpp *vpplink.VppLink) (*vppState, error) {
+	state := &vppState{
+		vpp:        vpp,
+		vrfs:       make(map[string]types.VRF),
+		routes:     make(map[string][]types.Route),
+		pblClients: make(map[uint32]*types.PblClient),
+	}
+	vrfs, err := vpp.ListVRFs()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing VRFs")
+	}
+	for _, vrf := range vrfs {
+		state.vrfs[vrfKey(vrf.VrfID, vrf.IsIP6)] = vrf
+	}
+	state.hostPorts, err = vpp.CnatTranslateDump()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing cnat translations")
+	}
+	pblClients, err := vpp.ListPblClients()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing PBL clients")
+	}
+	for _, client := range pblClients {
+		state.pblClients[client.ID] = client
+	}
+	return state, nil
+}
+
+// getRoutes lists the routes of a table once
+func (s *vppState) getRoutes(table uint32, isIP6 bool) ([]types.Route, error) {
+	key := vrfKey(table, isIP6)
+	if routes, found := s.routes[key]; found {
+		return routes, nil
+	}
+	routes, err := s.vpp.GetRoutes(table, isIP6)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing routes in VRF %d", table)
+	}
+	s.routes[key] = routes
+	return routes, nil
+}
+
+func (s *vppState) checkInterfaces(report *PodReport) {
+	for i := range report.Interfaces {
+		intf := &report.Interfaces[i]
+		details, err := s.vpp.GetInterfaceDetails(intf.SwIfIndex)
+		if err != nil {
+			report.addIssue("%s interface swIfIndex=%d not found", intf.Driver, intf.SwIfIndex)
+			continue
+		}
+		intf.VppName = details.Name
+		if intf.Tag != "" && details.Tag != intf.Tag {
+			report.addIssue("%s interface swIfIndex=%d has tag %q, expected %q", intf.Driver, intf.SwIfIndex, details.Tag, intf.Tag)
+		}
+	}
+}
+
+func (s *vppState) checkVRFs(report *PodReport) {
+	for _, podVrf := range report.VRFs {
+		vrf, found := s.vrfs[vrfKey(podVrf.ID, podVrf.Family == vpplink.IpFamilyV6.Str)]
+		if !found {
+			report.addIssue("%s %s VRF %d not found", podVrf.Family, podVrf.Kind, podVrf.ID)
+		} else if vrf.Name != podVrf.Tag {
+			report.addIssue("%s %s VRF %d is named %q, expected %q", podVrf.Family, podVrf.Kind, podVrf.ID, vrf.Name, podVrf.Tag)
+		}
+	}
+}
+
+func (s *vppState) checkRoutes(report *PodReport) error {
+	for _, route := range report.Routes {
+		if route.Table == nil {
+			continue
+		}
+		dst, _, err := net.ParseCIDR(route.Dst)
+		if err != nil {
+			return err
+		}
+		routes, err := s.getRoutes(*route.Table, vpplink.IsIP6(dst))
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, vppRoute := range routes {
+			if vppRoute.Dst.String() != route.Dst {
+				continue
+			}
+			for _, path := range vppRoute.Paths {
+				found = found || path.SwIfIndex == route.SwIfIndex
+			}
+		}
+		if !found {
+			report.addIssue("route to %s via swIfIndex=%d not found in VRF %d", route.Dst, route.SwIfIndex, *route.Table)
+		}
+	}
+	return nil
+}
+
+func (s *vppState) checkHostPorts(report *PodReport) {
+	for _, hostPort := range report.HostPorts {
+		if hostPort.EntryID == types.InvalidID {
+			continue
+		}
+		entry, found := s.hostPorts[hostPort.EntryID]
+		if !found {
+			report.addIssue("hostport %s %s:%d cnat entry %d not found", hostPort.Protocol, hostPort.HostIP, hostPort.HostPort, hostPort.EntryID)
+		} else if entry.Endpoint.Port != hostPort.HostPort {
+			report.addIssue("hostport %s %s:%d cnat entry %d is %s", hostPort.Protocol, hostPort.HostIP, hostPort.HostPort, hostPort.EntryID, entry.String())
+		}
+	}
+}
+
+func (s *vppState) checkPblClients(report *PodReport) {
+	for _, client := range report.PblClients {
+		vppClient, found := s.pblClients[client.Index]
+		if !found {
+			report.addIssue("PBL client %d for %s not found", client.Index, client.Addr)
+		} else if vppClient.Addr.String() != client.Addr {
+			report.addIssue("PBL client %d is for %s, expected %s", client.Index, vppClient.Addr, client.Addr)
+		}
+	}
+}
+
+// Check flags the parts of the pod state that are not in VPP
+func (s *vppState) Check(report *PodReport) error {
+	s.checkInterfaces(report)
+	s.checkVRFs(report)
+	err := s.checkRoutes(report)
+	if err != nil {
+		return errors.Wrapf(err, "error checking routes of %s", report.Name())
+	}
+	s.checkHostPorts(report)
+	s.checkPblClients(report)
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/agentapi"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+)
+
+// pod-capture asks the agent running on this node to capture the traffic of
+// a pod in a pcap file, e.g.
+// pod-capture -pod default/nginx -count 100 -duration 30s -filter "tcp port 80"
+
+func main() {
+	var socket string
+	var duration time.Duration
+	var count, snapLen uint
+	var jsonOutput bool
+	request := &cni.PodCaptureRequest{}
+	flag.StringVar(&socket, "socket", config.AgentAPISocket, "Agent API socket")
+	flag.StringVar(&request.Pod, "pod", "", "Captured pod, as namespace/name")
+	flag.StringVar(&request.Interface, "intf", "", "Captured pod interface, all of them by default")
+	flag.StringVar(&request.Filter, "filter", "", "Filter, in pcap-filter syntax")
+	flag.UintVar(&count, "count", 1000, "Stop after this many packets")
+	flag.DurationVar(&duration, "duration", 10*time.Second, "Stop after this duration")
+	flag.UintVar(&snapLen, "snaplen", 512, "Bytes captured per packet")
+	flag.BoolVar(&request.CaptureDrop, "drop", false, "Also capture the packets dropped by VPP")
+	flag.StringVar(&request.Output, "o", "", "Name of the pcap file written in "+config.PodCaptureDir)
+	flag.BoolVar(&jsonOutput, "json", false, "Print the reply as JSON")
+	flag.Parse()
+
+	if request.Pod == "" {
+		fmt.Fprintf(os.Stderr, "-pod is required\n")
+		os.Exit(2)
+	}
+	if duration < time.Second || count == 0 || count > 0xffffffff || snapLen == 0 || snapLen > 0xffffffff {
+		fmt.Fprintf(os.Stderr, "invalid count, duration or snaplen\n")
+		os.Exit(2)
+	}
+	request.MaxPackets, request.SnapLen = uint32(count), uint32(snapLen)
+	request.DurationSeconds = uint32(duration / time.Second)
+
+	fmt.Fprintf(os.Stderr, "Capturing %s for %s or %d packets...\n", request.Pod, duration, count)
+	reply, err := agentapi.Call[cni.PodCaptureRequest, cni.PodCaptureReply](socket, cni.PodCapturePath, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Capture failed: %s\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(reply)
+		return
+	}
+	for _, intf := range reply.Interfaces {
+		fmt.Printf("Captured %s %s swIfIndex=%d\n", intf.Interface, intf.Driver, intf.SwIfIndex)
+	}
+	if reply.Filter != "" {
+		fmt.Printf("Filter: %s\n", reply.Filter)
+	}
+	fmt.Printf("Written to %s\n", reply.File)
+}
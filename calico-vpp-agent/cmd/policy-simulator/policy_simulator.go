@@ -0,0 +1,119 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/agentapi"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/policy"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+)
+
+// policy-simulator asks the agent running on this node for the verdict of the
+// policies on a flow, e.g.
+// policy-simulator -src-pod default/client -dst-pod default/server \
+//   -src-ip 10.0.0.1 -dst-ip 10.0.0.2 -proto tcp -dst-port 80
+
+func ruleName(ref *policy.RuleRef) string {
+	if ref == nil {
+		return "-"
+	}
+	name := ref.Profile
+	if ref.Profile == "" {
+		name = ref.Tier + "/" + ref.Policy
+	}
+	if ref.RuleID != "" {
+		name += " rule " + ref.RuleID
+	}
+	return name
+}
+
+func printReply(reply *policy.SimulationReply) {
+	fmt.Printf("Flow: %s\n\n", reply.Flow)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, endpoint := range reply.Endpoints {
+		fmt.Fprintf(w, "%s (%s)\n", endpoint.Endpoint, endpoint.Direction)
+		fmt.Fprintf(w, "  TIER\tPOLICY\tRULE\tACTION\n")
+		for _, p := range endpoint.Policies {
+			tier, name := p.Tier, p.Policy
+			if p.Profile != "" {
+				tier, name = "(profile)", p.Profile
+			}
+			rule := p.RuleID
+			if rule == "" {
+				rule = "-"
+			}
+			action := p.Action
+			if p.AuditAction != "" {
+				action = "audit " + p.AuditAction
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", tier, name, rule, action)
+		}
+		for i := range endpoint.Logged {
+			fmt.Fprintf(w, "  logged by %s\n", ruleName(&endpoint.Logged[i]))
+		}
+		fmt.Fprintf(w, "  => %s (%s)\n\n", endpoint.Action, ruleName(endpoint.Rule))
+	}
+	w.Flush()
+	for _, note := range reply.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+	fmt.Printf("Verdict: %s\n", reply.Verdict)
+}
+
+func main() {
+	var socket string
+	var srcPort, dstPort uint
+	var jsonOutput bool
+	request := &policy.SimulationRequest{}
+	flag.StringVar(&socket, "socket", config.AgentAPISocket, "Agent API socket")
+	flag.StringVar(&request.SrcPod, "src-pod", "", "Source pod, as namespace/name")
+	flag.StringVar(&request.SrcInterface, "src-intf", "", "Source pod interface, when it has several")
+	flag.StringVar(&request.DstPod, "dst-pod", "", "Destination pod, as namespace/name")
+	flag.StringVar(&request.DstInterface, "dst-intf", "", "Destination pod interface, when it has several")
+	flag.StringVar(&request.Network, "network", "", "Network of the pod interfaces, empty for the default network")
+	flag.StringVar(&request.SrcIP, "src-ip", "", "Source address")
+	flag.StringVar(&request.DstIP, "dst-ip", "", "Destination address")
+	flag.StringVar(&request.Proto, "proto", "tcp", "Protocol: tcp, udp, sctp, icmp or icmp6")
+	flag.UintVar(&srcPort, "src-port", 0, "Source port, or ICMP type")
+	flag.UintVar(&dstPort, "dst-port", 0, "Destination port, or ICMP code")
+	flag.BoolVar(&jsonOutput, "json", false, "Print the reply as JSON")
+	flag.Parse()
+
+	if srcPort > 0xffff || dstPort > 0xffff {
+		fmt.Fprintf(os.Stderr, "invalid port\n")
+		os.Exit(2)
+	}
+	request.SrcPort, request.DstPort = uint16(srcPort), uint16(dstPort)
+
+	reply, err := agentapi.Call[policy.SimulationRequest, policy.SimulationReply](socket, policy.PolicySimulationPath, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Policy simulation failed: %s\n", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(reply)
+		return
+	}
+	printReply(reply)
+}
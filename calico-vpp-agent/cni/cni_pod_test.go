@@ -149,6 +149,199 @@ var _ = Describe("Pod-related functionality of CNI", func() {
 				})
 			})
 
+			Context("With interface spec annotation updates", func() {
+				It("should update the rx mode in place and reject queue changes", func() {
+					const (
+						ipAddress     = "1.2.3.48"
+						interfaceName = "newInterface"
+					)
+
+					By("Getting Pod mock container's PID")
+					containerPidOutput, err := exec.Command("docker", "inspect", "-f", "{{.State.Pid}}",
+						PodMockContainerName).Output()
+					Expect(err).Should(BeNil(), "Failed to get pod mock container's PID string")
+					containerPidStr := strings.ReplaceAll(string(containerPidOutput), "\n", "")
+
+					By("Adding pod using CNI server")
+					ifSpecAnnotation := cni.VppAnnotationPrefix + cni.IfSpecAnnotation
+					newPod := &cniproto.AddRequest{
+						InterfaceName: interfaceName,
+						Netns:         fmt.Sprintf("/proc/%s/ns/net", containerPidStr), // expecting mount of "/proc" from host
+						ContainerIps:  []*cniproto.IPConfig{{Address: ipAddress + "/24"}},
+						Workload: &cniproto.WorkloadIDs{
+							Namespace: "default",
+							Pod:       "updated",
+							Annotations: map[string]string{
+								ifSpecAnnotation: `{"newInterface": {"rx": 1, "tx": 1, "rxMode": "interrupt"}}`,
+							},
+						},
+					}
+					common.VppManagerInfo = &config.VppManagerInfo{}
+					config.GetCalicoVppInterfaces().DefaultPodIfSpec = &config.InterfaceSpec{}
+					config.GetCalicoVppInterfaces().MaxPodIfSpec = &config.InterfaceSpec{NumRxQueues: 4, NumTxQueues: 4, RxQueueSize: 1024, TxQueueSize: 1024}
+					err = config.LoadConfigSilent(log)
+					if err != nil {
+						log.Error(err)
+					}
+					reply, err := cniServer.Add(context.Background(), newPod)
+					Expect(err).ToNot(HaveOccurred(), "Pod addition failed")
+					Expect(reply.Successful).To(BeTrue(),
+						fmt.Sprintf("Pod addition failed due to: %s", reply.ErrorMessage))
+
+					By("Changing the rx mode")
+					err = cniServer.UpdatePodInterfaceSpecs("default/updated", map[string]string{
+						ifSpecAnnotation: `{"newInterface": {"rx": 1, "tx": 1, "rxMode": "polling"}}`,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					By("Changing the number of queues")
+					err = cniServer.UpdatePodInterfaceSpecs("default/updated", map[string]string{
+						ifSpecAnnotation: `{"newInterface": {"rx": 2, "tx": 1, "rxMode": "polling"}}`,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("requires recreating the pod"))
+
+					By("Removing the annotation")
+					err = cniServer.UpdatePodInterfaceSpecs("default/updated", map[string]string{})
+					Expect(err).ToNot(HaveOccurred(), "the default specs only change the rx mode")
+
+					By("Exceeding the maximum spec")
+					err = cniServer.UpdatePodInterfaceSpecs("default/updated", map[string]string{
+						ifSpecAnnotation: `{"newInterface": {"rx": 8, "tx": 1}}`,
+					})
+					Expect(err).To(HaveOccurred())
+				})
+			})
+
+			Context("With pods deleted while the agent was down", func() {
+				It("should delete the orphan pods and pod interfaces", func() {
+					const (
+						ipAddress     = "1.2.3.48"
+						interfaceName = "newInterface"
+					)
+
+					By("Getting Pod mock container's PID")
+					containerPidOutput, err := exec.Command("docker", "inspect", "-f", "{{.State.Pid}}",
+						PodMockContainerName).Output()
+					Expect(err).Should(BeNil(), "Failed to get pod mock container's PID string")
+					containerPidStr := strings.ReplaceAll(string(containerPidOutput), "\n", "")
+
+					By("Adding pod using CNI server")
+					newPod := &cniproto.AddRequest{
+						InterfaceName: interfaceName,
+						Netns:         fmt.Sprintf("/proc/%s/ns/net", containerPidStr), // expecting mount of "/proc" from host
+						ContainerIps:  []*cniproto.IPConfig{{Address: ipAddress + "/24"}},
+						Workload: &cniproto.WorkloadIDs{
+							Orchestrator: "k8s",
+							Namespace:    "default",
+							Pod:          "orphan",
+						},
+					}
+					common.VppManagerInfo = &config.VppManagerInfo{}
+					config.GetCalicoVppInterfaces().DefaultPodIfSpec = &config.InterfaceSpec{}
+					err = config.LoadConfigSilent(log)
+					if err != nil {
+						log.Error(err)
+					}
+					reply, err := cniServer.Add(context.Background(), newPod)
+					Expect(err).ToNot(HaveOccurred(), "Pod addition failed")
+					Expect(reply.Successful).To(BeTrue(),
+						fmt.Sprintf("Pod addition failed due to: %s", reply.ErrorMessage))
+
+					By("Creating a pod interface missing from the state")
+					orphanSwIfIndex, err := vpp.CreateTapV2(&types.TapV2{Tag: "AAAAAAAA-eth0-orphan", Flags: types.TapFlagTun})
+					Expect(err).ToNot(HaveOccurred())
+
+					By("Garbage collecting with the pod running")
+					podLister := &mocks.PodListerStub{Pods: map[string]bool{"default/orphan": true}}
+					cniServer.SetPodLister(podLister)
+					cniServer.GCOrphanPods()
+					ifSwIfIndex, err := vpp.SearchInterfaceWithTag(
+						test.InterfaceTagForLocalTunTunnel(newPod.InterfaceName, newPod.Netns))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ifSwIfIndex).ToNot(Equal(vpplink.INVALID_SW_IF_INDEX), "tun interface should be kept")
+					_, err = vpp.GetInterfaceDetails(orphanSwIfIndex)
+					Expect(err).To(HaveOccurred(), "orphan interface should be deleted")
+
+					By("Garbage collecting once the pod is deleted")
+					podLister.Pods = map[string]bool{}
+					cniServer.GCOrphanPods()
+					ifSwIfIndex, err = vpp.SearchInterfaceWithTag(
+						test.InterfaceTagForLocalTunTunnel(newPod.InterfaceName, newPod.Netns))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ifSwIfIndex).To(Equal(vpplink.INVALID_SW_IF_INDEX), "tun interface should be deleted")
+
+					By("Garbage collecting while the pod is added again")
+					podLister.OnList = func() {
+						reply, err := cniServer.Add(context.Background(), newPod)
+						Expect(err).ToNot(HaveOccurred(), "Pod addition failed")
+						Expect(reply.Successful).To(BeTrue(),
+							fmt.Sprintf("Pod addition failed due to: %s", reply.ErrorMessage))
+					}
+					cniServer.GCOrphanPods()
+					ifSwIfIndex, err = vpp.SearchInterfaceWithTag(
+						test.InterfaceTagForLocalTunTunnel(newPod.InterfaceName, newPod.Netns))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ifSwIfIndex).ToNot(Equal(vpplink.INVALID_SW_IF_INDEX), "tun interface added during the list should be kept")
+				})
+			})
+
+			Context("With bandwidth annotations", func() {
+				It("should reject invalid bandwidths and police the TUN interface", func() {
+					const (
+						ipAddress     = "1.2.3.45"
+						interfaceName = "newInterface"
+					)
+
+					By("Getting Pod mock container's PID")
+					containerPidOutput, err := exec.Command("docker", "inspect", "-f", "{{.State.Pid}}",
+						PodMockContainerName).Output()
+					Expect(err).Should(BeNil(), "Failed to get pod mock container's PID string")
+					containerPidStr := strings.ReplaceAll(string(containerPidOutput), "\n", "")
+
+					newPod := &cniproto.AddRequest{
+						InterfaceName: interfaceName,
+						Netns:         fmt.Sprintf("/proc/%s/ns/net", containerPidStr), // expecting mount of "/proc" from host
+						ContainerIps:  []*cniproto.IPConfig{{Address: ipAddress + "/24"}},
+						Workload: &cniproto.WorkloadIDs{
+							Annotations: map[string]string{
+								cni.IngressBandwidthAnnotation: "10",
+							},
+						},
+					}
+					common.VppManagerInfo = &config.VppManagerInfo{}
+					config.GetCalicoVppInterfaces().DefaultPodIfSpec = &config.InterfaceSpec{}
+					err = config.LoadConfigSilent(log)
+					if err != nil {
+						log.Error(err)
+					}
+
+					By("Adding pod with a bandwidth lower than the minimum")
+					reply, err := cniServer.Add(context.Background(), newPod)
+					Expect(err).ToNot(HaveOccurred(), "Pod addition failed")
+					Expect(reply.Successful).To(BeFalse(), "Pod with an invalid bandwidth should be rejected")
+
+					By("Adding pod with valid ingress and egress bandwidths")
+					newPod.Workload.Annotations[cni.IngressBandwidthAnnotation] = "10M"
+					newPod.Workload.Annotations[cni.EgressBandwidthAnnotation] = "1G"
+					reply, err = cniServer.Add(context.Background(), newPod)
+					Expect(err).ToNot(HaveOccurred(), "Pod addition failed")
+					Expect(reply.Successful).To(BeTrue(),
+						fmt.Sprintf("Pod addition failed due to: %s", reply.ErrorMessage))
+
+					By("Checking existence of interface tunnel at VPP's end")
+					test.AssertTunInterfaceExistence(vpp, newPod)
+
+					By("Deleting the pod")
+					delReply, err := cniServer.Del(context.Background(), &cniproto.DelRequest{
+						InterfaceName: interfaceName,
+						Netns:         newPod.Netns,
+					})
+					Expect(err).ToNot(HaveOccurred(), "Pod deletion failed")
+					Expect(delReply.Successful).To(BeTrue())
+				})
+			})
+
 			Context("With additional memif interface configured", func() {
 				BeforeEach(func() {
 					config.GetCalicoVppFeatureGates().MemifEnabled = &config.True
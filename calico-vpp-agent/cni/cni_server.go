@@ -23,6 +23,7 @@ import (
 	"os"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	calicov3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
@@ -51,13 +52,19 @@ type Server struct {
 	grpcServer *grpc.Server
 
 	podInterfaceMap map[string]storage.LocalPodSpec
-	lock            sync.Mutex /* protects Add/DelVppInterace/RescanState */
-	cniEventChan    chan common.CalicoVppEvent
-
-	memifDriver    *pod_interface.MemifPodInterfaceDriver
-	tuntapDriver   *pod_interface.TunTapPodInterfaceDriver
-	vclDriver      *pod_interface.VclPodInterfaceDriver
-	loopbackDriver *pod_interface.LoopbackPodInterfaceDriver
+	/* podGenerations is the value of podGeneration when each pod was last added,
+	 * so that pods added after listing the pods of the node are not garbage collected */
+	podGenerations map[string]uint64
+	podGeneration  uint64
+	lock           sync.Mutex /* protects Add/DelVppInterace/RescanState */
+	captureLock    sync.Mutex /* VPP runs one pcap capture at a time */
+	cniEventChan   chan common.CalicoVppEvent
+
+	memifDriver     *pod_interface.MemifPodInterfaceDriver
+	tuntapDriver    *pod_interface.TunTapPodInterfaceDriver
+	vclDriver       *pod_interface.VclPodInterfaceDriver
+	loopbackDriver  *pod_interface.LoopbackPodInterfaceDriver
+	vhostUserDriver *pod_interface.VhostUserPodInterfaceDriver
 
 	availableBuffers uint64
 
@@ -66,6 +73,9 @@ type Server struct {
 	networkDefinitions   sync.Map
 	cniMultinetEventChan chan common.CalicoVppEvent
 	nodeBGPSpec          *common.LocalNodeSpec
+
+	podLister        PodLister
+	podEventRecorder PodEventRecorder
 }
 
 func swIfIdxToIfName(idx uint32) string {
@@ -116,8 +126,12 @@ func (s *Server) newLocalPodSpecFromAdd(request *cniproto.AddRequest) (*storage.
 		V4VrfId: vpplink.InvalidID,
 		V6VrfId: vpplink.InvalidID,
 
-		MemifSwIfIndex:  vpplink.InvalidID,
-		TunTapSwIfIndex: vpplink.InvalidID,
+		MemifSwIfIndex:     vpplink.InvalidID,
+		TunTapSwIfIndex:    vpplink.InvalidID,
+		VhostUserSwIfIndex: vpplink.InvalidID,
+
+		IngressPolicerIndex: vpplink.InvalidID,
+		EgressPolicerIndex:  vpplink.InvalidID,
 
 		NetworkName: request.DataplaneOptions["network_name"],
 	}
@@ -137,24 +151,18 @@ func (s *Server) newLocalPodSpecFromAdd(request *cniproto.AddRequest) (*storage.
 	}
 
 	for _, port := range request.Workload.Ports {
-		hostIP := net.ParseIP(port.HostIp)
 		hostPort := uint16(port.HostPort)
-		if hostPort != 0 && hostIP != nil && !hostIP.IsUnspecified() {
-			podSpec.HostPorts = append(podSpec.HostPorts, storage.HostPortBinding{
-				HostPort:      hostPort,
-				HostIP:        hostIP,
-				ContainerPort: uint16(port.Port),
-				Protocol:      getHostEndpointProto(port.Protocol),
-			})
-		} else if hostPort != 0 {
-			// default to node IP
-			podSpec.HostPorts = append(podSpec.HostPorts, storage.HostPortBinding{
-				HostPort:      hostPort,
-				HostIP:        net.ParseIP(s.nodeBGPSpec.IPv4Address.IP.String()),
-				ContainerPort: uint16(port.Port),
-				Protocol:      getHostEndpointProto(port.Protocol),
-			})
+		if hostPort == 0 {
+			continue
 		}
+		// Without hostIP, the port is bound on all the node addresses,
+		// see AddHostPort
+		podSpec.HostPorts = append(podSpec.HostPorts, storage.HostPortBinding{
+			HostPort:      hostPort,
+			HostIP:        net.ParseIP(port.HostIp),
+			ContainerPort: uint16(port.Port),
+			Protocol:      getHostEndpointProto(port.Protocol),
+		})
 	}
 	for _, routeStr := range request.GetContainerRoutes() {
 		_, route, err := net.ParseCIDR(routeStr)
@@ -204,6 +212,14 @@ func (s *Server) newLocalPodSpecFromAdd(request *cniproto.AddRequest) (*storage.
 	if podSpec.DefaultIfType == storage.VppIfTypeUnknown {
 		podSpec.DefaultIfType = storage.VppIfTypeTunTap
 	}
+	if podSpec.DefaultIfType == storage.VppIfTypeVhostUser {
+		if !*config.GetCalicoVppFeatureGates().VhostUserEnabled {
+			return nil, fmt.Errorf("enable vhostUser in config for vhost-user interfaces")
+		}
+		if podSpec.NetworkName != "" || podSpec.EnableVCL || podSpec.EnableMemif {
+			return nil, fmt.Errorf("vhost-user interfaces cannot be used with secondary networks, memif or VCL")
+		}
+	}
 
 	return &podSpec, nil
 }
@@ -252,15 +268,16 @@ func (s *Server) Add(ctx context.Context, request *cniproto.AddRequest) (*cnipro
 		}, nil
 	}
 	if len(config.GetCalicoVppInitialConfig().RedirectToHostRules) != 0 && podSpec.NetworkName == "" {
-		err := s.AddRedirectToHostToInterface(podSpec.TunTapSwIfIndex)
+		err := s.AddRedirectToHostToInterface(podSpec.GetPodSwIfIndex())
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	s.podInterfaceMap[podSpec.Key()] = *podSpec
-	cniServerStateFile := fmt.Sprintf("%s%d", config.CniServerStateFile, storage.CniServerStateFileVersion)
-	err = storage.PersistCniServerState(s.podInterfaceMap, cniServerStateFile)
+	s.podGeneration++
+	s.podGenerations[podSpec.Key()] = s.podGeneration
+	err = storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
 	if err != nil {
 		s.log.Errorf("CNI state persist errored %v", err)
 	}
@@ -279,6 +296,7 @@ func (s *Server) fetchNDataThreads() {
 	nDataThreads := common.FetchNDataThreads(s.vpp, s.log)
 	s.memifDriver.NDataThreads = nDataThreads
 	s.tuntapDriver.NDataThreads = nDataThreads
+	s.vhostUserDriver.NDataThreads = nDataThreads
 }
 
 func (s *Server) FetchBufferConfig() {
@@ -301,22 +319,29 @@ func (s *Server) rescanState() {
 		}
 	}
 
-	cniServerStateFile := fmt.Sprintf("%s%d", config.CniServerStateFile, storage.CniServerStateFileVersion)
-	podSpecs, err := storage.LoadCniServerState(cniServerStateFile)
+	podSpecs, err := storage.LoadCniServerState(config.CniServerStateFile)
 	if err != nil {
-		s.log.Errorf("Error getting pods from file %s, removing cache", err)
-		err := os.Remove(cniServerStateFile)
+		s.log.Errorf("Error getting pods from file %s, setting it aside", err)
+		err := storage.SetAsideCniServerState(config.CniServerStateFile)
 		if err != nil {
-			s.log.Errorf("Could not remove %s, %s", cniServerStateFile, err)
+			s.log.Errorf("Could not set aside %s, %s", config.CniServerStateFile, err)
 		}
 	}
 
+	localPods := s.listLocalPods()
+
 	s.log.Infof("RescanState: re-creating all interfaces")
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	for _, podSpec := range podSpecs {
 		/* copy podSpec as a pointer to it will be sent over the event chan */
 		podSpecCopy := podSpec.Copy()
+		if reason := getOrphanReason(&podSpecCopy, localPods); reason != "" {
+			/* Deleted while the agent was down, its VPP objects may still be there */
+			s.log.Infof("pod(gc) deleting orphan spec=%s: %s", podSpecCopy.String(), reason)
+			s.GCVppInterface(&podSpecCopy)
+			continue
+		}
 		_, err := s.AddVppInterface(&podSpecCopy, false /* doHostSideConf */)
 		switch err.(type) {
 		case PodNSNotFoundErr:
@@ -328,12 +353,28 @@ func (s *Server) rescanState() {
 			s.log.Errorf("Interface add failed %s : %v", podSpecCopy.String(), err)
 		}
 		if len(config.GetCalicoVppInitialConfig().RedirectToHostRules) != 0 && podSpecCopy.NetworkName == "" {
-			err := s.AddRedirectToHostToInterface(podSpecCopy.TunTapSwIfIndex)
+			err := s.AddRedirectToHostToInterface(podSpecCopy.GetPodSwIfIndex())
 			if err != nil {
 				s.log.Error(err)
 			}
 		}
 	}
+	swept, err := s.sweepPodInterfaces()
+	if err != nil {
+		s.log.WithError(err).Warn("pod(gc) error sweeping pod interfaces")
+	} else if swept != 0 {
+		s.log.Infof("pod(gc) deleted %d orphan interfaces", swept)
+	}
+	/* Persist the state in the current format, so that files of older agents can go */
+	err = storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
+	if err != nil {
+		s.log.Errorf("CNI state persist errored %v", err)
+		return
+	}
+	err = storage.RemoveLegacyCniServerState(config.CniServerStateFile)
+	if err != nil {
+		s.log.Errorf("Could not remove legacy CNI state %v", err)
+	}
 }
 
 func (s *Server) DelRedirectToHostOnInterface(swIfIndex uint32) error {
@@ -381,7 +422,8 @@ func (s *Server) Del(ctx context.Context, request *cniproto.DelRequest) (*cnipro
 	}
 
 	delete(s.podInterfaceMap, initialSpec.Key())
-	err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile+fmt.Sprint(storage.CniServerStateFileVersion))
+	delete(s.podGenerations, initialSpec.Key())
+	err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
 	if err != nil {
 		s.log.Errorf("CNI state persist errored %v", err)
 	}
@@ -402,10 +444,12 @@ func NewCNIServer(vpp *vpplink.VppLink, policyServerIpam common.PolicyServerIpam
 
 		grpcServer:      grpc.NewServer(),
 		podInterfaceMap: make(map[string]storage.LocalPodSpec),
+		podGenerations:  make(map[string]uint64),
 		tuntapDriver:    pod_interface.NewTunTapPodInterfaceDriver(vpp, log),
 		memifDriver:     pod_interface.NewMemifPodInterfaceDriver(vpp, log),
 		vclDriver:       pod_interface.NewVclPodInterfaceDriver(vpp, log),
 		loopbackDriver:  pod_interface.NewLoopbackPodInterfaceDriver(vpp, log),
+		vhostUserDriver: pod_interface.NewVhostUserPodInterfaceDriver(vpp, log),
 
 		cniMultinetEventChan: make(chan common.CalicoVppEvent, common.ChanSize),
 	}
@@ -413,6 +457,7 @@ func NewCNIServer(vpp *vpplink.VppLink, policyServerIpam common.PolicyServerIpam
 	reg.ExpectEvents(
 		common.FelixConfChanged,
 		common.IpamConfChanged,
+		common.PodAnnotationsChanged,
 	)
 	regM := common.RegisterHandler(server.cniMultinetEventChan, "CNI server Multinet events")
 	regM.ExpectEvents(
@@ -423,11 +468,20 @@ func NewCNIServer(vpp *vpplink.VppLink, policyServerIpam common.PolicyServerIpam
 	return server
 }
 func (s *Server) cniServerEventLoop(t *tomb.Tomb) error {
+	/* A nil channel never fires, when the periodic GC is disabled */
+	var podGCTicks <-chan time.Time
+	if interval := *config.GetCalicoVppInitialConfig().PodGCInterval; interval > 0 {
+		podGCTicker := time.NewTicker(interval)
+		defer podGCTicker.Stop()
+		podGCTicks = podGCTicker.C
+	}
 forloop:
 	for {
 		select {
 		case <-t.Dying():
 			break forloop
+		case <-podGCTicks:
+			s.GCOrphanPods()
 		case evt := <-s.cniEventChan:
 			switch evt.Type {
 			case common.FelixConfChanged:
@@ -460,7 +514,7 @@ forloop:
 						podSpec.NeedsSnat = podSpec.NeedsSnat || s.policyServerIpam.IPNetNeedsSNAT(containerIP)
 					}
 					if NeededSnat != podSpec.NeedsSnat {
-						for _, swIfIndex := range []uint32{podSpec.LoopbackSwIfIndex, podSpec.TunTapSwIfIndex, podSpec.MemifSwIfIndex} {
+						for _, swIfIndex := range []uint32{podSpec.LoopbackSwIfIndex, podSpec.TunTapSwIfIndex, podSpec.MemifSwIfIndex, podSpec.VhostUserSwIfIndex} {
 							if swIfIndex != vpplink.InvalidID {
 								s.log.Infof("Enable/Disable interface[%d] SNAT", swIfIndex)
 								for _, ipFamily := range vpplink.IpFamilies {
@@ -476,6 +530,22 @@ forloop:
 				s.lock.Lock()
 				s.tuntapDriver.FelixConfigChanged(nil /* felixConfig */, ipipEncapRefCountDelta, vxlanEncapRefCountDelta, s.podInterfaceMap)
 				s.lock.Unlock()
+			case common.PodAnnotationsChanged:
+				if podAnnotations, _ := evt.New.(*watchers.PodAnnotations); podAnnotations != nil {
+					s.lock.Lock()
+					err := s.UpdatePodInterfaceSpecs(podAnnotations.WorkloadID, podAnnotations.Annotations)
+					s.lock.Unlock()
+					if err != nil {
+						s.log.Error(err)
+						if s.podEventRecorder != nil {
+							err = s.podEventRecorder.RecordPodWarning(podAnnotations.WorkloadID, podAnnotations.UID,
+								InterfaceSpecUpdateFailedReason, err.Error())
+							if err != nil {
+								s.log.Warn(err)
+							}
+						}
+					}
+				}
 			}
 		}
 	}
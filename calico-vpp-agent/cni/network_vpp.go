@@ -108,7 +108,8 @@ func (s *Server) removeConflictingContainers(newAddresses []storage.LocalIP, net
 		s.log.Infof("Deleting conflicting podSpec=%s", podSpec.Key())
 		s.DelVppInterface(&podSpec)
 		delete(s.podInterfaceMap, podSpec.Key())
-		err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile+fmt.Sprint(storage.CniServerStateFileVersion))
+		delete(s.podGenerations, podSpec.Key())
+		err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
 		if err != nil {
 			s.log.Errorf("CNI state persist errored %v", err)
 		}
@@ -141,7 +142,7 @@ func (s *Server) AddVppInterface(podSpec *storage.LocalPodSpec, doHostSideConf b
 	 */
 	if s.findPodVRFs(podSpec) {
 		s.log.Infof("VRF already exists in VPP podSpec=%s", podSpec.Key())
-		return podSpec.TunTapSwIfIndex, nil
+		return podSpec.GetPodSwIfIndex(), nil
 	}
 
 	/**
@@ -174,7 +175,13 @@ func (s *Server) AddVppInterface(podSpec *storage.LocalPodSpec, doHostSideConf b
 		goto err
 	}
 
-	if podSpec.NetworkName == "" || !podSpec.EnableMemif { // The only case where tun is not created is when we create memif interface in non main network
+	if podSpec.DefaultIfType == storage.VppIfTypeVhostUser {
+		s.log.Infof("pod(add) vhost-user")
+		err = s.vhostUserDriver.CreateInterface(podSpec, stack)
+		if err != nil {
+			goto err
+		}
+	} else if podSpec.NetworkName == "" || !podSpec.EnableMemif { // The only case where tun is not created is when we create memif interface in non main network
 		s.log.Infof("pod(add) tuntap")
 		err = s.tuntapDriver.CreateInterface(podSpec, stack, doHostSideConf)
 		if err != nil {
@@ -198,6 +205,12 @@ func (s *Server) AddVppInterface(podSpec *storage.LocalPodSpec, doHostSideConf b
 		}
 	}
 
+	s.log.Infof("pod(add) policers")
+	err = s.AddPodPolicers(podSpec, stack)
+	if err != nil {
+		goto err
+	}
+
 	/* Routes */
 	if podSpec.EnableVCL {
 		s.log.Infof("pod(add) Punt routes")
@@ -278,7 +291,7 @@ func (s *Server) AddVppInterface(podSpec *storage.LocalPodSpec, doHostSideConf b
 		s.log.Errorf("failed to activate rpf strict on interface : %s", err)
 		goto err
 	}
-	return podSpec.TunTapSwIfIndex, err
+	return podSpec.GetPodSwIfIndex(), err
 
 err:
 	s.log.Errorf("Error, try a cleanup %+v", err)
@@ -290,7 +303,7 @@ err:
 // CleanUpVPPNamespace deletes the devices in the network namespace.
 func (s *Server) DelVppInterface(podSpec *storage.LocalPodSpec) {
 	if len(config.GetCalicoVppInitialConfig().RedirectToHostRules) != 0 && podSpec.NetworkName == "" {
-		err := s.DelRedirectToHostOnInterface(podSpec.TunTapSwIfIndex)
+		err := s.DelRedirectToHostOnInterface(podSpec.GetPodSwIfIndex())
 		if err != nil {
 			s.log.Error(err)
 		}
@@ -300,7 +313,28 @@ func (s *Server) DelVppInterface(podSpec *storage.LocalPodSpec) {
 		s.log.Infof("pod(del) netns '%s' doesn't exist, skipping", podSpec.NetnsName)
 		return
 	}
+	s.delVppObjects(podSpec)
+}
+
+// GCVppInterface deletes the VPP objects of a stale pod. Unlike DelVppInterface,
+// it also deletes them when the netns of the pod does not exist anymore.
+func (s *Server) GCVppInterface(podSpec *storage.LocalPodSpec) {
+	err := ns.IsNSorErr(podSpec.NetnsName)
+	if err == nil {
+		s.DelVppInterface(podSpec)
+		return
+	}
+	s.log.Infof("pod(gc) netns '%s' doesn't exist, deleting VPP objects only", podSpec.NetnsName)
+	if len(config.GetCalicoVppInitialConfig().RedirectToHostRules) != 0 && podSpec.NetworkName == "" {
+		err := s.DelRedirectToHostOnInterface(podSpec.GetPodSwIfIndex())
+		if err != nil {
+			s.log.Error(err)
+		}
+	}
+	s.delVppObjects(podSpec)
+}
 
+func (s *Server) delVppObjects(podSpec *storage.LocalPodSpec) {
 	/* At least one VRF does not exist in VPP, still try removing */
 	if !s.findPodVRFs(podSpec) {
 		s.log.Warnf("pod(del) VRF for netns '%s' doesn't exist, skipping", podSpec.NetnsName)
@@ -362,6 +396,9 @@ func (s *Server) DelVppInterface(podSpec *storage.LocalPodSpec) {
 	s.log.Infof("pod(del) RPF VRF")
 	s.DeactivateStrictRPF(podSpec)
 
+	s.log.Infof("pod(del) policers")
+	s.DelPodPolicers(podSpec)
+
 	/* Interfaces */
 	if podSpec.EnableVCL && *config.GetCalicoVppFeatureGates().VCLEnabled {
 		s.log.Infof("pod(del) VCL")
@@ -371,6 +408,10 @@ func (s *Server) DelVppInterface(podSpec *storage.LocalPodSpec) {
 		s.log.Infof("pod(del) memif")
 		s.memifDriver.DeleteInterface(podSpec)
 	}
+	if podSpec.DefaultIfType == storage.VppIfTypeVhostUser {
+		s.log.Infof("pod(del) vhost-user")
+		s.vhostUserDriver.DeleteInterface(podSpec)
+	}
 	s.log.Infof("pod(del) tuntap")
 	s.tuntapDriver.DeleteInterface(podSpec)
 	s.log.Infof("pod(del) loopback")
@@ -16,40 +16,110 @@
 package cni
 
 import (
+	"net"
+
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
 )
 
+// getNodeAddresses returns the addresses of the node in a family: its BGP
+// address, and the other global addresses of the uplinks
+func (s *Server) getNodeAddresses(isIP6 bool) []net.IP {
+	addresses := make([]net.IP, 0)
+	addAddress := func(addr net.IP) {
+		if addr == nil || vpplink.IsIP6(addr) != isIP6 || !addr.IsGlobalUnicast() {
+			return
+		}
+		for _, address := range addresses {
+			if address.Equal(addr) {
+				return
+			}
+		}
+		addresses = append(addresses, addr)
+	}
+	if s.nodeBGPSpec != nil {
+		nodeIP4, nodeIP6 := common.GetBGPSpecAddresses(s.nodeBGPSpec)
+		if isIP6 && nodeIP6 != nil {
+			addAddress(*nodeIP6)
+		} else if !isIP6 && nodeIP4 != nil {
+			addAddress(*nodeIP4)
+		}
+	}
+	if common.VppManagerInfo != nil {
+		for _, uplinkStatus := range common.VppManagerInfo.UplinkStatuses {
+			uplinkAddresses, err := s.vpp.AddrList(uplinkStatus.SwIfIndex, isIP6)
+			if err != nil {
+				s.log.Warnf("Error listing addresses of uplink %s: %v", uplinkStatus.Name, err)
+				continue
+			}
+			for _, uplinkAddress := range uplinkAddresses {
+				addAddress(uplinkAddress.IPNet.IP)
+			}
+		}
+	}
+	return addresses
+}
+
+// getHostPortAddresses returns the node addresses a hostPort is bound on in a family
+func (s *Server) getHostPortAddresses(hostPort *storage.HostPortBinding, isIP6 bool) []net.IP {
+	if !hostPort.BindsFamily(isIP6) {
+		return nil
+	}
+	if hostPort.HostIP != nil && !hostPort.HostIP.IsUnspecified() {
+		return []net.IP{hostPort.HostIP}
+	}
+	return s.getNodeAddresses(isIP6)
+}
+
+// AddHostPort adds a cnat entry to the pod address of each family, for each
+// node address of the family the hostPort is bound on
 func (s *Server) AddHostPort(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack) error {
-	for idx, hostPort := range podSpec.HostPorts {
-		for _, containerAddr := range podSpec.ContainerIps {
-			if !vpplink.AddrFamilyDiffers(containerAddr.IP, hostPort.HostIP) {
+	for idx := range podSpec.HostPorts {
+		hostPort := &podSpec.HostPorts[idx]
+		hostPort.IP4Entries = make([]storage.HostPortEntry, 0)
+		hostPort.IP6Entries = make([]storage.HostPortEntry, 0)
+		for _, ipFamily := range vpplink.IpFamilies {
+			var containerIP net.IP
+			for _, containerAddr := range podSpec.ContainerIps {
+				if vpplink.IsIP6(containerAddr.IP) == ipFamily.IsIp6 {
+					containerIP = containerAddr.IP
+					break
+				}
+			}
+			if containerIP == nil {
 				continue
 			}
-			entry := &types.CnatTranslateEntry{
-				Endpoint: types.CnatEndpoint{
-					IP:   hostPort.HostIP,
-					Port: hostPort.HostPort,
-				},
-				Backends: []types.CnatEndpointTuple{{
-					DstEndpoint: types.CnatEndpoint{
-						Port: hostPort.ContainerPort,
-						IP:   containerAddr.IP,
-					},
-				}},
-				IsRealIP: true,
-				Proto:    hostPort.Protocol,
-				LbType:   types.DefaultLB,
+			hostIPs := s.getHostPortAddresses(hostPort, ipFamily.IsIp6)
+			if len(hostIPs) == 0 && hostPort.BindsFamily(ipFamily.IsIp6) {
+				s.log.Warnf("pod(add) no %s node address for hostport %d", ipFamily.Str, hostPort.HostPort)
 			}
-			s.log.Infof("pod(add) hostport %s", entry.String())
-			id, err := s.vpp.CnatTranslateAdd(entry)
-			if err != nil {
-				return err
-			} else {
-				stack.Push(s.vpp.CnatTranslateDel, id)
+			for _, hostIP := range hostIPs {
+				entry := &types.CnatTranslateEntry{
+					Endpoint: types.CnatEndpoint{
+						IP:   hostIP,
+						Port: hostPort.HostPort,
+					},
+					Backends: []types.CnatEndpointTuple{{
+						DstEndpoint: types.CnatEndpoint{
+							Port: hostPort.ContainerPort,
+							IP:   containerIP,
+						},
+					}},
+					IsRealIP: true,
+					Proto:    hostPort.Protocol,
+					LbType:   types.DefaultLB,
+				}
+				s.log.Infof("pod(add) hostport %s", entry.String())
+				id, err := s.vpp.CnatTranslateAdd(entry)
+				if err != nil {
+					return err
+				} else {
+					stack.Push(s.vpp.CnatTranslateDel, id)
+				}
+				hostPort.AddEntry(hostIP, id)
 			}
-			podSpec.HostPorts[idx].EntryID = id
 		}
 	}
 	return nil
@@ -59,11 +129,13 @@ func (s *Server) DelHostPort(podSpec *storage.LocalPodSpec) {
 	initialSpec, ok := s.podInterfaceMap[podSpec.Key()]
 	if ok {
 		for _, hostPort := range initialSpec.HostPorts {
-			err := s.vpp.CnatTranslateDel(hostPort.EntryID)
-			if err != nil {
-				s.log.Errorf("(del) Error deleting entry with ID %d: %v", hostPort.EntryID, err)
+			for _, entry := range hostPort.GetEntries() {
+				err := s.vpp.CnatTranslateDel(entry.EntryID)
+				if err != nil {
+					s.log.Errorf("(del) Error deleting entry with ID %d: %v", entry.EntryID, err)
+				}
+				s.log.Infof("pod(del) hostport %s entry=%d", entry.HostIP, entry.EntryID)
 			}
-			s.log.Infof("pod(del) hostport entry=%d", hostPort.EntryID)
 		}
 	} else {
 		s.log.Warnf("Initial spec not found")
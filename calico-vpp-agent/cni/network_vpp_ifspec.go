@@ -0,0 +1,179 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/pod_interface"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+)
+
+// InterfaceSpecUpdateFailedReason is the reason of the events recorded on pods
+// whose interfaces cannot be updated from their annotations
+const InterfaceSpecUpdateFailedReason = "InterfaceSpecUpdateFailed"
+
+// PodEventRecorder records events on the pods of this node
+type PodEventRecorder interface {
+	// RecordPodWarning records a warning event on a pod given as namespace/name
+	RecordPodWarning(workloadID string, uid k8stypes.UID, reason string, message string) error
+}
+
+func (s *Server) SetPodEventRecorder(podEventRecorder PodEventRecorder) {
+	s.podEventRecorder = podEventRecorder
+}
+
+type podInterfaceSpecUpdate struct {
+	driver    *pod_interface.PodInterfaceDriverData
+	swIfIndex uint32
+	oldSpec   config.InterfaceSpec
+	newSpec   config.InterfaceSpec
+}
+
+// getPodInterfaceSpecUpdates returns the specs to apply to the interfaces of the pod
+func (s *Server) getPodInterfaceSpecUpdates(podSpec, newPodSpec *storage.LocalPodSpec) []podInterfaceSpecUpdate {
+	updates := make([]podInterfaceSpecUpdate, 0)
+	if podSpec.TunTapSwIfIndex != vpplink.InvalidID {
+		updates = append(updates, podInterfaceSpecUpdate{
+			driver:    &s.tuntapDriver.PodInterfaceDriverData,
+			swIfIndex: podSpec.TunTapSwIfIndex,
+			oldSpec:   podSpec.IfSpec,
+			newSpec:   newPodSpec.IfSpec,
+		})
+	}
+	if podSpec.MemifSwIfIndex != vpplink.InvalidID {
+		update := podInterfaceSpecUpdate{
+			driver:    &s.memifDriver.PodInterfaceDriverData,
+			swIfIndex: podSpec.MemifSwIfIndex,
+			oldSpec:   podSpec.IfSpec,
+			newSpec:   newPodSpec.IfSpec,
+		}
+		if podSpec.NetworkName == "" {
+			/* PBL memifs get their queues from the PBLMemifSpec, and their
+			 * rx mode from the IfSpec, as in MemifPodInterfaceDriver.CreateInterface */
+			update.oldSpec, update.newSpec = podSpec.PBLMemifSpec, newPodSpec.PBLMemifSpec
+			update.oldSpec.RxMode, update.newSpec.RxMode = podSpec.IfSpec.RxMode, newPodSpec.IfSpec.RxMode
+		}
+		updates = append(updates, update)
+	}
+	if podSpec.VhostUserSwIfIndex != vpplink.InvalidID {
+		/* The queues of vhost-user interfaces are set by the VM, only the rx
+		 * mode applies, as in VhostUserPodInterfaceDriver.CreateInterface */
+		update := podInterfaceSpecUpdate{
+			driver:    &s.vhostUserDriver.PodInterfaceDriverData,
+			swIfIndex: podSpec.VhostUserSwIfIndex,
+			oldSpec:   podSpec.IfSpec,
+			newSpec:   podSpec.IfSpec,
+		}
+		update.newSpec.RxMode = newPodSpec.IfSpec.RxMode
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// getDefaultPodIfSpecs returns the interface specs of a pod without annotations,
+// as set by newLocalPodSpecFromAdd
+func getDefaultPodIfSpecs(podSpec *storage.LocalPodSpec) (ifSpec, pblMemifSpec config.InterfaceSpec) {
+	ifSpec = GetDefaultIfSpec(true /* isL3 */)
+	if podSpec.NetworkName != "" && isMemif(podSpec.InterfaceName) {
+		ifSpec = GetDefaultIfSpec(false /* isL3 */)
+	}
+	return ifSpec, GetDefaultIfSpec(false /* isL3 */)
+}
+
+// updatePodInterfaceSpec applies the interface specs in the annotations to the
+// interfaces of the pod, and returns the updated pod spec. The defaults apply
+// when an annotation was removed. When an interface cannot be updated, the
+// interfaces already updated are reverted and the pod spec is unchanged.
+func (s *Server) updatePodInterfaceSpec(podSpec *storage.LocalPodSpec, annotations map[string]string) (newPodSpec storage.LocalPodSpec, changed bool, err error) {
+	newPodSpec = podSpec.Copy()
+	newPodSpec.IfSpec, newPodSpec.PBLMemifSpec = getDefaultPodIfSpecs(podSpec)
+	for _, annotation := range []string{VppAnnotationPrefix + IfSpecAnnotation, VppAnnotationPrefix + IfSpecPBLAnnotation} {
+		value, found := annotations[annotation]
+		if !found {
+			continue
+		}
+		err = s.ParseIfSpecAnnotation(&newPodSpec, annotation, value)
+		if err != nil {
+			return *podSpec, false, err
+		}
+	}
+	if reflect.DeepEqual(podSpec.IfSpec, newPodSpec.IfSpec) && reflect.DeepEqual(podSpec.PBLMemifSpec, newPodSpec.PBLMemifSpec) {
+		return *podSpec, false, nil
+	}
+
+	updates := s.getPodInterfaceSpecUpdates(podSpec, &newPodSpec)
+	for _, update := range updates {
+		err = update.driver.CheckInterfaceSpecUpdate(update.oldSpec, update.newSpec)
+		if err != nil {
+			return *podSpec, false, err
+		}
+	}
+	s.log.Infof("pod(update) interface specs of %s", podSpec.Key())
+	for i, update := range updates {
+		err = update.driver.UpdateInterfaceSpec(update.swIfIndex, update.oldSpec, update.newSpec)
+		if err != nil {
+			/* The failed update may be partially applied, revert it too */
+			for j := i; j >= 0; j-- {
+				revertErr := updates[j].driver.UpdateInterfaceSpec(updates[j].swIfIndex, updates[j].newSpec, updates[j].oldSpec)
+				if revertErr != nil {
+					s.log.WithError(revertErr).Errorf("pod(update) error reverting interface spec of if[%d]", updates[j].swIfIndex)
+				}
+			}
+			return *podSpec, false, err
+		}
+	}
+	return newPodSpec, true, nil
+}
+
+// UpdatePodInterfaceSpecs applies the vppInterfacesSpec and vppExtraMemifSpec
+// annotations of a running pod to its interfaces. When a change requires
+// recreating one of the interfaces, the interfaces are left unchanged and an
+// error is returned.
+func (s *Server) UpdatePodInterfaceSpecs(workloadID string, annotations map[string]string) error {
+	errs := make([]string, 0)
+	updated := false
+	for key, podSpec := range s.podInterfaceMap {
+		if podSpec.WorkloadID != workloadID {
+			continue
+		}
+		newPodSpec, changed, err := s.updatePodInterfaceSpec(&podSpec, annotations)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", podSpec.InterfaceName, err))
+		}
+		if changed {
+			s.podInterfaceMap[key] = newPodSpec
+			updated = true
+		}
+	}
+	if updated {
+		err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
+		if err != nil {
+			s.log.Errorf("CNI state persist errored %v", err)
+		}
+	}
+	if len(errs) != 0 {
+		return errors.Errorf("cannot update interfaces of pod %s: %s", workloadID, strings.Join(errs, "; "))
+	}
+	return nil
+}
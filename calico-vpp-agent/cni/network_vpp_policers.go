@@ -0,0 +1,119 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// The burst of the policers lasts 100ms at the committed rate, and is at
+// least large enough for a GSO packet
+const (
+	policerBurstDivider = 10
+	minPolicerBurst     = 128 * 1024
+)
+
+// getPolicerForBandwidth returns the policer enforcing a bandwidth limit
+// given in bits per second
+func getPolicerForBandwidth(bandwidth uint64) *types.Policer {
+	policer := &types.Policer{
+		CIR: math.MaxUint32,
+		CB:  bandwidth / 8 / policerBurstDivider,
+	}
+	if bandwidth/1000 < math.MaxUint32 {
+		policer.CIR = uint32(bandwidth / 1000)
+	}
+	if policer.CB < minPolicerBurst {
+		policer.CB = minPolicerBurst
+	}
+	return policer
+}
+
+// getPolicedSwIfIndexes returns the pod interfaces the bandwidth limits apply to
+func getPolicedSwIfIndexes(podSpec *storage.LocalPodSpec) []uint32 {
+	swIfIndexes := make([]uint32, 0)
+	for _, swIfIndex := range []uint32{podSpec.TunTapSwIfIndex, podSpec.MemifSwIfIndex, podSpec.VhostUserSwIfIndex} {
+		if swIfIndex != vpplink.InvalidID {
+			swIfIndexes = append(swIfIndexes, swIfIndex)
+		}
+	}
+	return swIfIndexes
+}
+
+func (s *Server) addPodPolicer(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack, bandwidth uint64, name string,
+	enable func(uint32, uint32) error, disable func(uint32, uint32) error) (uint32, error) {
+	if bandwidth == 0 {
+		return vpplink.InvalidID, nil
+	}
+	policer := getPolicerForBandwidth(bandwidth)
+	policerIndex, err := s.vpp.AddPolicer(podSpec.GetInterfaceTag(name), policer)
+	if err != nil {
+		return vpplink.InvalidID, errors.Wrapf(err, "error creating %s", name)
+	}
+	stack.Push(s.vpp.DelPolicer, policerIndex)
+	s.log.Infof("pod(add) %s %s index=%d", name, policer.String(), policerIndex)
+
+	for _, swIfIndex := range getPolicedSwIfIndexes(podSpec) {
+		err = enable(policerIndex, swIfIndex)
+		if err != nil {
+			return vpplink.InvalidID, errors.Wrapf(err, "error applying %s to swIfIndex=%d", name, swIfIndex)
+		}
+		stack.Push(disable, policerIndex, swIfIndex)
+	}
+	return policerIndex, nil
+}
+
+// AddPodPolicers enforces the bandwidth limits of the pod. Traffic to the pod
+// is sent by VPP on the pod interfaces, so the ingress bandwidth is enforced
+// on their output and the egress bandwidth on their input.
+func (s *Server) AddPodPolicers(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack) (err error) {
+	podSpec.IngressPolicerIndex, err = s.addPodPolicer(podSpec, stack, podSpec.IngressBandwidth, "ingress-policer",
+		s.vpp.EnablePolicerOutput, s.vpp.DisablePolicerOutput)
+	if err != nil {
+		return err
+	}
+	podSpec.EgressPolicerIndex, err = s.addPodPolicer(podSpec, stack, podSpec.EgressBandwidth, "egress-policer",
+		s.vpp.EnablePolicerInput, s.vpp.DisablePolicerInput)
+	return err
+}
+
+func (s *Server) delPodPolicer(podSpec *storage.LocalPodSpec, policerIndex uint32, disable func(uint32, uint32) error) {
+	if policerIndex == vpplink.InvalidID {
+		return
+	}
+	for _, swIfIndex := range getPolicedSwIfIndexes(podSpec) {
+		err := disable(policerIndex, swIfIndex)
+		if err != nil {
+			s.log.Errorf("pod(del) Error removing policer %d from swIfIndex=%d: %v", policerIndex, swIfIndex, err)
+		}
+	}
+	err := s.vpp.DelPolicer(policerIndex)
+	if err != nil {
+		s.log.Errorf("pod(del) Error deleting policer %d: %v", policerIndex, err)
+	}
+	s.log.Infof("pod(del) policer index=%d", policerIndex)
+}
+
+func (s *Server) DelPodPolicers(podSpec *storage.LocalPodSpec) {
+	s.delPodPolicer(podSpec, podSpec.IngressPolicerIndex, s.vpp.DisablePolicerOutput)
+	s.delPodPolicer(podSpec, podSpec.EgressPolicerIndex, s.vpp.DisablePolicerInput)
+}
@@ -16,6 +16,8 @@
 package cni
 
 import (
+	"net"
+
 	"github.com/pkg/errors"
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
@@ -25,33 +27,37 @@ import (
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
 )
 
-func (s *Server) RoutePodInterface(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack, swIfIndex uint32, isL3 bool, inPodVrf bool) error {
-	for _, containerIP := range podSpec.GetContainerIps() {
-		var table uint32
-		if podSpec.NetworkName != "" {
-			idx := 0
-			if vpplink.IsIP6(containerIP.IP) {
-				idx = 1
-			}
-			value, ok := s.networkDefinitions.Load(podSpec.NetworkName)
-			if !ok {
-				s.log.Errorf("network not found %s", podSpec.NetworkName)
-			} else {
-				networkDefinition, ok := value.(*watchers.NetworkDefinition)
-				if !ok || networkDefinition == nil {
-					panic("networkDefinition not of type *watchers.NetworkDefinition")
-				}
-				table = networkDefinition.VRF.Tables[idx]
+// getPodRouteTable returns the VRF of the route to a container address
+func (s *Server) getPodRouteTable(podSpec *storage.LocalPodSpec, containerIP *net.IPNet, inPodVrf bool) (table uint32) {
+	if podSpec.NetworkName != "" {
+		idx := 0
+		if vpplink.IsIP6(containerIP.IP) {
+			idx = 1
+		}
+		value, ok := s.networkDefinitions.Load(podSpec.NetworkName)
+		if !ok {
+			s.log.Errorf("network not found %s", podSpec.NetworkName)
+		} else {
+			networkDefinition, ok := value.(*watchers.NetworkDefinition)
+			if !ok || networkDefinition == nil {
+				panic("networkDefinition not of type *watchers.NetworkDefinition")
 			}
-		} else if inPodVrf {
-			table = podSpec.GetVrfId(vpplink.IpFamilyFromIPNet(containerIP))
+			table = networkDefinition.VRF.Tables[idx]
 		}
+	} else if inPodVrf {
+		table = podSpec.GetVrfId(vpplink.IpFamilyFromIPNet(containerIP))
+	}
+	return table
+}
+
+func (s *Server) RoutePodInterface(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack, swIfIndex uint32, isL3 bool, inPodVrf bool) error {
+	for _, containerIP := range podSpec.GetContainerIps() {
 		route := types.Route{
 			Dst: containerIP,
 			Paths: []types.RoutePath{{
 				SwIfIndex: swIfIndex,
 			}},
-			Table: table,
+			Table: s.getPodRouteTable(podSpec, containerIP, inPodVrf),
 		}
 		s.log.Infof("pod(add) route [podVRF ->MainIF] %s", route.String())
 		err := s.vpp.RouteAdd(&route)
@@ -77,31 +83,12 @@ func (s *Server) RoutePodInterface(podSpec *storage.LocalPodSpec, stack *vpplink
 
 func (s *Server) UnroutePodInterface(podSpec *storage.LocalPodSpec, swIfIndex uint32, inPodVrf bool) {
 	for _, containerIP := range podSpec.GetContainerIps() {
-		var table uint32
-		if podSpec.NetworkName != "" {
-			idx := 0
-			if vpplink.IsIP6(containerIP.IP) {
-				idx = 1
-			}
-			value, ok := s.networkDefinitions.Load(podSpec.NetworkName)
-			if !ok {
-				s.log.Errorf("network not found %s", podSpec.NetworkName)
-			} else {
-				networkDefinition, ok := value.(*watchers.NetworkDefinition)
-				if !ok || networkDefinition == nil {
-					panic("networkDefinition not of type *watchers.NetworkDefinition")
-				}
-				table = networkDefinition.VRF.Tables[idx]
-			}
-		} else if inPodVrf {
-			table = podSpec.GetVrfId(vpplink.IpFamilyFromIPNet(containerIP))
-		}
 		route := types.Route{
 			Dst: containerIP,
 			Paths: []types.RoutePath{{
 				SwIfIndex: swIfIndex,
 			}},
-			Table: table,
+			Table: s.getPodRouteTable(podSpec, containerIP, inPodVrf),
 		}
 		s.log.Infof("pod(del) route [podVRF ->MainIF] %s", route.String())
 		err := s.vpp.RouteDel(&route)
@@ -249,11 +236,11 @@ func (s *Server) ActivateStrictRPF(podSpec *storage.LocalPodSpec, stack *vpplink
 		return errors.Wrapf(err, "failed to add routes for RPF VRF")
 	}
 	s.log.Infof("pod(add) set custom-vrf urpf")
-	err = s.vpp.SetCustomURPF(podSpec.TunTapSwIfIndex, podSpec.V4RPFVrfId)
+	err = s.vpp.SetCustomURPF(podSpec.GetPodSwIfIndex(), podSpec.V4RPFVrfId)
 	if err != nil {
 		return errors.Wrapf(err, "failed to set urpf strict on interface")
 	} else {
-		stack.Push(s.vpp.UnsetURPF, podSpec.TunTapSwIfIndex)
+		stack.Push(s.vpp.UnsetURPF, podSpec.GetPodSwIfIndex())
 	}
 	return nil
 }
@@ -263,7 +250,7 @@ func (s *Server) AddRPFRoutes(podSpec *storage.LocalPodSpec, stack *vpplink.Clea
 		RPFvrfId := podSpec.GetRPFVrfId(vpplink.IpFamilyFromIPNet(containerIP))
 		// Always there (except multinet memif)
 		pathsToPod := []types.RoutePath{{
-			SwIfIndex: podSpec.TunTapSwIfIndex,
+			SwIfIndex: podSpec.GetPodSwIfIndex(),
 			Gw:        containerIP.IP,
 		}}
 		// Add pbl memif case
@@ -320,7 +307,7 @@ func (s *Server) DeactivateStrictRPF(podSpec *storage.LocalPodSpec) {
 		RPFvrfId := podSpec.GetRPFVrfId(vpplink.IpFamilyFromIPNet(containerIP))
 		// Always there (except multinet memif)
 		pathsToPod := []types.RoutePath{{
-			SwIfIndex: podSpec.TunTapSwIfIndex,
+			SwIfIndex: podSpec.GetPodSwIfIndex(),
 			Gw:        containerIP.IP,
 		}}
 		// pbl memif case
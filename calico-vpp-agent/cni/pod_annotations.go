@@ -23,6 +23,7 @@ import (
 
 	"github.com/pkg/errors"
 	cnet "github.com/projectcalico/calico/libcalico-go/lib/net"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
 	"github.com/projectcalico/vpp-dataplane/v3/config"
@@ -38,6 +39,16 @@ const (
 	SpoofAnnotation        string = "AllowedSourcePrefixes"
 	IfSpecAnnotation       string = "InterfacesSpec"
 	IfSpecPBLAnnotation    string = "ExtraMemifSpec"
+	VhostUserAnnotation    string = "VhostUser"
+
+	IngressBandwidthAnnotation string = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  string = "kubernetes.io/egress-bandwidth"
+)
+
+var (
+	// Same bounds as the kubelet
+	minBandwidth = resource.MustParse("1k")
+	maxBandwidth = resource.MustParse("1P")
 )
 
 func (s *Server) ParsePortSpec(value string) (ifPortConfigs *storage.LocalIfPortConfigs, err error) {
@@ -127,6 +138,22 @@ func (s *Server) ParseSpoofAddressAnnotation(value string) ([]cnet.IPNet, error)
 	return allowedSources, nil
 }
 
+// ParseBandwidthAnnotation returns the bandwidth in bits per second of a
+// kubernetes.io/{ingress,egress}-bandwidth annotation e.g. "10M"
+func (s *Server) ParseBandwidthAnnotation(value string) (uint64, error) {
+	bandwidth, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error parsing bandwidth %s", value)
+	}
+	if bandwidth.Cmp(minBandwidth) < 0 {
+		return 0, errors.Errorf("Bandwidth %s is lower than %s", value, minBandwidth.String())
+	}
+	if bandwidth.Cmp(maxBandwidth) > 0 {
+		return 0, errors.Errorf("Bandwidth %s is greater than %s", value, maxBandwidth.String())
+	}
+	return uint64(bandwidth.Value()), nil
+}
+
 func GetDefaultIfSpec(isL3 bool) config.InterfaceSpec {
 	return config.InterfaceSpec{
 		NumRxQueues: config.GetCalicoVppInterfaces().DefaultPodIfSpec.NumRxQueues,
@@ -137,33 +164,72 @@ func GetDefaultIfSpec(isL3 bool) config.InterfaceSpec {
 	}
 }
 
+// ParseIfSpecAnnotation sets the interface specs of the pod from the
+// vppInterfacesSpec or vppExtraMemifSpec annotation
+func (s *Server) ParseIfSpecAnnotation(podSpec *storage.LocalPodSpec, key, value string) error {
+	switch key {
+	case VppAnnotationPrefix + IfSpecAnnotation:
+		var ifSpecs map[string]config.InterfaceSpec
+		err := json.Unmarshal([]byte(value), &ifSpecs)
+		if err != nil {
+			s.log.Warnf("Error parsing key %s %s", key, err)
+		}
+		for _, ifSpec := range ifSpecs {
+			if err := ifSpec.Validate(config.GetCalicoVppInterfaces().MaxPodIfSpec); err != nil {
+				s.log.Error("Pod interface config exceeds max config")
+				return err
+			}
+		}
+		if ethSpec, found := ifSpecs[podSpec.InterfaceName]; found {
+			podSpec.IfSpec = ethSpec
+			isL3 := podSpec.IfSpec.GetIsL3(isMemif(podSpec.InterfaceName))
+			podSpec.IfSpec.IsL3 = &isL3
+		}
+	case VppAnnotationPrefix + IfSpecPBLAnnotation:
+		var ifSpec *config.InterfaceSpec
+		err := json.Unmarshal([]byte(value), &ifSpec)
+		if err != nil || ifSpec == nil {
+			s.log.Warnf("Error parsing key %s %s", key, err)
+			return nil
+		}
+		err = ifSpec.Validate(config.GetCalicoVppInterfaces().MaxPodIfSpec)
+		if err != nil {
+			s.log.Error("PBL Memif interface config exceeds max config")
+			return err
+		}
+		podSpec.PBLMemifSpec = *ifSpec
+		isL3 := podSpec.PBLMemifSpec.GetIsL3(true)
+		podSpec.PBLMemifSpec.IsL3 = &isL3
+	}
+	return nil
+}
+
 func (s *Server) ParsePodAnnotations(podSpec *storage.LocalPodSpec, annotations map[string]string) (err error) {
 	for key, value := range annotations {
 		if key == CalicoAnnotationPrefix+SpoofAnnotation {
 			podSpec.AllowedSpoofingPrefixes = annotations[CalicoAnnotationPrefix+SpoofAnnotation]
 		}
+		switch key {
+		case IngressBandwidthAnnotation:
+			podSpec.IngressBandwidth, err = s.ParseBandwidthAnnotation(value)
+			if err != nil {
+				return err
+			}
+		case EgressBandwidthAnnotation:
+			podSpec.EgressBandwidth, err = s.ParseBandwidthAnnotation(value)
+			if err != nil {
+				return err
+			}
+		}
 		if !strings.HasPrefix(key, VppAnnotationPrefix) {
 			continue
 		}
 		switch key {
-		case VppAnnotationPrefix + IfSpecAnnotation:
-			var ifSpecs map[string]config.InterfaceSpec
-			err = json.Unmarshal([]byte(value), &ifSpecs)
+		case VppAnnotationPrefix + IfSpecAnnotation, VppAnnotationPrefix + IfSpecPBLAnnotation:
+			err = s.ParseIfSpecAnnotation(podSpec, key, value)
 			if err != nil {
-				s.log.Warnf("Error parsing key %s %s", key, err)
-			}
-			for _, ifSpec := range ifSpecs {
-				if err := ifSpec.Validate(config.GetCalicoVppInterfaces().MaxPodIfSpec); err != nil {
-					s.log.Error("Pod interface config exceeds max config")
-					return err
-				}
-			}
-			if ethSpec, found := ifSpecs[podSpec.InterfaceName]; found {
-				podSpec.IfSpec = ethSpec
-				isL3 := podSpec.IfSpec.GetIsL3(isMemif(podSpec.InterfaceName))
-				podSpec.IfSpec.IsL3 = &isL3
+				return err
 			}
-
 		case VppAnnotationPrefix + MemifPortAnnotation:
 			podSpec.EnableMemif = true
 			err = s.ParsePortMappingAnnotation(podSpec, storage.VppIfTypeMemif, value)
@@ -171,22 +237,14 @@ func (s *Server) ParsePodAnnotations(podSpec *storage.LocalPodSpec, annotations
 				return err
 			}
 			err = s.ParseDefaultIfType(podSpec, storage.VppIfTypeTunTap)
-		case VppAnnotationPrefix + IfSpecPBLAnnotation:
-			var ifSpec *config.InterfaceSpec
-			err := json.Unmarshal([]byte(value), &ifSpec)
-			if err != nil {
-				s.log.Warnf("Error parsing key %s %s", key, err)
-			}
-			err = ifSpec.Validate(config.GetCalicoVppInterfaces().MaxPodIfSpec)
-			if err != nil {
-				s.log.Error("PBL Memif interface config exceeds max config")
-				return err
-			}
-			podSpec.PBLMemifSpec = *ifSpec
-			isL3 := podSpec.PBLMemifSpec.GetIsL3(true)
-			podSpec.PBLMemifSpec.IsL3 = &isL3
 		case VppAnnotationPrefix + VclAnnotation:
 			podSpec.EnableVCL, err = s.ParseEnableDisableAnnotation(value)
+		case VppAnnotationPrefix + VhostUserAnnotation:
+			var enableVhostUser bool
+			enableVhostUser, err = s.ParseEnableDisableAnnotation(value)
+			if err == nil && enableVhostUser {
+				err = s.ParseDefaultIfType(podSpec, storage.VppIfTypeVhostUser)
+			}
 		default:
 			continue
 		}
@@ -0,0 +1,246 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+const (
+	// PodCapturePath is the agent API path of the pod captures
+	PodCapturePath = "/cni/capture"
+
+	defaultCapturePackets  = 1000
+	maxCapturePackets      = 1000000
+	defaultCaptureDuration = 10
+	maxCaptureDuration     = 300
+	defaultCaptureSnapLen  = 512
+)
+
+// PodCaptureRequest asks for a pcap capture of the VPP interfaces of a pod,
+// given as namespace/name. Without an interface, all the pod interfaces are
+// captured.
+type PodCaptureRequest struct {
+	Pod       string `json:"pod"`
+	Interface string `json:"interface,omitempty"`
+	// Filter is a filter in pcap-filter syntax, e.g. "tcp port 80"
+	Filter string `json:"filter,omitempty"`
+	// The capture stops after MaxPackets packets or DurationSeconds
+	MaxPackets      uint32 `json:"maxPackets,omitempty"`
+	DurationSeconds uint32 `json:"durationSeconds,omitempty"`
+	SnapLen         uint32 `json:"snapLen,omitempty"`
+	CaptureDrop     bool   `json:"captureDrop,omitempty"`
+	// Output is the name of the file written in config.PodCaptureDir
+	Output string `json:"output,omitempty"`
+}
+
+type CapturedInterface struct {
+	Interface string `json:"interface"`
+	Driver    string `json:"driver"`
+	SwIfIndex uint32 `json:"swIfIndex"`
+}
+
+type PodCaptureReply struct {
+	Interfaces []CapturedInterface `json:"interfaces"`
+	// Filter is the filter actually used, it also selects the pod addresses
+	// when several interfaces are captured
+	Filter string `json:"filter,omitempty"`
+	File   string `json:"file"`
+}
+
+func (r *PodCaptureRequest) validate() error {
+	if !strings.Contains(r.Pod, "/") {
+		return errors.Errorf("invalid pod %q, expected namespace/name", r.Pod)
+	}
+	if r.MaxPackets == 0 {
+		r.MaxPackets = defaultCapturePackets
+	} else if r.MaxPackets > maxCapturePackets {
+		return errors.Errorf("cannot capture more than %d packets", maxCapturePackets)
+	}
+	if r.DurationSeconds == 0 {
+		r.DurationSeconds = defaultCaptureDuration
+	} else if r.DurationSeconds > maxCaptureDuration {
+		return errors.Errorf("cannot capture for more than %ds", maxCaptureDuration)
+	}
+	if r.SnapLen == 0 {
+		r.SnapLen = defaultCaptureSnapLen
+	}
+	if r.Output == "" {
+		namespace, name, _ := strings.Cut(r.Pod, "/")
+		r.Output = fmt.Sprintf("%s_%s_%s.pcap", namespace, name, time.Now().Format("20060102-150405"))
+	} else if filepath.Base(r.Output) != r.Output || r.Output == "." || r.Output == ".." {
+		return errors.Errorf("invalid output %q, expected a file name", r.Output)
+	}
+	return nil
+}
+
+// getCapturedInterfaces returns the VPP interfaces of the pod, and its
+// addresses
+func (s *Server) getCapturedInterfaces(request *PodCaptureRequest) ([]CapturedInterface, []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	interfaces := make([]CapturedInterface, 0)
+	addresses := make([]string, 0)
+	for _, podSpec := range s.podInterfaceMap {
+		if podSpec.WorkloadID != request.Pod || (request.Interface != "" && podSpec.InterfaceName != request.Interface) {
+			continue
+		}
+		for _, intf := range []CapturedInterface{
+			{Driver: s.tuntapDriver.Name, SwIfIndex: podSpec.TunTapSwIfIndex},
+			{Driver: s.memifDriver.Name, SwIfIndex: podSpec.MemifSwIfIndex},
+			{Driver: s.vhostUserDriver.Name, SwIfIndex: podSpec.VhostUserSwIfIndex},
+		} {
+			if intf.SwIfIndex != vpplink.InvalidID {
+				intf.Interface = podSpec.InterfaceName
+				interfaces = append(interfaces, intf)
+			}
+		}
+		for _, containerIP := range podSpec.GetContainerIps() {
+			addresses = append(addresses, containerIP.IP.String())
+		}
+	}
+	return interfaces, addresses
+}
+
+// getCapturedFile returns where the agent finds the files VPP writes in its
+// /tmp directory
+func getCapturedFile(filename string) string {
+	if common.VppManagerInfo != nil && common.VppManagerInfo.VppPid != 0 {
+		return fmt.Sprintf("/proc/%d/root/tmp/%s", common.VppManagerInfo.VppPid, filename)
+	}
+	return filepath.Join("/tmp", filename)
+}
+
+func moveFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// CapturePod captures the traffic of a pod in a pcap file, until the packet
+// count or the duration is reached. VPP only runs one capture at a time,
+// so concurrent requests are rejected. It is served on the agent API.
+func (s *Server) CapturePod(request *PodCaptureRequest) (*PodCaptureReply, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+	interfaces, addresses := s.getCapturedInterfaces(request)
+	if len(interfaces) == 0 {
+		return nil, errors.Errorf("no interface found for pod %s %s", request.Pod, request.Interface)
+	}
+	if !s.captureLock.TryLock() {
+		return nil, errors.New("a capture is already running")
+	}
+	defer s.captureLock.Unlock()
+
+	reply := &PodCaptureReply{
+		Interfaces: interfaces,
+		Filter:     request.Filter,
+		File:       filepath.Join(config.PodCaptureDir, request.Output),
+	}
+	trace := &types.PcapTrace{
+		CaptureRx:         true,
+		CaptureTx:         true,
+		CaptureDrop:       request.CaptureDrop,
+		MaxPackets:        request.MaxPackets,
+		MaxBytesPerPacket: request.SnapLen,
+		SwIfIndex:         interfaces[0].SwIfIndex,
+		Filename:          fmt.Sprintf("calico-vpp-capture-%d.pcap", time.Now().Unix()),
+	}
+	if len(interfaces) > 1 {
+		/* VPP captures one interface or all of them, only keep the pod traffic */
+		trace.SwIfIndex = 0
+		hosts := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			hosts = append(hosts, "host "+address)
+		}
+		reply.Filter = strings.Join(hosts, " or ")
+		if request.Filter != "" {
+			reply.Filter = fmt.Sprintf("(%s) and (%s)", reply.Filter, request.Filter)
+		}
+	}
+	if reply.Filter != "" {
+		err = s.vpp.SetPcapFilterFunction(vpplink.BpfPcapFilterFunction)
+		if err != nil {
+			return nil, errors.Wrap(err, "error selecting the BPF pcap filter")
+		}
+		err = s.vpp.SetBpfTraceFilter(reply.Filter)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error setting filter %q", reply.Filter)
+		}
+		defer func() {
+			err := s.vpp.DelBpfTraceFilter()
+			if err != nil {
+				s.log.WithError(err).Warn("Error deleting the capture filter")
+			}
+		}()
+		trace.UseFilter = true
+	}
+
+	s.log.Infof("Capturing %s %+v for %ds in %s", request.Pod, interfaces, request.DurationSeconds, reply.File)
+	err = s.vpp.PcapTraceOn(trace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error starting capture of %s", request.Pod)
+	}
+	time.Sleep(time.Duration(request.DurationSeconds) * time.Second)
+	err = s.vpp.PcapTraceOff()
+	if err != nil {
+		/* This also happens when no packet was captured */
+		s.log.WithError(err).Warnf("Error stopping capture of %s", request.Pod)
+	}
+
+	err = os.MkdirAll(config.PodCaptureDir, 0700)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating %s", config.PodCaptureDir)
+	}
+	err = moveFile(getCapturedFile(trace.Filename), reply.File)
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("no packet captured for %s", request.Pod)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "error writing capture to %s", reply.File)
+	}
+	return reply, nil
+}
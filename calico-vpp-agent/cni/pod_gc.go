@@ -0,0 +1,160 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+)
+
+// PodLister lists the pods scheduled on this node
+type PodLister interface {
+	// ListLocalPods returns the pods as namespace/name
+	ListLocalPods() (map[string]bool, error)
+}
+
+// podInterfaceTagRegexp matches the tags of LocalPodSpec.GetInterfaceTag
+var podInterfaceTagRegexp = regexp.MustCompile(fmt.Sprintf("^[A-Za-z0-9+/]{%d}-.+-.+$", storage.VrfTagHashLen))
+
+func (s *Server) SetPodLister(podLister PodLister) {
+	s.podLister = podLister
+}
+
+// listLocalPods returns nil when the pods cannot be listed, so that only the
+// netns are checked
+func (s *Server) listLocalPods() map[string]bool {
+	if s.podLister == nil {
+		return nil
+	}
+	localPods, err := s.podLister.ListLocalPods()
+	if err != nil {
+		s.log.WithError(err).Warn("pod(gc) cannot list the pods of this node, only checking netns")
+		return nil
+	}
+	return localPods
+}
+
+func getOrphanReason(podSpec *storage.LocalPodSpec, localPods map[string]bool) string {
+	err := ns.IsNSorErr(podSpec.NetnsName)
+	if err != nil {
+		return fmt.Sprintf("netns does not exist: %s", err)
+	}
+	if localPods != nil && podSpec.OrchestratorID == "k8s" && !localPods[podSpec.WorkloadID] {
+		return "pod does not exist"
+	}
+	return ""
+}
+
+// GCOrphanPods deletes the pods of the state that don't exist anymore, and
+// the orphan pod interfaces of VPP. It runs every PodGCInterval.
+func (s *Server) GCOrphanPods() {
+	// The pods are listed without holding the lock as this queries the
+	// API server, pods added in the meantime may be missing from the list
+	s.lock.Lock()
+	listGeneration := s.podGeneration
+	s.lock.Unlock()
+	localPods := s.listLocalPods()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gcOrphanPods(localPods, listGeneration)
+}
+
+// gcOrphanPods deletes the pods of the state that don't exist anymore, e.g.
+// that were deleted while the agent was down, then the pod interfaces of VPP
+// that are not in the state. localPods may be nil. Pods added after
+// listGeneration are only checked for their netns. Expects s.lock to be held.
+func (s *Server) gcOrphanPods(localPods map[string]bool, listGeneration uint64) {
+	removed := 0
+	for key, podSpec := range s.podInterfaceMap {
+		podLocalPods := localPods
+		if s.podGenerations[key] > listGeneration {
+			podLocalPods = nil
+		}
+		reason := getOrphanReason(&podSpec, podLocalPods)
+		if reason == "" {
+			continue
+		}
+		s.log.Infof("pod(gc) deleting orphan spec=%s: %s", podSpec.String(), reason)
+		s.GCVppInterface(&podSpec)
+		delete(s.podInterfaceMap, key)
+		delete(s.podGenerations, key)
+		removed++
+	}
+	if removed != 0 {
+		err := storage.PersistCniServerState(s.podInterfaceMap, config.CniServerStateFile)
+		if err != nil {
+			s.log.Errorf("CNI state persist errored %v", err)
+		}
+	}
+	swept, err := s.sweepPodInterfaces()
+	if err != nil {
+		s.log.WithError(err).Warn("pod(gc) error sweeping pod interfaces")
+	}
+	if removed != 0 || swept != 0 {
+		s.log.Infof("pod(gc) Done, deleted %d pods and %d interfaces", removed, swept)
+	}
+}
+
+// sweepPodInterfaces deletes the VPP interfaces with a pod interface tag that
+// belong to no pod of the state. Their routes and VRFs are left in VPP as
+// they cannot be found without the pod spec. Expects s.lock to be held.
+func (s *Server) sweepPodInterfaces() (int, error) {
+	knownTags := make(map[string]bool)
+	for _, podSpec := range s.podInterfaceMap {
+		for _, driverName := range []string{s.tuntapDriver.Name, s.memifDriver.Name, s.vhostUserDriver.Name} {
+			knownTags[podSpec.GetInterfaceTag(driverName)] = true
+		}
+	}
+	taggedInterfaces, err := s.vpp.SearchInterfacesWithTagPrefix("")
+	if err != nil {
+		return 0, err
+	}
+	swept := 0
+	for tag, swIfIndex := range taggedInterfaces {
+		if knownTags[tag] || !podInterfaceTagRegexp.MatchString(tag) {
+			continue
+		}
+		details, err := s.vpp.GetInterfaceDetails(swIfIndex)
+		if err != nil {
+			return swept, err
+		}
+		var deleteInterface func(uint32) error
+		switch details.Type {
+		case "virtio":
+			deleteInterface = s.vpp.DelTap
+		case "memif":
+			deleteInterface = s.vpp.DeleteMemif
+		case "vhost-user":
+			deleteInterface = s.vpp.DeleteVhostUser
+		default:
+			continue
+		}
+		s.log.Infof("pod(gc) deleting orphan interface %s swIfIndex=%d tag=%s", details.Name, swIfIndex, tag)
+		s.tuntapDriver.UndoPodIfNatConfiguration(swIfIndex)
+		err = deleteInterface(swIfIndex)
+		if err != nil {
+			s.log.WithError(err).Warnf("pod(gc) error deleting interface %d", swIfIndex)
+			continue
+		}
+		swept++
+	}
+	return swept, nil
+}
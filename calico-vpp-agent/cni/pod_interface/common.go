@@ -16,6 +16,8 @@
 package pod_interface
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
@@ -70,6 +72,51 @@ func (i *PodInterfaceDriverData) SpreadRxQueuesOnWorkers(swIfIndex uint32, numRx
 	}
 }
 
+// UpdateInterfaceSpec applies a new spec to an existing pod interface. Only the
+// rx mode and the queue placement can change, the number and size of the
+// queues and the L2/L3 mode are set when the interface is created.
+func (i *PodInterfaceDriverData) UpdateInterfaceSpec(swIfIndex uint32, oldSpec, newSpec config.InterfaceSpec) error {
+	err := i.CheckInterfaceSpecUpdate(oldSpec, newSpec)
+	if err != nil {
+		return err
+	}
+	if oldSpec.RxMode != newSpec.RxMode {
+		i.log.Infof("pod(update) if[%d] rx mode %s", swIfIndex, types.FormatRxMode(newSpec.GetRxModeWithDefault(types.AdaptativeRxMode)))
+		err = i.vpp.SetInterfaceRxMode(swIfIndex, types.AllQueues, newSpec.GetRxModeWithDefault(types.AdaptativeRxMode))
+		if err != nil {
+			return errors.Wrapf(err, "error SetInterfaceRxMode on pod if interface")
+		}
+	}
+	i.SpreadRxQueuesOnWorkers(swIfIndex, newSpec.NumRxQueues)
+	return nil
+}
+
+// CheckInterfaceSpecUpdate returns an error when the new spec cannot be
+// applied without recreating the interface
+func (i *PodInterfaceDriverData) CheckInterfaceSpecUpdate(oldSpec, newSpec config.InterfaceSpec) error {
+	changed := make([]string, 0)
+	if oldSpec.NumRxQueues != newSpec.NumRxQueues {
+		changed = append(changed, "rx")
+	}
+	if oldSpec.NumTxQueues != newSpec.NumTxQueues {
+		changed = append(changed, "tx")
+	}
+	if oldSpec.RxQueueSize != newSpec.RxQueueSize {
+		changed = append(changed, "rxqsz")
+	}
+	if oldSpec.TxQueueSize != newSpec.TxQueueSize {
+		changed = append(changed, "txqsz")
+	}
+	/* IsL3 is always set in the pod specs */
+	if *oldSpec.IsL3 != *newSpec.IsL3 {
+		changed = append(changed, "isl3")
+	}
+	if len(changed) != 0 {
+		return errors.Errorf("changing %s of the %s interface requires recreating the pod", strings.Join(changed, ", "), i.Name)
+	}
+	return nil
+}
+
 func (i *PodInterfaceDriverData) UndoPodIfNatConfiguration(swIfIndex uint32) {
 	var err error
 	err = i.vpp.RemovePodInterface(swIfIndex)
@@ -0,0 +1,127 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pod_interface
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// VhostUserPodInterfaceDriver creates a vhost-user interface in place of the
+// tun of the pod, for VMs running in the pod to attach to
+type VhostUserPodInterfaceDriver struct {
+	PodInterfaceDriverData
+}
+
+func NewVhostUserPodInterfaceDriver(vpp *vpplink.VppLink, log *logrus.Entry) *VhostUserPodInterfaceDriver {
+	i := &VhostUserPodInterfaceDriver{}
+	i.vpp = vpp
+	i.log = log
+	i.Name = "vhostuser"
+	return i
+}
+
+// GetVhostUserSocketDir returns the directory of the vhost-user sockets of a
+// pod, that the pod mounts as a hostPath volume
+func GetVhostUserSocketDir(podSpec *storage.LocalPodSpec) string {
+	namespace, pod, _ := strings.Cut(podSpec.WorkloadID, "/")
+	return filepath.Join(config.VhostUserSocketDir, namespace, pod)
+}
+
+func (i *VhostUserPodInterfaceDriver) CreateInterface(podSpec *storage.LocalPodSpec, stack *vpplink.CleanupStack) (err error) {
+	socketDir := GetVhostUserSocketDir(podSpec)
+	err = os.MkdirAll(socketDir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating vhost-user socket directory %s", socketDir)
+	}
+	podSpec.VhostUserSocket = filepath.Join(socketDir, podSpec.InterfaceName+".sock")
+	stack.Push(i.removeSocket, podSpec.VhostUserSocket)
+
+	/* VPP creates the socket and the VM connects to it */
+	vhost := &types.VhostUser{
+		SockFilename: podSpec.VhostUserSocket,
+		IsServer:     true,
+		EnableGso:    *config.GetCalicoVppDebug().GSOEnabled,
+		Tag:          podSpec.GetInterfaceTag(i.Name),
+	}
+	err = i.vpp.CreateVhostUser(vhost)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating vhost-user interface")
+	} else {
+		stack.Push(i.vpp.DeleteVhostUser, vhost.SwIfIndex)
+	}
+	podSpec.VhostUserSwIfIndex = vhost.SwIfIndex
+	i.log.Infof("pod(add) vhost-user swIfIndex=%d socket=%s", vhost.SwIfIndex, vhost.SockFilename)
+
+	err = i.DoPodIfNatConfiguration(podSpec, stack, vhost.SwIfIndex)
+	if err != nil {
+		return err
+	}
+
+	/* The queues are set by the VM, and vhost-user interfaces are always ethernet */
+	ifSpec := podSpec.IfSpec
+	ifSpec.IsL3 = &config.False
+	err = i.DoPodInterfaceConfiguration(podSpec, stack, ifSpec, vhost.SwIfIndex)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *VhostUserPodInterfaceDriver) DeleteInterface(podSpec *storage.LocalPodSpec) {
+	if podSpec.VhostUserSwIfIndex == vpplink.InvalidID {
+		return
+	}
+
+	i.UndoPodInterfaceConfiguration(podSpec.VhostUserSwIfIndex)
+	i.UndoPodIfNatConfiguration(podSpec.VhostUserSwIfIndex)
+
+	err := i.vpp.DeleteVhostUser(podSpec.VhostUserSwIfIndex)
+	if err != nil {
+		i.log.Warnf("Error deleting vhost-user[%d] %s", podSpec.VhostUserSwIfIndex, err)
+	}
+	i.removeSocket(podSpec.VhostUserSocket)
+
+	i.log.Infof("pod(del) vhost-user swIfIndex=%d", podSpec.VhostUserSwIfIndex)
+}
+
+// removeSocket removes the socket left behind by VPP, and the directories of
+// the pod and its namespace once empty
+func (i *VhostUserPodInterfaceDriver) removeSocket(socket string) {
+	if socket == "" {
+		return
+	}
+	err := os.Remove(socket)
+	if err != nil && !os.IsNotExist(err) {
+		i.log.Warnf("Error removing vhost-user socket %s %s", socket, err)
+	}
+	/* os.Remove fails on directories that are not empty */
+	for dir := filepath.Dir(socket); strings.HasPrefix(dir, config.VhostUserSocketDir+"/"); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/lunixbochs/struc"
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// Up to version 8, the state file was a struc encoding of LocalPodSpec, whose
+// name was suffixed with its version. This file is loaded with a frozen copy
+// of the structs of version 8, and migrated to the current version.
+// From version 9 the state file is JSON encoded, and when changing LocalPodSpec
+// in a way that the zero value of a field is not what older agents meant (e.g.
+// a new index that should default to InvalidID, or a renamed field), a
+// migration from the previous version must be added to jsonStateMigrations.
+
+const (
+	legacyStateFileVersion = 8
+	jsonStateFileVersion   = 9
+)
+
+// jsonStateMigrations update the JSON encoded pod specs of the version they are
+// indexed with to the next version
+var jsonStateMigrations = map[int]func(specs []map[string]interface{}) error{
+	9:  migrateV9,
+	10: migrateV10,
+}
+
+// migrateV10 moves the single cnat entry of the hostPorts to the entries of
+// the family of their host address
+func migrateV10(specs []map[string]interface{}) error {
+	for _, spec := range specs {
+		hostPorts, ok := spec["HostPorts"].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, h := range hostPorts {
+			hostPort, ok := h.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Invalid hostPort %v", h)
+			}
+			entryID, found := hostPort["EntryID"]
+			if !found {
+				continue
+			}
+			delete(hostPort, "EntryID")
+			hostIP, _ := hostPort["HostIP"].(string)
+			entries := []interface{}{map[string]interface{}{"HostIP": hostIP, "EntryID": entryID}}
+			if ip := net.ParseIP(hostIP); ip != nil && ip.To4() == nil {
+				hostPort["IP6Entries"] = entries
+			} else {
+				hostPort["IP4Entries"] = entries
+			}
+		}
+	}
+	return nil
+}
+
+// migrateV9 sets the index of the vhost-user interface added in version 10
+func migrateV9(specs []map[string]interface{}) error {
+	for _, spec := range specs {
+		spec["VhostUserSwIfIndex"] = types.InvalidID
+	}
+	return nil
+}
+
+func migrateCniServerState(version int, data json.RawMessage) ([]LocalPodSpec, error) {
+	if version < jsonStateFileVersion {
+		return nil, fmt.Errorf("Unsupported save file version: %d", version)
+	}
+	if version < CniServerStateFileVersion {
+		var specs []map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err := decoder.Decode(&specs)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error decoding pod data")
+		}
+		for v := version; v < CniServerStateFileVersion; v++ {
+			migrate, found := jsonStateMigrations[v]
+			if !found {
+				return nil, fmt.Errorf("No migration from version %d", v)
+			}
+			err = migrate(specs)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error migrating from version %d", v)
+			}
+		}
+		data, err = json.Marshal(specs)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error encoding pod data")
+		}
+	}
+	/* Newer versions are loaded as well, ignoring the fields we don't know */
+	var specs []LocalPodSpec
+	err := json.Unmarshal(data, &specs)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error decoding pod data")
+	}
+	return specs, nil
+}
+
+func legacyCniServerStateFile(fname string) string {
+	return fmt.Sprintf("%s%d", fname, legacyStateFileVersion)
+}
+
+// loadLegacyCniServerState loads the state file written in the struc format
+// by older agents, if any
+func loadLegacyCniServerState(fname string) ([]LocalPodSpec, error) {
+	legacyFile := legacyCniServerStateFile(fname)
+	data, err := os.ReadFile(legacyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil // No state to load
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "Error reading file %s", legacyFile)
+	}
+	specs, err := decodeLegacyCniServerState(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error loading file %s", legacyFile)
+	}
+	return specs, nil
+}
+
+// RemoveLegacyCniServerState removes the state file written in the struc
+// format, once its content has been persisted in the current format
+func RemoveLegacyCniServerState(fname string) error {
+	legacyFile := legacyCniServerStateFile(fname)
+	err := os.Remove(legacyFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrapf(err, "Error removing file %s", legacyFile)
+	}
+	return nil
+}
+
+func decodeLegacyCniServerState(data []byte) ([]LocalPodSpec, error) {
+	var header struct {
+		Version int `struc:"int32"`
+	}
+	err := struc.Unpack(bytes.NewReader(data), &header)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error unpacking version")
+	}
+	if header.Version != legacyStateFileVersion {
+		return nil, fmt.Errorf("Unsupported save file version: %d", header.Version)
+	}
+	var state savedStateV8
+	err = struc.Unpack(bytes.NewReader(data), &state)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error unpacking")
+	}
+	specs := make([]LocalPodSpec, 0, len(state.Specs))
+	for _, spec := range state.Specs {
+		specs = append(specs, spec.migrate())
+	}
+	return specs, nil
+}
+
+/* Structs of the struc encoded state files, these must not change */
+
+type legacyIPNet struct {
+	MaskSize int    `struc:"int8,sizeof=Mask"`
+	IP       net.IP `struc:"[16]byte"`
+	Mask     net.IPMask
+}
+
+type legacyIP struct {
+	IP net.IP `struc:"[16]byte"`
+}
+
+type legacyHostPortBinding struct {
+	HostPort      uint16
+	HostIP        net.IP `struc:"[16]byte"`
+	ContainerPort uint16
+	EntryID       uint32
+	Protocol      uint8
+}
+
+type legacyIfPortConfigs struct {
+	Start uint16
+	End   uint16
+	Proto uint8
+}
+
+type legacyInterfaceSpec struct {
+	NumRxQueues int
+	NumTxQueues int
+	RxQueueSize int
+	TxQueueSize int
+	IsL3        *bool
+	RxMode      uint32
+}
+
+func (i *legacyInterfaceSpec) migrate() config.InterfaceSpec {
+	return config.InterfaceSpec{
+		NumRxQueues: i.NumRxQueues,
+		NumTxQueues: i.NumTxQueues,
+		RxQueueSize: i.RxQueueSize,
+		TxQueueSize: i.TxQueueSize,
+		IsL3:        i.IsL3,
+		RxMode:      types.RxMode(i.RxMode),
+	}
+}
+
+type savedStateV8 struct {
+	Version    int `struc:"int32"`
+	SpecsCount int `struc:"int32,sizeof=Specs"`
+	Specs      []podSpecV8
+}
+
+type podSpecV8 struct {
+	InterfaceNameSize int `struc:"int16,sizeof=InterfaceName"`
+	InterfaceName     string
+	NetnsNameSize     int `struc:"int16,sizeof=NetnsName"`
+	NetnsName         string
+	AllowIpForwarding bool
+	RoutesSize        int `struc:"int16,sizeof=Routes"`
+	Routes            []legacyIPNet
+	ContainerIpsSize  int `struc:"int16,sizeof=ContainerIps"`
+	ContainerIps      []legacyIP
+	Mtu               int
+
+	OrchestratorIDSize int `struc:"int16,sizeof=OrchestratorID"`
+	OrchestratorID     string
+	WorkloadIDSize     int `struc:"int16,sizeof=WorkloadID"`
+	WorkloadID         string
+	EndpointIDSize     int `struc:"int16,sizeof=EndpointID"`
+	EndpointID         string
+	HostPortsSize      int `struc:"int16,sizeof=HostPorts"`
+	HostPorts          []legacyHostPortBinding
+
+	IfPortConfigsLen   int `struc:"int16,sizeof=IfPortConfigs"`
+	IfPortConfigs      []legacyIfPortConfigs
+	PortFilteredIfType uint8
+	DefaultIfType      uint8
+	EnableVCL          bool
+	EnableMemif        bool
+
+	IfSpec       legacyInterfaceSpec
+	PBLMemifSpec legacyInterfaceSpec
+
+	MemifSocketId     uint32
+	TunTapSwIfIndex   uint32
+	MemifSwIfIndex    uint32
+	LoopbackSwIfIndex uint32
+	PblIndexesLen     int `struc:"int16,sizeof=PblIndexes"`
+	PblIndexes        []uint32
+
+	V4VrfId   uint32
+	V6VrfId   uint32
+	NeedsSnat bool
+
+	NetworkNameSize int `struc:"int16,sizeof=NetworkName"`
+	NetworkName     string
+
+	AllowedSpoofingPrefixesSize int `struc:"int16,sizeof=AllowedSpoofingPrefixes"`
+	AllowedSpoofingPrefixes     string
+
+	V4RPFVrfId uint32
+	V6RPFVrfId uint32
+}
+
+// migrate converts the spec to the JSON encoded LocalPodSpec of version 9,
+// pods of version 8 have no bandwidth limits
+func (ps *podSpecV8) migrate() LocalPodSpec {
+	spec := LocalPodSpec{
+		InterfaceName:           ps.InterfaceName,
+		NetnsName:               ps.NetnsName,
+		AllowIpForwarding:       ps.AllowIpForwarding,
+		Routes:                  make([]LocalIPNet, 0, len(ps.Routes)),
+		ContainerIps:            make([]LocalIP, 0, len(ps.ContainerIps)),
+		Mtu:                     ps.Mtu,
+		OrchestratorID:          ps.OrchestratorID,
+		WorkloadID:              ps.WorkloadID,
+		EndpointID:              ps.EndpointID,
+		HostPorts:               make([]HostPortBinding, 0, len(ps.HostPorts)),
+		IfPortConfigs:           make([]LocalIfPortConfigs, 0, len(ps.IfPortConfigs)),
+		PortFilteredIfType:      VppInterfaceType(ps.PortFilteredIfType),
+		DefaultIfType:           VppInterfaceType(ps.DefaultIfType),
+		EnableVCL:               ps.EnableVCL,
+		EnableMemif:             ps.EnableMemif,
+		IfSpec:                  ps.IfSpec.migrate(),
+		PBLMemifSpec:            ps.PBLMemifSpec.migrate(),
+		MemifSocketId:           ps.MemifSocketId,
+		TunTapSwIfIndex:         ps.TunTapSwIfIndex,
+		MemifSwIfIndex:          ps.MemifSwIfIndex,
+		LoopbackSwIfIndex:       ps.LoopbackSwIfIndex,
+		PblIndexes:              append(make([]uint32, 0), ps.PblIndexes...),
+		VhostUserSwIfIndex:      types.InvalidID,
+		IngressPolicerIndex:     types.InvalidID,
+		EgressPolicerIndex:      types.InvalidID,
+		V4VrfId:                 ps.V4VrfId,
+		V6VrfId:                 ps.V6VrfId,
+		NeedsSnat:               ps.NeedsSnat,
+		NetworkName:             ps.NetworkName,
+		AllowedSpoofingPrefixes: ps.AllowedSpoofingPrefixes,
+		V4RPFVrfId:              ps.V4RPFVrfId,
+		V6RPFVrfId:              ps.V6RPFVrfId,
+	}
+	for _, route := range ps.Routes {
+		spec.Routes = append(spec.Routes, LocalIPNet{IP: route.IP, Mask: route.Mask})
+	}
+	for _, containerIP := range ps.ContainerIps {
+		spec.ContainerIps = append(spec.ContainerIps, LocalIP{IP: containerIP.IP})
+	}
+	for _, hostPort := range ps.HostPorts {
+		binding := HostPortBinding{
+			HostPort:      hostPort.HostPort,
+			HostIP:        hostPort.HostIP,
+			ContainerPort: hostPort.ContainerPort,
+			Protocol:      types.IPProto(hostPort.Protocol),
+		}
+		binding.AddEntry(hostPort.HostIP, hostPort.EntryID)
+		spec.HostPorts = append(spec.HostPorts, binding)
+	}
+	for _, portConfig := range ps.IfPortConfigs {
+		spec.IfPortConfigs = append(spec.IfPortConfigs, LocalIfPortConfigs{
+			Start: portConfig.Start,
+			End:   portConfig.End,
+			Proto: types.IPProto(portConfig.Proto),
+		})
+	}
+	return spec
+}
@@ -16,16 +16,17 @@
 package storage
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/lunixbochs/struc"
 	"github.com/pkg/errors"
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
@@ -35,21 +36,20 @@ import (
 )
 
 const (
-	CniServerStateFileVersion = 8  // Used to ensure compatibility wen we reload data
+	CniServerStateFileVersion = 11 // Used to ensure compatibility wen we reload data
 	MaxApiTagLen              = 63 /* No more than 64 characters in API tags */
 	VrfTagHashLen             = 8  /* how many hash charatecters (b64) of the name in tag prefix (useful when trucated) */
 )
 
-// XXX: Increment CniServerStateFileVersion when changing this struct
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
 type LocalIPNet struct {
-	MaskSize int    `struc:"int8,sizeof=Mask"`
-	IP       net.IP `struc:"[16]byte"`
-	Mask     net.IPMask
+	IP   net.IP
+	Mask net.IPMask
 }
 
-// XXX: Increment CniServerStateFileVersion when changing this struct
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
 type LocalIP struct {
-	IP net.IP `struc:"[16]byte"`
+	IP net.IP
 }
 
 type VppInterfaceType uint8
@@ -59,6 +59,7 @@ const (
 	VppIfTypeTunTap
 	VppIfTypeMemif
 	VppIfTypeVCL
+	VppIfTypeVhostUser
 )
 
 func (ift VppInterfaceType) String() string {
@@ -71,6 +72,8 @@ func (ift VppInterfaceType) String() string {
 		return "Memif"
 	case VppIfTypeVCL:
 		return "VCL"
+	case VppIfTypeVhostUser:
+		return "VhostUser"
 	default:
 		return "Unknown"
 	}
@@ -88,20 +91,6 @@ func (n *LocalIP) String() string {
 	return n.IP.String()
 }
 
-func (n *LocalIPNet) UpdateSizes() {
-	n.MaskSize = len(n.Mask)
-}
-
-func (ps *LocalPodSpec) UpdateSizes() {
-	ps.RoutesSize = len(ps.Routes)
-	ps.ContainerIpsSize = len(ps.ContainerIps)
-	ps.InterfaceNameSize = len(ps.InterfaceName)
-	ps.NetnsNameSize = len(ps.NetnsName)
-	for _, n := range ps.Routes {
-		n.UpdateSizes()
-	}
-}
-
 func (ps *LocalPodSpec) Key() string {
 	return fmt.Sprintf("netns:%s,if:%s", ps.NetnsName, ps.InterfaceName)
 }
@@ -151,7 +140,13 @@ func (ps *LocalPodSpec) FullString() string {
 	s += fmt.Sprintf("TunTapSwIfIndex:    %d\n", ps.TunTapSwIfIndex)
 	s += fmt.Sprintf("MemifSwIfIndex:     %d\n", ps.MemifSwIfIndex)
 	s += fmt.Sprintf("LoopbackSwIfIndex:  %d\n", ps.LoopbackSwIfIndex)
+	s += fmt.Sprintf("VhostUserSwIfIndex: %d\n", ps.VhostUserSwIfIndex)
+	s += fmt.Sprintf("VhostUserSocket:    %s\n", ps.VhostUserSocket)
 	s += fmt.Sprintf("PblIndexes:         %s\n", strings.Join(pblIndexesLst, ", "))
+	s += fmt.Sprintf("IngressBandwidth:   %d\n", ps.IngressBandwidth)
+	s += fmt.Sprintf("EgressBandwidth:    %d\n", ps.EgressBandwidth)
+	s += fmt.Sprintf("IngressPolicer:     %d\n", ps.IngressPolicerIndex)
+	s += fmt.Sprintf("EgressPolicer:      %d\n", ps.EgressPolicerIndex)
 	s += fmt.Sprintf("V4VrfId:            %d\n", ps.V4VrfId)
 	s += fmt.Sprintf("V6VrfId:            %d\n", ps.V6VrfId)
 	return s
@@ -166,11 +161,27 @@ func (ps *LocalPodSpec) GetParamsForIfType(ifType VppInterfaceType) (swIfIndex u
 			return types.InvalidID, true
 		}
 		return ps.MemifSwIfIndex, *ps.PBLMemifSpec.IsL3
+	case VppIfTypeVhostUser:
+		/* vhost-user interfaces are always ethernet */
+		return ps.VhostUserSwIfIndex, false
 	default:
 		return types.InvalidID, true
 	}
 }
 
+// GetPodSwIfIndex returns the main interface of the pod: its tun, the
+// vhost-user interface replacing it, or its memif in secondary networks
+func (ps *LocalPodSpec) GetPodSwIfIndex() uint32 {
+	switch {
+	case ps.DefaultIfType == VppIfTypeVhostUser:
+		return ps.VhostUserSwIfIndex
+	case ps.TunTapSwIfIndex == types.InvalidID:
+		return ps.MemifSwIfIndex
+	default:
+		return ps.TunTapSwIfIndex
+	}
+}
+
 func (ps *LocalPodSpec) GetBuffersNeeded() uint64 {
 	var buffersNeededForThisPod uint64
 	buffersNeededForThisPod += ps.IfSpec.GetBuffersNeeded()
@@ -180,7 +191,7 @@ func (ps *LocalPodSpec) GetBuffersNeeded() uint64 {
 	return buffersNeededForThisPod
 }
 
-// XXX: Increment CniServerStateFileVersion when changing this struct
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
 type LocalIfPortConfigs struct {
 	Start uint16
 	End   uint16
@@ -191,38 +202,32 @@ func (pc *LocalIfPortConfigs) String() string {
 	return fmt.Sprintf("%s %d-%d", pc.Proto.String(), pc.Start, pc.End)
 }
 
-// XXX: Increment CniServerStateFileVersion when changing this struct
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
 type LocalPodSpec struct {
-	InterfaceNameSize int `struc:"int16,sizeof=InterfaceName"`
 	InterfaceName     string
-	NetnsNameSize     int `struc:"int16,sizeof=NetnsName"`
 	NetnsName         string
 	AllowIpForwarding bool
-	RoutesSize        int `struc:"int16,sizeof=Routes"`
 	Routes            []LocalIPNet
-	ContainerIpsSize  int `struc:"int16,sizeof=ContainerIps"`
 	ContainerIps      []LocalIP
 	Mtu               int
 
 	// Pod identifiers
-	OrchestratorIDSize int `struc:"int16,sizeof=OrchestratorID"`
-	OrchestratorID     string
-	WorkloadIDSize     int `struc:"int16,sizeof=WorkloadID"`
-	WorkloadID         string
-	EndpointIDSize     int `struc:"int16,sizeof=EndpointID"`
-	EndpointID         string
+	OrchestratorID string
+	WorkloadID     string
+	EndpointID     string
 	// HostPort
-	HostPortsSize int `struc:"int16,sizeof=HostPorts"`
-	HostPorts     []HostPortBinding
+	HostPorts []HostPortBinding
 
-	IfPortConfigsLen int `struc:"int16,sizeof=IfPortConfigs"`
-	IfPortConfigs    []LocalIfPortConfigs
+	IfPortConfigs []LocalIfPortConfigs
 	/* This interface type will traffic MATCHING the portConfigs */
 	PortFilteredIfType VppInterfaceType
 	/* This interface type will traffic not matching portConfigs */
 	DefaultIfType VppInterfaceType
 	EnableVCL     bool
 	EnableMemif   bool
+	/* Bandwidth limits in bits per second, zero when unlimited */
+	IngressBandwidth uint64
+	EgressBandwidth  uint64
 
 	IfSpec       config.InterfaceSpec
 	PBLMemifSpec config.InterfaceSpec
@@ -238,8 +243,13 @@ type LocalPodSpec struct {
 	TunTapSwIfIndex   uint32
 	MemifSwIfIndex    uint32
 	LoopbackSwIfIndex uint32
-	PblIndexesLen     int `struc:"int16,sizeof=PblIndexes"`
 	PblIndexes        []uint32
+	/* vhost-user interface replacing the tun, and its socket path on the host */
+	VhostUserSwIfIndex uint32
+	VhostUserSocket    string
+	/* Policers enforcing the bandwidth limits */
+	IngressPolicerIndex uint32
+	EgressPolicerIndex  uint32
 
 	/**
 	 * These fields are only a runtime cache, but we also store them
@@ -250,12 +260,10 @@ type LocalPodSpec struct {
 	NeedsSnat bool
 
 	/* Multi net */
-	NetworkNameSize int `struc:"int16,sizeof=NetworkName"`
-	NetworkName     string
+	NetworkName string
 
 	/* rpf check */
-	AllowedSpoofingPrefixesSize int `struc:"int16,sizeof=AllowedSpoofingPrefixes"`
-	AllowedSpoofingPrefixes     string
+	AllowedSpoofingPrefixes string
 
 	V4RPFVrfId uint32
 	V6RPFVrfId uint32
@@ -266,7 +274,10 @@ func (ps *LocalPodSpec) Copy() LocalPodSpec {
 
 	newPs.Routes = append(make([]LocalIPNet, 0), ps.Routes...)
 	newPs.ContainerIps = append(make([]LocalIP, 0), ps.ContainerIps...)
-	newPs.HostPorts = append(make([]HostPortBinding, 0), ps.HostPorts...)
+	newPs.HostPorts = make([]HostPortBinding, 0, len(ps.HostPorts))
+	for _, hostPort := range ps.HostPorts {
+		newPs.HostPorts = append(newPs.HostPorts, hostPort.Copy())
+	}
 	newPs.IfPortConfigs = append(make([]LocalIfPortConfigs, 0), ps.IfPortConfigs...)
 	newPs.PblIndexes = append(make([]uint32, 0), ps.PblIndexes...)
 
@@ -274,22 +285,67 @@ func (ps *LocalPodSpec) Copy() LocalPodSpec {
 
 }
 
-// XXX: Increment CniServerStateFileVersion when changing this struct
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
+type HostPortEntry struct {
+	HostIP  net.IP
+	EntryID uint32
+}
+
+// XXX: Increment CniServerStateFileVersion and add a migration when changing this struct
 type HostPortBinding struct {
-	HostPort      uint16
-	HostIP        net.IP `struc:"[16]byte"`
+	HostPort uint16
+	/* HostIP is unspecified when binding all the node addresses of its family,
+	 * and nil when binding all the node addresses */
+	HostIP        net.IP
 	ContainerPort uint16
-	EntryID       uint32
 	Protocol      types.IPProto
+	/* cnat entries, one per node address the port is bound on */
+	IP4Entries []HostPortEntry
+	IP6Entries []HostPortEntry
 }
 
 func (hp *HostPortBinding) String() string {
-	s := fmt.Sprintf("%s %s:%d", hp.Protocol.String(), hp.HostIP, hp.HostPort)
+	s := fmt.Sprintf("%s %s:%d", hp.Protocol.String(), hp.GetHostIPString(), hp.HostPort)
 	s += fmt.Sprintf(" cport=%d", hp.ContainerPort)
-	s += fmt.Sprintf(" id=%d", hp.EntryID)
+	for _, entry := range hp.GetEntries() {
+		s += fmt.Sprintf(" %s=%d", entry.HostIP, entry.EntryID)
+	}
 	return s
 }
 
+func (hp *HostPortBinding) GetHostIPString() string {
+	if hp.HostIP == nil {
+		return "*"
+	}
+	return hp.HostIP.String()
+}
+
+func (hp *HostPortBinding) Copy() HostPortBinding {
+	newHp := *hp
+	newHp.IP4Entries = append(make([]HostPortEntry, 0), hp.IP4Entries...)
+	newHp.IP6Entries = append(make([]HostPortEntry, 0), hp.IP6Entries...)
+	return newHp
+}
+
+// GetEntries returns the cnat entries of both families
+func (hp *HostPortBinding) GetEntries() []HostPortEntry {
+	return append(append(make([]HostPortEntry, 0), hp.IP4Entries...), hp.IP6Entries...)
+}
+
+func (hp *HostPortBinding) AddEntry(hostIP net.IP, entryID uint32) {
+	entry := HostPortEntry{HostIP: hostIP, EntryID: entryID}
+	if vpplink.IsIP6(hostIP) {
+		hp.IP6Entries = append(hp.IP6Entries, entry)
+	} else {
+		hp.IP4Entries = append(hp.IP4Entries, entry)
+	}
+}
+
+// BindsFamily tells whether the port is bound on the node addresses of a family
+func (hp *HostPortBinding) BindsFamily(isIP6 bool) bool {
+	return hp.HostIP == nil || vpplink.IsIP6(hp.HostIP) == isIP6
+}
+
 /* 8 base64 character hash */
 func hash(text string) string {
 	h := sha512.Sum512([]byte(text))
@@ -382,29 +438,57 @@ func (ps *LocalPodSpec) SetRPFVrfId(id uint32, ipFamily vpplink.IpFamily) {
 	}
 }
 
+// SavedState is the content of the state file. The specs are JSON encoded, so
+// that files written by newer agents can be loaded, unknown fields being
+// ignored, and files written by older agents are migrated when loaded.
 type SavedState struct {
-	Version    int `struc:"int32"`
-	SpecsCount int `struc:"int32,sizeof=Specs"`
-	Specs      []LocalPodSpec
+	Version int `json:"version"`
+	// Checksum is the hex encoded sha256 sum of the specs
+	Checksum string          `json:"checksum"`
+	Specs    json.RawMessage `json:"specs"`
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 func PersistCniServerState(podInterfaceMap map[string]LocalPodSpec, fname string) (err error) {
-	var buf bytes.Buffer
-	tmpFile := fmt.Sprintf("%s~", fname)
-	state := &SavedState{
-		Version:    CniServerStateFileVersion,
-		SpecsCount: len(podInterfaceMap),
-		Specs:      make([]LocalPodSpec, 0, len(podInterfaceMap)),
-	}
+	specs := make([]LocalPodSpec, 0, len(podInterfaceMap))
 	for _, podSpec := range podInterfaceMap {
-		state.Specs = append(state.Specs, podSpec)
+		specs = append(specs, podSpec)
 	}
-	err = struc.Pack(&buf, state)
+	data, err := json.Marshal(specs)
 	if err != nil {
 		return errors.Wrap(err, "Error encoding pod data")
 	}
+	data, err = json.Marshal(&SavedState{
+		Version:  CniServerStateFileVersion,
+		Checksum: checksum(data),
+		Specs:    data,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Error encoding state")
+	}
+	return writeFileAtomic(fname, data)
+}
 
-	err = os.WriteFile(tmpFile, buf.Bytes(), 0200)
+// writeFileAtomic writes the data to a temporary file that is renamed once
+// synced, so that a crash never leaves a partially written file behind
+func writeFileAtomic(fname string, data []byte) error {
+	tmpFile := fmt.Sprintf("%s~", fname)
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0200)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating file %s", tmpFile)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return errors.Wrapf(err, "Error writing file %s", tmpFile)
 	}
@@ -412,28 +496,52 @@ func PersistCniServerState(podInterfaceMap map[string]LocalPodSpec, fname string
 	if err != nil {
 		return errors.Wrapf(err, "Error moving file %s", tmpFile)
 	}
+	dir, err := os.Open(filepath.Dir(fname))
+	if err != nil {
+		return errors.Wrapf(err, "Error opening directory of %s", fname)
+	}
+	defer dir.Close()
+	err = dir.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "Error syncing directory of %s", fname)
+	}
 	return nil
 }
 
+// LoadCniServerState loads the pod specs from the state file, falling back to
+// the state files of older agents when it does not exist
 func LoadCniServerState(fname string) ([]LocalPodSpec, error) {
-	var state SavedState
 	data, err := os.ReadFile(fname)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return nil, nil // No state to load
+			return loadLegacyCniServerState(fname)
 		} else {
 			return nil, errors.Wrapf(err, "Error reading file %s", fname)
 		}
 	}
-	buf := bytes.NewBuffer(data)
-	err = struc.Unpack(buf, &state)
+	var state SavedState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decoding file %s", fname)
+	}
+	if state.Checksum != checksum(state.Specs) {
+		return nil, fmt.Errorf("Checksum mismatch in file %s", fname)
+	}
+	specs, err := migrateCniServerState(state.Version, state.Specs)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error unpacking")
+		return nil, errors.Wrapf(err, "Error migrating file %s from version %d", fname, state.Version)
 	}
-	if state.Version != CniServerStateFileVersion {
-		// When adding new versions, we need to keep loading old versions or some pods
-		// will remain disconnected forever after an upgrade
-		return nil, fmt.Errorf("Unsupported save file version: %d", state.Version)
+	return specs, nil
+}
+
+// SetAsideCniServerState renames the state files that cannot be loaded with a
+// .corrupt suffix, so that they are neither loaded again nor lost
+func SetAsideCniServerState(fname string) error {
+	for _, stateFile := range []string{fname, legacyCniServerStateFile(fname)} {
+		err := os.Rename(stateFile, stateFile+".corrupt")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrapf(err, "Error renaming file %s", stateFile)
+		}
 	}
-	return state.Specs, nil
+	return nil
 }
@@ -0,0 +1,229 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lunixbochs/struc"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestStorage(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CNI storage tests")
+}
+
+func testPodSpec() LocalPodSpec {
+	_, route, _ := net.ParseCIDR("10.0.0.1/32")
+	return LocalPodSpec{
+		InterfaceName:  "eth0",
+		NetnsName:      "/var/run/netns/test",
+		Routes:         []LocalIPNet{{IP: route.IP.To16(), Mask: route.Mask}},
+		ContainerIps:   []LocalIP{{IP: net.ParseIP("10.0.0.1")}},
+		Mtu:            1450,
+		OrchestratorID: "k8s",
+		WorkloadID:     "default/test",
+		EndpointID:     "eth0",
+		HostPorts: []HostPortBinding{{
+			HostPort:      8080,
+			HostIP:        net.ParseIP("192.168.0.1"),
+			ContainerPort: 80,
+			Protocol:      types.TCP,
+			IP4Entries:    []HostPortEntry{{HostIP: net.ParseIP("192.168.0.1"), EntryID: 3}},
+		}},
+		IfPortConfigs:       []LocalIfPortConfigs{{Start: 1000, End: 2000, Proto: types.UDP}},
+		DefaultIfType:       VppIfTypeTunTap,
+		IngressBandwidth:    1000000,
+		IfSpec:              config.InterfaceSpec{NumRxQueues: 2, NumTxQueues: 1, IsL3: &config.True, RxMode: types.PollingRxMode},
+		PBLMemifSpec:        config.InterfaceSpec{NumRxQueues: 1, NumTxQueues: 1, IsL3: &config.False},
+		TunTapSwIfIndex:     5,
+		MemifSwIfIndex:      types.InvalidID,
+		LoopbackSwIfIndex:   6,
+		PblIndexes:          []uint32{},
+		VhostUserSwIfIndex:  types.InvalidID,
+		IngressPolicerIndex: 2,
+		EgressPolicerIndex:  types.InvalidID,
+		V4VrfId:             7,
+		NeedsSnat:           true,
+	}
+}
+
+var _ = Describe("CNI state file", func() {
+	var dir, fname string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cni-storage-test")
+		Expect(err).ToNot(HaveOccurred())
+		fname = filepath.Join(dir, "pod_state")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should load the persisted pod specs", func() {
+		podSpec := testPodSpec()
+		err := PersistCniServerState(map[string]LocalPodSpec{podSpec.Key(): podSpec}, fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fname + "~").ToNot(BeAnExistingFile())
+
+		specs, err := LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specs).To(Equal([]LocalPodSpec{podSpec}))
+
+		specs, err = LoadCniServerState(filepath.Join(dir, "missing"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specs).To(BeEmpty())
+	})
+
+	It("should ignore interrupted writes and reject corrupted files", func() {
+		podSpec := testPodSpec()
+		err := PersistCniServerState(map[string]LocalPodSpec{podSpec.Key(): podSpec}, fname)
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(fname+"~", []byte(`{"version":`), 0600)
+		Expect(err).ToNot(HaveOccurred())
+		specs, err := LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specs).To(HaveLen(1))
+
+		data, err := os.ReadFile(fname)
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(fname, bytes.Replace(data, []byte("default/test"), []byte("default/tEst"), 1), 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = LoadCniServerState(fname)
+		Expect(err).To(MatchError(ContainSubstring("Checksum mismatch")))
+
+		err = SetAsideCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fname).ToNot(BeAnExistingFile())
+		Expect(fname + ".corrupt").To(BeAnExistingFile())
+		specs, err = LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specs).To(BeEmpty())
+	})
+
+	It("should load files written by newer agents", func() {
+		specs := []byte(`[{"InterfaceName":"eth0","NetnsName":"/var/run/netns/test","NewField":42}]`)
+		data, err := json.Marshal(&SavedState{Version: CniServerStateFileVersion + 1, Checksum: checksum(specs), Specs: specs})
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(fname, data, 0600)
+		Expect(err).ToNot(HaveOccurred())
+		loaded, err := LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded).To(HaveLen(1))
+		Expect(loaded[0].Key()).To(Equal("netns:/var/run/netns/test,if:eth0"))
+	})
+
+	It("should record the hostPort entries per family", func() {
+		hostPort := HostPortBinding{HostPort: 8080, ContainerPort: 80, Protocol: types.TCP}
+		Expect(hostPort.BindsFamily(false)).To(BeTrue())
+		Expect(hostPort.BindsFamily(true)).To(BeTrue())
+		hostPort.HostIP = net.ParseIP("::")
+		Expect(hostPort.BindsFamily(false)).To(BeFalse())
+		Expect(hostPort.BindsFamily(true)).To(BeTrue())
+
+		hostPort.AddEntry(net.ParseIP("192.168.0.1"), 1)
+		hostPort.AddEntry(net.ParseIP("fd00::1"), 2)
+		hostPort.AddEntry(net.ParseIP("fd00::2"), 3)
+		Expect(hostPort.IP4Entries).To(HaveLen(1))
+		Expect(hostPort.IP6Entries).To(HaveLen(2))
+		Expect(hostPort.GetEntries()).To(HaveLen(3))
+		Expect(hostPort.String()).To(Equal("TCP :::8080 cport=80 192.168.0.1=1 fd00::1=2 fd00::2=3"))
+	})
+
+	It("should migrate version 9 files", func() {
+		podSpec := testPodSpec()
+		data, err := json.Marshal([]LocalPodSpec{podSpec})
+		Expect(err).ToNot(HaveOccurred())
+		var specs []map[string]interface{}
+		err = json.Unmarshal(data, &specs)
+		Expect(err).ToNot(HaveOccurred())
+		delete(specs[0], "VhostUserSwIfIndex")
+		delete(specs[0], "VhostUserSocket")
+		hostPort := specs[0]["HostPorts"].([]interface{})[0].(map[string]interface{})
+		delete(hostPort, "IP4Entries")
+		delete(hostPort, "IP6Entries")
+		hostPort["EntryID"] = 3
+		data, err = json.Marshal(specs)
+		Expect(err).ToNot(HaveOccurred())
+		data, err = json.Marshal(&SavedState{Version: 9, Checksum: checksum(data), Specs: data})
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(fname, data, 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		loaded, err := LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded).To(Equal([]LocalPodSpec{podSpec}))
+	})
+
+	It("should migrate version 8 files", func() {
+		podSpec := testPodSpec()
+		legacy := podSpecV8{
+			InterfaceName:     podSpec.InterfaceName,
+			NetnsName:         podSpec.NetnsName,
+			Routes:            []legacyIPNet{{IP: podSpec.Routes[0].IP, Mask: podSpec.Routes[0].Mask}},
+			ContainerIps:      []legacyIP{{IP: podSpec.ContainerIps[0].IP}},
+			Mtu:               podSpec.Mtu,
+			OrchestratorID:    podSpec.OrchestratorID,
+			WorkloadID:        podSpec.WorkloadID,
+			EndpointID:        podSpec.EndpointID,
+			HostPorts:         []legacyHostPortBinding{{HostPort: 8080, HostIP: net.ParseIP("192.168.0.1"), ContainerPort: 80, EntryID: 3, Protocol: uint8(types.TCP)}},
+			IfPortConfigs:     []legacyIfPortConfigs{{Start: 1000, End: 2000, Proto: uint8(types.UDP)}},
+			DefaultIfType:     uint8(VppIfTypeTunTap),
+			IfSpec:            legacyInterfaceSpec{NumRxQueues: 2, NumTxQueues: 1, IsL3: &config.True, RxMode: uint32(types.PollingRxMode)},
+			PBLMemifSpec:      legacyInterfaceSpec{NumRxQueues: 1, NumTxQueues: 1, IsL3: &config.False},
+			TunTapSwIfIndex:   podSpec.TunTapSwIfIndex,
+			MemifSwIfIndex:    podSpec.MemifSwIfIndex,
+			LoopbackSwIfIndex: podSpec.LoopbackSwIfIndex,
+			PblIndexes:        []uint32{},
+			V4VrfId:           podSpec.V4VrfId,
+			NeedsSnat:         podSpec.NeedsSnat,
+		}
+		state := &savedStateV8{Version: 8, Specs: []podSpecV8{legacy}}
+		var buf bytes.Buffer
+		err := struc.Pack(&buf, state)
+		Expect(err).ToNot(HaveOccurred())
+		err = os.WriteFile(fname+"8", buf.Bytes(), 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		specs, err := LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		podSpec.IngressBandwidth = 0
+		podSpec.IngressPolicerIndex = types.InvalidID
+		Expect(specs).To(Equal([]LocalPodSpec{podSpec}))
+
+		err = PersistCniServerState(map[string]LocalPodSpec{podSpec.Key(): specs[0]}, fname)
+		Expect(err).ToNot(HaveOccurred())
+		err = RemoveLegacyCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fname + "8").ToNot(BeAnExistingFile())
+		specs, err = LoadCniServerState(fname)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specs).To(Equal([]LocalPodSpec{podSpec}))
+	})
+})
@@ -40,6 +40,8 @@ const (
 	PodAdded   CalicoVppEventType = "PodAdded"
 	PodDeleted CalicoVppEventType = "PodDeleted"
 
+	PodAnnotationsChanged CalicoVppEventType = "PodAnnotationsChanged"
+
 	LocalPodAddressAdded   CalicoVppEventType = "LocalPodAddressAdded"
 	LocalPodAddressDeleted CalicoVppEventType = "LocalPodAddressDeleted"
 
@@ -68,6 +70,11 @@ const (
 	IpamPoolRemove CalicoVppEventType = "IpamPoolRemove"
 
 	WireguardPublicKeyChanged CalicoVppEventType = "WireguardPublicKeyChanged"
+
+	PolicyRulesUpdated  CalicoVppEventType = "PolicyRulesUpdated"
+	PolicyDriftDetected CalicoVppEventType = "PolicyDriftDetected"
+
+	ServiceDrainingBackendsUpdated CalicoVppEventType = "ServiceDrainingBackendsUpdated"
 )
 
 var (
@@ -0,0 +1,147 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"github.com/pkg/errors"
+	"github.com/projectcalico/calico/felix/proto"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// Policies in audit mode do not enforce their rules: capo counts a matching
+// rule, and the evaluation continues with the following rules, as for a pass
+// preceded by a log rule. Rules keep their action, the mode is set on the
+// capo policy.
+// Felix only sends the annotations of the rules, so a policy is in audit mode
+// when one of its rules has the audit annotation, or when the PolicyAuditEnabled
+// feature gate is set. A policy without rules cannot be audited with the
+// annotation. The matching packets are punted by capo, and logged with the
+// action the rule would have enforced when flow logs are enabled.
+
+const (
+	policyModeAnnotation = "cni.projectcalico.org/vppPolicyMode"
+	policyModeAudit      = "audit"
+)
+
+// isAuditedProtoPolicy tells whether the policy received from felix is in audit mode
+func isAuditedProtoPolicy(p *proto.Policy) bool {
+	if *config.GetCalicoVppFeatureGates().PolicyAuditEnabled {
+		return true
+	}
+	for _, r := range append(append([]*proto.Rule{}, p.InboundRules...), p.OutboundRules...) {
+		if r.GetMetadata().GetAnnotations()[policyModeAnnotation] == policyModeAudit {
+			return true
+		}
+	}
+	return false
+}
+
+// setAuditMode sets the mode of the policy in VPP, policies being created
+// in enforce mode
+func (p *Policy) setAuditMode(vpp *vpplink.VppLink) error {
+	err := vpp.PolicySetAudit(p.VppID, p.Audit)
+	if err != nil {
+		return errors.Wrapf(err, "cannot set audit mode of policy %d", p.VppID)
+	}
+	return nil
+}
+
+// newAuditPassPolicy returns a policy passing all the traffic to the profiles.
+// It terminates the policies of endpoints whose policies are all audited, so
+// that they do not drop the traffic the audited policies do not match.
+func newAuditPassPolicy() *Policy {
+	return &Policy{
+		Policy: &types.Policy{},
+		VppID:  types.InvalidID,
+		InboundRules: []*Rule{{
+			VppID:  types.InvalidID,
+			RuleID: "calicovpp-internal-auditpass",
+			Rule:   &types.Rule{Action: types.ActionPass},
+		}},
+		OutboundRules: []*Rule{{
+			VppID:  types.InvalidID,
+			RuleID: "calicovpp-internal-auditpass",
+			Rule:   &types.Rule{Action: types.ActionPass},
+		}},
+	}
+}
+
+func (s *Server) createAuditPassPolicy() error {
+	auditPassPolicy := newAuditPassPolicy()
+	err := auditPassPolicy.Create(s.vpp, &PolicyState{})
+	if err != nil {
+		return errors.Wrap(err, "cannot create audit pass policy")
+	}
+	s.auditPassPolicy = auditPassPolicy
+	return nil
+}
+
+// onlyAuditedPolicies tells whether the tiers have policies in this direction,
+// all of them being in audit mode
+func onlyAuditedPolicies(state *PolicyState, tiers []Tier, ingress bool, network string) bool {
+	found := false
+	for _, tier := range tiers {
+		names := tier.EgressPolicies
+		if ingress {
+			names = tier.IngressPolicies
+		}
+		for _, name := range names {
+			pol, ok := state.Policies[PolicyID{Tier: tier.Name, Name: name, Network: network}]
+			if !ok || !pol.Audit {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// withAuditPass terminates the policies of the endpoint with the audit pass
+// policy when all of them are audited
+func (s *Server) withAuditPass(conf *types.InterfaceConfig, state *PolicyState, tiers []Tier, network string) {
+	if onlyAuditedPolicies(state, tiers, true /* ingress */, network) {
+		conf.IngressPolicyIDs = append(conf.IngressPolicyIDs, s.auditPassPolicy.VppID)
+	}
+	if onlyAuditedPolicies(state, tiers, false /* ingress */, network) {
+		conf.EgressPolicyIDs = append(conf.EgressPolicyIDs, s.auditPassPolicy.VppID)
+	}
+}
+
+// reconfigureEndpoints configures the policies of all the endpoints again, as
+// the audit pass policy depends on the mode of their policies
+func (s *Server) reconfigureEndpoints(state *PolicyState) error {
+	s.endpointsLock.Lock()
+	defer s.endpointsLock.Unlock()
+	for id, wep := range state.WorkloadEndpoints {
+		if len(wep.SwIfIndex) == 0 {
+			continue
+		}
+		err := wep.Update(s.vpp, wep, state, id.Network)
+		if err != nil {
+			return errors.Wrapf(err, "cannot reconfigure workload endpoint %s", id.String())
+		}
+	}
+	for _, hep := range state.HostEndpoints {
+		err := hep.Update(s.vpp, hep, state)
+		if err != nil {
+			return errors.Wrapf(err, "cannot reconfigure host endpoint %s", hep.InterfaceName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"net"
+
+	"github.com/projectcalico/calico/felix/proto"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testAuditPassID     = 4
+	testAllowFromHostID = 5
+)
+
+func auditedProtoRule(action, ruleID string, dstPort int32) *proto.Rule {
+	rule := tcpProtoRule(action, ruleID, dstPort)
+	rule.Metadata = &proto.RuleMetadata{Annotations: map[string]string{policyModeAnnotation: policyModeAudit}}
+	return rule
+}
+
+// newAuditTestState returns a state with a workload endpoint whose only
+// ingress policy is audited and denies tcp/80, and whose profile allows all
+func newAuditTestState() (*PolicyState, *WorkloadEndpoint) {
+	state := NewPolicyState()
+	policy, err := fromProtoPolicy(&proto.Policy{InboundRules: []*proto.Rule{auditedProtoRule("deny", "rule-deny", 80)}}, "")
+	Expect(err).ToNot(HaveOccurred())
+	policy.VppID = 10
+	policy.InboundRules[0].VppID = 20
+	state.Policies[PolicyID{Tier: "default", Name: "audited"}] = policy
+	profile, err := fromProtoProfile(&proto.Profile{InboundRules: []*proto.Rule{{Action: "allow"}}})
+	Expect(err).ToNot(HaveOccurred())
+	profile.VppID = 11
+	state.Profiles["kns.default"] = profile
+	wep := &WorkloadEndpoint{
+		Tiers:    []Tier{{Name: "default", IngressPolicies: []string{"audited"}}},
+		Profiles: []string{"kns.default"},
+		server: &Server{
+			AllowFromHostPolicy: &Policy{VppID: testAllowFromHostID},
+			auditPassPolicy:     &Policy{VppID: testAuditPassID},
+		},
+	}
+	return state, wep
+}
+
+var _ = Describe("Policy audit mode", func() {
+	It("should put annotated policies in audit mode", func() {
+		state, _ := newAuditTestState()
+		policy := state.Policies[PolicyID{Tier: "default", Name: "audited"}]
+		Expect(policy.Audit).To(BeTrue())
+		Expect(policy.InboundRules[0].Action).To(Equal(types.ActionDeny))
+		Expect(state.RuleLabels()[policy.InboundRules[0].VppID].AuditAction).To(Equal("deny"))
+
+		enforced, err := fromProtoPolicy(&proto.Policy{InboundRules: []*proto.Rule{tcpProtoRule("deny", "rule-deny", 80)}}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(enforced.Audit).To(BeFalse())
+		Expect(enforced.InboundRules[0].Action).To(Equal(types.ActionDeny))
+
+		config.GetCalicoVppFeatureGates().PolicyAuditEnabled = &config.True
+		defer func() { config.GetCalicoVppFeatureGates().PolicyAuditEnabled = &config.False }()
+		audited, err := fromProtoPolicy(&proto.Policy{InboundRules: []*proto.Rule{tcpProtoRule("deny", "rule-deny", 80)}}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(audited.Audit).To(BeTrue())
+	})
+
+	It("should not apply the default deny to endpoints with only audited policies", func() {
+		state, wep := newAuditTestState()
+		conf, err := wep.getPolicies(state, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(Equal([]uint32{testAllowFromHostID, 10, testAuditPassID}))
+		Expect(conf.EgressPolicyIDs).To(BeEmpty())
+
+		flow := &Flow{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.1.2"), Proto: types.TCP, SrcPort: 1234, DstPort: 80}
+		verdict, err := wep.Evaluate(state, "", flow, true /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionAllow))
+		ref := RuleRef{Tier: "default", Policy: "audited", RuleID: "rule-deny"}
+		Expect(verdict.Logged).To(BeEmpty())
+		Expect(verdict.Audited).To(Equal(map[RuleRef]types.RuleAction{ref: types.ActionDeny}))
+
+		// an enforced policy brings the default deny back
+		state.Policies[PolicyID{Tier: "default", Name: "enforced"}] = &Policy{Policy: &types.Policy{}, VppID: 12}
+		wep.Tiers[0].IngressPolicies = append(wep.Tiers[0].IngressPolicies, "enforced")
+		conf, err = wep.getPolicies(state, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(Equal([]uint32{testAllowFromHostID, 10, 12}))
+		verdict, err = wep.Evaluate(state, "", flow, true /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionDeny))
+	})
+})
@@ -0,0 +1,92 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strconv"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// RuleLabels identifies the calico rule a capo rule was created for,
+// it is used to label the capo rule counters
+type RuleLabels struct {
+	RuleRef
+	Network string
+	// Direction is ingress or egress, from the endpoint point of view
+	Direction string
+	// AuditAction is the action of the rule when its policy is in audit
+	// mode, empty otherwise
+	AuditAction string
+}
+
+func addRuleLabels(labels map[uint32]RuleLabels, ref RuleRef, network string, policy *Policy) {
+	for _, rules := range []struct {
+		direction string
+		rules     []*Rule
+	}{
+		{"ingress", policy.InboundRules},
+		{"egress", policy.OutboundRules},
+	} {
+		for _, rule := range rules.rules {
+			if rule.VppID == types.InvalidID {
+				continue
+			}
+			ruleRef := ref
+			ruleRef.RuleID = rule.RuleID
+			if ruleRef.RuleID == "" {
+				// Profile rules have no ID, tell them apart by their position
+				ruleRef.RuleID = strconv.Itoa(rule.Index)
+			}
+			ruleLabels := RuleLabels{RuleRef: ruleRef, Network: network, Direction: rules.direction}
+			if policy.Audit {
+				ruleLabels.AuditAction = rule.Action.String()
+			}
+			labels[rule.VppID] = ruleLabels
+		}
+	}
+}
+
+// RuleLabels returns the labels of all the capo rules created for the
+// policies and profiles in the state, indexed by capo rule ID
+func (s *PolicyState) RuleLabels() map[uint32]RuleLabels {
+	labels := make(map[uint32]RuleLabels)
+	for id, policy := range s.Policies {
+		addRuleLabels(labels, RuleRef{Tier: id.Tier, Policy: id.Name}, id.Network, policy)
+	}
+	for name, profile := range s.Profiles {
+		addRuleLabels(labels, RuleRef{Profile: name}, "", profile)
+	}
+	return labels
+}
+
+// publishRuleLabels sends the labels of the configured capo rules to the
+// prometheus server, if the policies or profiles changed since last time
+func (s *Server) publishRuleLabels() {
+	if !s.policyRulesChanged {
+		return
+	}
+	s.policyRulesChanged = false
+	if !*config.GetCalicoVppFeatureGates().PrometheusEnabled {
+		return
+	}
+	common.SendEvent(common.CalicoVppEvent{
+		Type: common.PolicyRulesUpdated,
+		New:  s.configuredState.RuleLabels(),
+	})
+}
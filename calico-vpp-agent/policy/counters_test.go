@@ -0,0 +1,71 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"github.com/projectcalico/calico/felix/proto"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func ruleWithIDs(ruleID string, vppID uint32) *Rule {
+	return &Rule{Rule: &types.Rule{}, RuleID: ruleID, VppID: vppID}
+}
+
+var _ = Describe("Rule counters labels", func() {
+	It("should map capo rules to the calico rules", func() {
+		state := NewPolicyState()
+		state.Policies[PolicyID{Tier: "default", Name: "web"}] = &Policy{
+			Policy:        &types.Policy{},
+			InboundRules:  []*Rule{ruleWithIDs("rule-in", 20), ruleWithIDs("rule-in", 21)},
+			OutboundRules: []*Rule{ruleWithIDs("rule-out", 22), ruleWithIDs("not-created", types.InvalidID)},
+		}
+		state.Policies[PolicyID{Tier: "default", Name: "web", Network: "blue"}] = &Policy{
+			Policy:       &types.Policy{},
+			InboundRules: []*Rule{ruleWithIDs("rule-in", 30)},
+		}
+		state.Profiles["kns.default"] = &Policy{
+			Policy:       &types.Policy{},
+			InboundRules: []*Rule{ruleWithIDs("", 40), ruleWithIDs("", 41)},
+		}
+		state.Profiles["kns.default"].InboundRules[1].Index = 1
+
+		labels := state.RuleLabels()
+		Expect(labels).To(HaveLen(6))
+		Expect(labels[20]).To(Equal(RuleLabels{RuleRef: RuleRef{Tier: "default", Policy: "web", RuleID: "rule-in"}, Direction: "ingress"}))
+		Expect(labels[21]).To(Equal(labels[20]))
+		Expect(labels[22]).To(Equal(RuleLabels{RuleRef: RuleRef{Tier: "default", Policy: "web", RuleID: "rule-out"}, Direction: "egress"}))
+		Expect(labels[30].Network).To(Equal("blue"))
+		// rules without ID are labeled with their position
+		Expect(labels[40]).To(Equal(RuleLabels{RuleRef: RuleRef{Profile: "kns.default", RuleID: "0"}, Direction: "ingress"}))
+		Expect(labels[41].RuleID).To(Equal("1"))
+	})
+
+	It("should not mark rules as changed for the pending state", func() {
+		server := newTestServer()
+		server.pendingState = NewPolicyState()
+		err := server.handleActiveProfileUpdate(&proto.ActiveProfileUpdate{
+			Id:      &proto.ProfileID{Name: "kns.default"},
+			Profile: &proto.Profile{InboundRules: []*proto.Rule{allowProtoRule("rule-in")}},
+		}, true /* pending */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.pendingState.Profiles).To(HaveKey("kns.default"))
+		Expect(server.policyRulesChanged).To(BeFalse())
+	})
+})
@@ -0,0 +1,532 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// DriftKind identifies a kind of difference between the capo objects
+// configured in VPP and the policy server state
+type DriftKind struct {
+	// Object is ipset, rule, policy, interface or stages
+	Object string
+	// Drift is missing (expected but absent from VPP), unknown (present in
+	// VPP but not expected) or mismatched (present in VPP with a different content)
+	Drift string
+}
+
+// PolicyDrift lists the capo objects, by VPP ID (sw_if_index for interfaces),
+// that differ between VPP and the policy server state
+type PolicyDrift struct {
+	MissingIPSets    []uint32
+	UnknownIPSets    []uint32
+	MismatchedIPSets []uint32
+
+	MissingRules    []uint32
+	UnknownRules    []uint32
+	MismatchedRules []uint32
+
+	MissingPolicies    []uint32
+	UnknownPolicies    []uint32
+	MismatchedPolicies []uint32
+
+	MismatchedInterfaces []uint32
+	MismatchedStages     []uint32
+}
+
+func (d *PolicyDrift) kinds() map[DriftKind]*[]uint32 {
+	return map[DriftKind]*[]uint32{
+		{"ipset", "missing"}:        &d.MissingIPSets,
+		{"ipset", "unknown"}:        &d.UnknownIPSets,
+		{"ipset", "mismatched"}:     &d.MismatchedIPSets,
+		{"rule", "missing"}:         &d.MissingRules,
+		{"rule", "unknown"}:         &d.UnknownRules,
+		{"rule", "mismatched"}:      &d.MismatchedRules,
+		{"policy", "missing"}:       &d.MissingPolicies,
+		{"policy", "unknown"}:       &d.UnknownPolicies,
+		{"policy", "mismatched"}:    &d.MismatchedPolicies,
+		{"interface", "mismatched"}: &d.MismatchedInterfaces,
+		{"stages", "mismatched"}:    &d.MismatchedStages,
+	}
+}
+
+// Counts returns the number of drifted objects for every kind of drift,
+// including the kinds without drift
+func (d *PolicyDrift) Counts() map[DriftKind]int {
+	counts := make(map[DriftKind]int)
+	for kind, ids := range d.kinds() {
+		counts[kind] = len(*ids)
+	}
+	return counts
+}
+
+func (d *PolicyDrift) Empty() bool {
+	for _, ids := range d.kinds() {
+		if len(*ids) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *PolicyDrift) String() string {
+	s := "["
+	s += types.IntListToString(" missing-ipsets=", d.MissingIPSets)
+	s += types.IntListToString(" unknown-ipsets=", d.UnknownIPSets)
+	s += types.IntListToString(" mismatched-ipsets=", d.MismatchedIPSets)
+	s += types.IntListToString(" missing-rules=", d.MissingRules)
+	s += types.IntListToString(" unknown-rules=", d.UnknownRules)
+	s += types.IntListToString(" mismatched-rules=", d.MismatchedRules)
+	s += types.IntListToString(" missing-policies=", d.MissingPolicies)
+	s += types.IntListToString(" unknown-policies=", d.UnknownPolicies)
+	s += types.IntListToString(" mismatched-policies=", d.MismatchedPolicies)
+	s += types.IntListToString(" mismatched-interfaces=", d.MismatchedInterfaces)
+	s += types.IntListToString(" mismatched-stages=", d.MismatchedStages)
+	return s + " ]"
+}
+
+func (d *PolicyDrift) sort() {
+	for _, ids := range d.kinds() {
+		sort.Slice(*ids, func(i, j int) bool { return (*ids)[i] < (*ids)[j] })
+	}
+}
+
+// expectedPolicy is a policy the policy server configured in VPP, along with
+// the state its rules ipsets are resolved from
+type expectedPolicy struct {
+	policy *Policy
+	state  *PolicyState
+}
+
+type expectedInterface struct {
+	conf       *types.InterfaceConfig
+	invertRxTx bool
+	// stages is nil on the interfaces without stages
+	stages *types.StagesConfig
+}
+
+// expectedCapoState is the capo configuration that the policy server state
+// translates to, indexed by VPP ID
+type expectedCapoState struct {
+	ipsets     map[uint32]*IPSet
+	policies   map[uint32]*expectedPolicy
+	rules      map[uint32]*Rule
+	interfaces map[uint32]*expectedInterface
+	// rulePolicies is the policy each rule belongs to
+	rulePolicies map[uint32]*expectedPolicy
+}
+
+// internalPolicyState is the state the ipsets of the policy server internal
+// policies are resolved from
+func (s *Server) internalPolicyState() *PolicyState {
+	return &PolicyState{IPSets: map[string]*IPSet{"calico-vpp-wep-addr-ipset": s.allPodsIpset}}
+}
+
+// expectedPolicies returns the policies, profiles and internal policies
+// configured by the policy server
+func (s *Server) expectedPolicies() []*expectedPolicy {
+	policies := make([]*expectedPolicy, 0, len(s.configuredState.Policies)+len(s.configuredState.Profiles)+7)
+	for _, policy := range s.configuredState.Policies {
+		policies = append(policies, &expectedPolicy{policy: policy, state: s.configuredState})
+	}
+	for _, profile := range s.configuredState.Profiles {
+		policies = append(policies, &expectedPolicy{policy: profile, state: s.configuredState})
+	}
+	internalState := s.internalPolicyState()
+	for _, policy := range []*Policy{
+		s.failSafePolicy,
+		s.failSafeReplyPolicy,
+		s.workloadsToHostPolicy,
+		s.allowAllPolicy,
+		s.AllowFromHostPolicy,
+		s.allowToHostPolicy,
+		s.auditPassPolicy,
+	} {
+		if policy != nil {
+			policies = append(policies, &expectedPolicy{policy: policy, state: internalState})
+		}
+	}
+	return policies
+}
+
+// expectedInterfaces returns the policies configuration of the interfaces of
+// the workload and host endpoints, and of the host taps. Endpoints whose
+// policies cannot be resolved are skipped, as they are not configured either.
+func (s *Server) expectedInterfaces(tapSwIfIndexes []uint32) map[uint32]*expectedInterface {
+	interfaces := make(map[uint32]*expectedInterface)
+	for _, swIfIndex := range tapSwIfIndexes {
+		conf := types.NewInterfaceConfig()
+		conf.IngressPolicyIDs = s.defaultTap0IngressConf
+		interfaces[swIfIndex] = &expectedInterface{conf: conf}
+	}
+	for _, hep := range s.configuredState.HostEndpoints {
+		forwardConf, err := hep.getForwardPolicies(s.configuredState)
+		if err != nil {
+			s.log.Debugf("Skipping host endpoint %s in drift detection: %s", hep.InterfaceName, err)
+			continue
+		}
+		stagesConf, err := hep.getStages(s.configuredState)
+		if err != nil {
+			s.log.Debugf("Skipping host endpoint %s in drift detection: %s", hep.InterfaceName, err)
+			continue
+		}
+		tapConf, err := hep.getTapPolicies(s.configuredState)
+		if err != nil {
+			s.log.Debugf("Skipping host endpoint %s in drift detection: %s", hep.InterfaceName, err)
+			continue
+		}
+		for _, swIfIndex := range append(append([]uint32{}, hep.UplinkSwIfIndexes...), hep.TunnelSwIfIndexes...) {
+			interfaces[swIfIndex] = &expectedInterface{conf: forwardConf, invertRxTx: true, stages: stagesConf}
+		}
+		for _, swIfIndex := range hep.TapSwIfIndexes {
+			interfaces[swIfIndex] = &expectedInterface{conf: tapConf}
+		}
+	}
+	for id, wep := range s.configuredState.WorkloadEndpoints {
+		if len(wep.SwIfIndex) == 0 {
+			continue
+		}
+		conf, err := wep.getPolicies(s.configuredState, id.Network)
+		if err != nil {
+			s.log.Debugf("Skipping workload endpoint %s in drift detection: %s", id.String(), err)
+			continue
+		}
+		for _, swIfIndex := range wep.SwIfIndex {
+			interfaces[swIfIndex] = &expectedInterface{conf: conf}
+		}
+	}
+	return interfaces
+}
+
+// expectedCapoState returns the capo configuration expected from the policy server state
+func (s *Server) expectedCapoState(tapSwIfIndexes []uint32) *expectedCapoState {
+	expected := &expectedCapoState{
+		ipsets:       make(map[uint32]*IPSet),
+		policies:     make(map[uint32]*expectedPolicy),
+		rules:        make(map[uint32]*Rule),
+		rulePolicies: make(map[uint32]*expectedPolicy),
+		interfaces:   s.expectedInterfaces(tapSwIfIndexes),
+	}
+	for _, ipset := range s.configuredState.IPSets {
+		if ipset.VppID != types.InvalidID {
+			expected.ipsets[ipset.VppID] = ipset
+		}
+	}
+	if s.allPodsIpset != nil {
+		expected.ipsets[s.allPodsIpset.VppID] = s.allPodsIpset
+	}
+	for _, policy := range s.expectedPolicies() {
+		if policy.policy.VppID == types.InvalidID {
+			continue
+		}
+		expected.policies[policy.policy.VppID] = policy
+		for _, rule := range append(append([]*Rule{}, policy.policy.InboundRules...), policy.policy.OutboundRules...) {
+			if rule.VppID != types.InvalidID {
+				expected.rules[rule.VppID] = rule
+				expected.rulePolicies[rule.VppID] = policy
+			}
+		}
+	}
+	return expected
+}
+
+// capoMembers returns the members of the ipset, formatted as by types.FormatCapoIPSetMember
+func (i *IPSet) capoMembers() map[string]bool {
+	members := make(map[string]bool)
+	switch i.Type {
+	case types.IpsetTypeIP:
+		for _, addr := range i.Addresses {
+			members[addr.String()] = true
+		}
+	case types.IpsetTypeIPPort:
+		for _, ipp := range i.IPPorts {
+			members[types.FormatCapoIPPort(ipp)] = true
+		}
+	case types.IpsetTypeNet:
+		for _, n := range i.Networks {
+			members[n.String()] = true
+		}
+	}
+	return members
+}
+
+func equalIDs(a, b []uint32) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (e *expectedInterface) matches(intf *types.CapoInterfaceDump) bool {
+	if intf == nil {
+		return len(e.conf.IngressPolicyIDs) == 0 && len(e.conf.EgressPolicyIDs) == 0 && len(e.conf.ProfileIDs) == 0
+	}
+	// Policies are expressed from the point of view of the endpoints,
+	// as in ConfigurePolicies
+	return intf.InvertRxTx == e.invertRxTx &&
+		equalIDs(intf.RxPolicyIDs, e.conf.EgressPolicyIDs) &&
+		equalIDs(intf.TxPolicyIDs, e.conf.IngressPolicyIDs) &&
+		equalIDs(intf.ProfileIDs, e.conf.ProfileIDs)
+}
+
+// stagesMatch compares the expected stages with the dumped ones. As capo forgets
+// the stages without policies, they match a missing dump.
+func (e *expectedInterface) stagesMatch(stages *types.CapoStagesDump) bool {
+	conf := e.stages
+	if conf == nil {
+		conf = types.NewStagesConfig()
+	}
+	if stages == nil {
+		return len(conf.UntrackedIngressPolicyIDs) == 0 && len(conf.UntrackedEgressPolicyIDs) == 0 && len(conf.PreDnatPolicyIDs) == 0
+	}
+	// rx and tx are reversed, as in ConfigureStages
+	return stages.InvertRxTx == e.invertRxTx &&
+		equalIDs(stages.UntrackedRxPolicyIDs, conf.UntrackedEgressPolicyIDs) &&
+		equalIDs(stages.UntrackedTxPolicyIDs, conf.UntrackedIngressPolicyIDs) &&
+		equalIDs(stages.PreDnatPolicyIDs, conf.PreDnatPolicyIDs)
+}
+
+// detectDrift compares the capo configuration dumped from VPP with the expected one.
+// Only the interfaces of known endpoints are compared, as the policies of other
+// interfaces are not managed by the policy server.
+func detectDrift(dump *types.CapoDump, expected *expectedCapoState) *PolicyDrift {
+	drift := &PolicyDrift{}
+	for id, ipset := range expected.ipsets {
+		dumped, ok := dump.IPSets[id]
+		if !ok {
+			drift.MissingIPSets = append(drift.MissingIPSets, id)
+		} else if dumped.Type != ipset.Type || !reflect.DeepEqual(dumped.Members, ipset.capoMembers()) {
+			drift.MismatchedIPSets = append(drift.MismatchedIPSets, id)
+		}
+	}
+	for id := range dump.IPSets {
+		if _, ok := expected.ipsets[id]; !ok {
+			drift.UnknownIPSets = append(drift.UnknownIPSets, id)
+		}
+	}
+	for id, rule := range expected.rules {
+		dumped, ok := dump.Rules[id]
+		if !ok {
+			drift.MissingRules = append(drift.MissingRules, id)
+		} else if !reflect.DeepEqual(dumped.Rule, types.NormalizeCapoRule(rule.Rule)) {
+			drift.MismatchedRules = append(drift.MismatchedRules, id)
+		}
+	}
+	for id := range dump.Rules {
+		if _, ok := expected.rules[id]; !ok {
+			drift.UnknownRules = append(drift.UnknownRules, id)
+		}
+	}
+	for id, policy := range expected.policies {
+		dumped, ok := dump.Policies[id]
+		if !ok {
+			drift.MissingPolicies = append(drift.MissingPolicies, id)
+		} else if !equalIDs(dumped.TxRuleIDs, policy.policy.InboundRuleIDs) ||
+			!equalIDs(dumped.RxRuleIDs, policy.policy.OutboundRuleIDs) ||
+			dumped.Audit != policy.policy.Audit {
+			// Inbound rules are applied in the tx direction, see ToCapoPolicy
+			drift.MismatchedPolicies = append(drift.MismatchedPolicies, id)
+		}
+	}
+	for id := range dump.Policies {
+		if _, ok := expected.policies[id]; !ok {
+			drift.UnknownPolicies = append(drift.UnknownPolicies, id)
+		}
+	}
+	for swIfIndex, intf := range expected.interfaces {
+		if !intf.matches(dump.Interfaces[swIfIndex]) {
+			drift.MismatchedInterfaces = append(drift.MismatchedInterfaces, swIfIndex)
+		}
+		if !intf.stagesMatch(dump.Stages[swIfIndex]) {
+			drift.MismatchedStages = append(drift.MismatchedStages, swIfIndex)
+		}
+	}
+	drift.sort()
+	return drift
+}
+
+func ruleUsesIPSet(rule *types.Rule, ipsetID uint32) bool {
+	for _, ids := range [][]uint32{
+		rule.DstIPPortIPSet, rule.DstNotIPPortIPSet, rule.SrcIPPortIPSet, rule.SrcNotIPPortIPSet,
+		rule.DstIPSet, rule.DstNotIPSet, rule.SrcIPSet, rule.SrcNotIPSet,
+	} {
+		for _, id := range ids {
+			if id == ipsetID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// repairDrift recreates the missing and mismatched objects, reconfigures the
+// interfaces using them, and finally deletes the stale and unknown objects,
+// so that no object is deleted while still in use.
+func (s *Server) repairDrift(drift *PolicyDrift, dump *types.CapoDump, expected *expectedCapoState, tapSwIfIndexes []uint32) (err error) {
+	staleIPSets := append([]uint32{}, drift.UnknownIPSets...)
+	staleRules := append([]uint32{}, drift.UnknownRules...)
+	stalePolicies := append([]uint32{}, drift.UnknownPolicies...)
+
+	rebuilt := make(map[*Policy]*expectedPolicy)
+	for _, id := range append(append([]uint32{}, drift.MissingIPSets...), drift.MismatchedIPSets...) {
+		ipset := expected.ipsets[id]
+		err = ipset.Create(s.vpp)
+		if err != nil {
+			return errors.Wrapf(err, "cannot recreate ipset %d", id)
+		}
+		if _, ok := dump.IPSets[id]; ok {
+			staleIPSets = append(staleIPSets, id)
+		}
+		for ruleID, rule := range expected.rules {
+			if ruleUsesIPSet(rule.Rule, id) {
+				policy := expected.rulePolicies[ruleID]
+				rebuilt[policy.policy] = policy
+			}
+		}
+	}
+	for _, id := range append(append([]uint32{}, drift.MissingRules...), drift.MismatchedRules...) {
+		policy := expected.rulePolicies[id]
+		rebuilt[policy.policy] = policy
+	}
+	for _, id := range append(append([]uint32{}, drift.MissingPolicies...), drift.MismatchedPolicies...) {
+		policy := expected.policies[id]
+		rebuilt[policy.policy] = policy
+	}
+
+	for _, policy := range rebuilt {
+		oldID := policy.policy.VppID
+		oldRuleIDs := append(append([]uint32{}, policy.policy.InboundRuleIDs...), policy.policy.OutboundRuleIDs...)
+		err = policy.policy.Create(s.vpp, policy.state)
+		if err != nil {
+			return errors.Wrapf(err, "cannot recreate policy %d", oldID)
+		}
+		if _, ok := dump.Policies[oldID]; ok {
+			stalePolicies = append(stalePolicies, oldID)
+		}
+		for _, ruleID := range oldRuleIDs {
+			if _, ok := dump.Rules[ruleID]; ok {
+				staleRules = append(staleRules, ruleID)
+			}
+		}
+		s.policyRulesChanged = true
+	}
+	if len(rebuilt) > 0 && s.workloadsToHostPolicy != nil && s.allowAllPolicy != nil {
+		s.defaultTap0IngressConf = []uint32{s.workloadsToHostPolicy.VppID, s.allowAllPolicy.VppID}
+	}
+	for _, hep := range s.configuredState.HostEndpoints {
+		forwardConf, err := hep.getForwardPolicies(s.configuredState)
+		if err == nil {
+			hep.currentForwardConf = forwardConf
+		}
+		stagesConf, err := hep.getStages(s.configuredState)
+		if err == nil {
+			hep.currentStagesConf = stagesConf
+		}
+	}
+
+	// Policy IDs may have changed, so the expected configuration is recomputed
+	for swIfIndex, intf := range s.expectedInterfaces(tapSwIfIndexes) {
+		var invertRxTx uint8
+		if intf.invertRxTx {
+			invertRxTx = 1
+		}
+		if !intf.matches(dump.Interfaces[swIfIndex]) {
+			s.log.Infof("policy(drift) interface swif=%d conf=%v", swIfIndex, intf.conf)
+			err = s.vpp.ConfigurePolicies(swIfIndex, intf.conf, invertRxTx)
+			if err != nil {
+				return errors.Wrapf(err, "cannot configure policies on interface %d", swIfIndex)
+			}
+		}
+		if !intf.stagesMatch(dump.Stages[swIfIndex]) {
+			stagesConf := intf.stages
+			if stagesConf == nil {
+				stagesConf = types.NewStagesConfig()
+			}
+			s.log.Infof("policy(drift) interface swif=%d stages=%v", swIfIndex, stagesConf)
+			err = s.vpp.ConfigureStages(swIfIndex, stagesConf, invertRxTx)
+			if err != nil {
+				return errors.Wrapf(err, "cannot configure stages on interface %d", swIfIndex)
+			}
+		}
+	}
+
+	err = s.vpp.PoliciesDelete(stalePolicies)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete stale policies")
+	}
+	err = s.vpp.RulesDelete(staleRules)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete stale rules")
+	}
+	for _, id := range staleIPSets {
+		err = s.vpp.IpsetDelete(id)
+		if err != nil {
+			return errors.Wrapf(err, "cannot delete stale ipset %d", id)
+		}
+	}
+	return nil
+}
+
+// checkPolicyDrift compares the capo configuration in VPP with the policy
+// server state, reports the drift and repairs it if configured to
+func (s *Server) checkPolicyDrift() (err error) {
+	// The CNI server updates the endpoints interfaces and the pods ipset
+	s.endpointsLock.Lock()
+	defer s.endpointsLock.Unlock()
+
+	taps, err := s.vpp.SearchInterfacesWithTagPrefix("host-")
+	if err != nil {
+		return errors.Wrap(err, "cannot list host taps")
+	}
+	tapSwIfIndexes := make([]uint32, 0, len(taps))
+	for _, swIfIndex := range taps {
+		tapSwIfIndexes = append(tapSwIfIndexes, swIfIndex)
+	}
+	dump, err := s.vpp.CapoDump()
+	if err != nil {
+		return errors.Wrap(err, "cannot dump capo configuration")
+	}
+	expected := s.expectedCapoState(tapSwIfIndexes)
+	drift := detectDrift(dump, expected)
+	if *config.GetCalicoVppFeatureGates().PrometheusEnabled {
+		common.SendEvent(common.CalicoVppEvent{
+			Type: common.PolicyDriftDetected,
+			New:  drift,
+		})
+	}
+	if drift.Empty() {
+		s.log.Debugf("No policy drift detected")
+		return nil
+	}
+	s.log.Warnf("Policy drift detected between VPP and the policy server state: %s", drift)
+	if !*config.GetCalicoVppPolicyDrift().Repair {
+		return nil
+	}
+	err = s.repairDrift(drift, dump, expected, tapSwIfIndexes)
+	if err != nil {
+		return errors.Wrap(err, "cannot repair policy drift")
+	}
+	s.log.Infof("Repaired policy drift %s", drift)
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"net"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newDriftTestServer returns a server configured with newConfiguredTestState,
+// and a pod using the kns.default profile on interface 3
+func newDriftTestServer() *Server {
+	state := newConfiguredTestState(true)
+	state.Policies[PolicyID{Tier: "default", Name: "web"}].setRuleIDs()
+	state.Profiles["kns.default"].setRuleIDs()
+	state.WorkloadEndpoints[WorkloadEndpointID{WorkloadID: "default/pod"}] = &WorkloadEndpoint{
+		SwIfIndex: []uint32{3},
+		Profiles:  []string{"kns.default"},
+	}
+	return &Server{
+		log:             logrus.NewEntry(logrus.New()),
+		configuredState: state,
+	}
+}
+
+// dumpedAllowRule returns the allowProtoRule of the test state as capo dumps it,
+// without its address family
+func dumpedAllowRule(srcIPSetIDs ...uint32) *types.Rule {
+	_, srcNet, _ := net.ParseCIDR("192.168.0.0/16")
+	return &types.Rule{
+		Action:   types.ActionAllow,
+		SrcNet:   []net.IPNet{*srcNet},
+		SrcIPSet: srcIPSetIDs,
+	}
+}
+
+// newDriftTestDump returns the capo configuration matching newDriftTestServer
+func newDriftTestDump() *types.CapoDump {
+	return &types.CapoDump{
+		IPSets: map[uint32]*types.CapoIPSetDump{
+			5: {ID: 5, Type: types.IpsetTypeIP, Members: map[string]bool{"10.0.0.1": true}},
+		},
+		Rules: map[uint32]*types.CapoRuleDump{
+			20: {ID: 20, Rule: dumpedAllowRule(5)},
+			21: {ID: 21, Rule: dumpedAllowRule()},
+		},
+		Policies: map[uint32]*types.CapoPolicyDump{
+			10: {ID: 10, TxRuleIDs: []uint32{20}},
+			11: {ID: 11, TxRuleIDs: []uint32{21}},
+		},
+		Interfaces: map[uint32]*types.CapoInterfaceDump{
+			3: {SwIfIndex: 3, ProfileIDs: []uint32{11}},
+		},
+	}
+}
+
+var _ = Describe("Policy drift detection", func() {
+	It("should not report drift when VPP matches the state", func() {
+		server := newDriftTestServer()
+		drift := detectDrift(newDriftTestDump(), server.expectedCapoState(nil))
+		Expect(drift.Empty()).To(BeTrue(), drift.String())
+	})
+
+	It("should report missing, unknown and mismatched objects", func() {
+		server := newDriftTestServer()
+		dump := newDriftTestDump()
+		dump.IPSets[5].Members = map[string]bool{"10.0.0.2": true}
+		dump.IPSets[7] = &types.CapoIPSetDump{ID: 7, Type: types.IpsetTypeNet}
+		delete(dump.Rules, 21)
+		dump.Rules[20].Rule.Action = types.ActionDeny
+		dump.Policies[11].TxRuleIDs = []uint32{types.InvalidID}
+		dump.Policies[12] = &types.CapoPolicyDump{ID: 12}
+		dump.Interfaces[3].ProfileIDs = []uint32{12}
+
+		drift := detectDrift(dump, server.expectedCapoState(nil))
+		Expect(drift.MismatchedIPSets).To(Equal([]uint32{5}))
+		Expect(drift.UnknownIPSets).To(Equal([]uint32{7}))
+		Expect(drift.MissingRules).To(Equal([]uint32{21}))
+		Expect(drift.MismatchedRules).To(Equal([]uint32{20}))
+		Expect(drift.MismatchedPolicies).To(Equal([]uint32{11}))
+		Expect(drift.UnknownPolicies).To(Equal([]uint32{12}))
+		Expect(drift.MismatchedInterfaces).To(Equal([]uint32{3}))
+		Expect(drift.Counts()).To(HaveKeyWithValue(DriftKind{Object: "policy", Drift: "missing"}, 0))
+		Expect(drift.Counts()).To(HaveKeyWithValue(DriftKind{Object: "rule", Drift: "missing"}, 1))
+	})
+
+	It("should expect the default configuration on host taps", func() {
+		server := newDriftTestServer()
+		server.defaultTap0IngressConf = []uint32{13, 14}
+		dump := newDriftTestDump()
+		drift := detectDrift(dump, server.expectedCapoState([]uint32{1}))
+		Expect(drift.MismatchedInterfaces).To(Equal([]uint32{1}))
+
+		dump.Interfaces[1] = &types.CapoInterfaceDump{SwIfIndex: 1, TxPolicyIDs: []uint32{13, 14}}
+		drift = detectDrift(dump, server.expectedCapoState([]uint32{1}))
+		Expect(drift.MismatchedInterfaces).To(BeEmpty())
+	})
+
+	It("should compare all the fields of the rules", func() {
+		server := newDriftTestServer()
+		dump := newDriftTestDump()
+		_, dstNet, _ := net.ParseCIDR("10.1.0.0/16")
+		dump.Rules[21].Rule.DstNet = []net.IPNet{*dstNet}
+		dump.Rules[20].Rule.SrcIPSet = nil
+
+		drift := detectDrift(dump, server.expectedCapoState(nil))
+		Expect(drift.MismatchedRules).To(Equal([]uint32{20, 21}))
+	})
+
+	It("should report the stages that differ", func() {
+		server := newDriftTestServer()
+		dump := newDriftTestDump()
+		dump.Stages = map[uint32]*types.CapoStagesDump{
+			3: {SwIfIndex: 3, PreDnatPolicyIDs: []uint32{10}},
+		}
+
+		drift := detectDrift(dump, server.expectedCapoState(nil))
+		Expect(drift.MismatchedStages).To(Equal([]uint32{3}))
+		Expect(drift.Counts()).To(HaveKeyWithValue(DriftKind{Object: "stages", Drift: "mismatched"}, 1))
+
+		delete(dump.Stages, 3)
+		drift = detectDrift(dump, server.expectedCapoState(nil))
+		Expect(drift.MismatchedStages).To(BeEmpty())
+	})
+})
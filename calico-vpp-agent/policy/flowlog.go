@@ -0,0 +1,373 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"gopkg.in/tomb.v2"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+const (
+	// puntPacketDescLen is the size of the punt_packet_desc_t header
+	// prepended by VPP to punted packets
+	puntPacketDescLen = 8
+	// flowLogQueueSize is the number of records waiting to be written
+	// before new ones are dropped
+	flowLogQueueSize = 1024
+)
+
+// flowLogPuntSocket is where the packets punted by capo for a reason are received.
+// Capo punts the packets matching the log rules of the rx policies of an
+// interface with capo-log-rx, and those of the tx policies with capo-log-tx.
+// As in ConfigurePolicies, the rx policies of a workload endpoint are its
+// egress policies.
+type flowLogPuntSocket struct {
+	reason  string
+	path    string
+	ingress bool
+}
+
+var flowLogPuntSockets = []flowLogPuntSocket{
+	{reason: "capo-log-rx", path: config.FlowLogRxPuntSocket, ingress: false},
+	{reason: "capo-log-tx", path: config.FlowLogTxPuntSocket, ingress: true},
+}
+
+// flowLogPacket is a packet punted by capo, along with the direction of the
+// policies whose log rule it matched
+type flowLogPacket struct {
+	data    []byte
+	ingress bool
+}
+
+// FlowLogRecord is the JSON record emitted for a flow matching a log rule
+type FlowLogRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	Direction string    `json:"direction"`
+	RuleRef
+	Proto   string `json:"proto"`
+	SrcIP   string `json:"srcIp"`
+	DstIP   string `json:"dstIp"`
+	SrcPort uint16 `json:"srcPort"`
+	DstPort uint16 `json:"dstPort"`
+	// Action is the verdict of the policies for this flow
+	Action string `json:"action"`
+	// AuditAction is the action the rule would have enforced, for the rules of
+	// policies in audit mode
+	AuditAction string `json:"auditAction,omitempty"`
+}
+
+func newFlowLogRecord(id *WorkloadEndpointID, flow *Flow, ingress bool, ref RuleRef, action types.RuleAction) *FlowLogRecord {
+	record := &FlowLogRecord{
+		Time:      time.Now(),
+		Pod:       id.WorkloadID,
+		Endpoint:  id.EndpointID,
+		Direction: "egress",
+		RuleRef:   ref,
+		Proto:     flow.Proto.String(),
+		SrcIP:     flow.SrcIP.String(),
+		DstIP:     flow.DstIP.String(),
+		SrcPort:   flow.SrcPort,
+		DstPort:   flow.DstPort,
+		Action:    action.String(),
+	}
+	// k8s workload IDs are namespace/pod
+	if namespace, pod, found := strings.Cut(id.WorkloadID, "/"); found {
+		record.Namespace = namespace
+		record.Pod = pod
+	}
+	if ingress {
+		record.Direction = "ingress"
+	}
+	return record
+}
+
+// FlowLogger writes flow log records as JSON lines to a file or a unix socket.
+// It never blocks its callers: packets above the rate limit and records
+// exceeding the queue are dropped.
+type FlowLogger struct {
+	log         *logrus.Entry
+	destination string
+	limiter     *rate.Limiter
+	records     chan *FlowLogRecord
+	dropped     uint64
+	sink        io.WriteCloser
+}
+
+func NewFlowLogger(conf *config.CalicoVppFlowLogsConfigType, log *logrus.Entry) *FlowLogger {
+	return &FlowLogger{
+		log:         log,
+		destination: conf.Destination,
+		limiter:     rate.NewLimiter(rate.Limit(conf.RateLimit), conf.Burst),
+		records:     make(chan *FlowLogRecord, flowLogQueueSize),
+	}
+}
+
+// Allow tells whether a new packet can be logged without exceeding the rate limit
+func (l *FlowLogger) Allow() bool {
+	if l.limiter.Allow() {
+		return true
+	}
+	atomic.AddUint64(&l.dropped, 1)
+	return false
+}
+
+// Log queues a record for writing
+func (l *FlowLogger) Log(record *FlowLogRecord) {
+	select {
+	case l.records <- record:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+}
+
+// Run writes the queued records until the tomb dies
+func (l *FlowLogger) Run(t *tomb.Tomb) error {
+	defer l.closeSink()
+	for {
+		select {
+		case <-t.Dying():
+			return nil
+		case record := <-l.records:
+			err := l.write(record)
+			if err != nil {
+				l.log.WithError(err).Warnf("Error writing flow log to %s", l.destination)
+				l.closeSink()
+			}
+		}
+	}
+}
+
+func (l *FlowLogger) openSink() (io.WriteCloser, error) {
+	if path, isSocket := strings.CutPrefix(l.destination, "unix://"); isSocket {
+		return net.Dial("unix", path)
+	}
+	err := os.MkdirAll(filepath.Dir(l.destination), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(l.destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+func (l *FlowLogger) closeSink() {
+	if l.sink != nil {
+		l.sink.Close()
+		l.sink = nil
+	}
+}
+
+func (l *FlowLogger) write(record *FlowLogRecord) (err error) {
+	if dropped := atomic.SwapUint64(&l.dropped, 0); dropped > 0 {
+		l.log.Warnf("Dropped %d flow logs", dropped)
+	}
+	if l.sink == nil {
+		l.sink, err = l.openSink()
+		if err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = l.sink.Write(append(data, '\n'))
+	return err
+}
+
+// parsePuntedPacket decodes a packet punted by VPP into the rx interface and the
+// flow 5-tuple as matched by capo
+func parsePuntedPacket(data []byte) (swIfIndex uint32, flow *Flow, err error) {
+	if len(data) <= puntPacketDescLen {
+		return 0, nil, fmt.Errorf("punted packet too short (%d bytes)", len(data))
+	}
+	// punt_packet_desc_t is in host byte order
+	swIfIndex = binary.LittleEndian.Uint32(data[0:4])
+	data = data[puntPacketDescLen:]
+
+	var packet gopacket.Packet
+	switch data[0] >> 4 {
+	case 4:
+		packet = gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	case 6:
+		packet = gopacket.NewPacket(data, layers.LayerTypeIPv6, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	default:
+		return 0, nil, fmt.Errorf("punted packet is not IP (version %d)", data[0]>>4)
+	}
+
+	flow = &Flow{}
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		flow.SrcIP, flow.DstIP, flow.Proto = ip.SrcIP, ip.DstIP, types.IPProto(ip.Protocol)
+	case *layers.IPv6:
+		flow.SrcIP, flow.DstIP, flow.Proto = ip.SrcIP, ip.DstIP, types.IPProto(ip.NextHeader)
+	default:
+		return 0, nil, errors.New("cannot decode punted packet IP header")
+	}
+
+	switch l4 := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		flow.SrcPort, flow.DstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+	case *layers.UDP:
+		flow.SrcPort, flow.DstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+	case *layers.SCTP:
+		flow.SrcPort, flow.DstPort = uint16(l4.SrcPort), uint16(l4.DstPort)
+	}
+	if icmp, ok := packet.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		flow.SrcPort, flow.DstPort = uint16(icmp.TypeCode.Type()), uint16(icmp.TypeCode.Code())
+	}
+	if icmp, ok := packet.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); ok {
+		flow.SrcPort, flow.DstPort = uint16(icmp.TypeCode.Type()), uint16(icmp.TypeCode.Code())
+	}
+	return swIfIndex, flow, nil
+}
+
+// ServeFlowLogs receives the packets punted by VPP when they match a rule with a
+// log action or a rule of a policy in audit mode, and hands them to the policy
+// server for logging
+func (s *Server) ServeFlowLogs(t *tomb.Tomb) error {
+	if s.flowLogger == nil {
+		return nil
+	}
+	s.log.Infof("Serving flow logs to %s", config.GetCalicoVppFlowLogs().Destination)
+	t.Go(func() error { return s.flowLogger.Run(t) })
+	for _, puntSocket := range flowLogPuntSockets {
+		puntSocket := puntSocket
+		t.Go(func() error { return s.serveFlowLogPuntSocket(t, &puntSocket) })
+	}
+	<-t.Dying()
+	return nil
+}
+
+func (s *Server) serveFlowLogPuntSocket(t *tomb.Tomb, puntSocket *flowLogPuntSocket) error {
+	reasonID, err := s.vpp.PuntReasonID(puntSocket.reason)
+	if err != nil {
+		s.log.WithError(err).Warnf("VPP does not punt packets matching log rules (%s), flow logs disabled", puntSocket.reason)
+		return nil
+	}
+
+	err = os.RemoveAll(puntSocket.path)
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete socket %s", puntSocket.path)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: puntSocket.path, Net: "unixgram"})
+	if err != nil {
+		return errors.Wrapf(err, "Could not bind to unixgram://%s", puntSocket.path)
+	}
+	defer func() {
+		conn.Close()
+		os.RemoveAll(puntSocket.path)
+	}()
+	err = s.vpp.PuntSocketRegister(reasonID, puntSocket.path)
+	if err != nil {
+		return errors.Wrapf(err, "Error registering flow logs punt socket for %s", puntSocket.reason)
+	}
+	defer func() {
+		err := s.vpp.PuntSocketDeregister(reasonID)
+		if err != nil {
+			s.log.WithError(err).Warnf("Error deregistering flow logs punt socket for %s", puntSocket.reason)
+		}
+	}()
+
+	go func() {
+		<-t.Dying()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if !t.Alive() {
+				return nil
+			}
+			return errors.Wrapf(err, "Error reading flow logs punt socket %s", puntSocket.path)
+		}
+		if !s.flowLogger.Allow() {
+			continue
+		}
+		packet := &flowLogPacket{data: make([]byte, n), ingress: puntSocket.ingress}
+		copy(packet.data, buf[:n])
+		select {
+		case s.flowLogPackets <- packet:
+		default:
+			atomic.AddUint64(&s.flowLogger.dropped, 1)
+		}
+	}
+}
+
+// handleFlowLogPacket logs the log rules, and the rules of policies in audit
+// mode, matched by a punted packet on the workload endpoint it was received on.
+// It runs in the policy server loop as it needs the configured state.
+func (s *Server) handleFlowLogPacket(packet *flowLogPacket) {
+	swIfIndex, flow, err := parsePuntedPacket(packet.data)
+	if err != nil {
+		s.log.WithError(err).Debug("Ignoring punted packet for flow logs")
+		return
+	}
+	if s.state != StateInSync {
+		return
+	}
+
+	s.endpointsLock.Lock()
+	defer s.endpointsLock.Unlock()
+
+	for id, intfs := range s.endpointsInterfaces {
+		for _, idx := range intfs {
+			if idx != swIfIndex {
+				continue
+			}
+			wep, ok := s.configuredState.WorkloadEndpoints[id]
+			if !ok {
+				return
+			}
+			verdict, err := wep.Evaluate(s.configuredState, id.Network, flow, packet.ingress)
+			if err != nil {
+				s.log.WithError(err).Debugf("Cannot evaluate flow %s on %s", flow, &id)
+				return
+			}
+			for _, ref := range verdict.Logged {
+				s.flowLogger.Log(newFlowLogRecord(&id, flow, packet.ingress, ref, verdict.Action))
+			}
+			for ref, auditAction := range verdict.Audited {
+				record := newFlowLogRecord(&id, flow, packet.ingress, ref, verdict.Action)
+				record.AuditAction = auditAction.String()
+				s.flowLogger.Log(record)
+			}
+			return
+		}
+	}
+	s.log.Debugf("No workload endpoint for punted flow %s on swIfIndex %d", flow, swIfIndex)
+}
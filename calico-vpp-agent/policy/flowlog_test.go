@@ -0,0 +1,170 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/tomb.v2"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func puntedTCPPacket(swIfIndex uint32, src, dst string, srcPort, dstPort uint16) []byte {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(src),
+		DstIP:    net.ParseIP(dst),
+	}
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), SYN: true}
+	Expect(tcp.SetNetworkLayerForChecksum(ip)).To(Succeed())
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, tcp)
+	Expect(err).ToNot(HaveOccurred())
+
+	desc := make([]byte, puntPacketDescLen)
+	binary.LittleEndian.PutUint32(desc, swIfIndex)
+	return append(desc, buf.Bytes()...)
+}
+
+// newFlowLogTestServer returns a server in sync with state, wep being the
+// default/web-0 workload endpoint on swIfIndex 7
+func newFlowLogTestServer(destination string, state *PolicyState, wep *WorkloadEndpoint) *Server {
+	conf := &config.CalicoVppFlowLogsConfigType{Destination: destination, RateLimit: 1, Burst: 1}
+	Expect(conf.Validate()).To(Succeed())
+	id := WorkloadEndpointID{OrchestratorID: "k8s", WorkloadID: "default/web-0", EndpointID: "eth0"}
+	wep.SwIfIndex = []uint32{7}
+	state.WorkloadEndpoints[id] = wep
+
+	server := newTestServer()
+	server.log = logrus.NewEntry(logrus.New())
+	server.state = StateInSync
+	server.configuredState = state
+	server.endpointsInterfaces = map[WorkloadEndpointID]map[string]uint32{id: {"eth0": 7}}
+	server.flowLogger = NewFlowLogger(conf, server.log)
+	return server
+}
+
+// writeFlowLogRecords runs the flow logger until the queued records are
+// written, and returns them
+func writeFlowLogRecords(server *Server, destination string) (records []FlowLogRecord) {
+	t := &tomb.Tomb{}
+	t.Go(func() error { return server.flowLogger.Run(t) })
+	Eventually(func() int {
+		data, _ := os.ReadFile(destination)
+		return len(data)
+	}).ShouldNot(BeZero())
+	t.Kill(nil)
+	Expect(t.Wait()).To(Succeed())
+
+	f, err := os.Open(destination)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := FlowLogRecord{}
+		Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+		records = append(records, record)
+	}
+	return records
+}
+
+var _ = Describe("Flow logs", func() {
+	It("should decode punted packets", func() {
+		swIfIndex, flow, err := parsePuntedPacket(puntedTCPPacket(7, "10.0.0.1", "10.0.1.2", 1234, 80))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(swIfIndex).To(Equal(uint32(7)))
+		Expect(flow.SrcIP.String()).To(Equal("10.0.0.1"))
+		Expect(flow.DstIP.String()).To(Equal("10.0.1.2"))
+		Expect(flow.Proto).To(Equal(types.TCP))
+		Expect(flow.SrcPort).To(Equal(uint16(1234)))
+		Expect(flow.DstPort).To(Equal(uint16(80)))
+
+		_, _, err = parsePuntedPacket([]byte{0, 0, 0, 0})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should write rate limited JSON records for punted packets", func() {
+		dir, err := os.MkdirTemp("", "flowlogs")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		destination := filepath.Join(dir, "flows.log")
+		state, wep := newLogRuleTestState()
+		server := newFlowLogTestServer(destination, state, wep)
+
+		packet := puntedTCPPacket(7, "10.0.0.1", "10.0.1.2", 1234, 80)
+		Expect(server.flowLogger.Allow()).To(BeTrue())
+		server.handleFlowLogPacket(&flowLogPacket{data: packet, ingress: true})
+		// Above the rate limit
+		Expect(server.flowLogger.Allow()).To(BeFalse())
+		// Unknown interface
+		server.handleFlowLogPacket(&flowLogPacket{data: puntedTCPPacket(8, "10.0.0.1", "10.0.1.2", 1234, 80), ingress: true})
+		// No log rule in the egress policies
+		server.handleFlowLogPacket(&flowLogPacket{data: packet, ingress: false})
+
+		records := writeFlowLogRecords(server, destination)
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Namespace).To(Equal("default"))
+		Expect(records[0].Pod).To(Equal("web-0"))
+		Expect(records[0].Direction).To(Equal("ingress"))
+		Expect(records[0].Policy).To(Equal("web"))
+		Expect(records[0].RuleID).To(Equal("rule-log"))
+		Expect(records[0].SrcIP).To(Equal("10.0.0.1"))
+		Expect(records[0].DstPort).To(Equal(uint16(80)))
+		Expect(records[0].Action).To(Equal("allow"))
+		Expect(records[0].AuditAction).To(BeEmpty())
+	})
+
+	It("should log the rules of policies in audit mode", func() {
+		dir, err := os.MkdirTemp("", "flowlogs")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		destination := filepath.Join(dir, "flows.log")
+		state := NewPolicyState()
+		state.Policies[PolicyID{Tier: "default", Name: "deny-web"}] = &Policy{
+			Policy:       &types.Policy{},
+			Audit:        true,
+			InboundRules: mustFromProtoRules(tcpProtoRule("deny", "rule-deny", 80)),
+		}
+		wep := &WorkloadEndpoint{
+			Tiers: []Tier{{Name: "default", IngressPolicies: []string{"deny-web"}}},
+		}
+		server := newFlowLogTestServer(destination, state, wep)
+
+		server.handleFlowLogPacket(&flowLogPacket{data: puntedTCPPacket(7, "10.0.0.1", "10.0.1.2", 1234, 80), ingress: true})
+
+		records := writeFlowLogRecords(server, destination)
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Policy).To(Equal("deny-web"))
+		Expect(records[0].RuleID).To(Equal("rule-deny"))
+		Expect(records[0].Action).To(Equal("allow"))
+		Expect(records[0].AuditAction).To(Equal("deny"))
+	})
+})
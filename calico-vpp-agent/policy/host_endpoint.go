@@ -40,11 +40,14 @@ type HostEndpoint struct {
 	Profiles          []string
 	Tiers             []Tier
 	ForwardTiers      []Tier
+	UntrackedTiers    []Tier
+	PreDnatTiers      []Tier
 	server            *Server
 	InterfaceName     string
 	expectedIPs       []string
 
 	currentForwardConf *types.InterfaceConfig
+	currentStagesConf  *types.StagesConfig
 }
 
 func (he *HostEndpoint) String() string {
@@ -56,6 +59,8 @@ func (he *HostEndpoint) String() string {
 	s += types.StrListToString(" profiles=", he.Profiles)
 	s += types.StrableListToString(" tiers=", he.Tiers)
 	s += types.StrableListToString(" forwardTiers=", he.ForwardTiers)
+	s += types.StrableListToString(" untrackedTiers=", he.UntrackedTiers)
+	s += types.StrableListToString(" preDnatTiers=", he.PreDnatTiers)
 	return s
 }
 
@@ -65,6 +70,12 @@ func fromProtoHostEndpointID(ep *proto.HostEndpointID) *HostEndpointID {
 	}
 }
 
+func (eid HostEndpointID) toProto() *proto.HostEndpointID {
+	return &proto.HostEndpointID{
+		EndpointId: eid.EndpointID,
+	}
+}
+
 func fromProtoHostEndpoint(hep *proto.HostEndpoint, server *Server) *HostEndpoint {
 	r := &HostEndpoint{
 		Profiles:          hep.ProfileIds,
@@ -75,6 +86,8 @@ func fromProtoHostEndpoint(hep *proto.HostEndpoint, server *Server) *HostEndpoin
 		InterfaceName:     hep.Name,
 		Tiers:             make([]Tier, 0),
 		ForwardTiers:      make([]Tier, 0),
+		UntrackedTiers:    make([]Tier, 0),
+		PreDnatTiers:      make([]Tier, 0),
 		expectedIPs:       append(hep.ExpectedIpv4Addrs, hep.ExpectedIpv6Addrs...),
 	}
 	for _, tier := range hep.Tiers {
@@ -92,14 +105,18 @@ func fromProtoHostEndpoint(hep *proto.HostEndpoint, server *Server) *HostEndpoin
 		})
 	}
 	for _, tier := range hep.PreDnatTiers {
-		if tier != nil {
-			server.log.Error("Existing PreDnatTiers, not implemented")
-		}
+		r.PreDnatTiers = append(r.PreDnatTiers, Tier{
+			Name:            tier.Name,
+			IngressPolicies: tier.IngressPolicies,
+			EgressPolicies:  tier.EgressPolicies,
+		})
 	}
 	for _, tier := range hep.UntrackedTiers {
-		if tier != nil {
-			server.log.Error("Existing UntrackedTiers, not implemented")
-		}
+		r.UntrackedTiers = append(r.UntrackedTiers, Tier{
+			Name:            tier.Name,
+			IngressPolicies: tier.IngressPolicies,
+			EgressPolicies:  tier.EgressPolicies,
+		})
 	}
 	return r
 }
@@ -121,6 +138,12 @@ func (h *HostEndpoint) handleTunnelChange(swIfIndex uint32, isAdd bool, pending
 				if err != nil {
 					return errors.Wrapf(err, "cannot configure policies on tunnel interface %d", swIfIndex)
 				}
+				if h.currentStagesConf != nil {
+					err = h.server.vpp.ConfigureStages(swIfIndex, h.currentStagesConf, 1 /*invertRxTx*/)
+					if err != nil {
+						return errors.Wrapf(err, "cannot configure stages on tunnel interface %d", swIfIndex)
+					}
+				}
 			}
 		}
 	} else { // delete case
@@ -134,7 +157,7 @@ func (h *HostEndpoint) handleTunnelChange(swIfIndex uint32, isAdd bool, pending
 	return err
 }
 
-func (h *HostEndpoint) getHostPolicies(state *PolicyState, tiers []Tier) (conf *types.InterfaceConfig, err error) {
+func (h *HostEndpoint) getTiersPolicies(state *PolicyState, tiers []Tier) (conf *types.InterfaceConfig, err error) {
 	conf = types.NewInterfaceConfig()
 	for _, tier := range tiers {
 		for _, polName := range tier.IngressPolicies {
@@ -158,6 +181,14 @@ func (h *HostEndpoint) getHostPolicies(state *PolicyState, tiers []Tier) (conf *
 			conf.EgressPolicyIDs = append(conf.EgressPolicyIDs, pol.VppID)
 		}
 	}
+	return conf, nil
+}
+
+func (h *HostEndpoint) getHostPolicies(state *PolicyState, tiers []Tier) (conf *types.InterfaceConfig, err error) {
+	conf, err = h.getTiersPolicies(state, tiers)
+	if err != nil {
+		return nil, err
+	}
 	for _, profileName := range h.Profiles {
 		prof, ok := state.Profiles[profileName]
 		if !ok {
@@ -168,6 +199,7 @@ func (h *HostEndpoint) getHostPolicies(state *PolicyState, tiers []Tier) (conf *
 		}
 		conf.ProfileIDs = append(conf.ProfileIDs, prof.VppID)
 	}
+	h.server.withAuditPass(conf, state, tiers, "")
 	return conf, nil
 }
 
@@ -201,19 +233,70 @@ func (h *HostEndpoint) getForwardPolicies(state *PolicyState) (conf *types.Inter
 	return conf, nil
 }
 
-func (h *HostEndpoint) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
-	forwardConf, err := h.getForwardPolicies(state)
+// getStages returns the policies evaluated by capo on the uplinks before cnat and
+// before the acl-plugin sessions, so that they apply to all the traffic crossing them
+// (forwarded and host terminated).
+// Untracked (doNotTrack) policies are stateless: an allow creates no session, so the
+// return traffic must be allowed by untracked policies too, and a flow matched by none
+// of them is processed by the other policies. As in the linux dataplane, the failsafe
+// rules, and the ones allowing their replies, are evaluated before them.
+// PreDNAT policies then apply to the traffic entering the node, after the failsafe
+// rules as in the linux dataplane. Only their deny is final, traffic they allow goes on
+// with cnat and the forward or host endpoint policies.
+func (h *HostEndpoint) getStages(state *PolicyState) (conf *types.StagesConfig, err error) {
+	untrackedConf, err := h.getTiersPolicies(state, h.UntrackedTiers)
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "cannot create untracked policies for stagesConf")
+	}
+	preDnatConf, err := h.getTiersPolicies(state, h.PreDnatTiers)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create preDNAT policies for stagesConf")
+	}
+	conf = types.NewStagesConfig()
+	if len(untrackedConf.IngressPolicyIDs) > 0 {
+		conf.UntrackedIngressPolicyIDs = append([]uint32{h.server.failSafePolicy.VppID, h.server.failSafeReplyPolicy.VppID}, untrackedConf.IngressPolicyIDs...)
 	}
+	if len(untrackedConf.EgressPolicyIDs) > 0 {
+		conf.UntrackedEgressPolicyIDs = append([]uint32{h.server.failSafePolicy.VppID, h.server.failSafeReplyPolicy.VppID}, untrackedConf.EgressPolicyIDs...)
+	}
+	// preDNAT policies only have inbound rules
+	if len(preDnatConf.IngressPolicyIDs) > 0 {
+		conf.PreDnatPolicyIDs = append([]uint32{h.server.failSafePolicy.VppID}, preDnatConf.IngressPolicyIDs...)
+	}
+	return conf, nil
+}
+
+// configureUplinks configures the forward policies and the stages on the uplinks and tunnels
+func (h *HostEndpoint) configureUplinks(vpp *vpplink.VppLink, forwardConf *types.InterfaceConfig, stagesConf *types.StagesConfig, op string) (err error) {
 	for _, swIfIndex := range append(h.UplinkSwIfIndexes, h.TunnelSwIfIndexes...) {
-		h.server.log.Infof("policy(add) interface swif=%d conf=%v", swIfIndex, forwardConf)
+		h.server.log.Infof("policy(%s) interface swif=%d conf=%v stages=%v", op, swIfIndex, forwardConf, stagesConf)
 		err = vpp.ConfigurePolicies(swIfIndex, forwardConf, 1 /*invertRxTx*/)
 		if err != nil {
 			return errors.Wrapf(err, "cannot configure policies on interface %d", swIfIndex)
 		}
+		err = vpp.ConfigureStages(swIfIndex, stagesConf, 1 /*invertRxTx*/)
+		if err != nil {
+			return errors.Wrapf(err, "cannot configure stages on interface %d", swIfIndex)
+		}
 	}
 	h.currentForwardConf = forwardConf
+	h.currentStagesConf = stagesConf
+	return nil
+}
+
+func (h *HostEndpoint) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
+	forwardConf, err := h.getForwardPolicies(state)
+	if err != nil {
+		return err
+	}
+	stagesConf, err := h.getStages(state)
+	if err != nil {
+		return err
+	}
+	err = h.configureUplinks(vpp, forwardConf, stagesConf, "add")
+	if err != nil {
+		return err
+	}
 	tapConf, err := h.getTapPolicies(state)
 	if err != nil {
 		return err
@@ -233,14 +316,14 @@ func (h *HostEndpoint) Update(vpp *vpplink.VppLink, new *HostEndpoint, state *Po
 	if err != nil {
 		return err
 	}
-	for _, swIfIndex := range append(h.UplinkSwIfIndexes, h.TunnelSwIfIndexes...) {
-		h.server.log.Infof("policy(upd) interface swif=%d conf=%v", swIfIndex, forwardConf)
-		err = vpp.ConfigurePolicies(swIfIndex, forwardConf, 1 /* invertRxTx */)
-		if err != nil {
-			return errors.Wrapf(err, "cannot configure policies on interface %d", swIfIndex)
-		}
+	stagesConf, err := new.getStages(state)
+	if err != nil {
+		return err
+	}
+	err = h.configureUplinks(vpp, forwardConf, stagesConf, "upd")
+	if err != nil {
+		return err
 	}
-	h.currentForwardConf = forwardConf
 	tapConf, err := new.getTapPolicies(state)
 	if err != nil {
 		return err
@@ -256,6 +339,8 @@ func (h *HostEndpoint) Update(vpp *vpplink.VppLink, new *HostEndpoint, state *Po
 	h.Profiles = new.Profiles
 	h.Tiers = new.Tiers
 	h.ForwardTiers = new.ForwardTiers
+	h.UntrackedTiers = new.UntrackedTiers
+	h.PreDnatTiers = new.PreDnatTiers
 	return nil
 }
 
@@ -267,6 +352,10 @@ func (h *HostEndpoint) Delete(vpp *vpplink.VppLink, state *PolicyState) (err err
 		if err != nil {
 			return errors.Wrapf(err, "cannot unconfigure policies on interface %d", swIfIndex)
 		}
+		err = vpp.ConfigureStages(swIfIndex, types.NewStagesConfig(), 0)
+		if err != nil {
+			return errors.Wrapf(err, "cannot unconfigure stages on interface %d", swIfIndex)
+		}
 	}
 	for _, swIfIndex := range h.TapSwIfIndexes {
 		// Unconfigure tap0 policies
@@ -292,3 +292,86 @@ func (i *IPSet) RemoveMembers(members []string, apply bool, vpp *vpplink.VppLink
 	}
 	return err
 }
+
+// Update applies the difference between the members of the ipset and the
+// members of new to VPP, and then updates the ipset with new members
+func (i *IPSet) Update(vpp *vpplink.VppLink, new *IPSet) (err error) {
+	switch i.Type {
+	case types.IpsetTypeIP:
+		added, removed := make([]net.IP, 0), make([]net.IP, 0)
+		for k, v := range new.Addresses {
+			if _, ok := i.Addresses[k]; !ok {
+				added = append(added, v)
+			}
+		}
+		for k, v := range i.Addresses {
+			if _, ok := new.Addresses[k]; !ok {
+				removed = append(removed, v)
+			}
+		}
+		if len(added) > 0 {
+			err = vpp.AddIpsetIPMembers(i.VppID, added)
+			if err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			err = vpp.DelIpsetIPMembers(i.VppID, removed)
+			if err != nil {
+				return err
+			}
+		}
+	case types.IpsetTypeIPPort:
+		added, removed := make([]types.IPPort, 0), make([]types.IPPort, 0)
+		for k, v := range new.IPPorts {
+			if _, ok := i.IPPorts[k]; !ok {
+				added = append(added, v)
+			}
+		}
+		for k, v := range i.IPPorts {
+			if _, ok := new.IPPorts[k]; !ok {
+				removed = append(removed, v)
+			}
+		}
+		if len(added) > 0 {
+			err = vpp.AddIpsetIPPortMembers(i.VppID, added)
+			if err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			err = vpp.DelIpsetIPPortMembers(i.VppID, removed)
+			if err != nil {
+				return err
+			}
+		}
+	case types.IpsetTypeNet:
+		added, removed := make([]*net.IPNet, 0), make([]*net.IPNet, 0)
+		for k, v := range new.Networks {
+			if _, ok := i.Networks[k]; !ok {
+				added = append(added, v)
+			}
+		}
+		for k, v := range i.Networks {
+			if _, ok := new.Networks[k]; !ok {
+				removed = append(removed, v)
+			}
+		}
+		if len(added) > 0 {
+			err = vpp.AddIpsetNetMembers(i.VppID, added)
+			if err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			err = vpp.DelIpsetNetMembers(i.VppID, removed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	i.Addresses = new.Addresses
+	i.IPPorts = new.IPPorts
+	i.Networks = new.Networks
+	return nil
+}
@@ -0,0 +1,346 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+// Flow is the 5-tuple of a packet, as matched by the capo plugin.
+// For ICMP packets, SrcPort holds the ICMP type and DstPort the ICMP code.
+type Flow struct {
+	SrcIP   net.IP
+	DstIP   net.IP
+	Proto   types.IPProto
+	SrcPort uint16
+	DstPort uint16
+}
+
+func (f *Flow) String() string {
+	return fmt.Sprintf("%s %s:%d -> %s:%d", f.Proto, f.SrcIP, f.SrcPort, f.DstIP, f.DstPort)
+}
+
+func (f *Flow) isICMP() bool {
+	return f.Proto == types.ICMP || f.Proto == types.ICMP6
+}
+
+// Matches tells whether the flow matches the rule, mirroring the
+// evaluation done by capo_match_rule in VPP. IPSets are resolved by
+// name in the given state.
+func (r *Rule) Matches(flow *Flow, state *PolicyState) bool {
+	for _, filter := range r.Filters {
+		switch filter.Type {
+		case types.CapoFilterProto:
+			if (filter.Value == int(flow.Proto)) != filter.ShouldMatch {
+				return false
+			}
+		case types.CapoFilterICMPType:
+			// A rule with an ICMP type / code specified doesn't match a non-icmp packet
+			if !flow.isICMP() || (filter.Value == int(flow.SrcPort)) != filter.ShouldMatch {
+				return false
+			}
+		case types.CapoFilterICMPCode:
+			if !flow.isICMP() || (filter.Value == int(flow.DstPort)) != filter.ShouldMatch {
+				return false
+			}
+		}
+	}
+
+	if len(r.SrcNet) > 0 && !netsContain(r.SrcNet, flow.SrcIP) {
+		return false
+	}
+	if netsContain(r.SrcNotNet, flow.SrcIP) {
+		return false
+	}
+	if len(r.DstNet) > 0 && !netsContain(r.DstNet, flow.DstIP) {
+		return false
+	}
+	if netsContain(r.DstNotNet, flow.DstIP) {
+		return false
+	}
+
+	if len(r.SrcIPSetNames) > 0 && !ipsetsContain(state, r.SrcIPSetNames, flow.SrcIP) {
+		return false
+	}
+	if ipsetsContain(state, r.SrcNotIPSetNames, flow.SrcIP) {
+		return false
+	}
+	if len(r.DstIPSetNames) > 0 && !ipsetsContain(state, r.DstIPSetNames, flow.DstIP) {
+		return false
+	}
+	if ipsetsContain(state, r.DstNotIPSetNames, flow.DstIP) {
+		return false
+	}
+
+	// Ports need to be in either the port ranges or the ip+port ipsets
+	if portRangesContain(r.SrcNotPortRange, flow.SrcPort) ||
+		ipPortIPSetsContain(state, r.SrcNotIPPortIPSetNames, flow.SrcIP, flow.Proto, flow.SrcPort) {
+		return false
+	}
+	if portRangesContain(r.DstNotPortRange, flow.DstPort) ||
+		ipPortIPSetsContain(state, r.DstNotIPPortIPSetNames, flow.DstIP, flow.Proto, flow.DstPort) {
+		return false
+	}
+	if len(r.SrcPortRange) > 0 || len(r.SrcIPPortIPSetNames) > 0 {
+		if !portRangesContain(r.SrcPortRange, flow.SrcPort) &&
+			!ipPortIPSetsContain(state, r.SrcIPPortIPSetNames, flow.SrcIP, flow.Proto, flow.SrcPort) {
+			return false
+		}
+	}
+	dstIPPortIPSetNames := append(append([]string{}, r.DstIPPortIPSetNames...), r.DstIPPortSetNames...)
+	if len(r.DstPortRange) > 0 || len(dstIPPortIPSetNames) > 0 {
+		if !portRangesContain(r.DstPortRange, flow.DstPort) &&
+			!ipPortIPSetsContain(state, dstIPPortIPSetNames, flow.DstIP, flow.Proto, flow.DstPort) {
+			return false
+		}
+	}
+	return true
+}
+
+func netsContain(nets []net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func portRangesContain(ranges []types.PortRange, port uint16) bool {
+	for _, pr := range ranges {
+		if pr.First <= port && port <= pr.Last {
+			return true
+		}
+	}
+	return false
+}
+
+func ipsetsContain(state *PolicyState, names []string, ip net.IP) bool {
+	for _, name := range names {
+		ipset, ok := state.IPSets[name]
+		if !ok {
+			continue
+		}
+		for _, addr := range ipset.Addresses {
+			if addr.Equal(ip) {
+				return true
+			}
+		}
+		for _, n := range ipset.Networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ipPortIPSetsContain(state *PolicyState, names []string, ip net.IP, proto types.IPProto, port uint16) bool {
+	for _, name := range names {
+		ipset, ok := state.IPSets[name]
+		if !ok {
+			continue
+		}
+		for _, ipPort := range ipset.IPPorts {
+			if ipPort.Addr.Equal(ip) && ipPort.L4Proto == uint8(proto) && ipPort.Port == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RuleRef identifies a rule within the policies or profiles applied to an endpoint
+type RuleRef struct {
+	Tier    string `json:"tier,omitempty"`
+	Policy  string `json:"policy,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	RuleID  string `json:"ruleId,omitempty"`
+}
+
+// PolicyResult is the outcome of the evaluation of a flow against a single policy or profile
+type PolicyResult struct {
+	RuleRef
+	// Action is the action of the rule that matched, nil when no rule matched
+	// (or only log rules, or the policy is in audit mode)
+	Action *types.RuleAction
+	// AuditAction is the action of the first rule that matched in a policy
+	// in audit mode, if any
+	AuditAction *types.RuleAction
+}
+
+// Verdict is the outcome of the evaluation of a flow against the policies of an endpoint
+type Verdict struct {
+	Action types.RuleAction
+	// Rule is the rule that decided the action, nil when no rule matched
+	Rule *RuleRef
+	// Logged are the log rules matched while evaluating the flow
+	Logged []RuleRef
+	// Audited are the rules of policies in audit mode matched while evaluating
+	// the flow, with the action they would have enforced
+	Audited map[RuleRef]types.RuleAction
+	// Evaluated are the policies and profiles evaluated, in order
+	Evaluated []PolicyResult
+}
+
+// namedPolicy is a policy or profile along with the names felix knows it by
+type namedPolicy struct {
+	RuleRef
+	policy *Policy
+	// state resolves the ipsets of the policy rules when it is not the
+	// state of the endpoint, as for the policies added by the agent
+	state *PolicyState
+}
+
+func (np *namedPolicy) rules(ingress bool) []*Rule {
+	if ingress {
+		return np.policy.InboundRules
+	}
+	return np.policy.OutboundRules
+}
+
+// evaluatePolicies mirrors capo_match_policies: policies are evaluated in order, and the
+// first matching rule of a policy decides. As in VPP, log rules and the rules of policies in
+// audit mode are only recorded and the evaluation goes on with the following rules, a pass
+// jumps to the profiles, and a flow matching nothing is denied.
+// Without policies the profiles are evaluated, and no profiles means allow.
+func evaluatePolicies(policies []*namedPolicy, profiles []*namedPolicy, ingress bool, flow *Flow, state *PolicyState) *Verdict {
+	verdict := &Verdict{Action: types.ActionDeny}
+	if len(policies) > 0 {
+		if !matchPolicies(verdict, policies, ingress, flow, state) {
+			// nothing matched, deny
+			return verdict
+		}
+		if verdict.Action != types.ActionPass {
+			return verdict
+		}
+		verdict.Action = types.ActionDeny
+	}
+	if len(profiles) == 0 {
+		verdict.Action = types.ActionAllow
+		return verdict
+	}
+	if matchPolicies(verdict, profiles, ingress, flow, state) && verdict.Action == types.ActionPass {
+		// pass is not valid in profiles, VPP drops the packet
+		verdict.Action = types.ActionDeny
+	}
+	return verdict
+}
+
+// matchPolicies returns true when an allow, deny or pass rule matched, with verdict.Action
+// set to this rule's action
+func matchPolicies(verdict *Verdict, policies []*namedPolicy, ingress bool, flow *Flow, state *PolicyState) bool {
+	for _, np := range policies {
+		policyState := state
+		if np.state != nil {
+			policyState = np.state
+		}
+		result := PolicyResult{RuleRef: np.RuleRef}
+		for _, rule := range np.rules(ingress) {
+			if !rule.Matches(flow, policyState) {
+				continue
+			}
+			ref := np.RuleRef
+			ref.RuleID = rule.RuleID
+			action := rule.Action
+			if action == types.ActionLog {
+				verdict.Logged = append(verdict.Logged, ref)
+				continue
+			}
+			if np.policy.Audit {
+				if verdict.Audited == nil {
+					verdict.Audited = make(map[RuleRef]types.RuleAction)
+				}
+				verdict.Audited[ref] = action
+				if result.AuditAction == nil {
+					result.RuleID = rule.RuleID
+					result.AuditAction = &action
+				}
+				continue
+			}
+			result.RuleID = rule.RuleID
+			result.Action = &action
+			verdict.Evaluated = append(verdict.Evaluated, result)
+			verdict.Action = rule.Action
+			verdict.Rule = &ref
+			return true
+		}
+		verdict.Evaluated = append(verdict.Evaluated, result)
+	}
+	return false
+}
+
+func tiersPolicies(state *PolicyState, tiers []Tier, ingress bool, network string) (policies []*namedPolicy, err error) {
+	for _, tier := range tiers {
+		names := tier.EgressPolicies
+		if ingress {
+			names = tier.IngressPolicies
+		}
+		for _, name := range names {
+			pol, ok := state.Policies[PolicyID{Tier: tier.Name, Name: name, Network: network}]
+			if !ok {
+				return nil, fmt.Errorf("policy %s tier %s not found", name, tier.Name)
+			}
+			policies = append(policies, &namedPolicy{RuleRef: RuleRef{Tier: tier.Name, Policy: name}, policy: pol})
+		}
+	}
+	return policies, nil
+}
+
+func namedProfiles(state *PolicyState, names []string) (profiles []*namedPolicy, err error) {
+	for _, name := range names {
+		prof, ok := state.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %s not found", name)
+		}
+		profiles = append(profiles, &namedPolicy{RuleRef: RuleRef{Profile: name}, policy: prof})
+	}
+	return profiles, nil
+}
+
+// Evaluate computes the verdict of the user defined policies and profiles of the workload
+// endpoint for a flow, ingress being the traffic going to the pod. The policies added by
+// the agent (e.g. allowing traffic from the host) are not taken into account, except the
+// one terminating endpoints policies that are all audited.
+func (w *WorkloadEndpoint) Evaluate(state *PolicyState, network string, flow *Flow, ingress bool) (*Verdict, error) {
+	return w.evaluate(state, network, flow, ingress, nil)
+}
+
+// evaluate computes the verdict for a flow, with the internal policies evaluated
+// before the ingress policies when there are some, as in getPolicies
+func (w *WorkloadEndpoint) evaluate(state *PolicyState, network string, flow *Flow, ingress bool, internal []*namedPolicy) (*Verdict, error) {
+	policies, err := tiersPolicies(state, w.Tiers, ingress, network)
+	if err != nil {
+		return nil, err
+	}
+	if onlyAuditedPolicies(state, w.Tiers, ingress, network) {
+		policies = append(policies, &namedPolicy{
+			RuleRef: RuleRef{Tier: internalTier, Policy: "audit-pass"},
+			policy:  newAuditPassPolicy(),
+		})
+	}
+	if ingress && len(policies) > 0 {
+		policies = append(append([]*namedPolicy{}, internal...), policies...)
+	}
+	profiles, err := namedProfiles(state, w.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	return evaluatePolicies(policies, profiles, ingress, flow, state), nil
+}
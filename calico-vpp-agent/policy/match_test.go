@@ -0,0 +1,99 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"net"
+
+	"github.com/projectcalico/calico/felix/proto"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func mustFromProtoRules(rules ...*proto.Rule) (out []*Rule) {
+	for _, r := range rules {
+		converted, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		out = append(out, converted...)
+	}
+	return out
+}
+
+func tcpProtoRule(action, ruleID string, dstPort int32) *proto.Rule {
+	return &proto.Rule{
+		Action:    action,
+		IpVersion: proto.IPVersion_IPV4,
+		RuleId:    ruleID,
+		Protocol:  &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "TCP"}},
+		DstPorts:  []*proto.PortRange{{First: dstPort, Last: dstPort}},
+	}
+}
+
+// newLogRuleTestState returns a state with a workload endpoint whose ingress
+// policy logs tcp/80 from the frontend ipset, and then allows tcp/80
+func newLogRuleTestState() (*PolicyState, *WorkloadEndpoint) {
+	state := NewPolicyState()
+	state.IPSets["frontend"] = &IPSet{
+		Type:      types.IpsetTypeIP,
+		Addresses: map[string]net.IP{"10.0.0.1": net.ParseIP("10.0.0.1")},
+	}
+	logRule := tcpProtoRule("log", "rule-log", 80)
+	logRule.SrcIpSetIds = []string{"frontend"}
+	allowRule := tcpProtoRule("allow", "rule-allow", 80)
+	state.Policies[PolicyID{Tier: "default", Name: "web"}] = &Policy{
+		Policy:       &types.Policy{},
+		InboundRules: mustFromProtoRules(logRule, allowRule),
+	}
+	wep := &WorkloadEndpoint{
+		Tiers: []Tier{{Name: "default", IngressPolicies: []string{"web"}}},
+	}
+	return state, wep
+}
+
+var _ = Describe("Policy evaluation", func() {
+	It("should report log rules and the final verdict", func() {
+		state, wep := newLogRuleTestState()
+		flow := &Flow{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.1.2"), Proto: types.TCP, SrcPort: 1234, DstPort: 80}
+		verdict, err := wep.Evaluate(state, "", flow, true /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionAllow))
+		// the log rule does not end the evaluation of its policy
+		Expect(verdict.Rule).To(Equal(&RuleRef{Tier: "default", Policy: "web", RuleID: "rule-allow"}))
+		Expect(verdict.Logged).To(Equal([]RuleRef{{Tier: "default", Policy: "web", RuleID: "rule-log"}}))
+
+		// not in the frontend ipset
+		flow.SrcIP = net.ParseIP("10.0.0.2")
+		verdict, err = wep.Evaluate(state, "", flow, true /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionAllow))
+		Expect(verdict.Logged).To(BeEmpty())
+
+		// nothing matches
+		flow.DstPort = 443
+		verdict, err = wep.Evaluate(state, "", flow, true /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionDeny))
+		Expect(verdict.Rule).To(BeNil())
+
+		// no egress policies nor profiles
+		verdict, err = wep.Evaluate(state, "", flow, false /* ingress */)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verdict.Action).To(Equal(types.ActionAllow))
+	})
+})
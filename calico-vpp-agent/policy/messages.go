@@ -26,6 +26,14 @@ import (
 	"github.com/projectcalico/calico/felix/proto"
 )
 
+// Endpoint statuses reported to felix, these are the values used by the
+// linux dataplane
+const (
+	EndpointStatusUp    = "up"
+	EndpointStatusDown  = "down"
+	EndpointStatusError = "error"
+)
+
 func (s *Server) MessageReader(conn net.Conn) <-chan interface{} {
 	ch := make(chan interface{})
 
@@ -184,3 +192,54 @@ func (s *Server) SendMessage(conn net.Conn, msg interface{}) (err error) {
 	}
 	return nil
 }
+
+// sendStatusMessage sends an endpoint status message to felix if it is connected.
+// Failures are only logged, felix will get the statuses again on its next connection.
+func (s *Server) sendStatusMessage(msg interface{}) {
+	if s.felixConn == nil {
+		return
+	}
+	err := s.SendMessage(s.felixConn, msg)
+	if err != nil {
+		s.log.WithError(err).Warnf("Error sending endpoint status to felix %v", msg)
+	}
+}
+
+func endpointStatus(err error) string {
+	if err != nil {
+		return EndpointStatusError
+	}
+	return EndpointStatusUp
+}
+
+// reportWorkloadEndpointStatus tells felix whether the policies of a workload endpoint
+// are configured in VPP. Only endpoints of the default network are known to felix.
+func (s *Server) reportWorkloadEndpointStatus(id *WorkloadEndpointID, status string) {
+	if id.Network != "" {
+		return
+	}
+	s.sendStatusMessage(&proto.WorkloadEndpointStatusUpdate{
+		Id:     id.toProto(),
+		Status: &proto.EndpointStatus{Status: status},
+	})
+}
+
+func (s *Server) reportWorkloadEndpointRemoved(id *WorkloadEndpointID) {
+	if id.Network != "" {
+		return
+	}
+	s.sendStatusMessage(&proto.WorkloadEndpointStatusRemove{Id: id.toProto()})
+}
+
+// reportHostEndpointStatus tells felix whether the policies of a host endpoint
+// are configured in VPP.
+func (s *Server) reportHostEndpointStatus(id *HostEndpointID, status string) {
+	s.sendStatusMessage(&proto.HostEndpointStatusUpdate{
+		Id:     id.toProto(),
+		Status: &proto.EndpointStatus{Status: status},
+	})
+}
+
+func (s *Server) reportHostEndpointRemoved(id *HostEndpointID) {
+	s.sendStatusMessage(&proto.HostEndpointStatusRemove{Id: id.toProto()})
+}
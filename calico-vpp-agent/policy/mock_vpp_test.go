@@ -0,0 +1,295 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/binapi/memclnt"
+	"go.fd.io/govpp/codec"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
+)
+
+// sockclntCreateMsgID is the message ID of sockclnt_create, hard-coded in
+// VPP and govpp as it is sent before the message table is known
+const sockclntCreateMsgID = 15
+
+type delayedReply struct {
+	deadline time.Time
+	data     []byte
+}
+
+// mockVpp serves the VPP binary API on a unix socket, keeping track of the
+// capo objects and of the policies configured on interfaces. It replies to
+// each request after a fixed latency, in the order the requests were received.
+type mockVpp struct {
+	listener net.Listener
+	latency  time.Duration
+	msgTypes map[uint16]reflect.Type
+	msgIDs   map[string]uint16
+	table    []memclnt.MessageTableEntry
+	// replyTypes are the message types by package and name, to build the replies
+	replyTypes map[string]reflect.Type
+
+	lock   sync.Mutex
+	nextID uint32
+	// fail tells whether a request should get an error reply
+	fail       func(msg api.Message) bool
+	ipsets     map[uint32]bool
+	rules      map[uint32]bool
+	policies   map[uint32]bool
+	audited    map[uint32]bool
+	interfaces map[uint32][]uint32
+	stages     map[uint32][]uint32
+}
+
+// newMockVpp starts a mock VPP listening in dir, and returns a VppLink connected to it
+func newMockVpp(dir string, latency time.Duration) (*mockVpp, *vpplink.VppLink, error) {
+	m := &mockVpp{
+		latency:    latency,
+		msgTypes:   make(map[uint16]reflect.Type),
+		msgIDs:     make(map[string]uint16),
+		replyTypes: make(map[string]reflect.Type),
+		nextID:     100,
+		ipsets:     make(map[uint32]bool),
+		rules:      make(map[uint32]bool),
+		policies:   make(map[uint32]bool),
+		audited:    make(map[uint32]bool),
+		interfaces: make(map[uint32][]uint32),
+		stages:     make(map[uint32][]uint32),
+	}
+	msgID := uint16(sockclntCreateMsgID + 1)
+	for _, msgs := range api.GetRegisteredMessages() {
+		for _, msg := range msgs {
+			// Skip the messages of govpp itself, which are not generated
+			if _, ok := msg.(interface{ Unmarshal([]byte) error }); !ok {
+				continue
+			}
+			msgType := reflect.TypeOf(msg).Elem()
+			m.replyTypes[msgType.PkgPath()+"."+msg.GetMessageName()] = msgType
+			name := msg.GetMessageName() + "_" + msg.GetCrcString()
+			if _, ok := m.msgIDs[name]; ok {
+				continue
+			}
+			m.msgIDs[name] = msgID
+			m.msgTypes[msgID] = msgType
+			m.table = append(m.table, memclnt.MessageTableEntry{Index: msgID, Name: name})
+			msgID++
+		}
+	}
+	var err error
+	socket := filepath.Join(dir, "api.sock")
+	m.listener, err = net.Listen("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		for {
+			conn, err := m.listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	vpp, err := vpplink.NewVppLink(socket, logrus.NewEntry(logrus.New()))
+	if err != nil {
+		m.listener.Close()
+		return nil, nil, err
+	}
+	return m, vpp, nil
+}
+
+func (m *mockVpp) Close() {
+	m.listener.Close()
+}
+
+func readFrame(conn net.Conn) ([]byte, error) {
+	header := make([]byte, 16)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+	_, err = io.ReadFull(conn, data)
+	return data, err
+}
+
+func writeFrame(conn net.Conn, data []byte) error {
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(data)))
+	_, err := conn.Write(append(header, data...))
+	return err
+}
+
+func (m *mockVpp) serve(conn net.Conn) {
+	defer conn.Close()
+	replies := make(chan delayedReply, 1024)
+	defer close(replies)
+	go func() {
+		for reply := range replies {
+			time.Sleep(time.Until(reply.deadline))
+			if writeFrame(conn, reply.data) != nil {
+				return
+			}
+		}
+	}()
+	for {
+		data, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		var reply []byte
+		msgID := binary.BigEndian.Uint16(data[0:2])
+		if msgID == sockclntCreateMsgID {
+			reply, err = codec.DefaultCodec.EncodeMsg(&memclnt.SockclntCreateReply{
+				Index:        1,
+				Count:        uint16(len(m.table)),
+				MessageTable: m.table,
+			}, 0)
+		} else {
+			reply, err = m.handle(msgID, data)
+		}
+		if err != nil || reply == nil {
+			continue
+		}
+		replies <- delayedReply{deadline: time.Now().Add(m.latency), data: reply}
+	}
+}
+
+// handle decodes a request and encodes its reply, with the request context
+func (m *mockVpp) handle(msgID uint16, data []byte) ([]byte, error) {
+	msgType, ok := m.msgTypes[msgID]
+	if !ok {
+		return nil, nil
+	}
+	request := reflect.New(msgType).Interface().(api.Message)
+	err := codec.DefaultCodec.DecodeMsg(data, request)
+	if err != nil {
+		return nil, err
+	}
+	reply := m.reply(request)
+	if reply == nil {
+		return nil, nil
+	}
+	replyData, err := codec.DefaultCodec.EncodeMsg(reply, m.msgIDs[reply.GetMessageName()+"_"+reply.GetCrcString()])
+	if err != nil {
+		return nil, err
+	}
+	// The reply context is the one of the request, after its ID and client index
+	copy(replyData[2:6], data[6:10])
+	return replyData, nil
+}
+
+func (m *mockVpp) reply(request api.Message) api.Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.fail != nil && m.fail(request) {
+		reply := m.emptyReply(request)
+		if reply != nil {
+			reflect.ValueOf(reply).Elem().FieldByName("Retval").SetInt(int64(api.INVALID_VALUE))
+		}
+		return reply
+	}
+	switch req := request.(type) {
+	case *capo.CapoIpsetCreate:
+		m.nextID++
+		m.ipsets[m.nextID] = true
+		return &capo.CapoIpsetCreateReply{SetID: m.nextID}
+	case *capo.CapoIpsetDelete:
+		delete(m.ipsets, req.SetID)
+	case *capo.CapoRuleCreate:
+		m.nextID++
+		m.rules[m.nextID] = true
+		return &capo.CapoRuleCreateReply{RuleID: m.nextID}
+	case *capo.CapoRuleDelete:
+		delete(m.rules, req.RuleID)
+	case *capo.CapoPolicyCreate:
+		m.nextID++
+		m.policies[m.nextID] = true
+		return &capo.CapoPolicyCreateReply{PolicyID: m.nextID}
+	case *capo.CapoPolicyDelete:
+		delete(m.policies, req.PolicyID)
+		delete(m.audited, req.PolicyID)
+	case *capo.CapoPolicySetMode:
+		m.audited[req.PolicyID] = req.Mode == capo.CAPO_POLICY_AUDIT
+	case *capo.CapoConfigurePolicies:
+		if req.TotalIds == 0 {
+			delete(m.interfaces, req.SwIfIndex)
+		} else {
+			m.interfaces[req.SwIfIndex] = req.PolicyIds
+		}
+	case *capo.CapoConfigureStages:
+		if req.TotalIds == 0 {
+			delete(m.stages, req.SwIfIndex)
+		} else {
+			m.stages[req.SwIfIndex] = req.PolicyIds
+		}
+	}
+	return m.emptyReply(request)
+}
+
+// emptyReply returns a successful reply to a request, without any data
+func (m *mockVpp) emptyReply(request api.Message) api.Message {
+	pkgPath := reflect.TypeOf(request).Elem().PkgPath()
+	replyType, ok := m.replyTypes[pkgPath+"."+request.GetMessageName()+"_reply"]
+	if !ok {
+		return nil
+	}
+	return reflect.New(replyType).Interface().(api.Message)
+}
+
+// objects returns the number of capo ipsets, rules and policies in the mock VPP
+func (m *mockVpp) objects() (ipsets, rules, policies int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.ipsets), len(m.rules), len(m.policies)
+}
+
+func (m *mockVpp) policyAudited(policyID uint32) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.audited[policyID]
+}
+
+func (m *mockVpp) interfacePolicies(swIfIndex uint32) []uint32 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.interfaces[swIfIndex]
+}
+
+func (m *mockVpp) interfaceStages(swIfIndex uint32) []uint32 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.stages[swIfIndex]
+}
+
+func (m *mockVpp) setFail(fail func(msg api.Message) bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.fail = fail
+}
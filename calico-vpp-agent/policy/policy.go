@@ -42,6 +42,9 @@ type Policy struct {
 	VppID         uint32
 	InboundRules  []*Rule
 	OutboundRules []*Rule
+	// Audit is set for policies in audit mode, whose rules are counted
+	// but not enforced, see audit.go
+	Audit bool
 }
 
 func (p *Policy) DeepCopy() *Policy {
@@ -50,6 +53,7 @@ func (p *Policy) DeepCopy() *Policy {
 		VppID:         p.VppID,
 		InboundRules:  make([]*Rule, 0),
 		OutboundRules: make([]*Rule, 0),
+		Audit:         p.Audit,
 	}
 	for _, r := range p.InboundRules {
 		policy.InboundRules = append(policy.InboundRules, r.DeepCopy())
@@ -85,36 +89,31 @@ func fromProtoPolicy(p *proto.Policy, network string) (policy *Policy, err error
 		Policy: &types.Policy{},
 		VppID:  types.InvalidID,
 	}
-	if p.Untracked {
-		log.Errorf("untracked policies not supported")
-		return
-	}
 	if p.PreDnat && len(p.OutboundRules) > 0 {
 		log.Errorf("pre dnat outbound policies not supported")
 		return
 	}
-	if p.PreDnat && len(p.InboundRules) > 0 {
-		log.Errorf("pre dnat inbound policies ????")
-		return
-	}
-	for _, r := range p.InboundRules {
+	for i, r := range p.InboundRules {
 		if ruleInNetwork(r, network) {
-			rule, err := fromProtoRule(r)
+			rules, err := fromProtoRule(r)
 			if err != nil {
 				return nil, err
 			}
-			policy.InboundRules = append(policy.InboundRules, rule)
+			setRulesIndex(rules, i)
+			policy.InboundRules = append(policy.InboundRules, rules...)
 		}
 	}
-	for _, r := range p.OutboundRules {
+	for i, r := range p.OutboundRules {
 		if ruleInNetwork(r, network) {
-			rule, err := fromProtoRule(r)
+			rules, err := fromProtoRule(r)
 			if err != nil {
 				return nil, err
 			}
-			policy.OutboundRules = append(policy.OutboundRules, rule)
+			setRulesIndex(rules, i)
+			policy.OutboundRules = append(policy.OutboundRules, rules...)
 		}
 	}
+	policy.Audit = isAuditedProtoPolicy(p)
 	return policy, nil
 }
 
@@ -123,57 +122,67 @@ func fromProtoProfile(p *proto.Profile) (profile *Policy, err error) {
 		Policy: &types.Policy{},
 		VppID:  types.InvalidID,
 	}
-	for _, r := range p.InboundRules {
-		rule, err := fromProtoRule(r)
+	for i, r := range p.InboundRules {
+		rules, err := fromProtoRule(r)
 		if err != nil {
 			return nil, err
 		}
-		profile.InboundRules = append(profile.InboundRules, rule)
+		setRulesIndex(rules, i)
+		profile.InboundRules = append(profile.InboundRules, rules...)
 	}
-	for _, r := range p.OutboundRules {
-		rule, err := fromProtoRule(r)
+	for i, r := range p.OutboundRules {
+		rules, err := fromProtoRule(r)
 		if err != nil {
 			return nil, err
 		}
-		profile.OutboundRules = append(profile.OutboundRules, rule)
+		setRulesIndex(rules, i)
+		profile.OutboundRules = append(profile.OutboundRules, rules...)
 	}
 	return profile, nil
 }
 
 func (p *Policy) createRules(vpp *vpplink.VppLink, state *PolicyState) (err error) {
+	err = createRules(vpp, state, append(append([]*Rule{}, p.InboundRules...), p.OutboundRules...))
+	if err != nil {
+		return err
+	}
+	p.setRuleIDs()
+	return nil
+}
+
+// setRuleIDs sets the VPP IDs of the rules in the VPP policy
+func (p *Policy) setRuleIDs() {
 	p.InboundRuleIDs = make([]uint32, 0, len(p.InboundRules))
 	for _, rule := range p.InboundRules {
-		err := rule.Create(vpp, state)
-		if err != nil {
-			return err
-		}
 		p.InboundRuleIDs = append(p.InboundRuleIDs, rule.VppID)
 	}
 	p.OutboundRuleIDs = make([]uint32, 0, len(p.OutboundRules))
 	for _, rule := range p.OutboundRules {
-		err := rule.Create(vpp, state)
-		if err != nil {
-			return err
-		}
 		p.OutboundRuleIDs = append(p.OutboundRuleIDs, rule.VppID)
 	}
-	return nil
 }
 
 func (p *Policy) deleteRules(vpp *vpplink.VppLink, state *PolicyState) (err error) {
-	for _, rule := range p.InboundRules {
-		err = rule.Delete(vpp)
-		if err != nil {
-			return err
+	return deleteRules(vpp, append(append([]*Rule{}, p.InboundRules...), p.OutboundRules...))
+}
+
+// equalRules tells whether both policies have identical rules in VPP,
+// ipsets having been resolved
+func (p *Policy) equalRules(other *Policy) bool {
+	if len(p.InboundRules) != len(other.InboundRules) || len(p.OutboundRules) != len(other.OutboundRules) {
+		return false
+	}
+	for i, rule := range p.InboundRules {
+		if !rule.equal(other.InboundRules[i]) {
+			return false
 		}
 	}
-	for _, rule := range p.OutboundRules {
-		err = rule.Delete(vpp)
-		if err != nil {
-			return err
+	for i, rule := range p.OutboundRules {
+		if !rule.equal(other.OutboundRules[i]) {
+			return false
 		}
 	}
-	return nil
+	return true
 }
 
 func (p *Policy) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
@@ -188,6 +197,9 @@ func (p *Policy) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
 	p.VppID = id
 	log.Infof("policy(add) VPP policy id=%d inbound=[%+v]=[%+v] outbound=[%+v]=[%+v]",
 		p.VppID, p.InboundRules, p.InboundRuleIDs, p.OutboundRules, p.OutboundRuleIDs)
+	if p.Audit {
+		return p.setAuditMode(vpp)
+	}
 	return nil
 }
 
@@ -202,6 +214,10 @@ func (p *Policy) Update(vpp *vpplink.VppLink, new *Policy, state *PolicyState) (
 	// Update policy
 	err = vpp.PolicyUpdate(p.VppID, new.Policy)
 	if err != nil {
+		delErr := new.deleteRules(vpp, state)
+		if delErr != nil {
+			log.Warnf("error deleting rules: %v", delErr)
+		}
 		return errors.Wrap(err, "cannot update policy")
 	}
 
@@ -216,6 +232,12 @@ func (p *Policy) Update(vpp *vpplink.VppLink, new *Policy, state *PolicyState) (
 	p.InboundRuleIDs = new.InboundRuleIDs
 	p.OutboundRules = new.OutboundRules
 	p.OutboundRuleIDs = new.OutboundRuleIDs
+
+	// The mode is kept across policy updates in VPP
+	if p.Audit != new.Audit {
+		p.Audit = new.Audit
+		return p.setAuditMode(vpp)
+	}
 	return nil
 }
 
@@ -233,3 +255,77 @@ func (p *Policy) Delete(vpp *vpplink.VppLink, state *PolicyState) (err error) {
 	p.VppID = types.InvalidID
 	return nil
 }
+
+// createPolicies creates the rules of all the policies, and then the policies,
+// pipelining the requests to VPP
+func createPolicies(vpp *vpplink.VppLink, state *PolicyState, policies []*Policy) (err error) {
+	if len(policies) == 0 {
+		return nil
+	}
+	rules := make([]*Rule, 0)
+	for _, p := range policies {
+		rules = append(append(rules, p.InboundRules...), p.OutboundRules...)
+	}
+	err = createRules(vpp, state, rules)
+	if err != nil {
+		return errors.Wrap(err, "cannot create rules for policies")
+	}
+	vppPolicies := make([]*types.Policy, 0, len(policies))
+	for _, p := range policies {
+		p.setRuleIDs()
+		vppPolicies = append(vppPolicies, p.Policy)
+	}
+	ids, err := vpp.PoliciesCreate(vppPolicies)
+	orphans := make([]*Rule, 0)
+	for i, p := range policies {
+		p.VppID = ids[i]
+		if p.VppID == types.InvalidID {
+			orphans = append(append(orphans, p.InboundRules...), p.OutboundRules...)
+		}
+	}
+	if err != nil {
+		// The rules of the policies that were not created are not referenced
+		delErr := deleteRules(vpp, orphans)
+		if delErr != nil {
+			log.Warnf("error deleting rules: %v", delErr)
+		}
+		return errors.Wrap(err, "cannot create policies")
+	}
+	for _, p := range policies {
+		if p.Audit {
+			err = p.setAuditMode(vpp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	log.Infof("policy(add) created %d VPP policies with %d rules", len(policies), len(rules))
+	return nil
+}
+
+// deletePolicies deletes the policies and then their rules, pipelining
+// the requests to VPP
+func deletePolicies(vpp *vpplink.VppLink, policies []*Policy) (err error) {
+	if len(policies) == 0 {
+		return nil
+	}
+	ids := make([]uint32, 0, len(policies))
+	rules := make([]*Rule, 0)
+	for _, p := range policies {
+		ids = append(ids, p.VppID)
+		rules = append(append(rules, p.InboundRules...), p.OutboundRules...)
+	}
+	err = vpp.PoliciesDelete(ids)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete policies")
+	}
+	for _, p := range policies {
+		p.VppID = types.InvalidID
+	}
+	err = deleteRules(vpp, rules)
+	if err != nil {
+		return errors.Wrap(err, "cannot delete rules for policies")
+	}
+	log.Infof("policy(del) deleted %d VPP policies with %d rules", len(policies), len(rules))
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/projectcalico/api/pkg/lib/numorstring"
@@ -71,6 +72,8 @@ type Server struct {
 
 	state         SyncState
 	nextSeqNumber uint64
+	// felixConn is the connection to felix while it is connected, used to report endpoint statuses
+	felixConn net.Conn
 
 	endpointsLock       sync.Mutex
 	endpointsInterfaces map[WorkloadEndpointID]map[string]uint32
@@ -80,8 +83,12 @@ type Server struct {
 
 	/* failSafe policies allow traffic on some ports irrespective of the policy */
 	failSafePolicy *Policy
+	/* failSafeReply policies allow the replies to the failsafe traffic in the untracked policies */
+	failSafeReplyPolicy *Policy
 	/* workloadToHost may drop traffic that goes from the pods to the host */
-	workloadsToHostPolicy  *Policy
+	workloadsToHostPolicy *Policy
+	/* allowAllPolicy allows all traffic, it terminates policy lists that must not drop */
+	allowAllPolicy         *Policy
 	defaultTap0IngressConf []uint32
 	/* always allow traffic coming from host to the pods (for healthchecks and so on) */
 	// AllowFromHostPolicy persists the policy allowing host --> pod communications.
@@ -91,9 +98,11 @@ type Server struct {
 	allPodsIpset *IPSet
 	/* allow traffic between uplink/tunnels and tap interfaces */
 	allowToHostPolicy *Policy
-	ip4               *net.IP
-	ip6               *net.IP
-	interfacesMap     map[string]interfaceDetails
+	/* auditPassPolicy terminates the policies of endpoints with only audited policies */
+	auditPassPolicy *Policy
+	ip4             *net.IP
+	ip6             *net.IP
+	interfacesMap   map[string]interfaceDetails
 
 	policyServerEventChan chan common.CalicoVppEvent
 	networkDefinitions    map[string]*watchers.NetworkDefinition
@@ -112,6 +121,17 @@ type Server struct {
 	nodeByWGPublicKey map[string]string
 
 	GotOurNodeBGPchan chan interface{}
+
+	// flowLogger emits the flows matching log rules, nil when flow logs are disabled
+	flowLogger     *FlowLogger
+	flowLogPackets chan *flowLogPacket
+
+	// policyRulesChanged is set when capo rules were added or removed since
+	// their labels were last sent to the prometheus server
+	policyRulesChanged bool
+
+	// simulations are the policy simulations requested on the agent API
+	simulations chan *simulation
 }
 
 // NewServer creates a policy server
@@ -143,6 +163,13 @@ func NewPolicyServer(vpp *vpplink.VppLink, log *logrus.Entry) (*Server, error) {
 
 		nodeStatesByName:  make(map[string]*common.LocalNodeSpec),
 		GotOurNodeBGPchan: make(chan interface{}),
+
+		simulations: make(chan *simulation),
+	}
+
+	if *config.GetCalicoVppFlowLogs().Enabled {
+		server.flowLogger = NewFlowLogger(config.GetCalicoVppFlowLogs(), log.WithField("subcomponent", "flowlogs"))
+		server.flowLogPackets = make(chan *flowLogPacket, common.ChanSize)
 	}
 
 	reg := common.RegisterHandler(server.policyServerEventChan, "policy server events")
@@ -248,7 +275,6 @@ func (s *Server) getEndpointToHostAction() types.RuleAction {
 // workloadAdded is called by the CNI server when a container interface is created,
 // either during startup when reconnecting the interfaces, or when a new pod is created
 func (s *Server) workloadAdded(id *WorkloadEndpointID, swIfIndex uint32, ifName string, containerIPs []*net.IPNet) {
-	// TODO: Send WorkloadEndpointStatusUpdate to felix
 	s.endpointsLock.Lock()
 	defer s.endpointsLock.Unlock()
 
@@ -283,6 +309,7 @@ func (s *Server) workloadAdded(id *WorkloadEndpointID, swIfIndex uint32, ifName
 			if err != nil {
 				s.log.Errorf("Error processing workload addition: %s", err)
 			}
+			s.reportWorkloadEndpointStatus(id, endpointStatus(err))
 		}
 	}
 	// EndpointToHostAction
@@ -298,7 +325,6 @@ func (s *Server) workloadAdded(id *WorkloadEndpointID, swIfIndex uint32, ifName
 
 // WorkloadRemoved is called by the CNI server when the interface of a pod is deleted
 func (s *Server) WorkloadRemoved(id *WorkloadEndpointID, containerIPs []*net.IPNet) {
-	// TODO: Send WorkloadEndpointStatusRemove to felix
 	s.endpointsLock.Lock()
 	defer s.endpointsLock.Unlock()
 
@@ -318,6 +344,8 @@ func (s *Server) WorkloadRemoved(id *WorkloadEndpointID, containerIPs []*net.IPN
 			if err != nil {
 				s.log.Errorf("Error processing workload removal: %s", err)
 			}
+			// The endpoint is still known to felix, it only lost its interface
+			s.reportWorkloadEndpointStatus(id, EndpointStatusDown)
 		}
 	}
 	delete(s.endpointsInterfaces, *id)
@@ -352,10 +380,7 @@ func (s *Server) handlePolicyServerEvents(evt common.CalicoVppEvent) error {
 		if !ok {
 			return fmt.Errorf("evt.New is not a (*storage.LocalPodSpec) %v", evt.New)
 		}
-		swIfIndex := podSpec.TunTapSwIfIndex
-		if swIfIndex == vpplink.InvalidID {
-			swIfIndex = podSpec.MemifSwIfIndex
-		}
+		swIfIndex := podSpec.GetPodSwIfIndex()
 		s.workloadAdded(&WorkloadEndpointID{
 			OrchestratorID: podSpec.OrchestratorID,
 			WorkloadID:     podSpec.WorkloadID,
@@ -462,10 +487,21 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 	if err != nil {
 		return errors.Wrap(err, "Error in createAllowToHostPolicy")
 	}
+	err = s.createAuditPassPolicy()
+	if err != nil {
+		return errors.Wrap(err, "Error in createAuditPassPolicy")
+	}
 	err = s.createFailSafePolicies()
 	if err != nil {
 		return errors.Wrap(err, "Error in createFailSafePolicies")
 	}
+	// driftChecks stays nil when drift detection is disabled
+	var driftChecks <-chan time.Time
+	if *config.GetCalicoVppPolicyDrift().Enabled {
+		driftTicker := time.NewTicker(*config.GetCalicoVppPolicyDrift().Interval)
+		defer driftTicker.Stop()
+		driftChecks = driftTicker.C
+	}
 	for {
 		s.state = StateDisconnected
 		// Accept only one connection
@@ -475,6 +511,7 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 		}
 		s.log.Infof("Accepted connection from felix")
 		s.state = StateConnected
+		s.felixConn = conn
 
 		felixUpdates := s.MessageReader(conn)
 	innerLoop:
@@ -482,6 +519,7 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 			select {
 			case <-t.Dying():
 				s.log.Warn("Policy server exiting")
+				s.felixConn = nil
 				err = conn.Close()
 				if err != nil {
 					s.log.WithError(err).Warn("Error closing unix connection to felix API proxy")
@@ -493,6 +531,20 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 				if err != nil {
 					s.log.WithError(err).Warn("Error handling PolicyServerEvents")
 				}
+			case packet := <-s.flowLogPackets:
+				s.handleFlowLogPacket(packet)
+			case <-driftChecks:
+				if s.state != StateInSync {
+					continue
+				}
+				err = s.checkPolicyDrift()
+				s.publishRuleLabels()
+				if err != nil {
+					s.log.WithError(err).Error("Error checking policy drift")
+				}
+			case sim := <-s.simulations:
+				reply, err := s.simulate(sim.request)
+				sim.reply <- simulationResult{reply: reply, err: err}
 			// <-felixUpdates & handleFelixUpdate does the bulk of the policy sync job. It starts by reconciling the current
 			// configured state in VPP (empty at first) with what is sent by felix, and once both are in
 			// sync, it keeps processing felix updates. It also sends endpoint updates to felix when the
@@ -503,6 +555,7 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 					break innerLoop
 				}
 				err = s.handleFelixUpdate(msg)
+				s.publishRuleLabels()
 				if err != nil {
 					switch err.(type) {
 					case NodeWatcherRestartError:
@@ -515,6 +568,7 @@ func (s *Server) ServePolicy(t *tomb.Tomb) error {
 				}
 			}
 		}
+		s.felixConn = nil
 		err = conn.Close()
 		if err != nil {
 			s.log.WithError(err).Warn("Error closing unix connection to felix API proxy")
@@ -799,12 +853,16 @@ func (s *Server) handleActivePolicyUpdate(msg *proto.ActivePolicyUpdate, pending
 	}
 
 	log.Infof("Handling ActivePolicyUpdate pending=%t id=%s %s", pending, id, p)
+	// the endpoints using the policy are terminated by the audit pass policy
+	// or not depending on its mode
+	auditChanged := false
 	existing, ok := state.Policies[id]
 	if ok { // Policy with this ID already exists
 		if pending {
 			// Just replace policy in pending state
 			state.Policies[id] = p
 		} else {
+			auditChanged = auditChanged || existing.Audit != p.Audit
 			err := existing.Update(s.vpp, p, state)
 			if err != nil {
 				return errors.Wrap(err, "cannot update policy")
@@ -840,6 +898,7 @@ func (s *Server) handleActivePolicyUpdate(msg *proto.ActivePolicyUpdate, pending
 				// Just replace policy in pending state
 				state.Policies[id] = p
 			} else {
+				auditChanged = auditChanged || existing.Audit != p.Audit
 				err := existing.Update(s.vpp, p, state)
 				if err != nil {
 					return errors.Wrap(err, "cannot update policy")
@@ -857,6 +916,12 @@ func (s *Server) handleActivePolicyUpdate(msg *proto.ActivePolicyUpdate, pending
 		}
 
 	}
+	if !pending {
+		s.policyRulesChanged = true
+	}
+	if auditChanged {
+		return s.reconfigureEndpoints(state)
+	}
 	return nil
 }
 
@@ -882,6 +947,9 @@ func (s *Server) handleActivePolicyRemove(msg *proto.ActivePolicyRemove, pending
 				}
 			}
 			delete(state.Policies, policyId)
+			if !pending {
+				s.policyRulesChanged = true
+			}
 		}
 	}
 	return nil
@@ -917,6 +985,9 @@ func (s *Server) handleActiveProfileUpdate(msg *proto.ActiveProfileUpdate, pendi
 		}
 	}
 	log.Infof("policy(upd) Handled Profile Update pending=%t id=%s existing=%s new=%s", pending, id, existing, p)
+	if !pending {
+		s.policyRulesChanged = true
+	}
 	return nil
 }
 
@@ -936,6 +1007,9 @@ func (s *Server) handleActiveProfileRemove(msg *proto.ActiveProfileRemove, pendi
 	}
 	log.Infof("policy(del) Handled Profile Remove pending=%t id=%s policy=%s", pending, id, existing)
 	delete(state.Profiles, id)
+	if !pending {
+		s.policyRulesChanged = true
+	}
 	return nil
 }
 
@@ -984,6 +1058,9 @@ func (s *Server) handleHostEndpointUpdate(msg *proto.HostEndpointUpdate, pending
 	hep.TunnelSwIfIndexes = s.getAllTunnelSwIfIndexes()
 	if len(hep.UplinkSwIfIndexes) == 0 || len(hep.TapSwIfIndexes) == 0 {
 		s.log.Warnf("No interface in vpp for host endpoint id=%s hep=%s", id.EndpointID, hep.String())
+		if !pending {
+			s.reportHostEndpointStatus(id, EndpointStatusDown)
+		}
 		return nil
 	}
 
@@ -991,9 +1068,11 @@ func (s *Server) handleHostEndpointUpdate(msg *proto.HostEndpointUpdate, pending
 	if found {
 		if pending {
 			hep.currentForwardConf = existing.currentForwardConf
+			hep.currentStagesConf = existing.currentStagesConf
 			state.HostEndpoints[*id] = hep
 		} else {
 			err := existing.Update(s.vpp, hep, state)
+			s.reportHostEndpointStatus(id, endpointStatus(err))
 			if err != nil {
 				return errors.Wrap(err, "cannot update host endpoint")
 			}
@@ -1003,6 +1082,7 @@ func (s *Server) handleHostEndpointUpdate(msg *proto.HostEndpointUpdate, pending
 		state.HostEndpoints[*id] = hep
 		if !pending {
 			err := hep.Create(s.vpp, state)
+			s.reportHostEndpointStatus(id, endpointStatus(err))
 			if err != nil {
 				return errors.Wrap(err, "cannot create host endpoint")
 			}
@@ -1028,6 +1108,9 @@ func (s *Server) handleHostEndpointRemove(msg *proto.HostEndpointRemove, pending
 	}
 	log.Infof("policy(del) Handled Host Endpoint Remove pending=%t id=%s %s", pending, id, existing)
 	delete(state.HostEndpoints, *id)
+	if !pending {
+		s.reportHostEndpointRemoved(id)
+	}
 	return nil
 }
 
@@ -1070,8 +1153,12 @@ func (s *Server) handleWorkloadEndpointUpdate(msg *proto.WorkloadEndpointUpdate,
 			if pending || !swIfIndexFound {
 				state.WorkloadEndpoints[*id] = wep
 				log.Infof("policy(upd) Workload Endpoint Update pending=%t id=%s existing=%s new=%s swIf=??", pending, *id, existing, wep)
+				if !pending {
+					s.reportWorkloadEndpointStatus(id, EndpointStatusDown)
+				}
 			} else {
 				err := existing.Update(s.vpp, wep, state, id.Network)
+				s.reportWorkloadEndpointStatus(id, endpointStatus(err))
 				if err != nil {
 					return errors.Wrap(err, "cannot update workload endpoint")
 				}
@@ -1085,12 +1172,16 @@ func (s *Server) handleWorkloadEndpointUpdate(msg *proto.WorkloadEndpointUpdate,
 					swIfIndexList = append(swIfIndexList, idx)
 				}
 				err := wep.Create(s.vpp, swIfIndexList, state, id.Network)
+				s.reportWorkloadEndpointStatus(id, endpointStatus(err))
 				if err != nil {
 					return errors.Wrap(err, "cannot create workload endpoint")
 				}
 				log.Infof("policy(add) Workload Endpoint add pending=%t id=%s new=%s swIf=%v", pending, *id, wep, swIfIndexMap)
 			} else {
 				log.Infof("policy(add) Workload Endpoint add pending=%t id=%s new=%s swIf=??", pending, *id, wep)
+				if !pending {
+					s.reportWorkloadEndpointStatus(id, EndpointStatusDown)
+				}
 			}
 		}
 	}
@@ -1116,6 +1207,9 @@ func (s *Server) handleWorkloadEndpointRemove(msg *proto.WorkloadEndpointRemove,
 	}
 	log.Infof("policy(del) Handled Workload Endpoint Remove pending=%t id=%s existing=%s", pending, *id, existing)
 	delete(state.WorkloadEndpoints, *id)
+	if !pending {
+		s.reportWorkloadEndpointRemoved(id)
+	}
 	for existingId := range state.WorkloadEndpoints {
 		if existingId.OrchestratorID == id.OrchestratorID && existingId.WorkloadID == id.WorkloadID {
 			if !pending && len(existing.SwIfIndex) != 0 {
@@ -1488,88 +1582,193 @@ func (s *Server) handleGlobalBGPConfigUpdate(msg *proto.GlobalBGPConfigUpdate, p
 	return nil
 }
 
-// Reconciles the pending state with the configured state
+// applyPendingState reconciles the configured state with the pending state received
+// from felix, only programming the differences in VPP: ipsets are updated in place,
+// unchanged policies and profiles are kept, and new ones are created in batches.
+// The pending state only becomes the configured state once programmed. On error,
+// the objects of the former state that are still in VPP are kept in it, so that
+// the next resync reuses or deletes them.
 func (s *Server) applyPendingState() (err error) {
 	s.log.Infof("Reconciliating pending policy state with configured state")
-	// Stupid algorithm for now, delete all that is in configured state, and then recreate everything
-	for _, wep := range s.configuredState.WorkloadEndpoints {
-		if len(wep.SwIfIndex) != 0 {
-			err = wep.Delete(s.vpp)
+	state := s.pendingState
+	s.pendingState = NewPolicyState()
+	err = s.reconcileState(s.configuredState, state)
+	if err != nil {
+		s.keepProgrammedObjects(s.configuredState, state)
+	}
+	s.configuredState = state
+	s.policyRulesChanged = true
+	if err != nil {
+		return err
+	}
+	s.log.Infof("Reconciliation done")
+	return nil
+}
+
+// reconcileState programs the differences between oldState, as configured in VPP,
+// and state
+func (s *Server) reconcileState(oldState, state *PolicyState) (err error) {
+	for name, ipset := range state.IPSets {
+		existing, ok := oldState.IPSets[name]
+		if ok && existing.Type == ipset.Type && existing.VppID != types.InvalidID {
+			err = existing.Update(s.vpp, ipset)
 			if err != nil {
-				return errors.Wrap(err, "cannot cleanup workload endpoint")
+				return errors.Wrap(err, "error updating ipset")
 			}
+			state.IPSets[name] = existing
+			continue
 		}
-	}
-	for _, policy := range s.configuredState.Policies {
-		err = policy.Delete(s.vpp, s.configuredState)
-		if err != nil {
-			s.log.Warnf("error deleting policy: %v", err)
-		}
-	}
-	for _, profile := range s.configuredState.Profiles {
-		err = profile.Delete(s.vpp, s.configuredState)
+		err = ipset.Create(s.vpp)
 		if err != nil {
-			s.log.Warnf("error deleting profile: %v", err)
+			return errors.Wrap(err, "error creating ipset")
 		}
 	}
-	for _, ipset := range s.configuredState.IPSets {
-		err = ipset.Delete(s.vpp)
-		if err != nil {
-			s.log.Warnf("error deleting ipset: %v", err)
+
+	newPolicies := make([]*Policy, 0)
+	reconcilePolicy := func(policy *Policy, existing *Policy) (*Policy, error) {
+		if existing == nil || existing.VppID == types.InvalidID {
+			newPolicies = append(newPolicies, policy)
+			return policy, nil
 		}
-	}
-	for _, hep := range s.configuredState.HostEndpoints {
-		if len(hep.UplinkSwIfIndexes) != 0 {
-			err = hep.Delete(s.vpp, s.configuredState)
+		for _, rule := range append(append([]*Rule{}, policy.InboundRules...), policy.OutboundRules...) {
+			err := rule.resolveIPSets(state)
 			if err != nil {
-				s.log.Warnf("error deleting hostendpoint : %v", err)
+				return nil, err
 			}
 		}
-	}
-
-	s.configuredState = s.pendingState
-	s.pendingState = NewPolicyState()
-	for _, ipset := range s.configuredState.IPSets {
-		err = ipset.Create(s.vpp)
-		if err != nil {
-			return errors.Wrap(err, "error creating ipset")
+		if policy.equalRules(existing) && policy.Audit == existing.Audit {
+			return existing, nil
 		}
+		return existing, existing.Update(s.vpp, policy, state)
 	}
-	for _, profile := range s.configuredState.Profiles {
-		err = profile.Create(s.vpp, s.configuredState)
+	for name, profile := range state.Profiles {
+		state.Profiles[name], err = reconcilePolicy(profile, oldState.Profiles[name])
 		if err != nil {
-			return errors.Wrap(err, "error creating profile")
+			return errors.Wrap(err, "error updating profile")
 		}
 	}
-	for _, policy := range s.configuredState.Policies {
-		err = policy.Create(s.vpp, s.configuredState)
+	for id, policy := range state.Policies {
+		state.Policies[id], err = reconcilePolicy(policy, oldState.Policies[id])
 		if err != nil {
-			return errors.Wrap(err, "error creating policy")
+			return errors.Wrap(err, "error updating policy")
 		}
 	}
-	for id, wep := range s.configuredState.WorkloadEndpoints {
+	err = createPolicies(s.vpp, state, newPolicies)
+	if err != nil {
+		return errors.Wrap(err, "error creating policies")
+	}
+
+	for id, wep := range state.WorkloadEndpoints {
 		intf, intfFound := s.endpointsInterfaces[id]
 		if intfFound {
 			swIfIndexList := []uint32{}
 			for _, idx := range intf {
 				swIfIndexList = append(swIfIndexList, idx)
 			}
-			err = wep.Create(s.vpp, swIfIndexList, s.configuredState, id.Network)
+			err = wep.Create(s.vpp, swIfIndexList, state, id.Network)
+			s.reportWorkloadEndpointStatus(&id, endpointStatus(err))
 			if err != nil {
 				return errors.Wrap(err, "cannot configure workload endpoint")
 			}
+		} else {
+			s.reportWorkloadEndpointStatus(&id, EndpointStatusDown)
 		}
 	}
-	for _, hep := range s.configuredState.HostEndpoints {
-		err = hep.Create(s.vpp, s.configuredState)
+	// Endpoints are unconfigured before their policies are deleted
+	for id, wep := range oldState.WorkloadEndpoints {
+		if _, found := state.WorkloadEndpoints[id]; found || len(wep.SwIfIndex) == 0 {
+			continue
+		}
+		err = wep.Unconfigure(s.vpp)
+		if err != nil {
+			return errors.Wrap(err, "cannot unconfigure workload endpoint")
+		}
+	}
+	for id, hep := range oldState.HostEndpoints {
+		if _, found := state.HostEndpoints[id]; found || len(hep.UplinkSwIfIndexes) == 0 {
+			continue
+		}
+		err = hep.Delete(s.vpp, oldState)
+		if err != nil {
+			return errors.Wrap(err, "cannot delete host endpoint")
+		}
+	}
+	for id, hep := range state.HostEndpoints {
+		err = hep.Create(s.vpp, state)
+		s.reportHostEndpointStatus(&id, endpointStatus(err))
 		if err != nil {
 			return errors.Wrap(err, "cannot create host endpoint")
 		}
 	}
-	s.log.Infof("Reconciliation done")
+
+	// Policies and ipsets are deleted last, once they are not referenced anymore
+	stalePolicies := make([]*Policy, 0)
+	for name, profile := range oldState.Profiles {
+		if state.Profiles[name] != profile && profile.VppID != types.InvalidID {
+			stalePolicies = append(stalePolicies, profile)
+		}
+	}
+	for id, policy := range oldState.Policies {
+		if state.Policies[id] != policy && policy.VppID != types.InvalidID {
+			stalePolicies = append(stalePolicies, policy)
+		}
+	}
+	err = deletePolicies(s.vpp, stalePolicies)
+	if err != nil {
+		s.log.Warnf("error deleting policies: %v", err)
+	}
+	for name, ipset := range oldState.IPSets {
+		if state.IPSets[name] != ipset && ipset.VppID != types.InvalidID {
+			err = ipset.Delete(s.vpp)
+			if err != nil {
+				s.log.Warnf("error deleting ipset: %v", err)
+			}
+		}
+	}
 	return nil
 }
 
+// keepProgrammedObjects adds to state the objects of oldState that a failed
+// reconciliation left in VPP: ipsets and policies that were not replaced, and
+// endpoints that were not reconfigured nor unconfigured
+func (s *Server) keepProgrammedObjects(oldState, state *PolicyState) {
+	for name, ipset := range oldState.IPSets {
+		existing, ok := state.IPSets[name]
+		if ipset.VppID == types.InvalidID || existing == ipset {
+			continue
+		}
+		if ok && existing.VppID != types.InvalidID {
+			s.log.Warnf("ipset %s was recreated, leaking former VPP ipset %d", name, ipset.VppID)
+			continue
+		}
+		state.IPSets[name] = ipset
+	}
+	for name, profile := range oldState.Profiles {
+		existing, ok := state.Profiles[name]
+		if profile.VppID != types.InvalidID && (!ok || existing.VppID == types.InvalidID) {
+			state.Profiles[name] = profile
+		}
+	}
+	for id, policy := range oldState.Policies {
+		existing, ok := state.Policies[id]
+		if policy.VppID != types.InvalidID && (!ok || existing.VppID == types.InvalidID) {
+			state.Policies[id] = policy
+		}
+	}
+	for id, wep := range oldState.WorkloadEndpoints {
+		existing, ok := state.WorkloadEndpoints[id]
+		if len(wep.SwIfIndex) != 0 && (!ok || len(existing.SwIfIndex) == 0) {
+			state.WorkloadEndpoints[id] = wep
+		}
+	}
+	for id, hep := range oldState.HostEndpoints {
+		existing, ok := state.HostEndpoints[id]
+		if len(hep.UplinkSwIfIndexes) != 0 && (!ok || len(existing.UplinkSwIfIndexes) == 0) {
+			state.HostEndpoints[id] = hep
+		}
+	}
+}
+
 func (s *Server) createAllowToHostPolicy() (err error) {
 	s.log.Infof("Creating policy to allow traffic to host that is applied on uplink")
 	r_in := &Rule{
@@ -1715,6 +1914,7 @@ func (s *Server) createEndpointToHostPolicy( /*may be return*/ ) (err error) {
 	if err != nil {
 		return err
 	}
+	s.allowAllPolicy = allowAllPol
 	conf := types.NewInterfaceConfig()
 	conf.IngressPolicyIDs = append(conf.IngressPolicyIDs, s.workloadsToHostPolicy.VppID, allowAllPol.VppID)
 	swifindexes, err := s.vpp.SearchInterfacesWithTagPrefix("host-") // tap0 interfaces
@@ -1731,94 +1931,101 @@ func (s *Server) createEndpointToHostPolicy( /*may be return*/ ) (err error) {
 	return nil
 }
 
-// createFailSafePolicies ensures the failsafe policies defined in the Felixconfiguration exist in VPP.
-// check https://github.com/projectcalico/calico/blob/master/felix/rules/static.go :: failsafeInChain for the linux implementation
-// To be noted. This does not implement the doNotTrack case as we do not yet support doNotTrack policies.
-func (s *Server) createFailSafePolicies() (err error) {
-	failSafePol := &Policy{
-		Policy: &types.Policy{},
-		VppID:  types.InvalidID,
+// failSafeRules translates failsafe ports into rules allowing the traffic to these ports,
+// or the replies from them when reply is set.
+func (s *Server) failSafeRules(protoPorts []felixConfig.ProtoPort, outbound bool, reply bool) (rules []*Rule) {
+	direction := "in"
+	if outbound {
+		direction = "out"
 	}
-
-	if len(s.felixConfig.FailsafeInboundHostPorts) != 0 {
-		for _, protoPort := range s.felixConfig.FailsafeInboundHostPorts {
-			protocol, err := parseProtocol(&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: protoPort.Protocol}})
-			if err != nil {
-				s.log.WithError(err).Error("Failed to parse protocol in inbound failsafe rule. Skipping failsafe rule")
-				continue
-			}
-			rule := &Rule{
-				VppID:  types.InvalidID,
-				RuleID: fmt.Sprintf("failsafe-in-%s-%s-%d", protoPort.Net, protoPort.Protocol, protoPort.Port),
-				Rule: &types.Rule{
-					Action: types.ActionAllow,
-					// Ports are always filtered on the destination of packets
-					DstPortRange: []types.PortRange{{First: protoPort.Port, Last: protoPort.Port}},
-					Filters: []types.RuleFilter{{
-						ShouldMatch: true,
-						Type:        types.CapoFilterProto,
-						Value:       int(protocol),
-					}},
-				},
-			}
-			if protoPort.Net != "" {
-				_, protoPortNet, err := net.ParseCIDR(protoPort.Net)
-				if err != nil {
-					s.log.WithError(err).Error("Failed to parse CIDR in inbound failsafe rule. Skipping failsafe rule")
-					continue
-				}
-				// Inbound packets are checked for where they come FROM
-				rule.Rule.SrcNet = append(rule.Rule.SrcNet, *protoPortNet)
-			}
-			failSafePol.InboundRules = append(failSafePol.InboundRules, rule)
-		}
+	if reply {
+		direction += "-reply"
 	}
-
-	if len(s.felixConfig.FailsafeOutboundHostPorts) != 0 {
-		for _, protoPort := range s.felixConfig.FailsafeOutboundHostPorts {
-			protocol, err := parseProtocol(&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: protoPort.Protocol}})
+	for _, protoPort := range protoPorts {
+		protocol, err := parseProtocol(&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: protoPort.Protocol}})
+		if err != nil {
+			s.log.WithError(err).Errorf("Failed to parse protocol in %s failsafe rule. Skipping failsafe rule", direction)
+			continue
+		}
+		rule := &Rule{
+			VppID:  types.InvalidID,
+			RuleID: fmt.Sprintf("failsafe-%s-%s-%s-%d", direction, protoPort.Net, protoPort.Protocol, protoPort.Port),
+			Rule: &types.Rule{
+				Action: types.ActionAllow,
+				Filters: []types.RuleFilter{{
+					ShouldMatch: true,
+					Type:        types.CapoFilterProto,
+					Value:       int(protocol),
+				}},
+			},
+		}
+		// Ports are always filtered on the destination of packets,
+		// and on their source for the replies
+		portRange := []types.PortRange{{First: protoPort.Port, Last: protoPort.Port}}
+		if reply {
+			rule.Rule.SrcPortRange = portRange
+		} else {
+			rule.Rule.DstPortRange = portRange
+		}
+		if protoPort.Net != "" {
+			_, protoPortNet, err := net.ParseCIDR(protoPort.Net)
 			if err != nil {
-				s.log.WithError(err).Error("Failed to parse protocol in outbound failsafe rule. Skipping failsafe rule")
+				s.log.WithError(err).Errorf("Failed to parse CIDR in %s failsafe rule. Skipping failsafe rule", direction)
 				continue
 			}
-			rule := &Rule{
-				VppID:  types.InvalidID,
-				RuleID: fmt.Sprintf("failsafe-out-%s-%s-%d", protoPort.Net, protoPort.Protocol, protoPort.Port),
-				Rule: &types.Rule{
-					Action: types.ActionAllow,
-					// Ports are always filtered on the destination of packets
-					DstPortRange: []types.PortRange{{First: protoPort.Port, Last: protoPort.Port}},
-					Filters: []types.RuleFilter{{
-						ShouldMatch: true,
-						Type:        types.CapoFilterProto,
-						Value:       int(protocol),
-					}},
-				},
-			}
-			if protoPort.Net != "" {
-				_, protoPortNet, err := net.ParseCIDR(protoPort.Net)
-				if err != nil {
-					s.log.WithError(err).Error("Failed to parse CIDR in outbound failsafe rule. Skipping failsafe rule")
-					continue
-				}
-				// Outbound packets are checked for where they go TO
+			// Inbound packets are checked for where they come FROM,
+			// and outbound packets for where they go TO
+			if outbound == reply {
+				rule.Rule.SrcNet = append(rule.Rule.SrcNet, *protoPortNet)
+			} else {
 				rule.Rule.DstNet = append(rule.Rule.DstNet, *protoPortNet)
 			}
-			failSafePol.OutboundRules = append(failSafePol.OutboundRules, rule)
 		}
+		rules = append(rules, rule)
 	}
+	return rules
+}
 
-	if s.failSafePolicy == nil {
-		err = failSafePol.Create(s.vpp, nil)
+// createOrUpdateInternalPolicy creates policy in VPP, or updates existing with it when not nil
+func (s *Server) createOrUpdateInternalPolicy(existing *Policy, policy *Policy) (err error) {
+	if existing == nil {
+		return policy.Create(s.vpp, nil)
+	}
+	policy.VppID = existing.VppID
+	return existing.Update(s.vpp, policy, nil)
+}
 
-	} else {
-		failSafePol.VppID = s.failSafePolicy.VppID
-		err = s.failSafePolicy.Update(s.vpp, failSafePol, nil)
+// createFailSafePolicies ensures the failsafe policies defined in the Felixconfiguration exist in VPP.
+// check https://github.com/projectcalico/calico/blob/master/felix/rules/static.go :: failsafeInChain for the linux implementation
+// They are also evaluated ahead of the untracked and preDNAT policies on the uplinks, see getStages.
+// As the untracked policies are stateless, the replies to the failsafe traffic are allowed
+// there by a second policy, as in the raw table of the linux dataplane.
+func (s *Server) createFailSafePolicies() (err error) {
+	failSafePol := &Policy{
+		Policy:        &types.Policy{},
+		VppID:         types.InvalidID,
+		InboundRules:  s.failSafeRules(s.felixConfig.FailsafeInboundHostPorts, false /* outbound */, false /* reply */),
+		OutboundRules: s.failSafeRules(s.felixConfig.FailsafeOutboundHostPorts, true /* outbound */, false /* reply */),
 	}
+	failSafeReplyPol := &Policy{
+		Policy:        &types.Policy{},
+		VppID:         types.InvalidID,
+		InboundRules:  s.failSafeRules(s.felixConfig.FailsafeOutboundHostPorts, true /* outbound */, true /* reply */),
+		OutboundRules: s.failSafeRules(s.felixConfig.FailsafeInboundHostPorts, false /* outbound */, true /* reply */),
+	}
+
+	err = s.createOrUpdateInternalPolicy(s.failSafePolicy, failSafePol)
 	if err != nil {
 		return err
 	}
 	s.failSafePolicy = failSafePol
 	s.log.Infof("Created failsafe policy with ID %+v", s.failSafePolicy.VppID)
+
+	err = s.createOrUpdateInternalPolicy(s.failSafeReplyPolicy, failSafeReplyPol)
+	if err != nil {
+		return err
+	}
+	s.failSafeReplyPolicy = failSafeReplyPol
+	s.log.Infof("Created failsafe reply policy with ID %+v", s.failSafeReplyPolicy.VppID)
 	return nil
 }
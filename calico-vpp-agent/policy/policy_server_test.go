@@ -0,0 +1,722 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/gogo/protobuf/proto"
+	felixConfig "github.com/projectcalico/calico/felix/config"
+	"github.com/projectcalico/calico/felix/proto"
+	"github.com/sirupsen/logrus"
+	"go.fd.io/govpp/api"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testFailSafeID    = 1
+	testAllowToHostID = 2
+	testAllowAllID    = 3
+	// 4 and 5 are used by the audit tests
+	testFailSafeReplyID = 6
+	testForwardID       = 10
+	testUntrackedID     = 11
+	testPreDnatID       = 12
+)
+
+func newTestServer() *Server {
+	return &Server{
+		failSafePolicy:      &Policy{VppID: testFailSafeID},
+		failSafeReplyPolicy: &Policy{VppID: testFailSafeReplyID},
+		allowToHostPolicy:   &Policy{VppID: testAllowToHostID},
+		allowAllPolicy:      &Policy{VppID: testAllowAllID},
+	}
+}
+
+func newTestPolicyState() *PolicyState {
+	state := NewPolicyState()
+	state.Policies[PolicyID{Tier: "default", Name: "forward"}] = &Policy{VppID: testForwardID}
+	state.Policies[PolicyID{Tier: "default", Name: "untracked"}] = &Policy{VppID: testUntrackedID}
+	state.Policies[PolicyID{Tier: "default", Name: "prednat"}] = &Policy{VppID: testPreDnatID}
+	return state
+}
+
+func allowProtoRule(ruleID string) *proto.Rule {
+	return &proto.Rule{
+		Action:    "allow",
+		IpVersion: proto.IPVersion_IPV4,
+		RuleId:    ruleID,
+		SrcNet:    []string{"192.168.0.0/16"},
+	}
+}
+
+var _ = Describe("PreDNAT policies", func() {
+	It("should translate preDNAT inbound rules", func() {
+		policy, err := fromProtoPolicy(&proto.Policy{
+			PreDnat:      true,
+			InboundRules: []*proto.Rule{allowProtoRule("rule-prednat")},
+		}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy.InboundRules).To(HaveLen(1))
+		Expect(policy.InboundRules[0].RuleID).To(Equal("rule-prednat"))
+		Expect(policy.OutboundRules).To(BeEmpty())
+	})
+
+	It("should not translate preDNAT outbound rules", func() {
+		policy, err := fromProtoPolicy(&proto.Policy{
+			PreDnat:       true,
+			OutboundRules: []*proto.Rule{allowProtoRule("rule-prednat")},
+		}, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy.InboundRules).To(BeEmpty())
+		Expect(policy.OutboundRules).To(BeEmpty())
+	})
+
+	It("should store preDNAT tiers of host endpoints", func() {
+		hep := fromProtoHostEndpoint(&proto.HostEndpoint{
+			Name:         "eth0",
+			PreDnatTiers: []*proto.TierInfo{{Name: "default", IngressPolicies: []string{"prednat"}}},
+		}, newTestServer())
+		Expect(hep.PreDnatTiers).To(HaveLen(1))
+		Expect(hep.PreDnatTiers[0].IngressPolicies).To(Equal([]string{"prednat"}))
+	})
+
+	It("should evaluate preDNAT policies in the stages of the uplinks", func() {
+		hep := &HostEndpoint{
+			server:       newTestServer(),
+			ForwardTiers: []Tier{{Name: "default", IngressPolicies: []string{"forward"}, EgressPolicies: []string{"forward"}}},
+			PreDnatTiers: []Tier{{Name: "default", IngressPolicies: []string{"prednat"}}},
+		}
+		stages, err := hep.getStages(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stages.PreDnatPolicyIDs).To(Equal([]uint32{testFailSafeID, testPreDnatID}))
+		Expect(stages.UntrackedIngressPolicyIDs).To(BeEmpty())
+		// the forward policies are still evaluated after a preDNAT allow
+		conf, err := hep.getForwardPolicies(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(Equal([]uint32{testAllowToHostID, testForwardID}))
+		Expect(conf.EgressPolicyIDs).To(Equal([]uint32{testAllowToHostID, testForwardID}))
+	})
+
+	It("should not configure forward policies for preDNAT policies only", func() {
+		hep := &HostEndpoint{
+			server:       newTestServer(),
+			PreDnatTiers: []Tier{{Name: "default", IngressPolicies: []string{"prednat"}}},
+		}
+		conf, err := hep.getForwardPolicies(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(BeEmpty())
+		Expect(conf.EgressPolicyIDs).To(BeEmpty())
+	})
+
+	It("should configure preDNAT policies after the untracked ones", func() {
+		dir, err := os.MkdirTemp("", "policy-test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		vpp, link, err := newMockVpp(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		defer vpp.Close()
+		defer link.Close()
+
+		hep := &HostEndpoint{
+			server:            newReconciliationTestServer(link),
+			UplinkSwIfIndexes: []uint32{1},
+			UntrackedTiers:    []Tier{{Name: "default", IngressPolicies: []string{"untracked"}}},
+			PreDnatTiers:      []Tier{{Name: "default", IngressPolicies: []string{"prednat"}}},
+		}
+		Expect(hep.Create(link, newTestPolicyState())).To(Succeed())
+		Expect(vpp.interfaceStages(1)).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID, testFailSafeID, testPreDnatID}))
+		Expect(vpp.interfacePolicies(1)).To(BeEmpty())
+	})
+
+	It("should fail when a preDNAT policy is not yet created", func() {
+		hep := &HostEndpoint{
+			server:       newTestServer(),
+			PreDnatTiers: []Tier{{Name: "default", IngressPolicies: []string{"missing"}}},
+		}
+		_, err := hep.getStages(newTestPolicyState())
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+func readFelixMessage(conn net.Conn) *proto.FromDataplane {
+	buf := make([]byte, 8)
+	_, err := io.ReadFull(conn, buf)
+	Expect(err).ToNot(HaveOccurred())
+	data := make([]byte, binary.LittleEndian.Uint64(buf))
+	_, err = io.ReadFull(conn, data)
+	Expect(err).ToNot(HaveOccurred())
+	envelope := &proto.FromDataplane{}
+	Expect(pb.Unmarshal(data, envelope)).To(Succeed())
+	return envelope
+}
+
+var _ = Describe("Untracked policies", func() {
+	It("should evaluate untracked policies in the stages of the uplinks", func() {
+		hep := &HostEndpoint{
+			server:         newTestServer(),
+			UntrackedTiers: []Tier{{Name: "default", IngressPolicies: []string{"untracked"}, EgressPolicies: []string{"untracked"}}},
+			PreDnatTiers:   []Tier{{Name: "default", IngressPolicies: []string{"prednat"}}},
+		}
+		stages, err := hep.getStages(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stages.UntrackedIngressPolicyIDs).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+		Expect(stages.UntrackedEgressPolicyIDs).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+		Expect(stages.PreDnatPolicyIDs).To(Equal([]uint32{testFailSafeID, testPreDnatID}))
+		// they are not evaluated again with the policies creating sessions
+		conf, err := hep.getForwardPolicies(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(BeEmpty())
+		Expect(conf.EgressPolicyIDs).To(BeEmpty())
+	})
+
+	It("should not configure forward policies for untracked policies only", func() {
+		hep := &HostEndpoint{
+			server:         newTestServer(),
+			UntrackedTiers: []Tier{{Name: "default", IngressPolicies: []string{"untracked"}}},
+		}
+		conf, err := hep.getForwardPolicies(newTestPolicyState())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conf.IngressPolicyIDs).To(BeEmpty())
+		Expect(conf.EgressPolicyIDs).To(BeEmpty())
+	})
+
+	It("should configure the stages of the uplinks and tunnels", func() {
+		dir, err := os.MkdirTemp("", "policy-test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		vpp, link, err := newMockVpp(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		defer vpp.Close()
+		defer link.Close()
+
+		state := newTestPolicyState()
+		hep := &HostEndpoint{
+			server:            newReconciliationTestServer(link),
+			UplinkSwIfIndexes: []uint32{1},
+			TunnelSwIfIndexes: []uint32{2},
+			UntrackedTiers:    []Tier{{Name: "default", IngressPolicies: []string{"untracked"}}},
+		}
+		Expect(hep.Create(link, state)).To(Succeed())
+		Expect(vpp.interfaceStages(1)).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+		Expect(vpp.interfaceStages(2)).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+		Expect(vpp.interfacePolicies(1)).To(BeEmpty())
+
+		Expect(hep.handleTunnelChange(3, true /* isAdd */, false /* pending */)).To(Succeed())
+		Expect(vpp.interfaceStages(3)).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+
+		Expect(hep.Update(link, &HostEndpoint{server: hep.server}, state)).To(Succeed())
+		Expect(vpp.interfaceStages(1)).To(BeEmpty())
+
+		hep.UntrackedTiers = []Tier{{Name: "default", EgressPolicies: []string{"untracked"}}}
+		Expect(hep.Create(link, state)).To(Succeed())
+		Expect(vpp.interfaceStages(2)).To(Equal([]uint32{testFailSafeID, testFailSafeReplyID, testUntrackedID}))
+		Expect(hep.Delete(link, state)).To(Succeed())
+		Expect(vpp.interfaceStages(1)).To(BeEmpty())
+		Expect(vpp.interfaceStages(2)).To(BeEmpty())
+		Expect(vpp.interfaceStages(3)).To(BeEmpty())
+	})
+	It("should let the failsafe traffic and its replies through an untracked deny-all", func() {
+		dir, err := os.MkdirTemp("", "policy-test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		vpp, link, err := newMockVpp(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		defer vpp.Close()
+		defer link.Close()
+
+		server := newReconciliationTestServer(link)
+		server.felixConfig = &felixConfig.Config{
+			FailsafeInboundHostPorts:  []felixConfig.ProtoPort{{Protocol: "tcp", Port: 22}},
+			FailsafeOutboundHostPorts: []felixConfig.ProtoPort{{Protocol: "tcp", Port: 2379, Net: "10.0.0.0/24"}},
+		}
+		server.failSafePolicy = nil
+		server.failSafeReplyPolicy = nil
+		Expect(server.createFailSafePolicies()).To(Succeed())
+
+		denyAll := &Policy{
+			Policy:        &types.Policy{},
+			VppID:         testUntrackedID,
+			InboundRules:  []*Rule{{Rule: &types.Rule{Action: types.ActionDeny}}},
+			OutboundRules: []*Rule{{Rule: &types.Rule{Action: types.ActionDeny}}},
+		}
+		state := NewPolicyState()
+		state.Policies[PolicyID{Tier: "default", Name: "untracked"}] = denyAll
+		hep := &HostEndpoint{
+			server:         server,
+			UntrackedTiers: []Tier{{Name: "default", IngressPolicies: []string{"untracked"}, EgressPolicies: []string{"untracked"}}},
+		}
+		stages, err := hep.getStages(state)
+		Expect(err).ToNot(HaveOccurred())
+
+		policies := map[uint32]*Policy{
+			server.failSafePolicy.VppID:      server.failSafePolicy,
+			server.failSafeReplyPolicy.VppID: server.failSafeReplyPolicy,
+			denyAll.VppID:                    denyAll,
+		}
+		untrackedAction := func(ingress bool, flow *Flow) types.RuleAction {
+			ids := stages.UntrackedEgressPolicyIDs
+			if ingress {
+				ids = stages.UntrackedIngressPolicyIDs
+			}
+			var named []*namedPolicy
+			for _, id := range ids {
+				Expect(policies).To(HaveKey(id))
+				named = append(named, &namedPolicy{policy: policies[id]})
+			}
+			verdict := &Verdict{}
+			Expect(matchPolicies(verdict, named, ingress, flow, state)).To(BeTrue())
+			return verdict.Action
+		}
+		host := net.ParseIP("10.0.0.1")
+		remote := net.ParseIP("10.0.0.2")
+		// to and from the failsafe inbound port
+		Expect(untrackedAction(true, &Flow{SrcIP: remote, DstIP: host, Proto: types.TCP, SrcPort: 40000, DstPort: 22})).To(Equal(types.ActionAllow))
+		Expect(untrackedAction(false, &Flow{SrcIP: host, DstIP: remote, Proto: types.TCP, SrcPort: 22, DstPort: 40000})).To(Equal(types.ActionAllow))
+		// to and from the failsafe outbound port
+		Expect(untrackedAction(false, &Flow{SrcIP: host, DstIP: remote, Proto: types.TCP, SrcPort: 40000, DstPort: 2379})).To(Equal(types.ActionAllow))
+		Expect(untrackedAction(true, &Flow{SrcIP: remote, DstIP: host, Proto: types.TCP, SrcPort: 2379, DstPort: 40000})).To(Equal(types.ActionAllow))
+		// the net of failsafe outbound ports is the remote end
+		other := net.ParseIP("10.0.1.2")
+		Expect(untrackedAction(false, &Flow{SrcIP: host, DstIP: other, Proto: types.TCP, SrcPort: 40000, DstPort: 2379})).To(Equal(types.ActionDeny))
+		Expect(untrackedAction(true, &Flow{SrcIP: other, DstIP: host, Proto: types.TCP, SrcPort: 2379, DstPort: 40000})).To(Equal(types.ActionDeny))
+		// other traffic is still denied
+		Expect(untrackedAction(true, &Flow{SrcIP: remote, DstIP: host, Proto: types.TCP, SrcPort: 40000, DstPort: 80})).To(Equal(types.ActionDeny))
+		Expect(untrackedAction(true, &Flow{SrcIP: remote, DstIP: host, Proto: types.UDP, SrcPort: 40000, DstPort: 22})).To(Equal(types.ActionDeny))
+	})
+})
+
+var _ = Describe("Endpoint status reporting", func() {
+	var (
+		server *Server
+		agent  net.Conn
+		felix  net.Conn
+	)
+
+	BeforeEach(func() {
+		server = newTestServer()
+		server.log = logrus.NewEntry(logrus.New())
+		agent, felix = net.Pipe()
+		server.felixConn = agent
+	})
+
+	AfterEach(func() {
+		agent.Close()
+		felix.Close()
+	})
+
+	It("should report workload endpoint statuses", func() {
+		id := &WorkloadEndpointID{OrchestratorID: "k8s", WorkloadID: "default/pod", EndpointID: "eth0"}
+		go server.reportWorkloadEndpointStatus(id, endpointStatus(nil))
+		msg := readFelixMessage(felix)
+		Expect(msg.SequenceNumber).To(Equal(uint64(0)))
+		update := msg.GetWorkloadEndpointStatusUpdate()
+		Expect(update).ToNot(BeNil())
+		Expect(update.Id).To(Equal(&proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "default/pod", EndpointId: "eth0"}))
+		Expect(update.Status.Status).To(Equal(EndpointStatusUp))
+
+		go server.reportWorkloadEndpointRemoved(id)
+		msg = readFelixMessage(felix)
+		Expect(msg.SequenceNumber).To(Equal(uint64(1)))
+		Expect(msg.GetWorkloadEndpointStatusRemove().Id.WorkloadId).To(Equal("default/pod"))
+	})
+
+	It("should report host endpoint errors", func() {
+		go server.reportHostEndpointStatus(&HostEndpointID{EndpointID: "hep"}, endpointStatus(io.EOF))
+		update := readFelixMessage(felix).GetHostEndpointStatusUpdate()
+		Expect(update).ToNot(BeNil())
+		Expect(update.Id.EndpointId).To(Equal("hep"))
+		Expect(update.Status.Status).To(Equal(EndpointStatusError))
+	})
+
+	It("should not report endpoints of secondary networks", func() {
+		server.reportWorkloadEndpointStatus(&WorkloadEndpointID{WorkloadID: "default/pod", Network: "blue"}, EndpointStatusUp)
+		Expect(server.nextSeqNumber).To(Equal(uint64(0)))
+	})
+
+	It("should not report statuses when felix is disconnected", func() {
+		server.felixConn = nil
+		server.reportHostEndpointRemoved(&HostEndpointID{EndpointID: "hep"})
+		Expect(server.nextSeqNumber).To(Equal(uint64(0)))
+	})
+})
+
+// newConfiguredTestState returns a state with an ipset, a policy and a profile as they are
+// received from felix. When configured is true, they have VPP IDs as if they were created.
+func newConfiguredTestState(configured bool) *PolicyState {
+	state := NewPolicyState()
+	state.IPSets["frontend"] = &IPSet{
+		VppID:     types.InvalidID,
+		Type:      types.IpsetTypeIP,
+		Addresses: map[string]net.IP{"10.0.0.1": net.ParseIP("10.0.0.1")},
+	}
+	rule := allowProtoRule("rule-web")
+	rule.SrcIpSetIds = []string{"frontend"}
+	policy, err := fromProtoPolicy(&proto.Policy{InboundRules: []*proto.Rule{rule}}, "")
+	Expect(err).ToNot(HaveOccurred())
+	state.Policies[PolicyID{Tier: "default", Name: "web"}] = policy
+	profile, err := fromProtoProfile(&proto.Profile{InboundRules: []*proto.Rule{allowProtoRule("rule-profile")}})
+	Expect(err).ToNot(HaveOccurred())
+	state.Profiles["kns.default"] = profile
+
+	if configured {
+		state.IPSets["frontend"].VppID = 5
+		Expect(policy.InboundRules[0].resolveIPSets(state)).To(Succeed())
+		policy.InboundRules[0].VppID = 20
+		policy.VppID = 10
+		profile.InboundRules[0].VppID = 21
+		profile.VppID = 11
+	}
+	return state
+}
+
+var _ = Describe("Pending state reconciliation", func() {
+	It("should keep unchanged ipsets, policies and profiles", func() {
+		server := newTestServer()
+		server.log = logrus.NewEntry(logrus.New())
+		// VPP is nil, so any API call would panic
+		configured := newConfiguredTestState(true)
+		server.configuredState = configured
+		server.pendingState = newConfiguredTestState(false)
+
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(server.configuredState.IPSets["frontend"]).To(BeIdenticalTo(configured.IPSets["frontend"]))
+		Expect(server.configuredState.IPSets["frontend"].VppID).To(Equal(uint32(5)))
+		policy := server.configuredState.Policies[PolicyID{Tier: "default", Name: "web"}]
+		Expect(policy.VppID).To(Equal(uint32(10)))
+		Expect(policy.InboundRules[0].VppID).To(Equal(uint32(20)))
+		Expect(server.configuredState.Profiles["kns.default"].VppID).To(Equal(uint32(11)))
+		Expect(server.pendingState.Policies).To(BeEmpty())
+		Expect(server.policyRulesChanged).To(BeTrue())
+	})
+
+	It("should detect rules referencing recreated ipsets", func() {
+		configured := newConfiguredTestState(true)
+		pending := newConfiguredTestState(false)
+		pending.IPSets["frontend"].VppID = 6
+		policy := pending.Policies[PolicyID{Tier: "default", Name: "web"}]
+		Expect(policy.InboundRules[0].resolveIPSets(pending)).To(Succeed())
+		Expect(policy.equalRules(configured.Policies[PolicyID{Tier: "default", Name: "web"}])).To(BeFalse())
+
+		pending.IPSets["frontend"].VppID = 5
+		Expect(policy.InboundRules[0].resolveIPSets(pending)).To(Succeed())
+		Expect(policy.equalRules(configured.Policies[PolicyID{Tier: "default", Name: "web"}])).To(BeTrue())
+	})
+})
+
+// newSyntheticState returns a state with n workload endpoints, each one with a
+// policy of rulesPerPolicy rules matching the sources of an ipset of its own
+func newSyntheticState(server *Server, n int, rulesPerPolicy int) *PolicyState {
+	state := NewPolicyState()
+	for i := 0; i < n; i++ {
+		ipsetName := fmt.Sprintf("ipset-%d", i)
+		ipset, err := fromIPSetUpdate(&proto.IPSetUpdate{
+			Id:      ipsetName,
+			Type:    proto.IPSetUpdate_IP,
+			Members: []string{fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff)},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		state.IPSets[ipsetName] = ipset
+
+		rules := make([]*proto.Rule, 0, rulesPerPolicy)
+		for j := 0; j < rulesPerPolicy; j++ {
+			rules = append(rules, &proto.Rule{
+				Action:      "allow",
+				IpVersion:   proto.IPVersion_IPV4,
+				RuleId:      fmt.Sprintf("rule-%d-%d", i, j),
+				Protocol:    &proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 6}},
+				DstPorts:    []*proto.PortRange{{First: int32(1000 + j), Last: int32(1000 + j)}},
+				SrcIpSetIds: []string{ipsetName},
+			})
+		}
+		policyName := fmt.Sprintf("policy-%d", i)
+		policy, err := fromProtoPolicy(&proto.Policy{InboundRules: rules}, "")
+		Expect(err).ToNot(HaveOccurred())
+		state.Policies[PolicyID{Tier: "default", Name: policyName}] = policy
+
+		id := WorkloadEndpointID{OrchestratorID: "k8s", WorkloadID: fmt.Sprintf("default/pod-%d", i), EndpointID: "eth0"}
+		state.WorkloadEndpoints[id] = &WorkloadEndpoint{
+			SwIfIndex: []uint32{},
+			Tiers:     []Tier{{Name: "default", IngressPolicies: []string{policyName}}},
+			server:    server,
+		}
+		server.endpointsInterfaces[id] = map[string]uint32{"eth0": uint32(1000 + i)}
+	}
+	return state
+}
+
+func newReconciliationTestServer(vpp *vpplink.VppLink) *Server {
+	server := newTestServer()
+	server.log = logrus.NewEntry(logrus.New())
+	server.vpp = vpp
+	server.configuredState = NewPolicyState()
+	server.endpointsInterfaces = make(map[WorkloadEndpointID]map[string]uint32)
+	server.AllowFromHostPolicy = &Policy{VppID: testAllowFromHostID}
+	server.auditPassPolicy = &Policy{VppID: testAuditPassID}
+	return server
+}
+
+var _ = Describe("Pending state programming", func() {
+	var (
+		dir    string
+		vpp    *mockVpp
+		server *Server
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "policy-test")
+		Expect(err).ToNot(HaveOccurred())
+		var link *vpplink.VppLink
+		vpp, link, err = newMockVpp(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		server = newReconciliationTestServer(link)
+		server.pendingState = newSyntheticState(server, 2, 2)
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(vpp.interfacePolicies(1001)).To(HaveLen(2))
+	})
+
+	AfterEach(func() {
+		server.vpp.Close()
+		vpp.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("should unconfigure removed workload endpoints before deleting their policies", func() {
+		server.pendingState = newSyntheticState(server, 1, 2)
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(vpp.interfacePolicies(1000)).To(HaveLen(2))
+		Expect(vpp.interfacePolicies(1001)).To(BeEmpty())
+		ipsets, rules, policies := vpp.objects()
+		Expect([]int{ipsets, rules, policies}).To(Equal([]int{1, 2, 1}))
+	})
+
+	It("should not leak rules when policies cannot be created", func() {
+		vpp.setFail(func(msg api.Message) bool {
+			_, ok := msg.(*capo.CapoPolicyCreate)
+			return ok
+		})
+		server.pendingState = newSyntheticState(server, 3, 2)
+		Expect(server.applyPendingState()).ToNot(Succeed())
+		// The new policy is not created, so none of the endpoints changed
+		Expect(vpp.interfacePolicies(1001)).To(HaveLen(2))
+		ipsets, rules, policies := vpp.objects()
+		Expect([]int{ipsets, rules, policies}).To(Equal([]int{3, 4, 2}))
+
+		vpp.setFail(nil)
+		server.pendingState = newSyntheticState(server, 1, 2)
+		Expect(server.applyPendingState()).To(Succeed())
+		ipsets, rules, policies = vpp.objects()
+		Expect([]int{ipsets, rules, policies}).To(Equal([]int{1, 2, 1}))
+	})
+
+	It("should keep track of the objects left in VPP when endpoints cannot be configured", func() {
+		vpp.setFail(func(msg api.Message) bool {
+			conf, ok := msg.(*capo.CapoConfigurePolicies)
+			return ok && conf.SwIfIndex == 1002
+		})
+		server.pendingState = newSyntheticState(server, 3, 2)
+		delete(server.pendingState.WorkloadEndpoints, WorkloadEndpointID{OrchestratorID: "k8s", WorkloadID: "default/pod-1", EndpointID: "eth0"})
+		Expect(server.applyPendingState()).ToNot(Succeed())
+		Expect(server.configuredState.Policies).To(HaveLen(3))
+		Expect(server.configuredState.WorkloadEndpoints).To(HaveLen(3))
+
+		vpp.setFail(nil)
+		server.pendingState = newSyntheticState(server, 1, 2)
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(vpp.interfacePolicies(1000)).To(HaveLen(2))
+		Expect(vpp.interfacePolicies(1001)).To(BeEmpty())
+		ipsets, rules, policies := vpp.objects()
+		Expect([]int{ipsets, rules, policies}).To(Equal([]int{1, 2, 1}))
+	})
+
+	It("should set the mode of policies in audit mode", func() {
+		id := PolicyID{Tier: "default", Name: "policy-1"}
+		server.pendingState = newSyntheticState(server, 3, 2)
+		server.pendingState.Policies[id].Audit = true
+		server.pendingState.Policies[PolicyID{Tier: "default", Name: "policy-2"}].Audit = true
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(vpp.policyAudited(server.configuredState.Policies[id].VppID)).To(BeTrue())
+		Expect(vpp.policyAudited(server.configuredState.Policies[PolicyID{Tier: "default", Name: "policy-2"}].VppID)).To(BeTrue())
+		Expect(vpp.policyAudited(server.configuredState.Policies[PolicyID{Tier: "default", Name: "policy-0"}].VppID)).To(BeFalse())
+
+		// the rules did not change, but the policy is enforced again
+		server.pendingState = newSyntheticState(server, 3, 2)
+		Expect(server.applyPendingState()).To(Succeed())
+		Expect(vpp.policyAudited(server.configuredState.Policies[id].VppID)).To(BeFalse())
+	})
+})
+
+// BenchmarkApplyPendingState applies a state of 10k rules, in 100 policies of
+// 100 rules, to a VPP replying after 20µs: on a first sync, on a resync where
+// nothing changed, and on a resync where a single policy changed
+// applyPendingStateSerially programs the pending state the way the policy server
+// did before pipelining the requests and diffing the states: everything configured
+// is deleted and recreated, with one request per rule.
+func applyPendingStateSerially(s *Server) (err error) {
+	for _, wep := range s.configuredState.WorkloadEndpoints {
+		if len(wep.SwIfIndex) != 0 {
+			err = wep.Delete(s.vpp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, policy := range s.configuredState.Policies {
+		for _, rule := range append(append([]*Rule{}, policy.InboundRules...), policy.OutboundRules...) {
+			err = rule.Delete(s.vpp)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.vpp.PolicyDelete(policy.VppID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, ipset := range s.configuredState.IPSets {
+		err = ipset.Delete(s.vpp)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.configuredState = s.pendingState
+	s.pendingState = NewPolicyState()
+	for _, ipset := range s.configuredState.IPSets {
+		err = ipset.Create(s.vpp)
+		if err != nil {
+			return err
+		}
+	}
+	for _, policy := range s.configuredState.Policies {
+		for _, rule := range append(append([]*Rule{}, policy.InboundRules...), policy.OutboundRules...) {
+			err = rule.Create(s.vpp, s.configuredState)
+			if err != nil {
+				return err
+			}
+		}
+		policy.setRuleIDs()
+		policy.VppID, err = s.vpp.PolicyCreate(policy.Policy)
+		if err != nil {
+			return err
+		}
+	}
+	for id, wep := range s.configuredState.WorkloadEndpoints {
+		swIfIndexes := []uint32{}
+		for _, swIfIndex := range s.endpointsInterfaces[id] {
+			swIfIndexes = append(swIfIndexes, swIfIndex)
+		}
+		err = wep.Create(s.vpp, swIfIndexes, s.configuredState, id.Network)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func BenchmarkApplyPendingState(b *testing.B) {
+	RegisterTestingT(b)
+	err := config.GetCalicoVppFeatureGates().Validate()
+	if err != nil {
+		b.Fatal(err)
+	}
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.WarnLevel)
+	defer logrus.SetLevel(level)
+
+	vpp, link, err := newMockVpp(b.TempDir(), 20*time.Microsecond)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer vpp.Close()
+	defer link.Close()
+	server := newReconciliationTestServer(link)
+	server.log.Logger.SetLevel(logrus.WarnLevel)
+	apply := func(state *PolicyState) {
+		server.pendingState = state
+		err := server.applyPendingState()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("sync", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			apply(NewPolicyState())
+			state := newSyntheticState(server, 100, 100)
+			b.StartTimer()
+			apply(state)
+		}
+	})
+
+	// The same synthetic state, programmed with serial requests and without diff
+	b.Run("sync serial", func(b *testing.B) {
+		pipelineDepth := vpplink.PipelineDepth
+		vpplink.PipelineDepth = 1
+		defer func() { vpplink.PipelineDepth = pipelineDepth }()
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			apply(NewPolicyState())
+			server.pendingState = newSyntheticState(server, 100, 100)
+			b.StartTimer()
+			err := applyPendingStateSerially(server)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("unchanged", func(b *testing.B) {
+		apply(newSyntheticState(server, 100, 100))
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			state := newSyntheticState(server, 100, 100)
+			b.StartTimer()
+			apply(state)
+		}
+	})
+
+	b.Run("one policy changed", func(b *testing.B) {
+		apply(newSyntheticState(server, 100, 100))
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			state := newSyntheticState(server, 100, 100)
+			if n%2 == 0 {
+				policy := state.Policies[PolicyID{Tier: "default", Name: "policy-0"}]
+				policy.InboundRules = policy.InboundRules[1:]
+			}
+			b.StartTimer()
+			apply(state)
+		}
+	})
+}
@@ -18,6 +18,7 @@ package policy
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -34,6 +35,9 @@ type Rule struct {
 
 	RuleID string
 	VppID  uint32
+	// Index is the position of the calico rule in its policy or profile,
+	// shared by the rules a calico rule is split into
+	Index int
 
 	DstIPPortIPSetNames    []string
 	DstNotIPPortIPSetNames []string
@@ -56,6 +60,7 @@ func (r *Rule) DeepCopy() *Rule {
 
 		RuleID: r.RuleID,
 		VppID:  r.VppID,
+		Index:  r.Index,
 
 		DstIPPortIPSetNames:    make([]string, len(r.DstIPPortIPSetNames)),
 		DstNotIPPortIPSetNames: make([]string, len(r.DstNotIPPortIPSetNames)),
@@ -101,8 +106,14 @@ func (r *Rule) String() string {
 	return s
 }
 
-func fromProtoRule(r *proto.Rule) (rule *Rule, err error) {
-	rule = &Rule{
+func setRulesIndex(rules []*Rule, index int) {
+	for _, rule := range rules {
+		rule.Index = index
+	}
+}
+
+func fromProtoRule(r *proto.Rule) (rules []*Rule, err error) {
+	rule := &Rule{
 		Rule:   &types.Rule{},
 		RuleID: r.RuleId,
 		VppID:  types.InvalidID,
@@ -160,7 +171,43 @@ func fromProtoRule(r *proto.Rule) (rule *Rule, err error) {
 		})
 	}
 
-	// TODO: ICMP filters
+	var icmpType, icmpCode *int32
+	switch icmp := r.GetIcmp().(type) {
+	case *proto.Rule_IcmpType:
+		icmpType = &icmp.IcmpType
+		rule.Filters = append(rule.Filters, icmpTypeFilter(icmp.IcmpType, true))
+	case *proto.Rule_IcmpTypeCode:
+		icmpType, icmpCode = &icmp.IcmpTypeCode.Type, &icmp.IcmpTypeCode.Code
+		rule.Filters = append(rule.Filters,
+			icmpTypeFilter(icmp.IcmpTypeCode.Type, true),
+			icmpCodeFilter(icmp.IcmpTypeCode.Code, true),
+		)
+	}
+	// Negated ICMP matches on another type than the positive one are
+	// redundant, and only constrain the code on the same type. Dropping them
+	// keeps the rule within the filters capo supports.
+	var notIcmpTypeCode *proto.IcmpTypeAndCode
+	switch notIcmp := r.GetNotIcmp().(type) {
+	case *proto.Rule_NotIcmpType:
+		if icmpType == nil {
+			rule.Filters = append(rule.Filters, icmpTypeFilter(notIcmp.NotIcmpType, false))
+		} else if *icmpType == notIcmp.NotIcmpType {
+			// The rule cannot match any packet
+			return []*Rule{}, nil
+		}
+	case *proto.Rule_NotIcmpTypeCode:
+		switch {
+		case icmpType == nil:
+			// Handled once the rule is complete, see below
+			notIcmpTypeCode = notIcmp.NotIcmpTypeCode
+		case *icmpType != notIcmp.NotIcmpTypeCode.Type:
+		case icmpCode == nil:
+			rule.Filters = append(rule.Filters, icmpCodeFilter(notIcmp.NotIcmpTypeCode.Code, false))
+		case *icmpCode == notIcmp.NotIcmpTypeCode.Code:
+			// The rule cannot match any packet
+			return []*Rule{}, nil
+		}
+	}
 
 	// Nets
 	for _, str := range r.SrcNet {
@@ -228,7 +275,43 @@ func fromProtoRule(r *proto.Rule) (rule *Rule, err error) {
 	rule.DstIPPortSetNames = make([]string, len(r.DstIpPortSetIds))
 	copy(rule.DstIPPortSetNames, r.DstIpPortSetIds)
 
-	return rule, nil
+	rules = []*Rule{rule}
+	if notIcmpTypeCode != nil {
+		// capo filters are ANDed, so !(type==T && code==C) cannot be
+		// expressed in a single rule. We split it in two rules with the same
+		// action: (type!=T) and (type==T && code!=C)
+		sameTypeRule := rule.DeepCopy()
+		sameTypeRule.Annotations = rule.Annotations
+		rule.Filters = append(rule.Filters, icmpTypeFilter(notIcmpTypeCode.Type, false))
+		sameTypeRule.Filters = append(sameTypeRule.Filters,
+			icmpTypeFilter(notIcmpTypeCode.Type, true),
+			icmpCodeFilter(notIcmpTypeCode.Code, false),
+		)
+		rules = append(rules, sameTypeRule)
+	}
+	for _, rule := range rules {
+		if len(rule.Filters) > types.CapoMaxRuleFilters {
+			return nil, fmt.Errorf("Rule %s has too many filters (%d > %d)", r.RuleId, len(rule.Filters), types.CapoMaxRuleFilters)
+		}
+	}
+
+	return rules, nil
+}
+
+func icmpTypeFilter(icmpType int32, shouldMatch bool) types.RuleFilter {
+	return types.RuleFilter{
+		ShouldMatch: shouldMatch,
+		Type:        types.CapoFilterICMPType,
+		Value:       int(icmpType),
+	}
+}
+
+func icmpCodeFilter(icmpCode int32, shouldMatch bool) types.RuleFilter {
+	return types.RuleFilter{
+		ShouldMatch: shouldMatch,
+		Type:        types.CapoFilterICMPCode,
+		Value:       int(icmpCode),
+	}
 }
 
 func parseProtocol(pr *proto.Protocol) (types.IPProto, error) {
@@ -257,98 +340,107 @@ func parseProtocol(pr *proto.Protocol) (types.IPProto, error) {
 	}
 }
 
-func (r *Rule) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
-	// Resolve ipset IDs from names
-	// TODO maybe refactor? :p
-	r.DstIPPortIPSet = nil
-	r.DstNotIPPortIPSet = nil
-	r.SrcIPPortIPSet = nil
-	r.SrcNotIPPortIPSet = nil
-	r.DstIPSet = nil
-	r.DstNotIPSet = nil
-	r.SrcIPSet = nil
-	r.SrcNotIPSet = nil
-	r.DstIPPortSet = nil
-
-	for _, n := range r.DstIPPortIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
-		}
-		r.DstIPPortIPSet = append(r.DstIPPortIPSet, ipset.VppID)
-	}
-	for _, n := range r.DstNotIPPortIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
-		}
-		r.DstNotIPPortIPSet = append(r.DstNotIPPortIPSet, ipset.VppID)
-	}
-	for _, n := range r.SrcIPPortIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
-		}
-		r.SrcIPPortIPSet = append(r.SrcIPPortIPSet, ipset.VppID)
-	}
-	for _, n := range r.SrcNotIPPortIPSetNames {
+// ipsetIDs resolves ipset names to their VPP IDs
+func ipsetIDs(state *PolicyState, names []string) (ids []uint32, err error) {
+	for _, n := range names {
 		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
+		if !ok {
+			return nil, fmt.Errorf("ipset %s not found for rule", n)
 		}
-		r.SrcNotIPPortIPSet = append(r.SrcNotIPPortIPSet, ipset.VppID)
-	}
-	for _, n := range r.DstIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
+		if ipset.VppID == types.InvalidID {
+			return nil, fmt.Errorf("ipset %s not created for rule", n)
 		}
-		r.DstIPSet = append(r.DstIPSet, ipset.VppID)
+		ids = append(ids, ipset.VppID)
 	}
-	for _, n := range r.DstNotIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
+	return ids, nil
+}
+
+// resolveIPSets sets the VPP IDs of the ipsets referenced by name in the rule
+func (r *Rule) resolveIPSets(state *PolicyState) (err error) {
+	for _, ipsets := range []struct {
+		ids   *[]uint32
+		names []string
+	}{
+		{&r.DstIPPortIPSet, r.DstIPPortIPSetNames},
+		{&r.DstNotIPPortIPSet, r.DstNotIPPortIPSetNames},
+		{&r.SrcIPPortIPSet, r.SrcIPPortIPSetNames},
+		{&r.SrcNotIPPortIPSet, r.SrcNotIPPortIPSetNames},
+		{&r.DstIPSet, r.DstIPSetNames},
+		{&r.DstNotIPSet, r.DstNotIPSetNames},
+		{&r.SrcIPSet, r.SrcIPSetNames},
+		{&r.SrcNotIPSet, r.SrcNotIPSetNames},
+		{&r.DstIPPortSet, r.DstIPPortSetNames},
+	} {
+		*ipsets.ids, err = ipsetIDs(state, ipsets.names)
+		if err != nil {
+			return err
 		}
-		r.DstNotIPSet = append(r.DstNotIPSet, ipset.VppID)
 	}
-	for _, n := range r.SrcIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
-		}
-		r.SrcIPSet = append(r.SrcIPSet, ipset.VppID)
+	return nil
+}
+
+// equal tells whether both rules are identical in VPP, ipsets having been resolved
+func (r *Rule) equal(other *Rule) bool {
+	return r.RuleID == other.RuleID && reflect.DeepEqual(r.Rule, other.Rule)
+}
+
+func (r *Rule) Create(vpp *vpplink.VppLink, state *PolicyState) (err error) {
+	return createRules(vpp, state, []*Rule{r})
+}
+
+// createRules creates the rules in VPP with pipelined requests
+func createRules(vpp *vpplink.VppLink, state *PolicyState, rules []*Rule) (err error) {
+	if len(rules) == 0 {
+		return nil
 	}
-	for _, n := range r.SrcNotIPSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
+	vppRules := make([]*types.Rule, 0, len(rules))
+	for _, r := range rules {
+		err = r.resolveIPSets(state)
+		if err != nil {
+			return err
 		}
-		r.SrcNotIPSet = append(r.SrcNotIPSet, ipset.VppID)
+		vppRules = append(vppRules, r.Rule)
 	}
-	for _, n := range r.DstIPPortSetNames {
-		ipset, ok := state.IPSets[n]
-		if !ok || ipset.VppID == types.InvalidID {
-			return fmt.Errorf("ipset not found (%v) or created (%d) for rule", ok, ipset.VppID)
+	ids, err := vpp.RulesCreate(vppRules)
+	created := make([]*Rule, 0, len(rules))
+	for i, r := range rules {
+		r.VppID = ids[i]
+		if r.VppID != types.InvalidID {
+			logrus.Infof("policy(add) VPP rule=%s id=%d", r.Rule, r.VppID)
+			created = append(created, r)
 		}
-		r.DstIPPortSet = append(r.DstIPPortSet, ipset.VppID)
 	}
-
-	id, err := vpp.RuleCreate(r.Rule)
 	if err != nil {
-		return errors.Wrap(err, "error creating rule")
+		// Nothing references the rules created so far
+		delErr := deleteRules(vpp, created)
+		if delErr != nil {
+			logrus.Warnf("error deleting rules: %v", delErr)
+		}
+		return errors.Wrap(err, "error creating rules")
 	}
-	r.VppID = id
-	logrus.Infof("policy(add) VPP rule=%s id=%d", r.Rule, id)
 	return nil
 }
 
 func (r *Rule) Delete(vpp *vpplink.VppLink) (err error) {
-	logrus.Infof("policy(del) VPP rule id=%d", r.VppID)
-	err = vpp.RuleDelete(r.VppID)
+	return deleteRules(vpp, []*Rule{r})
+}
+
+// deleteRules deletes the rules from VPP with pipelined requests
+func deleteRules(vpp *vpplink.VppLink, rules []*Rule) (err error) {
+	if len(rules) == 0 {
+		return nil
+	}
+	ids := make([]uint32, 0, len(rules))
+	for _, r := range rules {
+		logrus.Infof("policy(del) VPP rule id=%d", r.VppID)
+		ids = append(ids, r.VppID)
+	}
+	err = vpp.RulesDelete(ids)
 	if err != nil {
 		return err
 	}
-	r.VppID = types.InvalidID
+	for _, r := range rules {
+		r.VppID = types.InvalidID
+	}
 	return nil
 }
@@ -0,0 +1,178 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/projectcalico/calico/felix/proto"
+
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	// feature gates defaults, as set when the agent loads its config
+	err := config.GetCalicoVppFeatureGates().Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	RunSpecs(t, "policy tests")
+}
+
+func icmpProtoRule() *proto.Rule {
+	return &proto.Rule{
+		Action:    "allow",
+		IpVersion: proto.IPVersion_IPV4,
+		RuleId:    "rule-icmp",
+		Protocol:  &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "ICMP"}},
+	}
+}
+
+var protoFilter = types.RuleFilter{ShouldMatch: true, Type: types.CapoFilterProto, Value: int(types.ICMP)}
+
+var _ = Describe("Rule translation from felix", func() {
+	It("should translate an ICMP type match", func() {
+		r := icmpProtoRule()
+		r.Icmp = &proto.Rule_IcmpType{IcmpType: 8}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: true, Type: types.CapoFilterICMPType, Value: 8},
+		}))
+	})
+
+	It("should translate an ICMP type and code match", func() {
+		r := icmpProtoRule()
+		r.Icmp = &proto.Rule_IcmpTypeCode{IcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: true, Type: types.CapoFilterICMPType, Value: 3},
+			{ShouldMatch: true, Type: types.CapoFilterICMPCode, Value: 4},
+		}))
+	})
+
+	It("should translate a negated ICMP type match", func() {
+		r := icmpProtoRule()
+		r.Action = "deny"
+		r.NotIcmp = &proto.Rule_NotIcmpType{NotIcmpType: 137}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Action).To(Equal(types.ActionDeny))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: false, Type: types.CapoFilterICMPType, Value: 137},
+		}))
+	})
+
+	It("should split a negated ICMP type and code match in two rules", func() {
+		r := icmpProtoRule()
+		r.SrcNet = []string{"10.0.0.0/24"}
+		r.NotIcmp = &proto.Rule_NotIcmpTypeCode{NotIcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: false, Type: types.CapoFilterICMPType, Value: 3},
+		}))
+		Expect(rules[1].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: true, Type: types.CapoFilterICMPType, Value: 3},
+			{ShouldMatch: false, Type: types.CapoFilterICMPCode, Value: 4},
+		}))
+		for _, rule := range rules {
+			Expect(rule.RuleID).To(Equal("rule-icmp"))
+			Expect(rule.Action).To(Equal(types.ActionAllow))
+			Expect(rule.SrcNet).To(HaveLen(1))
+			Expect(rule.SrcNet[0].String()).To(Equal("10.0.0.0/24"))
+		}
+	})
+
+	It("should translate ICMPv6 matches", func() {
+		r := icmpProtoRule()
+		r.IpVersion = proto.IPVersion_IPV6
+		r.Protocol = &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "ICMPv6"}}
+		r.NotIcmp = &proto.Rule_NotIcmpType{NotIcmpType: 137}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].AddressFamily).To(Equal(types.FAMILY_V6))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			{ShouldMatch: true, Type: types.CapoFilterProto, Value: int(types.ICMP6)},
+			{ShouldMatch: false, Type: types.CapoFilterICMPType, Value: 137},
+		}))
+	})
+
+	It("should drop negated ICMP matches on another type", func() {
+		r := icmpProtoRule()
+		r.Icmp = &proto.Rule_IcmpTypeCode{IcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		r.NotIcmp = &proto.Rule_NotIcmpType{NotIcmpType: 8}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: true, Type: types.CapoFilterICMPType, Value: 3},
+			{ShouldMatch: true, Type: types.CapoFilterICMPCode, Value: 4},
+		}))
+
+		r.NotIcmp = &proto.Rule_NotIcmpTypeCode{NotIcmpTypeCode: &proto.IcmpTypeAndCode{Type: 8, Code: 0}}
+		rules, err = fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Filters).To(HaveLen(3))
+	})
+
+	It("should only negate the ICMP code on the same type", func() {
+		r := icmpProtoRule()
+		r.Icmp = &proto.Rule_IcmpType{IcmpType: 3}
+		r.NotIcmp = &proto.Rule_NotIcmpTypeCode{NotIcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].Filters).To(Equal([]types.RuleFilter{
+			protoFilter,
+			{ShouldMatch: true, Type: types.CapoFilterICMPType, Value: 3},
+			{ShouldMatch: false, Type: types.CapoFilterICMPCode, Value: 4},
+		}))
+	})
+
+	It("should drop rules whose negated ICMP match excludes the positive one", func() {
+		r := icmpProtoRule()
+		r.Icmp = &proto.Rule_IcmpType{IcmpType: 8}
+		r.NotIcmp = &proto.Rule_NotIcmpType{NotIcmpType: 8}
+		rules, err := fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(BeEmpty())
+
+		r.Icmp = &proto.Rule_IcmpTypeCode{IcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		r.NotIcmp = &proto.Rule_NotIcmpTypeCode{NotIcmpTypeCode: &proto.IcmpTypeAndCode{Type: 3, Code: 4}}
+		rules, err = fromProtoRule(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(BeEmpty())
+	})
+})
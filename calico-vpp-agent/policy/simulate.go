@@ -0,0 +1,294 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+const (
+	// PolicySimulationPath is the agent API path of the policy simulation
+	PolicySimulationPath = "/policy/simulate"
+	// internalTier is the tier reported for the policies added by the agent
+	internalTier = "calico-vpp-internal"
+
+	simulationTimeout = 5 * time.Second
+)
+
+// SimulationRequest describes a flow between two pods. Pods are given as
+// namespace/name, the interface is only needed for pods with several interfaces.
+// Either pod may be omitted, e.g. for traffic to or from outside of the cluster.
+type SimulationRequest struct {
+	SrcPod       string `json:"srcPod,omitempty"`
+	SrcInterface string `json:"srcInterface,omitempty"`
+	DstPod       string `json:"dstPod,omitempty"`
+	DstInterface string `json:"dstInterface,omitempty"`
+	Network      string `json:"network,omitempty"`
+
+	SrcIP string `json:"srcIP"`
+	DstIP string `json:"dstIP"`
+	// Proto is tcp, udp, sctp, icmp or icmp6
+	Proto string `json:"proto"`
+	// For ICMP, SrcPort is the ICMP type and DstPort the ICMP code
+	SrcPort uint16 `json:"srcPort,omitempty"`
+	DstPort uint16 `json:"dstPort,omitempty"`
+}
+
+// SimulatedPolicy is the outcome of the evaluation of a policy or profile
+type SimulatedPolicy struct {
+	RuleRef
+	// Action is the action of the matching rule, or no-match
+	Action string `json:"action"`
+	// AuditAction is the action of the matching rule of a policy in audit mode
+	AuditAction string `json:"auditAction,omitempty"`
+}
+
+// SimulatedEndpoint is the outcome of the evaluation of the flow on an endpoint
+type SimulatedEndpoint struct {
+	Endpoint string `json:"endpoint"`
+	// Direction is egress for the source pod and ingress for the destination pod
+	Direction string            `json:"direction"`
+	Policies  []SimulatedPolicy `json:"policies"`
+	// Rule is the rule that decided the action, nil when no rule matched
+	Rule   *RuleRef  `json:"rule,omitempty"`
+	Logged []RuleRef `json:"logged,omitempty"`
+	Action string    `json:"action"`
+}
+
+// SimulationReply is the outcome of the evaluation of the flow on the endpoints
+// of both pods, the verdict is allow when all the endpoints allow the flow
+type SimulationReply struct {
+	Flow      string              `json:"flow"`
+	Endpoints []SimulatedEndpoint `json:"endpoints"`
+	Verdict   string              `json:"verdict"`
+	// Notes explain why an endpoint was not evaluated
+	Notes []string `json:"notes,omitempty"`
+}
+
+type simulation struct {
+	request *SimulationRequest
+	reply   chan simulationResult
+}
+
+type simulationResult struct {
+	reply *SimulationReply
+	err   error
+}
+
+func (r *SimulationRequest) flow() (*Flow, error) {
+	flow := &Flow{
+		SrcIP:   net.ParseIP(r.SrcIP),
+		DstIP:   net.ParseIP(r.DstIP),
+		SrcPort: r.SrcPort,
+		DstPort: r.DstPort,
+	}
+	if flow.SrcIP == nil || flow.DstIP == nil {
+		return nil, errors.Errorf("invalid source or destination address %q %q", r.SrcIP, r.DstIP)
+	}
+	proto, err := types.UnformatProto(r.Proto)
+	if err != nil {
+		return nil, err
+	}
+	flow.Proto = proto
+	return flow, nil
+}
+
+// SimulatePolicy evaluates a flow against the policies configured for the pods,
+// as capo would, without sending traffic. It is served on the agent API.
+func (s *Server) SimulatePolicy(request *SimulationRequest) (*SimulationReply, error) {
+	sim := &simulation{request: request, reply: make(chan simulationResult, 1)}
+	select {
+	case s.simulations <- sim:
+	case <-time.After(simulationTimeout):
+		return nil, errors.New("policy server is busy or not connected to felix")
+	}
+	result := <-sim.reply
+	return result.reply, result.err
+}
+
+// findWorkloadEndpoint returns the local workload endpoint of the pod, nil if there is none
+func (s *Server) findWorkloadEndpoint(pod string, intf string, network string) (*WorkloadEndpointID, *WorkloadEndpoint, error) {
+	var foundID *WorkloadEndpointID
+	var found *WorkloadEndpoint
+	for id, wep := range s.configuredState.WorkloadEndpoints {
+		if id.WorkloadID != pod || id.Network != network || (intf != "" && id.EndpointID != intf) {
+			continue
+		}
+		if found != nil {
+			return nil, nil, errors.Errorf("pod %s has several endpoints (%s, %s), please specify the interface",
+				pod, foundID.EndpointID, id.EndpointID)
+		}
+		id := id
+		foundID, found = &id, wep
+	}
+	return foundID, found, nil
+}
+
+// internalIngressPolicies are the policies the agent evaluates before the
+// ingress policies of workload endpoints, see getPolicies
+func (s *Server) internalIngressPolicies() []*namedPolicy {
+	if s.AllowFromHostPolicy == nil {
+		return nil
+	}
+	return []*namedPolicy{{
+		RuleRef: RuleRef{Tier: internalTier, Policy: "allow-from-host"},
+		policy:  s.AllowFromHostPolicy,
+		state:   s.internalPolicyState(),
+	}}
+}
+
+// isHostAddress tells whether the address belongs to the node
+func (s *Server) isHostAddress(ip net.IP) bool {
+	for _, hostIP := range []*net.IP{s.ip4, s.ip6} {
+		if hostIP != nil && hostIP.Equal(ip) {
+			return true
+		}
+	}
+	for _, intf := range s.interfacesMap {
+		for _, address := range intf.addresses {
+			if net.ParseIP(address).Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostEndpointPolicies tells whether host endpoints configure policies on the
+// tap interfaces of the host, and on the uplink and tunnel interfaces
+func (s *Server) hostEndpointPolicies() (tap bool, uplink bool) {
+	for _, hep := range s.configuredState.HostEndpoints {
+		if len(hep.TapSwIfIndexes) > 0 && (len(hep.Tiers) > 0 || len(hep.Profiles) > 0) {
+			tap = true
+		}
+		conf := hep.currentForwardConf
+		if len(hep.UplinkSwIfIndexes)+len(hep.TunnelSwIfIndexes) > 0 && conf != nil &&
+			len(conf.IngressPolicyIDs)+len(conf.EgressPolicyIDs)+len(conf.ProfileIDs) > 0 {
+			uplink = true
+		}
+		stages := hep.currentStagesConf
+		if len(hep.UplinkSwIfIndexes)+len(hep.TunnelSwIfIndexes) > 0 && stages != nil &&
+			len(stages.UntrackedIngressPolicyIDs)+len(stages.UntrackedEgressPolicyIDs)+len(stages.PreDnatPolicyIDs) > 0 {
+			uplink = true
+		}
+	}
+	return tap, uplink
+}
+
+// checkSimulatedFlow rejects the flows crossing other interfaces than the
+// ones of workload endpoints, as their policies are not simulated: the
+// workloads-to-host policy and the host endpoint policies on the host taps,
+// and the failsafe, untracked, preDNAT and forward policies on the uplinks.
+func (s *Server) checkSimulatedFlow(flow *Flow, localEndpoints int) error {
+	tapPolicies, uplinkPolicies := s.hostEndpointPolicies()
+	if s.isHostAddress(flow.DstIP) {
+		return errors.New("flows to the host are not simulated, they are subject to the workloads-to-host and host endpoint policies")
+	}
+	if s.isHostAddress(flow.SrcIP) && tapPolicies {
+		return errors.New("flows from the host are not simulated when host endpoints have policies")
+	}
+	if localEndpoints < 2 && uplinkPolicies {
+		return errors.New("flows leaving or entering the node are not simulated when host endpoints have forward, " +
+			"untracked or preDNAT policies, give two pods running on the node")
+	}
+	return nil
+}
+
+func simulatedEndpoint(id *WorkloadEndpointID, ingress bool, verdict *Verdict) SimulatedEndpoint {
+	endpoint := SimulatedEndpoint{
+		Endpoint:  id.String(),
+		Direction: "egress",
+		Policies:  make([]SimulatedPolicy, 0, len(verdict.Evaluated)),
+		Rule:      verdict.Rule,
+		Logged:    verdict.Logged,
+		Action:    verdict.Action.String(),
+	}
+	if ingress {
+		endpoint.Direction = "ingress"
+	}
+	for _, result := range verdict.Evaluated {
+		policy := SimulatedPolicy{RuleRef: result.RuleRef, Action: "no-match"}
+		if result.Action != nil {
+			policy.Action = result.Action.String()
+		}
+		if result.AuditAction != nil {
+			policy.AuditAction = result.AuditAction.String()
+		}
+		endpoint.Policies = append(endpoint.Policies, policy)
+	}
+	return endpoint
+}
+
+// simulate runs in the policy server loop as it needs the configured state
+func (s *Server) simulate(request *SimulationRequest) (*SimulationReply, error) {
+	if s.state != StateInSync {
+		return nil, errors.New("policy server is not in sync with felix yet")
+	}
+	flow, err := request.flow()
+	if err != nil {
+		return nil, err
+	}
+	// The CNI server updates the endpoints interfaces
+	s.endpointsLock.Lock()
+	defer s.endpointsLock.Unlock()
+
+	reply := &SimulationReply{Flow: flow.String(), Endpoints: []SimulatedEndpoint{}, Verdict: types.ActionAllow.String()}
+	for _, endpoint := range []struct {
+		pod     string
+		intf    string
+		ingress bool
+	}{
+		{request.SrcPod, request.SrcInterface, false},
+		{request.DstPod, request.DstInterface, true},
+	} {
+		if endpoint.pod == "" {
+			continue
+		}
+		id, wep, err := s.findWorkloadEndpoint(endpoint.pod, endpoint.intf, request.Network)
+		if err != nil {
+			return nil, err
+		}
+		if wep == nil {
+			reply.Notes = append(reply.Notes, fmt.Sprintf("pod %s has no endpoint on this node, its policies are not evaluated", endpoint.pod))
+			continue
+		}
+		if len(wep.SwIfIndex) == 0 {
+			reply.Notes = append(reply.Notes, fmt.Sprintf("endpoint %s has no interface, its policies are not configured in VPP", id))
+		}
+		verdict, err := wep.evaluate(s.configuredState, id.Network, flow, endpoint.ingress, s.internalIngressPolicies())
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot evaluate the policies of %s", id)
+		}
+		reply.Endpoints = append(reply.Endpoints, simulatedEndpoint(id, endpoint.ingress, verdict))
+		if verdict.Action != types.ActionAllow {
+			reply.Verdict = verdict.Action.String()
+		}
+	}
+	if len(reply.Endpoints) == 0 && len(reply.Notes) == 0 {
+		return nil, errors.New("no source or destination pod given")
+	}
+	err = s.checkSimulatedFlow(flow, len(reply.Endpoints))
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"net"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newSimulationTestServer returns a server in sync with the state of
+// newLogRuleTestState, the workload endpoint being default/server
+func newSimulationTestServer() *Server {
+	state, wep := newLogRuleTestState()
+	wep.SwIfIndex = []uint32{3}
+	state.WorkloadEndpoints[WorkloadEndpointID{OrchestratorID: "k8s", WorkloadID: "default/server", EndpointID: "eth0"}] = wep
+	_, hostNet, _ := net.ParseCIDR("192.168.0.1/32")
+	return &Server{
+		log:             logrus.NewEntry(logrus.New()),
+		state:           StateInSync,
+		configuredState: state,
+		AllowFromHostPolicy: &Policy{
+			Policy: &types.Policy{},
+			InboundRules: []*Rule{{
+				RuleID: "calicovpp-internal-ingressallowfromhost",
+				Rule:   &types.Rule{Action: types.ActionAllow, SrcNet: []net.IPNet{*hostNet}},
+			}},
+		},
+	}
+}
+
+var _ = Describe("Policy simulation", func() {
+	It("should report the evaluated policies and the verdict", func() {
+		server := newSimulationTestServer()
+		reply, err := server.simulate(&SimulationRequest{
+			SrcPod:  "default/client",
+			DstPod:  "default/server",
+			SrcIP:   "10.0.0.1",
+			DstIP:   "10.0.1.2",
+			Proto:   "tcp",
+			DstPort: 80,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reply.Verdict).To(Equal("allow"))
+		Expect(reply.Notes).To(HaveLen(1))
+		Expect(reply.Endpoints).To(HaveLen(1))
+		endpoint := reply.Endpoints[0]
+		Expect(endpoint.Direction).To(Equal("ingress"))
+		Expect(endpoint.Policies).To(Equal([]SimulatedPolicy{
+			{RuleRef: RuleRef{Tier: internalTier, Policy: "allow-from-host"}, Action: "no-match"},
+			{RuleRef: RuleRef{Tier: "default", Policy: "web", RuleID: "rule-allow"}, Action: "allow"},
+		}))
+		Expect(endpoint.Logged).To(Equal([]RuleRef{{Tier: "default", Policy: "web", RuleID: "rule-log"}}))
+
+		// tiers end with an implicit deny
+		reply, err = server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "10.0.0.1", DstIP: "10.0.1.2", Proto: "tcp", DstPort: 443})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reply.Verdict).To(Equal("deny"))
+		Expect(reply.Endpoints[0].Rule).To(BeNil())
+
+		// the host can always reach pods
+		reply, err = server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "192.168.0.1", DstIP: "10.0.1.2", Proto: "tcp", DstPort: 443})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reply.Verdict).To(Equal("allow"))
+		Expect(reply.Endpoints[0].Rule.Tier).To(Equal(internalTier))
+	})
+
+	It("should reject flows crossing host or uplink policies", func() {
+		server := newSimulationTestServer()
+		hostIP := net.ParseIP("192.168.0.1")
+		server.ip4 = &hostIP
+		_, err := server.simulate(&SimulationRequest{SrcPod: "default/server", SrcIP: "10.0.1.2", DstIP: "192.168.0.1", Proto: "tcp", DstPort: 22})
+		Expect(err).To(HaveOccurred())
+
+		hep := &HostEndpoint{
+			UplinkSwIfIndexes:  []uint32{1},
+			TapSwIfIndexes:     []uint32{2},
+			Tiers:              []Tier{{Name: "default", IngressPolicies: []string{"web"}}},
+			currentForwardConf: types.NewInterfaceConfig(),
+		}
+		server.configuredState.HostEndpoints[HostEndpointID{EndpointID: "hep"}] = hep
+		_, err = server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "192.168.0.1", DstIP: "10.0.1.2", Proto: "tcp", DstPort: 80})
+		Expect(err).To(HaveOccurred())
+		// the uplinks only have policies with forward, untracked or preDNAT tiers
+		reply, err := server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "10.0.0.1", DstIP: "10.0.1.2", Proto: "tcp", DstPort: 80})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reply.Verdict).To(Equal("allow"))
+
+		hep.currentForwardConf.IngressPolicyIDs = []uint32{testFailSafeID, testForwardID}
+		_, err = server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "10.0.0.1", DstIP: "10.0.1.2", Proto: "tcp", DstPort: 80})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject invalid requests", func() {
+		server := newSimulationTestServer()
+		_, err := server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "10.0.0.1", DstIP: "foo", Proto: "tcp"})
+		Expect(err).To(HaveOccurred())
+		_, err = server.simulate(&SimulationRequest{SrcIP: "10.0.0.1", DstIP: "10.0.1.2", Proto: "tcp"})
+		Expect(err).To(HaveOccurred())
+
+		server.state = StateConnected
+		_, err = server.simulate(&SimulationRequest{DstPod: "default/server", SrcIP: "10.0.0.1", DstIP: "10.0.1.2", Proto: "tcp"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -71,6 +71,14 @@ func fromProtoEndpointID(ep *proto.WorkloadEndpointID) *WorkloadEndpointID {
 	}
 }
 
+func (wi *WorkloadEndpointID) toProto() *proto.WorkloadEndpointID {
+	return &proto.WorkloadEndpointID{
+		OrchestratorId: wi.OrchestratorID,
+		WorkloadId:     wi.WorkloadID,
+		EndpointId:     wi.EndpointID,
+	}
+}
+
 func fromProtoWorkload(wep *proto.WorkloadEndpoint, server *Server) *WorkloadEndpoint {
 	r := &WorkloadEndpoint{
 		SwIfIndex: []uint32{},
@@ -121,6 +129,7 @@ func (w *WorkloadEndpoint) getPolicies(state *PolicyState, network string) (conf
 		}
 		conf.ProfileIDs = append(conf.ProfileIDs, prof.VppID)
 	}
+	w.server.withAuditPass(conf, state, w.Tiers, network)
 	if len(conf.IngressPolicyIDs) > 0 {
 		conf.IngressPolicyIDs = append([]uint32{w.server.AllowFromHostPolicy.VppID}, conf.IngressPolicyIDs...)
 	}
@@ -160,6 +169,18 @@ func (w *WorkloadEndpoint) Update(vpp *vpplink.VppLink, new *WorkloadEndpoint, s
 	return nil
 }
 
+// Unconfigure removes the policies of an endpoint that is removed while its
+// interfaces still exist
+func (w *WorkloadEndpoint) Unconfigure(vpp *vpplink.VppLink) (err error) {
+	for _, swIfIndex := range w.SwIfIndex {
+		err = vpp.ConfigurePolicies(swIfIndex, types.NewInterfaceConfig(), 0)
+		if err != nil {
+			return errors.Wrapf(err, "cannot unconfigure policies on interface %d", swIfIndex)
+		}
+	}
+	return w.Delete(vpp)
+}
+
 func (w *WorkloadEndpoint) Delete(vpp *vpplink.VppLink) (err error) {
 	if len(w.SwIfIndex) == 0 {
 		return fmt.Errorf("deleting unconfigured wep")
@@ -0,0 +1,201 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"strings"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	prometheusExporter "github.com/orijtech/prometheus-go-metrics-exporter"
+	"go.fd.io/govpp/adapter"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/policy"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
+)
+
+var ruleLabelKeys = []*metricspb.LabelKey{
+	{Key: "tier", Description: "Tier of the policy"},
+	{Key: "policy", Description: "Name of the policy"},
+	{Key: "profile", Description: "Name of the profile"},
+	{Key: "ruleId", Description: "ID of the rule in the policy or profile"},
+	{Key: "direction", Description: "ingress or egress, from the endpoint point of view"},
+	{Key: "network", Description: "Network of the policy"},
+}
+
+var auditLabelKeys = append(append([]*metricspb.LabelKey{}, ruleLabelKeys...),
+	&metricspb.LabelKey{Key: "action", Description: "Action the rule would enforce if its policy was not in audit mode"},
+)
+
+var podLabelKeys = []*metricspb.LabelKey{
+	{Key: "namespace", Description: "Kubernetes namespace of the pod"},
+	{Key: "podName", Description: "Name of the pod"},
+	{Key: "nameInPod", Description: "Name of interface in the pod"},
+}
+
+func doublePoint(value uint64) []*metricspb.Point {
+	return []*metricspb.Point{{Value: &metricspb.Point_DoubleValue{DoubleValue: float64(value)}}}
+}
+
+// counterMetrics are the packets and bytes metrics of a capo combined counter
+type counterMetrics struct {
+	packets *metricspb.Metric
+	bytes   *metricspb.Metric
+}
+
+func newCounterMetric(name, unit, description string, labelKeys []*metricspb.LabelKey) *metricspb.Metric {
+	return &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:        name + "_" + unit,
+			Unit:        unit,
+			Description: description + " in " + unit,
+			LabelKeys:   labelKeys,
+		},
+		Timeseries: []*metricspb.TimeSeries{},
+	}
+}
+
+// newCounterMetrics returns the packets and bytes metrics for a capo counter
+func newCounterMetrics(name, description string, labelKeys []*metricspb.LabelKey) *counterMetrics {
+	return &counterMetrics{
+		packets: newCounterMetric(name, "packets", description, labelKeys),
+		bytes:   newCounterMetric(name, "bytes", description, labelKeys),
+	}
+}
+
+func (m *counterMetrics) add(labelValues []*metricspb.LabelValue, counter adapter.CombinedCounter) {
+	m.packets.Timeseries = append(m.packets.Timeseries, &metricspb.TimeSeries{LabelValues: labelValues, Points: doublePoint(counter.Packets())})
+	m.bytes.Timeseries = append(m.bytes.Timeseries, &metricspb.TimeSeries{LabelValues: labelValues, Points: doublePoint(counter.Bytes())})
+}
+
+func ruleLabelValues(labels policy.RuleLabels) []*metricspb.LabelValue {
+	return []*metricspb.LabelValue{
+		{Value: labels.Tier},
+		{Value: labels.Policy},
+		{Value: labels.Profile},
+		{Value: labels.RuleID},
+		{Value: labels.Direction},
+		{Value: labels.Network},
+	}
+}
+
+func podLabelValues(workloadID, interfaceName string) []*metricspb.LabelValue {
+	namespace, podName, _ := strings.Cut(workloadID, "/")
+	return []*metricspb.LabelValue{
+		{Value: namespace},
+		{Value: podName},
+		{Value: interfaceName},
+	}
+}
+
+// ruleCountersMetrics maps the capo rule counters to the calico rules they were
+// created for. A calico rule may be split in several capo rules, in which case
+// their counters are summed. The rules of policies in audit mode are exported
+// separately, along with the action they would enforce.
+func (s *Server) ruleCountersMetrics(counters map[uint32]adapter.CombinedCounter) []*metricspb.Metric {
+	hits := newCounterMetrics("policy_rule_hits", "number of hits of the policy rule", ruleLabelKeys)
+	auditHits := newCounterMetrics("policy_audit_hits", "number of hits of the audited policy rule", auditLabelKeys)
+	sums := make(map[policy.RuleLabels]adapter.CombinedCounter)
+	s.lock.Lock()
+	for ruleID, counter := range counters {
+		labels, ok := s.policyRuleLabels[ruleID]
+		if !ok {
+			continue
+		}
+		sum := sums[labels]
+		sum[0] += counter.Packets()
+		sum[1] += counter.Bytes()
+		sums[labels] = sum
+	}
+	s.lock.Unlock()
+	for labels, sum := range sums {
+		if labels.AuditAction != "" {
+			auditHits.add(append(ruleLabelValues(labels), &metricspb.LabelValue{Value: labels.AuditAction}), sum)
+			continue
+		}
+		hits.add(ruleLabelValues(labels), sum)
+	}
+	return []*metricspb.Metric{hits.packets, hits.bytes, auditHits.packets, auditHits.bytes}
+}
+
+// defaultDenyMetrics maps the per interface default deny counters to the pods
+func (s *Server) defaultDenyMetrics(counters map[uint32]adapter.CombinedCounter) []*metricspb.Metric {
+	drops := newCounterMetrics("policy_default_deny", "number of drops because no policy rule matched", podLabelKeys)
+	s.lock.Lock()
+	for swIfIndex, counter := range counters {
+		pod, ok := s.podInterfacesBySwifIndex[swIfIndex]
+		if !ok {
+			continue
+		}
+		drops.add(podLabelValues(pod.WorkloadID, pod.InterfaceName), counter)
+	}
+	s.lock.Unlock()
+	return []*metricspb.Metric{drops.packets, drops.bytes}
+}
+
+var driftLabelKeys = []*metricspb.LabelKey{
+	{Key: "object", Description: "Kind of capo object: ipset, rule, policy or interface"},
+	{Key: "drift", Description: "missing, unknown or mismatched in VPP"},
+}
+
+// policyDriftMetric exports the drift found at the last policy drift check,
+// it is nil until the first check
+func (s *Server) policyDriftMetric() *metricspb.Metric {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.policyDrift == nil {
+		return nil
+	}
+	metric := &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:        "policy_drift_objects",
+			Unit:        "objects",
+			Description: "number of capo objects differing from the agent state at the last drift check",
+			Type:        metricspb.MetricDescriptor_GAUGE_INT64,
+			LabelKeys:   driftLabelKeys,
+		},
+		Timeseries: []*metricspb.TimeSeries{},
+	}
+	for kind, count := range s.policyDrift.Counts() {
+		metric.Timeseries = append(metric.Timeseries, &metricspb.TimeSeries{
+			LabelValues: []*metricspb.LabelValue{{Value: kind.Object}, {Value: kind.Drift}},
+			Points:      []*metricspb.Point{{Value: &metricspb.Point_Int64Value{Int64Value: int64(count)}}},
+		})
+	}
+	return metric
+}
+
+func (s *Server) exportPolicyMetrics(pe *prometheusExporter.Exporter) error {
+	ruleCounters, defaultDenyCounters, err := vpplink.GetCapoStats(s.sc)
+	if err != nil {
+		return err
+	}
+	metrics := append(s.ruleCountersMetrics(ruleCounters), s.defaultDenyMetrics(defaultDenyCounters)...)
+	if driftMetric := s.policyDriftMetric(); driftMetric != nil {
+		metrics = append(metrics, driftMetric)
+	}
+	for _, metric := range metrics {
+		// empty timeseries prevents exporter from updating
+		if len(metric.Timeseries) == 0 {
+			metric.Timeseries = []*metricspb.TimeSeries{{}}
+		}
+		err := pe.ExportMetric(context.Background(), nil, nil, metric)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
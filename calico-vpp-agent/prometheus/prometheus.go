@@ -34,6 +34,8 @@ import (
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni/storage"
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/policy"
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/services"
 	"github.com/projectcalico/vpp-dataplane/v3/config"
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink"
 )
@@ -43,6 +45,9 @@ type Server struct {
 	vpp                      *vpplink.VppLink
 	podInterfacesBySwifIndex map[uint32]storage.LocalPodSpec
 	podInterfacesByKey       map[string]storage.LocalPodSpec
+	policyRuleLabels         map[uint32]policy.RuleLabels
+	policyDrift              *policy.PolicyDrift
+	serviceDrainingBackends  map[string]services.DrainingBackends
 	sc                       *statsclient.StatsClient
 	channel                  chan common.CalicoVppEvent
 	lock                     sync.Mutex
@@ -79,6 +84,14 @@ func (s *Server) recordMetrics(t *tomb.Tomb) {
 				}
 			}
 		}
+		err = s.exportPolicyMetrics(pe)
+		if err != nil {
+			s.log.Errorf("exportPolicyMetrics errored with %s", err)
+		}
+		err = s.exportServiceMetrics(pe)
+		if err != nil {
+			s.log.Errorf("exportServiceMetrics errored with %s", err)
+		}
 	}
 	ticker.Stop()
 }
@@ -209,10 +222,12 @@ func NewPrometheusServer(vpp *vpplink.VppLink, l *logrus.Entry) *Server {
 		channel:                  make(chan common.CalicoVppEvent, 10),
 		podInterfacesByKey:       make(map[string]storage.LocalPodSpec),
 		podInterfacesBySwifIndex: make(map[uint32]storage.LocalPodSpec),
+		serviceDrainingBackends:  make(map[string]services.DrainingBackends),
 	}
 	if *config.GetCalicoVppFeatureGates().PrometheusEnabled {
 		reg := common.RegisterHandler(server.channel, "prometheus events")
-		reg.ExpectEvents(common.PodAdded, common.PodDeleted)
+		reg.ExpectEvents(common.PodAdded, common.PodDeleted, common.PolicyRulesUpdated, common.PolicyDriftDetected,
+			common.ServiceDrainingBackendsUpdated)
 	}
 	return server
 }
@@ -235,11 +250,7 @@ func (s *Server) ServePrometheus(t *tomb.Tomb) error {
 					continue
 				}
 				s.lock.Lock()
-				if podSpec.TunTapSwIfIndex == vpplink.INVALID_SW_IF_INDEX {
-					s.podInterfacesBySwifIndex[podSpec.MemifSwIfIndex] = *podSpec
-				} else {
-					s.podInterfacesBySwifIndex[podSpec.TunTapSwIfIndex] = *podSpec
-				}
+				s.podInterfacesBySwifIndex[podSpec.GetPodSwIfIndex()] = *podSpec
 				s.podInterfacesByKey[podSpec.Key()] = *podSpec
 				s.lock.Unlock()
 			case common.PodDeleted:
@@ -251,10 +262,37 @@ func (s *Server) ServePrometheus(t *tomb.Tomb) error {
 				}
 				initialPod := s.podInterfacesByKey[podSpec.Key()]
 				delete(s.podInterfacesByKey, initialPod.Key())
-				if podSpec.TunTapSwIfIndex == vpplink.INVALID_SW_IF_INDEX {
-					delete(s.podInterfacesBySwifIndex, initialPod.MemifSwIfIndex)
+				delete(s.podInterfacesBySwifIndex, initialPod.GetPodSwIfIndex())
+				s.lock.Unlock()
+			case common.PolicyRulesUpdated:
+				ruleLabels, ok := evt.New.(map[uint32]policy.RuleLabels)
+				if !ok {
+					s.log.Errorf("evt.New is not a map[uint32]policy.RuleLabels %v", evt.New)
+					continue
+				}
+				s.lock.Lock()
+				s.policyRuleLabels = ruleLabels
+				s.lock.Unlock()
+			case common.PolicyDriftDetected:
+				drift, ok := evt.New.(*policy.PolicyDrift)
+				if !ok {
+					s.log.Errorf("evt.New is not a *policy.PolicyDrift %v", evt.New)
+					continue
+				}
+				s.lock.Lock()
+				s.policyDrift = drift
+				s.lock.Unlock()
+			case common.ServiceDrainingBackendsUpdated:
+				draining, ok := evt.New.(*services.ServiceDrainingBackends)
+				if !ok {
+					s.log.Errorf("evt.New is not a *services.ServiceDrainingBackends %v", evt.New)
+					continue
+				}
+				s.lock.Lock()
+				if draining.DrainingBackends == (services.DrainingBackends{}) {
+					delete(s.serviceDrainingBackends, draining.ServiceID)
 				} else {
-					delete(s.podInterfacesBySwifIndex, initialPod.TunTapSwIfIndex)
+					s.serviceDrainingBackends[draining.ServiceID] = draining.DrainingBackends
 				}
 				s.lock.Unlock()
 			}
@@ -0,0 +1,73 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"strings"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	prometheusExporter "github.com/orijtech/prometheus-go-metrics-exporter"
+)
+
+var drainingLabelKeys = []*metricspb.LabelKey{
+	{Key: "namespace", Description: "Kubernetes namespace of the service"},
+	{Key: "service", Description: "Name of the service"},
+	{Key: "serving", Description: "true when the backends still receive new connections, as no endpoint is ready"},
+}
+
+func int64Point(value int) []*metricspb.Point {
+	return []*metricspb.Point{{Value: &metricspb.Point_Int64Value{Int64Value: int64(value)}}}
+}
+
+// serviceDrainingMetric exports the terminating endpoints of the services
+func (s *Server) serviceDrainingMetric() *metricspb.Metric {
+	metric := &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:        "service_draining_backends",
+			Unit:        "backends",
+			Description: "number of terminating endpoints of the service",
+			Type:        metricspb.MetricDescriptor_GAUGE_INT64,
+			LabelKeys:   drainingLabelKeys,
+		},
+		Timeseries: []*metricspb.TimeSeries{},
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for serviceID, draining := range s.serviceDrainingBackends {
+		namespace, name, _ := strings.Cut(serviceID, "/")
+		metric.Timeseries = append(metric.Timeseries,
+			&metricspb.TimeSeries{
+				LabelValues: []*metricspb.LabelValue{{Value: namespace}, {Value: name}, {Value: "false"}},
+				Points:      int64Point(draining.Idle),
+			},
+			&metricspb.TimeSeries{
+				LabelValues: []*metricspb.LabelValue{{Value: namespace}, {Value: name}, {Value: "true"}},
+				Points:      int64Point(draining.Serving),
+			},
+		)
+	}
+	return metric
+}
+
+func (s *Server) exportServiceMetrics(pe *prometheusExporter.Exporter) error {
+	metric := s.serviceDrainingMetric()
+	// empty timeseries prevents exporter from updating
+	if len(metric.Timeseries) == 0 {
+		metric.Timeseries = []*metricspb.TimeSeries{{}}
+	}
+	return pe.ExportMetric(context.Background(), nil, nil, metric)
+}
@@ -29,4 +29,9 @@ type serviceInfo struct {
 	keepOriginalPacket bool
 	lbType             lbType
 	hashConfig         types.IPFlowHash
+	affinity           types.CnatAffinity
+	/* ClientIP affinity timeout in seconds */
+	affinityTimeout uint32
+	/* zone of the node, when the service uses topology aware routing */
+	topologyZone string
 }
@@ -17,9 +17,10 @@ package services
 
 import (
 	"net"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
-
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/cni"
@@ -29,7 +30,7 @@ import (
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
 )
 
-func getCnatBackendDstPort(servicePort *v1.ServicePort, endpointPort *v1.EndpointPort) uint16 {
+func getCnatBackendDstPort(servicePort *v1.ServicePort, endpointPort *discoveryv1.EndpointPort) uint16 {
 	targetPort := servicePort.TargetPort
 	if targetPort.Type == intstr.Int {
 		if targetPort.IntVal == 0 {
@@ -38,9 +39,10 @@ func getCnatBackendDstPort(servicePort *v1.ServicePort, endpointPort *v1.Endpoin
 		} else {
 			return uint16(targetPort.IntVal)
 		}
-	} else {
-		return uint16(endpointPort.Port)
+	} else if endpointPort.Port != nil {
+		return uint16(*endpointPort.Port)
 	}
+	return 0
 }
 
 func getServicePortProto(proto v1.Protocol) types.IPProto {
@@ -56,13 +58,101 @@ func getServicePortProto(proto v1.Protocol) types.IPProto {
 	}
 }
 
-func isEndpointAddressLocal(endpointAddress *v1.EndpointAddress) bool {
-	if endpointAddress != nil && endpointAddress.NodeName != nil && *endpointAddress.NodeName != *config.NodeName {
+func isEndpointLocal(endpoint *discoveryv1.Endpoint) bool {
+	if endpoint != nil && endpoint.NodeName != nil && *endpoint.NodeName != *config.NodeName {
 		return false
 	}
 	return true
 }
 
+// isEndpointReady tells whether the endpoint should receive traffic, a nil
+// condition means ready
+func isEndpointReady(endpoint *discoveryv1.Endpoint) bool {
+	return endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+}
+
+// isEndpointServing is isEndpointReady regardless of termination, a nil
+// condition means the same as ready
+func isEndpointServing(endpoint *discoveryv1.Endpoint) bool {
+	if endpoint.Conditions.Serving != nil {
+		return *endpoint.Conditions.Serving
+	}
+	return isEndpointReady(endpoint)
+}
+
+func isEndpointTerminating(endpoint *discoveryv1.Endpoint) bool {
+	return endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating
+}
+
+// hasZoneHint tells whether the endpoint is hinted for the zone
+func hasZoneHint(endpoint *discoveryv1.Endpoint, zone string) bool {
+	if endpoint.Hints == nil {
+		return false
+	}
+	for _, forZone := range endpoint.Hints.ForZones {
+		if forZone.Name == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// isTopologyAware tells whether the service asks for topology aware routing,
+// the topology-mode annotation replacing the topology-aware-hints one
+func isTopologyAware(service *v1.Service) bool {
+	value, found := service.Annotations[v1.AnnotationTopologyMode]
+	if !found {
+		value = service.Annotations[v1.DeprecatedAnnotationTopologyAwareHints]
+	}
+	return strings.EqualFold(value, "auto")
+}
+
+// canUseTopology tells whether the hints of the ready endpoints of the family
+// of ip are safe to use: like kube-proxy, they must all have hints, and some
+// must be for our zone. Otherwise all the endpoints are used.
+func canUseTopology(slices []*discoveryv1.EndpointSlice, ip net.IP, zone string) bool {
+	hasEndpointsForZone := false
+	for _, slice := range slices {
+		if !sliceMatchesFamily(slice, ip) {
+			continue
+		}
+		for i := range slice.Endpoints {
+			endpoint := &slice.Endpoints[i]
+			if !isEndpointReady(endpoint) {
+				continue
+			}
+			if endpoint.Hints == nil || len(endpoint.Hints.ForZones) == 0 {
+				return false
+			}
+			hasEndpointsForZone = hasEndpointsForZone || hasZoneHint(endpoint, zone)
+		}
+	}
+	return hasEndpointsForZone
+}
+
+// getEndpointSlicePort returns the port of the slice exposing the service port
+func getEndpointSlicePort(servicePort *v1.ServicePort, slice *discoveryv1.EndpointSlice) *discoveryv1.EndpointPort {
+	for i, endpointPort := range slice.Ports {
+		name := ""
+		if endpointPort.Name != nil {
+			name = *endpointPort.Name
+		}
+		if name == servicePort.Name {
+			return &slice.Ports[i]
+		}
+	}
+	return nil
+}
+
+// sliceMatchesFamily tells whether the addresses of the slice are of the
+// family of ip
+func sliceMatchesFamily(slice *discoveryv1.EndpointSlice, ip net.IP) bool {
+	if vpplink.IsIP6(ip) {
+		return slice.AddressType == discoveryv1.AddressTypeIPv6
+	}
+	return slice.AddressType == discoveryv1.AddressTypeIPv4
+}
+
 func getCnatLBType(lbType lbType) types.CnatLbType {
 	if lbType == lbTypeMaglev || lbType == lbTypeMaglevDSR {
 		return types.MaglevLB
@@ -70,6 +160,20 @@ func getCnatLBType(lbType lbType) types.CnatLbType {
 	return types.DefaultLB
 }
 
+// withSessionAffinity makes cnat send the new connections of the clients of
+// services with ClientIP affinity to the backend of their previous ones
+func (s *Server) withSessionAffinity(service *v1.Service, svc *serviceInfo) {
+	if service.Spec.SessionAffinity != v1.ServiceAffinityClientIP {
+		return
+	}
+	svc.affinity = types.ClientIPAffinity
+	svc.affinityTimeout = uint32(v1.DefaultClientIPServiceAffinitySeconds)
+	affinityConfig := service.Spec.SessionAffinityConfig
+	if affinityConfig != nil && affinityConfig.ClientIP != nil && affinityConfig.ClientIP.TimeoutSeconds != nil {
+		svc.affinityTimeout = uint32(*affinityConfig.ClientIP.TimeoutSeconds)
+	}
+}
+
 func getCnatVipDstPort(servicePort *v1.ServicePort, isNodePort bool) uint16 {
 	if isNodePort {
 		return uint16(servicePort.NodePort)
@@ -77,81 +181,216 @@ func getCnatVipDstPort(servicePort *v1.ServicePort, isNodePort bool) uint16 {
 	return uint16(servicePort.Port)
 }
 
-func buildCnatEntryForServicePort(servicePort *v1.ServicePort, service *v1.Service, ep *v1.Endpoints, serviceIP net.IP, isNodePort bool, svcInfo serviceInfo) *types.CnatTranslateEntry {
+func buildCnatEntryForServicePort(servicePort *v1.ServicePort, service *v1.Service, slices []*discoveryv1.EndpointSlice, serviceIP net.IP, isNodePort bool, svcInfo serviceInfo) *types.CnatTranslateEntry {
 	backends := make([]types.CnatEndpointTuple, 0)
+	/* Serving endpoints being terminated, only used when none is ready */
+	terminatingBackends := make([]types.CnatEndpointTuple, 0)
 	isLocalOnly := IsLocalOnly(service)
 	if isNodePort {
 		isLocalOnly = false
+	} else if IsInternalLocalOnly(service) && isServiceClusterIP(service, serviceIP) {
+		isLocalOnly = true
 	}
-	// Find the endpoint subset port that exposes the port we're interested in
-	for _, endpointSubset := range ep.Subsets {
-		for _, endpointPort := range endpointSubset.Ports {
-			if servicePort.Name == endpointPort.Name {
-				for _, endpointAddress := range endpointSubset.Addresses {
-					var flags uint8 = 0
-					if !isEndpointAddressLocal(&endpointAddress) && isLocalOnly {
-						continue
-					}
-					if !isEndpointAddressLocal(&endpointAddress) {
-						/* dont NAT to remote endpoints unless this is a nodeport */
-						if svcInfo.lbType == lbTypeMaglevDSR && !isNodePort {
-							flags = flags | types.CnatNoNat
-						}
-					}
-					ip := net.ParseIP(endpointAddress.IP)
-					if ip != nil {
-						backend := types.CnatEndpointTuple{
-							DstEndpoint: types.CnatEndpoint{
-								Port: getCnatBackendDstPort(servicePort, &endpointPort),
-								IP:   ip,
-							},
-							Flags: flags,
-						}
-						/* In nodeports, we need to sNAT when endpoint is not local to have a symmetric traffic */
-						if isNodePort && !isEndpointAddressLocal(&endpointAddress) {
-							backend.SrcEndpoint.IP = serviceIP
-						}
-						backends = append(backends, backend)
-					}
+	/* Topology does not apply to services only using local endpoints */
+	useTopology := svcInfo.topologyZone != "" && !isLocalOnly &&
+		canUseTopology(slices, serviceIP, svcInfo.topologyZone)
+	/* The same endpoint may transiently appear in several slices */
+	seen := make(map[string]bool)
+	seenTerminating := make(map[string]bool)
+	for _, slice := range slices {
+		if !sliceMatchesFamily(slice, serviceIP) {
+			continue
+		}
+		// Find the slice port that exposes the port we're interested in
+		endpointPort := getEndpointSlicePort(servicePort, slice)
+		if endpointPort == nil {
+			continue
+		}
+		for i := range slice.Endpoints {
+			endpoint := &slice.Endpoints[i]
+			var flags uint8 = 0
+			isReady := isEndpointReady(endpoint)
+			if !isReady && !(isEndpointServing(endpoint) && isEndpointTerminating(endpoint)) {
+				continue
+			}
+			if len(endpoint.Addresses) == 0 {
+				continue
+			}
+			if useTopology && isReady && !hasZoneHint(endpoint, svcInfo.topologyZone) {
+				continue
+			}
+			if !isEndpointLocal(endpoint) && isLocalOnly {
+				continue
+			}
+			if !isEndpointLocal(endpoint) {
+				/* dont NAT to remote endpoints unless this is a nodeport */
+				if svcInfo.lbType == lbTypeMaglevDSR && !isNodePort {
+					flags = flags | types.CnatNoNat
 				}
-				break
+			}
+			/* Consumers should only use the first address of an endpoint */
+			ip := net.ParseIP(endpoint.Addresses[0])
+			if ip == nil || seen[ip.String()] || (!isReady && seenTerminating[ip.String()]) {
+				continue
+			}
+			if isReady {
+				seen[ip.String()] = true
+			} else {
+				seenTerminating[ip.String()] = true
+			}
+			backend := types.CnatEndpointTuple{
+				DstEndpoint: types.CnatEndpoint{
+					Port: getCnatBackendDstPort(servicePort, endpointPort),
+					IP:   ip,
+				},
+				Flags: flags,
+			}
+			/* In nodeports, we need to sNAT when endpoint is not local to have a symmetric traffic */
+			if isNodePort && !isEndpointLocal(endpoint) {
+				backend.SrcEndpoint.IP = serviceIP
+			}
+			if isReady {
+				backends = append(backends, backend)
+			} else {
+				terminatingBackends = append(terminatingBackends, backend)
 			}
 		}
 	}
+	if len(backends) == 0 {
+		/* Like kube-proxy, rather send new connections to terminating
+		 * endpoints than drop them during rollouts */
+		backends = terminatingBackends
+	}
 
-	return &types.CnatTranslateEntry{
+	entry := &types.CnatTranslateEntry{
 		Proto: getServicePortProto(servicePort.Protocol),
 		Endpoint: types.CnatEndpoint{
 			Port: getCnatVipDstPort(servicePort, isNodePort),
 			IP:   serviceIP,
 		},
-		Backends:   backends,
-		IsRealIP:   isNodePort,
-		LbType:     getCnatLBType(svcInfo.lbType),
-		HashConfig: svcInfo.hashConfig,
+		Backends:        backends,
+		IsRealIP:        isNodePort,
+		LbType:          getCnatLBType(svcInfo.lbType),
+		HashConfig:      svcInfo.hashConfig,
+		Affinity:        svcInfo.affinity,
+		AffinityTimeout: svcInfo.affinityTimeout,
+	}
+	return entry
+}
+
+// getServiceClusterIPs returns the clusterIPs of all the families of a
+// service, Spec.ClusterIP being the first of Spec.ClusterIPs when set
+func getServiceClusterIPs(service *v1.Service) []net.IP {
+	ips := service.Spec.ClusterIPs
+	if len(ips) == 0 {
+		ips = []string{service.Spec.ClusterIP}
+	}
+	clusterIPs := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		clusterIP := net.ParseIP(ip)
+		if !clusterIP.IsUnspecified() && len(clusterIP) > 0 {
+			clusterIPs = append(clusterIPs, clusterIP)
+		}
+	}
+	return clusterIPs
+}
+
+func isServiceClusterIP(service *v1.Service, ip net.IP) bool {
+	for _, clusterIP := range getServiceClusterIPs(service) {
+		if clusterIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getServiceIPFamilies returns the families the nodePorts of a service are
+// exposed on. Older API servers do not set Spec.IPFamilies, in which case
+// they are the families of the clusterIPs, defaulting to IPv4
+func getServiceIPFamilies(service *v1.Service, clusterIPs []net.IP) []v1.IPFamily {
+	if len(service.Spec.IPFamilies) > 0 {
+		return service.Spec.IPFamilies
+	}
+	ipFamilies := make([]v1.IPFamily, 0, len(clusterIPs))
+	for _, clusterIP := range clusterIPs {
+		if vpplink.IsIP6(clusterIP) {
+			ipFamilies = append(ipFamilies, v1.IPv6Protocol)
+		} else {
+			ipFamilies = append(ipFamilies, v1.IPv4Protocol)
+		}
+	}
+	if len(ipFamilies) == 0 {
+		ipFamilies = append(ipFamilies, v1.IPv4Protocol)
 	}
+	return ipFamilies
 }
 
-func (s *Server) GetLocalService(service *v1.Service, ep *v1.Endpoints) (localService *LocalService) {
+// getDrainingBackends counts the terminating endpoints of the slices, and
+// those the entries still load-balance to
+func getDrainingBackends(slices []*discoveryv1.EndpointSlice, entries []types.CnatTranslateEntry) (draining DrainingBackends) {
+	backends := make(map[string]bool)
+	for _, entry := range entries {
+		for _, backend := range entry.Backends {
+			backends[backend.DstEndpoint.IP.String()] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for _, slice := range slices {
+		for i := range slice.Endpoints {
+			endpoint := &slice.Endpoints[i]
+			if !isEndpointTerminating(endpoint) || len(endpoint.Addresses) == 0 {
+				continue
+			}
+			ip := net.ParseIP(endpoint.Addresses[0])
+			if ip == nil || seen[ip.String()] {
+				continue
+			}
+			seen[ip.String()] = true
+			if backends[ip.String()] {
+				draining.Serving++
+			} else {
+				draining.Idle++
+			}
+		}
+	}
+	return draining
+}
+
+// GetLocalService returns the cnat entries of a service, load-balancing to
+// the endpoints of all its slices
+func (s *Server) GetLocalService(service *v1.Service, slices []*discoveryv1.EndpointSlice) (localService *LocalService) {
 	localService = &LocalService{
 		Entries:        make([]types.CnatTranslateEntry, 0),
 		SpecificRoutes: make([]net.IP, 0),
+		DropRoutes:     make([]net.IP, 0),
 		ServiceID:      serviceID(&service.ObjectMeta), /* ip.ObjectMeta should yield the same id */
 	}
 
 	serviceSpec := s.ParseServiceAnnotations(service.Annotations, service.Name)
-	clusterIP := net.ParseIP(service.Spec.ClusterIP)
-	nodeIP := s.getNodeIP(vpplink.IsIP6(clusterIP))
+	s.withSessionAffinity(service, serviceSpec)
+	if isTopologyAware(service) {
+		serviceSpec.topologyZone = s.getNodeZone()
+	}
+	clusterIPs := getServiceClusterIPs(service)
+	if IsInternalLocalOnly(service) {
+		/* Without local endpoints, cnat does not translate the clusterIPs
+		 * and the traffic would be forwarded as is */
+		localService.DropRoutes = append(localService.DropRoutes, clusterIPs...)
+	}
+	nodeIPs := make([]net.IP, 0, 2)
+	for _, ipFamily := range getServiceIPFamilies(service, clusterIPs) {
+		nodeIPs = append(nodeIPs, s.getNodeIP(ipFamily == v1.IPv6Protocol))
+	}
 	for _, servicePort := range service.Spec.Ports {
-		if !clusterIP.IsUnspecified() && len(clusterIP) > 0 {
-			entry := buildCnatEntryForServicePort(&servicePort, service, ep, clusterIP, false /* isNodePort */, *serviceSpec)
+		for _, clusterIP := range clusterIPs {
+			entry := buildCnatEntryForServicePort(&servicePort, service, slices, clusterIP, false /* isNodePort */, *serviceSpec)
 			localService.Entries = append(localService.Entries, *entry)
 		}
 
 		for _, eip := range service.Spec.ExternalIPs {
 			extIP := net.ParseIP(eip)
 			if !extIP.IsUnspecified() && len(extIP) > 0 {
-				entry := buildCnatEntryForServicePort(&servicePort, service, ep, extIP, false /* isNodePort */, *serviceSpec)
+				entry := buildCnatEntryForServicePort(&servicePort, service, slices, extIP, false /* isNodePort */, *serviceSpec)
 				localService.Entries = append(localService.Entries, *entry)
 				if IsLocalOnly(service) && len(entry.Backends) > 0 {
 					localService.SpecificRoutes = append(localService.SpecificRoutes, extIP)
@@ -162,7 +401,7 @@ func (s *Server) GetLocalService(service *v1.Service, ep *v1.Endpoints) (localSe
 		for _, ingress := range service.Status.LoadBalancer.Ingress {
 			ingressIP := net.ParseIP(ingress.IP)
 			if !ingressIP.IsUnspecified() && len(ingressIP) > 0 {
-				entry := buildCnatEntryForServicePort(&servicePort, service, ep, ingressIP, false /* isNodePort */, *serviceSpec)
+				entry := buildCnatEntryForServicePort(&servicePort, service, slices, ingressIP, false /* isNodePort */, *serviceSpec)
 				localService.Entries = append(localService.Entries, *entry)
 				if IsLocalOnly(service) && len(entry.Backends) > 0 {
 					localService.SpecificRoutes = append(localService.SpecificRoutes, ingressIP)
@@ -171,9 +410,11 @@ func (s *Server) GetLocalService(service *v1.Service, ep *v1.Endpoints) (localSe
 		}
 
 		if service.Spec.Type == v1.ServiceTypeNodePort {
-			if !nodeIP.IsUnspecified() && len(nodeIP) > 0 {
-				entry := buildCnatEntryForServicePort(&servicePort, service, ep, nodeIP, true /* isNodePort */, *serviceSpec)
-				localService.Entries = append(localService.Entries, *entry)
+			for _, nodeIP := range nodeIPs {
+				if !nodeIP.IsUnspecified() && len(nodeIP) > 0 {
+					entry := buildCnatEntryForServicePort(&servicePort, service, slices, nodeIP, true /* isNodePort */, *serviceSpec)
+					localService.Entries = append(localService.Entries, *entry)
+				}
 			}
 		}
 
@@ -181,12 +422,15 @@ func (s *Server) GetLocalService(service *v1.Service, ep *v1.Endpoints) (localSe
 		// Note: type=LoadBalancer only makes sense on cloud providers which support external load balancers and the actual
 		// creation of the load balancer happens asynchronously.
 		if service.Spec.Type == v1.ServiceTypeLoadBalancer && *service.Spec.AllocateLoadBalancerNodePorts {
-			if !nodeIP.IsUnspecified() && len(nodeIP) > 0 {
-				entry := buildCnatEntryForServicePort(&servicePort, service, ep, nodeIP, true /* isNodePort */, *serviceSpec)
-				localService.Entries = append(localService.Entries, *entry)
+			for _, nodeIP := range nodeIPs {
+				if !nodeIP.IsUnspecified() && len(nodeIP) > 0 {
+					entry := buildCnatEntryForServicePort(&servicePort, service, slices, nodeIP, true /* isNodePort */, *serviceSpec)
+					localService.Entries = append(localService.Entries, *entry)
+				}
 			}
 		}
 	}
+	localService.Draining = getDrainingBackends(slices, localService.Entries)
 	return
 }
 
@@ -221,6 +465,31 @@ func (s *Server) advertiseSpecificRoute(added []net.IP, deleted []net.IP) {
 	}
 }
 
+// configureDropRoutes adds routes dropping the traffic to clusterIPs in the
+// main table. cnat's FIB entries take precedence over them, so they only
+// apply to the clusterIPs without backends.
+func (s *Server) configureDropRoutes(added []net.IP, deleted []net.IP) {
+	for _, ip := range deleted {
+		err := s.vpp.RouteDel(getDropRoute(ip))
+		if err != nil {
+			s.log.Errorf("svc(del) Error deleting drop route for %s: %v", ip, err)
+		}
+	}
+	for _, ip := range added {
+		err := s.vpp.RouteAdd(getDropRoute(ip))
+		if err != nil {
+			s.log.Errorf("svc(add) Error adding drop route for %s: %v", ip, err)
+		}
+	}
+}
+
+func getDropRoute(ip net.IP) *types.Route {
+	return &types.Route{
+		Dst:   common.ToMaxLenCIDR(ip),
+		Paths: []types.RoutePath{{IsDrop: true, SwIfIndex: types.InvalidID}},
+	}
+}
+
 func (s *Server) deleteServiceEntries(entries []types.CnatTranslateEntry, oldService *LocalService) {
 	for _, entry := range entries {
 		oldServiceState, found := s.serviceStateMap[entry.Key()]
@@ -258,7 +527,12 @@ func (s *Server) deleteServiceByName(serviceID string) {
 		}
 		delete(s.serviceStateMap, key)
 	}
-
+	if oldService, found := s.localServices[serviceID]; found {
+		s.advertiseSpecificRoute(nil, oldService.SpecificRoutes)
+		s.configureDropRoutes(nil, oldService.DropRoutes)
+		s.publishDrainingBackends(serviceID, nil, oldService)
+		delete(s.localServices, serviceID)
+	}
 }
 
 func (s *Server) sameServiceEntries(entries []types.CnatTranslateEntry, service *LocalService) {
@@ -0,0 +1,430 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testNodeName    = "node1"
+	testServiceName = "web"
+	testNamespace   = "default"
+)
+
+func TestServices(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Services tests")
+}
+
+func newTestServer() *Server {
+	*config.NodeName = testNodeName
+	nodeIP4 := &net.IPNet{IP: net.ParseIP("192.168.0.1"), Mask: net.CIDRMask(24, 32)}
+	nodeIP6 := &net.IPNet{IP: net.ParseIP("fd10::1"), Mask: net.CIDRMask(64, 128)}
+	return &Server{
+		log:                  logrus.WithField("component", "services-test"),
+		nodeBGPSpec:          &common.LocalNodeSpec{IPv4Address: nodeIP4, IPv6Address: nodeIP6},
+		serviceStore:         cache.NewStore(cache.MetaNamespaceKeyFunc),
+		endpointSliceIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{serviceNameIndex: endpointSliceServiceIndexFunc}),
+		serviceStateMap:      make(map[string]ServiceState),
+		localServices:        make(map[string]*LocalService),
+	}
+}
+
+func testService(targetPort intstr.IntOrString) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: testServiceName, Namespace: testNamespace},
+		Spec: v1.ServiceSpec{
+			Type:      v1.ServiceTypeClusterIP,
+			ClusterIP: "10.96.0.10",
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   v1.ProtocolTCP,
+				Port:       80,
+				TargetPort: targetPort,
+			}},
+		},
+	}
+}
+
+// testEndpointSlice returns a slice of the test service with count endpoints,
+// numbered from first
+func testEndpointSlice(name string, first, count int, nodeName string) *discoveryv1.EndpointSlice {
+	portName := "http"
+	port := int32(8080)
+	protocol := v1.ProtocolTCP
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: testServiceName},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port, Protocol: &protocol}},
+	}
+	for i := first; i < first+count; i++ {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses: []string{fmt.Sprintf("10.0.%d.%d", i/256, i%256)},
+			NodeName:  &nodeName,
+		})
+	}
+	return slice
+}
+
+// testEndpointSlice6 is testEndpointSlice with IPv6 endpoints
+func testEndpointSlice6(name string, first, count int, nodeName string) *discoveryv1.EndpointSlice {
+	slice := testEndpointSlice(name, first, count, nodeName)
+	slice.AddressType = discoveryv1.AddressTypeIPv6
+	for i := range slice.Endpoints {
+		slice.Endpoints[i].Addresses = []string{fmt.Sprintf("fd00::%x", first+i)}
+	}
+	return slice
+}
+
+func addSlices(server *Server, slices ...*discoveryv1.EndpointSlice) {
+	for _, slice := range slices {
+		err := server.endpointSliceIndexer.Add(slice)
+		Expect(err).ToNot(HaveOccurred())
+	}
+}
+
+func backendIPs(entry *types.CnatTranslateEntry) map[string]bool {
+	ips := make(map[string]bool)
+	for _, backend := range entry.Backends {
+		ips[backend.DstEndpoint.IP.String()] = true
+	}
+	return ips
+}
+
+var _ = Describe("Services from EndpointSlices", func() {
+	var server *Server
+
+	BeforeEach(func() {
+		server = newTestServer()
+	})
+
+	It("should merge the slices of services with more than 1000 endpoints", func() {
+		service := testService(intstr.FromInt(8080))
+		Expect(server.serviceStore.Add(service)).To(Succeed())
+		/* The endpointslice controller caps slices at 100 endpoints */
+		for i := 0; i < 25; i++ {
+			addSlices(server, testEndpointSlice(fmt.Sprintf("web-%02d", i), i*100, 100, "node2"))
+		}
+		/* Slices of other services are not merged */
+		other := testEndpointSlice("other", 5000, 10, "node2")
+		other.Labels[discoveryv1.LabelServiceName] = "other"
+		addSlices(server, other)
+
+		localService := server.resolveLocalServiceByID(testNamespace + "/" + testServiceName)
+		Expect(localService).ToNot(BeNil())
+		Expect(localService.ServiceID).To(Equal("default/web"))
+		Expect(localService.Entries).To(HaveLen(1))
+		entry := localService.Entries[0]
+		Expect(entry.Endpoint.IP.String()).To(Equal("10.96.0.10"))
+		Expect(entry.Endpoint.Port).To(Equal(uint16(80)))
+		Expect(entry.Backends).To(HaveLen(2500))
+		Expect(backendIPs(&entry)).To(HaveKey("10.0.9.195"))
+		Expect(backendIPs(&entry)).ToNot(HaveKey("10.0.19.136"))
+		for _, backend := range entry.Backends {
+			Expect(backend.DstEndpoint.Port).To(Equal(uint16(8080)))
+		}
+	})
+
+	It("should skip not ready and duplicated endpoints", func() {
+		service := testService(intstr.FromString("http"))
+		Expect(server.serviceStore.Add(service)).To(Succeed())
+		notReady := false
+		sliceA := testEndpointSlice("web-a", 0, 1200, testNodeName)
+		sliceA.Endpoints[3].Conditions.Ready = &notReady
+		/* Endpoints can transiently be in two slices while moving */
+		sliceB := testEndpointSlice("web-b", 1100, 200, testNodeName)
+		addSlices(server, sliceB, sliceA)
+
+		localService := server.resolveLocalServiceByID("default/web")
+		Expect(localService).ToNot(BeNil())
+		entry := localService.Entries[0]
+		Expect(entry.Backends).To(HaveLen(1299))
+		Expect(backendIPs(&entry)).ToNot(HaveKey("10.0.0.3"))
+		/* Named target ports use the slice port */
+		Expect(entry.Backends[0].DstEndpoint.Port).To(Equal(uint16(8080)))
+		Expect(entry.Backends[0].DstEndpoint.IP.String()).To(Equal("10.0.0.0"))
+	})
+
+	It("should only keep local endpoints of local services", func() {
+		service := testService(intstr.FromInt(8080))
+		service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+		service.Spec.ExternalIPs = []string{"172.16.0.1"}
+		addSlices(server,
+			testEndpointSlice("web-local", 0, 600, testNodeName),
+			testEndpointSlice("web-remote", 600, 600, "node2"),
+		)
+		localService := server.resolveLocalServiceFromService(service)
+		Expect(localService.Entries).To(HaveLen(2))
+		Expect(localService.Entries[0].Backends).To(HaveLen(600))
+		Expect(localService.Entries[1].Endpoint.IP.String()).To(Equal("172.16.0.1"))
+		Expect(localService.Entries[1].Backends).To(HaveLen(600))
+		Expect(localService.SpecificRoutes).To(HaveLen(1))
+	})
+
+	It("should diff services with their previous state", func() {
+		service := testService(intstr.FromInt(8080))
+		oldService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{
+			testEndpointSlice("web-a", 0, 1000, "node2"),
+			testEndpointSlice("web-b", 1000, 500, "node2"),
+		})
+		newService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{
+			testEndpointSlice("web-b", 1000, 500, "node2"),
+		})
+		added, same, deleted, changed := compareEntryLists(newService, oldService)
+		Expect(changed).To(BeTrue())
+		Expect(deleted).To(BeEmpty())
+		Expect(same).To(HaveLen(1))
+		Expect(added).To(HaveLen(1))
+		Expect(added[0].Backends).To(HaveLen(500))
+
+		/* Slices listed in another order yield the same entries */
+		reordered := server.GetLocalService(service, []*discoveryv1.EndpointSlice{
+			testEndpointSlice("web-b", 1000, 500, "node2"),
+			testEndpointSlice("web-a", 0, 1000, "node2"),
+		})
+		_, _, _, changed = compareEntryLists(reordered, oldService)
+		Expect(changed).To(BeFalse())
+
+		_, _, deleted, changed = compareEntryLists(nil, oldService)
+		Expect(changed).To(BeTrue())
+		Expect(deleted).To(HaveLen(1))
+	})
+
+	It("should program both families of dual-stack services", func() {
+		service := testService(intstr.FromInt(8080))
+		service.Spec.Type = v1.ServiceTypeNodePort
+		service.Spec.Ports[0].NodePort = 30080
+		service.Spec.ClusterIPs = []string{"10.96.0.10", "fd20::10"}
+		service.Spec.IPFamilies = []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}
+		addSlices(server,
+			testEndpointSlice("web-v4", 0, 3, "node2"),
+			testEndpointSlice6("web-v6", 0, 2, testNodeName),
+		)
+		localService := server.resolveLocalServiceFromService(service)
+		entries := make(map[string]types.CnatTranslateEntry)
+		for _, entry := range localService.Entries {
+			entries[entry.Endpoint.IP.String()] = entry
+		}
+		Expect(entries).To(HaveLen(4))
+		for vip, backends := range map[string]int{"10.96.0.10": 3, "fd20::10": 2, "192.168.0.1": 3, "fd10::1": 2} {
+			Expect(entries).To(HaveKey(vip))
+			entry := entries[vip]
+			Expect(entry.Backends).To(HaveLen(backends))
+			for _, backend := range entry.Backends {
+				Expect(backend.DstEndpoint.IP.To4() == nil).To(Equal(entry.Endpoint.IP.To4() == nil))
+			}
+		}
+		Expect(entries["fd10::1"].Endpoint.Port).To(Equal(uint16(30080)))
+		Expect(entries["fd10::1"].IsRealIP).To(BeTrue())
+		/* Remote IPv4 backends of nodeports are sNATed to the node address */
+		Expect(entries["192.168.0.1"].Backends[0].SrcEndpoint.IP.String()).To(Equal("192.168.0.1"))
+
+		/* Single stack IPv6 services only expose an IPv6 nodePort */
+		service.Spec.ClusterIPs = []string{"fd20::10"}
+		service.Spec.ClusterIP = "fd20::10"
+		service.Spec.IPFamilies = []v1.IPFamily{v1.IPv6Protocol}
+		localService = server.resolveLocalServiceFromService(service)
+		Expect(localService.Entries).To(HaveLen(2))
+		Expect(localService.Entries[0].Endpoint.IP.String()).To(Equal("fd20::10"))
+		Expect(localService.Entries[1].Endpoint.IP.String()).To(Equal("fd10::1"))
+	})
+
+	It("should set the ClientIP affinity of the cnat entries", func() {
+		service := testService(intstr.FromInt(8080))
+		slices := []*discoveryv1.EndpointSlice{testEndpointSlice("web-a", 0, 3, "node2")}
+		oldService := server.GetLocalService(service, slices)
+		Expect(oldService.Entries[0].Affinity).To(Equal(types.NoAffinity))
+		Expect(oldService.Entries[0].AffinityTimeout).To(BeZero())
+
+		service.Spec.SessionAffinity = v1.ServiceAffinityClientIP
+		localService := server.GetLocalService(service, slices)
+		entry := localService.Entries[0]
+		Expect(entry.Affinity).To(Equal(types.ClientIPAffinity))
+		Expect(entry.AffinityTimeout).To(Equal(uint32(v1.DefaultClientIPServiceAffinitySeconds)))
+		Expect(entry.String()).To(ContainSubstring("affinity=ClientIP/10800s"))
+		/* The load balancing is left to the annotations */
+		Expect(entry.HashConfig).To(BeZero())
+		Expect(entry.LbType).To(Equal(types.DefaultLB))
+		/* Enabling affinity updates the existing cnat entry */
+		added, same, deleted, changed := compareEntryLists(localService, oldService)
+		Expect(changed).To(BeTrue())
+		Expect(deleted).To(BeEmpty())
+		Expect(same).To(HaveLen(1))
+		Expect(added).To(HaveLen(1))
+	})
+
+	It("should use the ClientIP affinity timeout of the service", func() {
+		service := testService(intstr.FromInt(8080))
+		slices := []*discoveryv1.EndpointSlice{testEndpointSlice("web-a", 0, 3, "node2")}
+		service.Spec.SessionAffinity = v1.ServiceAffinityClientIP
+		oldEntry := server.GetLocalService(service, slices).Entries[0]
+
+		timeout := int32(60)
+		service.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &timeout}}
+		service.Annotations = map[string]string{"cni.projectcalico.org/vppLBType": "maglev"}
+		entry := server.GetLocalService(service, slices).Entries[0]
+		Expect(entry.Affinity).To(Equal(types.ClientIPAffinity))
+		Expect(entry.AffinityTimeout).To(Equal(uint32(60)))
+		Expect(entry.LbType).To(Equal(types.MaglevLB))
+
+		entry.LbType = oldEntry.LbType
+		Expect(entry.Equal(&oldEntry)).To(Equal(types.CanUpdateObj))
+		entry.AffinityTimeout = oldEntry.AffinityTimeout
+		Expect(entry.Equal(&oldEntry)).To(Equal(types.AreEqualObj))
+	})
+
+	It("should only keep local endpoints of internal local services", func() {
+		service := testService(intstr.FromInt(8080))
+		service.Spec.Type = v1.ServiceTypeNodePort
+		service.Spec.Ports[0].NodePort = 30080
+		service.Spec.ExternalIPs = []string{"172.16.0.1"}
+		policy := v1.ServiceInternalTrafficPolicyLocal
+		service.Spec.InternalTrafficPolicy = &policy
+		slices := []*discoveryv1.EndpointSlice{
+			testEndpointSlice("web-local", 0, 2, testNodeName),
+			testEndpointSlice("web-remote", 2, 3, "node2"),
+		}
+		localService := server.GetLocalService(service, slices)
+		Expect(localService.Entries).To(HaveLen(3))
+		Expect(localService.Entries[0].Endpoint.IP.String()).To(Equal("10.96.0.10"))
+		Expect(localService.Entries[0].Backends).To(HaveLen(2))
+		/* External addresses still load-balance to all endpoints */
+		Expect(localService.Entries[1].Endpoint.IP.String()).To(Equal("172.16.0.1"))
+		Expect(localService.Entries[1].Backends).To(HaveLen(5))
+		Expect(localService.Entries[2].Endpoint.IP.String()).To(Equal("192.168.0.1"))
+		Expect(localService.Entries[2].Backends).To(HaveLen(5))
+		Expect(localService.DropRoutes).To(Equal([]net.IP{net.ParseIP("10.96.0.10")}))
+
+		/* Without local endpoints, the clusterIP has no backend and is dropped */
+		localService = server.GetLocalService(service, slices[1:])
+		Expect(localService.Entries[0].Backends).To(BeEmpty())
+		Expect(localService.DropRoutes).To(HaveLen(1))
+
+		added, deleted, changed := compareDropRoutes(nil, localService)
+		Expect(changed).To(BeTrue())
+		Expect(added).To(BeEmpty())
+		Expect(deleted).To(HaveLen(1))
+		service.Spec.InternalTrafficPolicy = nil
+		_, deleted, changed = compareDropRoutes(server.GetLocalService(service, slices), localService)
+		Expect(changed).To(BeTrue())
+		Expect(deleted).To(HaveLen(1))
+	})
+
+	It("should keep dropping the clusterIPs of internal local services without endpoint slices", func() {
+		service := testService(intstr.FromInt(8080))
+		policy := v1.ServiceInternalTrafficPolicyLocal
+		service.Spec.InternalTrafficPolicy = &policy
+		oldService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{testEndpointSlice("web-local", 0, 2, testNodeName)})
+
+		localService := server.resolveLocalServiceFromService(service)
+		Expect(localService).ToNot(BeNil())
+		Expect(localService.Entries).To(BeEmpty())
+		Expect(localService.DropRoutes).To(Equal([]net.IP{net.ParseIP("10.96.0.10")}))
+		_, _, deleted, _ := compareEntryLists(localService, oldService)
+		Expect(deleted).To(HaveLen(1))
+		_, _, changed := compareDropRoutes(localService, oldService)
+		Expect(changed).To(BeFalse())
+
+		service.Spec.InternalTrafficPolicy = nil
+		Expect(server.resolveLocalServiceFromService(service)).To(BeNil())
+	})
+
+	It("should fall back to serving terminating endpoints", func() {
+		service := testService(intstr.FromInt(8080))
+		yes, no := true, false
+		slice := testEndpointSlice("web-a", 0, 4, "node2")
+		/* 0 is ready, 1 is serving while terminating, 2 is draining */
+		slice.Endpoints[1].Conditions = discoveryv1.EndpointConditions{Ready: &no, Serving: &yes, Terminating: &yes}
+		slice.Endpoints[2].Conditions = discoveryv1.EndpointConditions{Ready: &no, Serving: &no, Terminating: &yes}
+		slice.Endpoints[3].Conditions = discoveryv1.EndpointConditions{Ready: &no}
+		oldService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(backendIPs(&oldService.Entries[0])).To(Equal(map[string]bool{"10.0.0.0": true}))
+		Expect(oldService.Draining).To(Equal(DrainingBackends{Idle: 2}))
+
+		slice.Endpoints[0].Conditions = discoveryv1.EndpointConditions{Ready: &no, Serving: &yes, Terminating: &yes}
+		localService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(backendIPs(&localService.Entries[0])).To(Equal(map[string]bool{"10.0.0.0": true, "10.0.0.1": true}))
+		Expect(localService.Draining).To(Equal(DrainingBackends{Idle: 1, Serving: 2}))
+		/* The cnat entry is updated in place, keeping its sessions */
+		added, same, deleted, _ := compareEntryLists(localService, oldService)
+		Expect(deleted).To(BeEmpty())
+		Expect(same).To(HaveLen(1))
+		Expect(added).To(HaveLen(1))
+
+		/* Terminating endpoints are not used while some endpoint is ready */
+		readySlice := testEndpointSlice("web-b", 10, 1, "node2")
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice, readySlice})
+		Expect(backendIPs(&localService.Entries[0])).To(Equal(map[string]bool{"10.0.0.10": true}))
+		Expect(localService.Draining).To(Equal(DrainingBackends{Idle: 3}))
+	})
+
+	It("should only use the endpoints hinted for our zone", func() {
+		server.nodeBGPSpec.Labels = map[string]string{v1.LabelTopologyZone: "zone-a"}
+		service := testService(intstr.FromInt(8080))
+		service.Annotations = map[string]string{v1.AnnotationTopologyMode: "Auto"}
+		slice := testEndpointSlice("web-a", 0, 4, "node2")
+		for i, zone := range []string{"zone-a", "zone-b", "zone-a", "zone-b"} {
+			slice.Endpoints[i].Hints = &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: zone}}}
+		}
+		localService := server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(backendIPs(&localService.Entries[0])).To(Equal(map[string]bool{"10.0.0.0": true, "10.0.0.2": true}))
+
+		/* Without the annotation, hints are ignored */
+		service.Annotations = map[string]string{v1.AnnotationTopologyMode: "Disabled"}
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(localService.Entries[0].Backends).To(HaveLen(4))
+		service.Annotations = map[string]string{v1.DeprecatedAnnotationTopologyAwareHints: "auto"}
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(localService.Entries[0].Backends).To(HaveLen(2))
+
+		/* Hints are unsafe when an endpoint misses them */
+		slice.Endpoints[3].Hints = nil
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(localService.Entries[0].Backends).To(HaveLen(4))
+
+		/* or when no endpoint is for our zone */
+		server.nodeBGPSpec.Labels = map[string]string{v1.LabelTopologyZone: "zone-c"}
+		slice.Endpoints[3].Hints = &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: "zone-b"}}}
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(localService.Entries[0].Backends).To(HaveLen(4))
+
+		/* or when the node has no zone */
+		server.nodeBGPSpec.Labels = nil
+		localService = server.GetLocalService(service, []*discoveryv1.EndpointSlice{slice})
+		Expect(localService.Entries[0].Backends).To(HaveLen(4))
+	})
+})
@@ -18,6 +18,7 @@ package services
 import (
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,6 +29,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/tomb.v2"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
@@ -44,6 +46,9 @@ const (
 	KeepOriginalPacketAnnotation string = "KeepOriginalPacket"
 	HashConfigAnnotation         string = "HashConfig"
 	LBTypeAnnotation             string = "LBType"
+
+	/* Index of the EndpointSlices by the service they belong to */
+	serviceNameIndex string = "serviceName"
 )
 
 /**
@@ -54,7 +59,26 @@ const (
 type LocalService struct {
 	Entries        []types.CnatTranslateEntry
 	SpecificRoutes []net.IP
+	DropRoutes     []net.IP /* clusterIPs dropped when cnat has no backend for them */
 	ServiceID      string
+	Draining       DrainingBackends
+}
+
+/**
+ * Counts the terminating endpoints of a service. cnat keeps their
+ * established connections in its sessions until they go away.
+ */
+type DrainingBackends struct {
+	Idle    int /* not receiving new connections */
+	Serving int /* still receiving new connections, as no endpoint is ready */
+}
+
+/**
+ * Sent to the prometheus server when the draining backends of a service change
+ */
+type ServiceDrainingBackends struct {
+	ServiceID string
+	DrainingBackends
 }
 
 /**
@@ -66,12 +90,12 @@ type ServiceState struct {
 }
 
 type Server struct {
-	log              *logrus.Entry
-	vpp              *vpplink.VppLink
-	endpointStore    cache.Store
-	serviceStore     cache.Store
-	serviceInformer  cache.Controller
-	endpointInformer cache.Controller
+	log                   *logrus.Entry
+	vpp                   *vpplink.VppLink
+	endpointSliceIndexer  cache.Indexer
+	serviceStore          cache.Store
+	serviceInformer       cache.Controller
+	endpointSliceInformer cache.Controller
 
 	lock sync.Mutex /* protects handleServiceEndpointEvent(s)/Serve */
 
@@ -79,6 +103,8 @@ type Server struct {
 	nodeBGPSpec *common.LocalNodeSpec
 
 	serviceStateMap map[string]ServiceState
+	/* LocalServices programmed in VPP, by serviceID */
+	localServices map[string]*LocalService
 
 	t tomb.Tomb
 }
@@ -150,24 +176,59 @@ func (s *Server) resolveLocalServiceFromService(service *v1.Service) *LocalServi
 	if service == nil {
 		return nil
 	}
-	ep := s.findMatchingEndpoint(service)
-	if ep == nil {
+	slices := s.findMatchingEndpointSlices(serviceID(&service.ObjectMeta))
+	if len(slices) == 0 {
 		s.log.Debugf("svc() no endpoints found for service=%s", serviceID(&service.ObjectMeta))
+		if IsInternalLocalOnly(service) {
+			/* Nothing translates the clusterIPs anymore, keep dropping them */
+			return &LocalService{
+				Entries:        make([]types.CnatTranslateEntry, 0),
+				SpecificRoutes: make([]net.IP, 0),
+				DropRoutes:     getServiceClusterIPs(service),
+				ServiceID:      serviceID(&service.ObjectMeta),
+			}
+		}
 		return nil
 	}
-	return s.GetLocalService(service, ep)
+	return s.GetLocalService(service, slices)
 }
 
-func (s *Server) resolveLocalServiceFromEndpoints(ep *v1.Endpoints) *LocalService {
-	if ep == nil {
-		return nil
-	}
-	service := s.findMatchingService(ep)
+func (s *Server) resolveLocalServiceByID(id string) *LocalService {
+	service := s.findMatchingService(id)
 	if service == nil {
-		s.log.Debugf("svc() no svc found for endpoints=%s", serviceID(&ep.ObjectMeta))
+		s.log.Debugf("svc() no svc found for endpoints=%s", id)
 		return nil
 	}
-	return s.GetLocalService(service, ep)
+	return s.resolveLocalServiceFromService(service)
+}
+
+// endpointSliceServiceID returns the serviceID of the service owning the
+// slice, or "" when the slice is not managed for a service
+func endpointSliceServiceID(slice *discoveryv1.EndpointSlice) string {
+	serviceName, found := slice.Labels[discoveryv1.LabelServiceName]
+	if !found || serviceName == "" {
+		return ""
+	}
+	return slice.Namespace + "/" + serviceName
+}
+
+func endpointSliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, errors.Errorf("wrong type for obj, not *discoveryv1.EndpointSlice")
+	}
+	if id := endpointSliceServiceID(slice); id != "" {
+		return []string{id}, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) handleEndpointSliceEvent(slice *discoveryv1.EndpointSlice) {
+	id := endpointSliceServiceID(slice)
+	if id == "" {
+		return
+	}
+	s.handleServiceEndpointEvent(id, s.resolveLocalServiceByID(id))
 }
 
 func NewServiceServer(vpp *vpplink.VppLink, k8sclient *kubernetes.Clientset, log *logrus.Entry) *Server {
@@ -175,6 +236,7 @@ func NewServiceServer(vpp *vpplink.VppLink, k8sclient *kubernetes.Clientset, log
 		vpp:             vpp,
 		log:             log,
 		serviceStateMap: make(map[string]ServiceState),
+		localServices:   make(map[string]*LocalService),
 	}
 
 	serviceListWatch := cache.NewListWatchFromClient(k8sclient.CoreV1().RESTClient(),
@@ -189,21 +251,20 @@ func NewServiceServer(vpp *vpplink.VppLink, k8sclient *kubernetes.Clientset, log
 				if !ok {
 					panic("wrong type for obj, not *v1.Service")
 				}
-				localService := server.resolveLocalServiceFromService(service)
-				server.handleServiceEndpointEvent(localService, nil)
+				server.handleServiceEndpointEvent(
+					serviceID(&service.ObjectMeta),
+					server.resolveLocalServiceFromService(service),
+				)
 			},
 			UpdateFunc: func(old interface{}, obj interface{}) {
 				service, ok := obj.(*v1.Service)
 				if !ok {
 					panic("wrong type for obj, not *v1.Service")
 				}
-				oldService, ok := old.(*v1.Service)
-				if !ok {
-					panic("wrong type for old, not *v1.Service")
-				}
-				oldLocalService := server.resolveLocalServiceFromService(oldService)
-				localService := server.resolveLocalServiceFromService(service)
-				server.handleServiceEndpointEvent(localService, oldLocalService)
+				server.handleServiceEndpointEvent(
+					serviceID(&service.ObjectMeta),
+					server.resolveLocalServiceFromService(service),
+				)
 			},
 			DeleteFunc: func(obj interface{}) {
 				switch value := obj.(type) {
@@ -221,57 +282,56 @@ func NewServiceServer(vpp *vpplink.VppLink, k8sclient *kubernetes.Clientset, log
 			},
 		})
 
-	endpointListWatch := cache.NewListWatchFromClient(k8sclient.CoreV1().RESTClient(),
-		"endpoints", "", fields.Everything())
-	endpointStore, endpointInformer := cache.NewInformer(
-		endpointListWatch,
-		&v1.Endpoints{},
+	endpointSliceListWatch := cache.NewListWatchFromClient(k8sclient.DiscoveryV1().RESTClient(),
+		"endpointslices", "", fields.Everything())
+	endpointSliceIndexer, endpointSliceInformer := cache.NewIndexerInformer(
+		endpointSliceListWatch,
+		&discoveryv1.EndpointSlice{},
 		60*time.Second,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				endpoints, ok := obj.(*v1.Endpoints)
+				slice, ok := obj.(*discoveryv1.EndpointSlice)
 				if !ok {
-					panic("wrong type for obj, not *v1.Endpoints")
+					panic("wrong type for obj, not *discoveryv1.EndpointSlice")
 				}
-				server.handleServiceEndpointEvent(
-					server.resolveLocalServiceFromEndpoints(endpoints),
-					nil,
-				)
+				server.handleEndpointSliceEvent(slice)
 			},
 			UpdateFunc: func(old interface{}, obj interface{}) {
-				endpoints, ok := obj.(*v1.Endpoints)
+				slice, ok := obj.(*discoveryv1.EndpointSlice)
 				if !ok {
-					panic("wrong type for obj, not *v1.Endpoints")
+					panic("wrong type for obj, not *discoveryv1.EndpointSlice")
 				}
-				oldEndpoints, ok := old.(*v1.Endpoints)
+				oldSlice, ok := old.(*discoveryv1.EndpointSlice)
 				if !ok {
-					panic("wrong type for old, not *v1.Endpoints")
+					panic("wrong type for old, not *discoveryv1.EndpointSlice")
 				}
-				server.handleServiceEndpointEvent(
-					server.resolveLocalServiceFromEndpoints(endpoints),
-					server.resolveLocalServiceFromEndpoints(oldEndpoints),
-				)
+				if endpointSliceServiceID(oldSlice) != endpointSliceServiceID(slice) {
+					server.handleEndpointSliceEvent(oldSlice)
+				}
+				server.handleEndpointSliceEvent(slice)
 			},
 			DeleteFunc: func(obj interface{}) {
 				switch value := obj.(type) {
 				case cache.DeletedFinalStateUnknown:
-					endpoints, ok := value.Obj.(*v1.Endpoints)
+					slice, ok := value.Obj.(*discoveryv1.EndpointSlice)
 					if !ok {
-						panic(fmt.Sprintf("obj.(cache.DeletedFinalStateUnknown).Obj not a (*v1.Endpoints) %v", obj))
+						panic(fmt.Sprintf("obj.(cache.DeletedFinalStateUnknown).Obj not a (*discoveryv1.EndpointSlice) %v", obj))
 					}
-					server.deleteServiceByName(serviceID(&endpoints.ObjectMeta))
-				case *v1.Service:
-					server.deleteServiceByName(serviceID(&value.ObjectMeta))
+					server.handleEndpointSliceEvent(slice)
+				case *discoveryv1.EndpointSlice:
+					server.handleEndpointSliceEvent(value)
 				default:
-					log.Errorf("unknown type in service deleteFunction %v", obj)
+					log.Errorf("unknown type in endpointSlice deleteFunction %v", obj)
 				}
 			},
-		})
+		},
+		cache.Indexers{serviceNameIndex: endpointSliceServiceIndexFunc},
+	)
 
-	server.endpointStore = endpointStore
+	server.endpointSliceIndexer = endpointSliceIndexer
 	server.serviceStore = serviceStore
 	server.serviceInformer = serviceInformer
-	server.endpointInformer = endpointInformer
+	server.endpointSliceInformer = endpointSliceInformer
 
 	return &server
 }
@@ -290,10 +350,25 @@ func (s *Server) getNodeIP(isv6 bool) net.IP {
 	return net.IP{}
 }
 
+// getNodeZone returns the zone of the node from its labels, or "" when unset
+func (s *Server) getNodeZone() string {
+	if s.nodeBGPSpec == nil {
+		return ""
+	}
+	return s.nodeBGPSpec.Labels[v1.LabelTopologyZone]
+}
+
 func IsLocalOnly(service *v1.Service) bool {
 	return service.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
 }
 
+// IsInternalLocalOnly tells whether the clusterIPs of a service only
+// load-balance to endpoints of this node
+func IsInternalLocalOnly(service *v1.Service) bool {
+	return service.Spec.InternalTrafficPolicy != nil &&
+		*service.Spec.InternalTrafficPolicy == v1.ServiceInternalTrafficPolicyLocal
+}
+
 func serviceID(meta *metav1.ObjectMeta) string {
 	return meta.Namespace + "/" + meta.Name
 }
@@ -325,12 +400,7 @@ func (s *Server) configureSnat() (err error) {
 	return nil
 }
 
-func (s *Server) findMatchingService(ep *v1.Endpoints) *v1.Service {
-	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(ep)
-	if err != nil {
-		s.log.Errorf("Error getting endpoint %+v key: %v", ep, err)
-		return nil
-	}
+func (s *Server) findMatchingService(key string) *v1.Service {
 	value, found, err := s.serviceStore.GetByKey(key)
 	if err != nil {
 		s.log.Errorf("Error getting service %s: %v", key, err)
@@ -347,26 +417,24 @@ func (s *Server) findMatchingService(ep *v1.Endpoints) *v1.Service {
 	return service
 }
 
-func (s *Server) findMatchingEndpoint(service *v1.Service) *v1.Endpoints {
-	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(service)
+// findMatchingEndpointSlices returns the EndpointSlices of a service, sorted
+// by name so that the backends are built in a stable order
+func (s *Server) findMatchingEndpointSlices(key string) []*discoveryv1.EndpointSlice {
+	values, err := s.endpointSliceIndexer.ByIndex(serviceNameIndex, key)
 	if err != nil {
-		s.log.Errorf("Error getting service %+v key: %v", service, err)
+		s.log.Errorf("Error getting endpointSlices of %s: %v", key, err)
 		return nil
 	}
-	value, found, err := s.endpointStore.GetByKey(key)
-	if err != nil {
-		s.log.Errorf("Error getting endpoint %s: %v", key, err)
-		return nil
-	}
-	if !found {
-		s.log.Debugf("Endpoint %s not found", key)
-		return nil
-	}
-	endpoints, ok := value.(*v1.Endpoints)
-	if !ok {
-		panic("s.serviceStore.GetByKey did not return value of type *v1.Service")
+	slices := make([]*discoveryv1.EndpointSlice, 0, len(values))
+	for _, value := range values {
+		slice, ok := value.(*discoveryv1.EndpointSlice)
+		if !ok {
+			panic("s.endpointSliceIndexer.ByIndex did not return value of type *discoveryv1.EndpointSlice")
+		}
+		slices = append(slices, slice)
 	}
-	return endpoints
+	sort.Slice(slices, func(i, j int) bool { return slices[i].Name < slices[j].Name })
+	return slices
 }
 
 /**
@@ -433,10 +501,36 @@ func compareSpecificRoutes(service *LocalService, oldService *LocalService) (add
 	return added, deleted, changed
 }
 
-func (s *Server) handleServiceEndpointEvent(service *LocalService, oldService *LocalService) {
+// compareDropRoutes compares two lists of service.DropRoutes and returns
+// those that should be added and deleted.
+func compareDropRoutes(service *LocalService, oldService *LocalService) (added []net.IP, deleted []net.IP, changed bool) {
+	if service == nil && oldService == nil {
+		changed = false
+	} else if service == nil {
+		changed = len(oldService.DropRoutes) > 0
+		deleted = oldService.DropRoutes
+	} else if oldService == nil {
+		changed = len(service.DropRoutes) > 0
+		added = service.DropRoutes
+	} else {
+		added, deleted, changed = common.CompareIPList(service.DropRoutes, oldService.DropRoutes)
+	}
+	return added, deleted, changed
+}
+
+// handleServiceEndpointEvent programs the new state of a service, diffing it
+// against the one previously programmed. A nil service deletes it.
+func (s *Server) handleServiceEndpointEvent(id string, service *LocalService) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	oldService := s.localServices[id]
+	if service == nil {
+		delete(s.localServices, id)
+	} else {
+		s.localServices[id] = service
+	}
+
 	if added, same, deleted, changed := compareEntryLists(service, oldService); changed {
 		s.deleteServiceEntries(deleted, oldService)
 		s.sameServiceEntries(same, service)
@@ -445,6 +539,35 @@ func (s *Server) handleServiceEndpointEvent(service *LocalService, oldService *L
 	if added, deleted, changed := compareSpecificRoutes(service, oldService); changed {
 		s.advertiseSpecificRoute(added, deleted)
 	}
+	if added, deleted, changed := compareDropRoutes(service, oldService); changed {
+		s.configureDropRoutes(added, deleted)
+	}
+	s.publishDrainingBackends(id, service, oldService)
+}
+
+// publishDrainingBackends sends the draining backends of a service to the
+// prometheus server when they changed
+func (s *Server) publishDrainingBackends(id string, service *LocalService, oldService *LocalService) {
+	var draining, oldDraining DrainingBackends
+	if service != nil {
+		draining = service.Draining
+	}
+	if oldService != nil {
+		oldDraining = oldService.Draining
+	}
+	if draining == oldDraining {
+		return
+	}
+	if draining.Serving > 0 && oldDraining.Serving == 0 {
+		s.log.Infof("svc(drain) %s has no ready endpoint, using %d terminating ones", id, draining.Serving)
+	}
+	if !*config.GetCalicoVppFeatureGates().PrometheusEnabled {
+		return
+	}
+	common.SendEvent(common.CalicoVppEvent{
+		Type: common.ServiceDrainingBackendsUpdated,
+		New:  &ServiceDrainingBackends{ServiceID: id, DrainingBackends: draining},
+	})
 }
 
 func (s *Server) getServiceIPs() ([]*net.IPNet, []*net.IPNet, []*net.IPNet) {
@@ -499,7 +622,7 @@ func (s *Server) ServeService(t *tomb.Tomb) error {
 
 	if *config.GetCalicoVppDebug().ServicesEnabled {
 		s.t.Go(func() error { s.serviceInformer.Run(t.Dying()); return nil })
-		s.t.Go(func() error { s.endpointInformer.Run(t.Dying()); return nil })
+		s.t.Go(func() error { s.endpointSliceInformer.Run(t.Dying()); return nil })
 	}
 
 	<-s.t.Dying()
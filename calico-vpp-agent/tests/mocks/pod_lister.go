@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Cisco and/or its affiliates.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mocks
+
+// PodListerStub is stub implementation of cni.PodLister.
+type PodListerStub struct {
+	Pods map[string]bool
+	// OnList is called when listing the pods, before returning them
+	OnList func()
+}
+
+// ListLocalPods returns the pods of the stub, as namespace/name
+func (s *PodListerStub) ListLocalPods() (map[string]bool, error) {
+	pods := s.Pods
+	if s.OnList != nil {
+		s.OnList()
+	}
+	return pods, nil
+}
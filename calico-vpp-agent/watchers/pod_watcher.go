@@ -0,0 +1,159 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/tomb.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/projectcalico/vpp-dataplane/v3/calico-vpp-agent/common"
+	"github.com/projectcalico/vpp-dataplane/v3/config"
+)
+
+// PodAnnotations is sent with PodAnnotationsChanged events
+type PodAnnotations struct {
+	// WorkloadID is namespace/name, as in the CNI pod specs
+	WorkloadID  string
+	UID         types.UID
+	Annotations map[string]string
+}
+
+// PodWatcher watches the pods running on this node, and sends an event
+// when their annotations change, so that their interfaces can be updated
+type PodWatcher struct {
+	log       *logrus.Entry
+	k8sclient *kubernetes.Clientset
+	informer  cache.Controller
+}
+
+func NewPodWatcher(k8sclient *kubernetes.Clientset, log *logrus.Entry) *PodWatcher {
+	w := &PodWatcher{
+		log:       log,
+		k8sclient: k8sclient,
+	}
+	podListWatch := cache.NewListWatchFromClient(k8sclient.CoreV1().RESTClient(),
+		"pods", "", fields.OneTermEqualSelector("spec.nodeName", *config.NodeName))
+	_, w.informer = cache.NewInformer(
+		podListWatch,
+		&v1.Pod{},
+		60*time.Second,
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old interface{}, obj interface{}) {
+				pod, ok := obj.(*v1.Pod)
+				if !ok {
+					panic("wrong type for obj, not *v1.Pod")
+				}
+				oldPod, ok := old.(*v1.Pod)
+				if !ok {
+					panic("wrong type for old, not *v1.Pod")
+				}
+				w.onPodUpdate(oldPod, pod)
+			},
+		})
+	return w
+}
+
+func (w *PodWatcher) onPodUpdate(old, pod *v1.Pod) {
+	if reflect.DeepEqual(old.Annotations, pod.Annotations) {
+		return
+	}
+	w.log.Debugf("Annotations of pod %s/%s changed", pod.Namespace, pod.Name)
+	common.SendEvent(common.CalicoVppEvent{
+		Type: common.PodAnnotationsChanged,
+		New: &PodAnnotations{
+			WorkloadID:  pod.Namespace + "/" + pod.Name,
+			UID:         pod.UID,
+			Annotations: pod.Annotations,
+		},
+	})
+}
+
+// ListLocalPods returns the pods running on this node as namespace/name. It
+// lists them from the API server rather than the informer cache, so that the
+// result is complete even before the informer is synced.
+func (w *PodWatcher) ListLocalPods() (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pods, err := w.k8sclient.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", *config.NodeName).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing pods")
+	}
+	localPods := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		localPods[pod.Namespace+"/"+pod.Name] = true
+	}
+	return localPods, nil
+}
+
+// RecordPodWarning creates a warning event on a pod of this node, given as
+// namespace/name, so that it shows up when describing the pod
+func (w *PodWatcher) RecordPodWarning(workloadID string, uid types.UID, reason string, message string) error {
+	namespace, name, found := strings.Cut(workloadID, "/")
+	if !found {
+		return errors.Errorf("invalid pod %s", workloadID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	now := metav1.Now()
+	_, err := w.k8sclient.CoreV1().Events(namespace).Create(ctx, &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + ".",
+			Namespace:    namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  namespace,
+			Name:       name,
+			UID:        uid,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           v1.EventTypeWarning,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: v1.EventSource{
+			Component: "calico-vpp-agent",
+			Host:      *config.NodeName,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "error creating event on pod %s", workloadID)
+	}
+	return nil
+}
+
+func (w *PodWatcher) WatchPods(t *tomb.Tomb) error {
+	w.log.Infof("Pod watcher starts")
+	w.informer.Run(t.Dying())
+	w.log.Infof("Pod watcher stopped")
+	return nil
+}
@@ -44,6 +44,11 @@ const (
 	VppManagerInfoFile   = "/var/run/vpp/vppmanagerinfofile"
 	CniServerStateFile   = "/var/run/vpp/calico_vpp_pod_state"
 	CalicoVppPidFile     = "/var/run/vpp/calico_vpp.pid"
+	FlowLogRxPuntSocket  = "/var/run/vpp/flowlog-punt-rx.sock"
+	FlowLogTxPuntSocket  = "/var/run/vpp/flowlog-punt-tx.sock"
+	AgentAPISocket       = "/var/run/vpp/agent-api.sock"
+	VhostUserSocketDir   = "/var/run/vpp/vhost-user"
+	PodCaptureDir        = "/var/run/vpp/pcap"
 	CalicoVppVersionFile = "/etc/calicovppversion"
 
 	DefaultVXLANVni      = 4096
@@ -82,6 +87,8 @@ var (
 	CalicoVppIpsec                   = JsonEnvVar("CALICOVPP_IPSEC", &CalicoVppIpsecConfigType{})
 	CalicoVppSrv6                    = JsonEnvVar("CALICOVPP_SRV6", &CalicoVppSrv6ConfigType{})
 	CalicoVppInitialConfig           = JsonEnvVar("CALICOVPP_INITIAL_CONFIG", &CalicoVppInitialConfigConfigType{})
+	CalicoVppFlowLogs                = JsonEnvVar("CALICOVPP_FLOW_LOGS", &CalicoVppFlowLogsConfigType{})
+	CalicoVppPolicyDrift             = JsonEnvVar("CALICOVPP_POLICY_DRIFT", &CalicoVppPolicyDriftConfigType{})
 	CalicoVppGracefulShutdownTimeout = EnvVar("CALICOVPP_GRACEFUL_SHUTDOWN_TIMEOUT", 10*time.Second, time.ParseDuration)
 	LogFormat                        = StringEnvVar("CALICOVPP_LOG_FORMAT", "")
 
@@ -167,6 +174,8 @@ func GetCalicoVppFeatureGates() *CalicoVppFeatureGatesConfigType   { return *Cal
 func GetCalicoVppIpsec() *CalicoVppIpsecConfigType                 { return *CalicoVppIpsec }
 func GetCalicoVppSrv6() *CalicoVppSrv6ConfigType                   { return *CalicoVppSrv6 }
 func GetCalicoVppInitialConfig() *CalicoVppInitialConfigConfigType { return *CalicoVppInitialConfig }
+func GetCalicoVppFlowLogs() *CalicoVppFlowLogsConfigType           { return *CalicoVppFlowLogs }
+func GetCalicoVppPolicyDrift() *CalicoVppPolicyDriftConfigType     { return *CalicoVppPolicyDrift }
 
 type InterfaceSpec struct {
 	NumRxQueues int   `json:"rx"`
@@ -308,19 +317,25 @@ func (self *CalicoVppDebugConfigType) Validate() (err error) {
 type CalicoVppFeatureGatesConfigType struct {
 	MemifEnabled      *bool `json:"memifEnabled,omitempty"`
 	VCLEnabled        *bool `json:"vclEnabled,omitempty"`
+	VhostUserEnabled  *bool `json:"vhostUserEnabled,omitempty"`
 	MultinetEnabled   *bool `json:"multinetEnabled,omitempty"`
 	SRv6Enabled       *bool `json:"srv6Enabled,omitempty"`
 	IPSecEnabled      *bool `json:"ipsecEnabled,omitempty"`
 	PrometheusEnabled *bool `json:"prometheusEnabled,omitempty"`
+	// PolicyAuditEnabled puts all the network policies in audit mode: the
+	// packets matching their rules are counted, but their action is not enforced
+	PolicyAuditEnabled *bool `json:"policyAuditEnabled,omitempty"`
 }
 
 func (self *CalicoVppFeatureGatesConfigType) Validate() (err error) {
 	self.MemifEnabled = DefaultToPtr(self.MemifEnabled, true)
 	self.VCLEnabled = DefaultToPtr(self.VCLEnabled, false)
+	self.VhostUserEnabled = DefaultToPtr(self.VhostUserEnabled, false)
 	self.MultinetEnabled = DefaultToPtr(self.MultinetEnabled, false)
 	self.SRv6Enabled = DefaultToPtr(self.SRv6Enabled, false)
 	self.IPSecEnabled = DefaultToPtr(self.IPSecEnabled, false)
 	self.PrometheusEnabled = DefaultToPtr(self.PrometheusEnabled, false)
+	self.PolicyAuditEnabled = DefaultToPtr(self.PolicyAuditEnabled, false)
 	return nil
 }
 
@@ -329,6 +344,70 @@ func (self *CalicoVppFeatureGatesConfigType) String() string {
 	return string(b)
 }
 
+// CalicoVppFlowLogsConfigType configures the records emitted for packets
+// matching policy rules with a log action
+type CalicoVppFlowLogsConfigType struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Destination is either a file path, or unix:///path/to/socket
+	// for a unix stream socket
+	Destination string `json:"destination,omitempty"`
+	// RateLimit is the maximum number of records emitted per second
+	RateLimit int `json:"rateLimit,omitempty"`
+	// Burst is the number of records that can be emitted at once above the rate limit
+	Burst int `json:"burst,omitempty"`
+}
+
+func (self *CalicoVppFlowLogsConfigType) Validate() (err error) {
+	self.Enabled = DefaultToPtr(self.Enabled, false)
+	if self.Destination == "" {
+		self.Destination = "/var/log/calico/vpp/flows.log"
+	}
+	if self.RateLimit < 0 || self.Burst < 0 {
+		return errors.Errorf("invalid flow logs rate limit %d burst %d", self.RateLimit, self.Burst)
+	}
+	if self.RateLimit == 0 {
+		self.RateLimit = 100
+	}
+	if self.Burst == 0 {
+		self.Burst = self.RateLimit
+	}
+	return nil
+}
+
+func (self *CalicoVppFlowLogsConfigType) String() string {
+	b, _ := json.MarshalIndent(self, "", "  ")
+	return string(b)
+}
+
+// CalicoVppPolicyDriftConfigType configures the periodic comparison of the
+// policies configured in VPP with the policy server state
+type CalicoVppPolicyDriftConfigType struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// Interval is the interval at which the policies configured in VPP
+	// are compared with the policy server state. Default to 5 minutes
+	Interval *time.Duration `json:"interval,omitempty"`
+	// Repair makes the policy server fix the drift it detects
+	Repair *bool `json:"repair,omitempty"`
+}
+
+func (self *CalicoVppPolicyDriftConfigType) Validate() (err error) {
+	self.Enabled = DefaultToPtr(self.Enabled, false)
+	self.Repair = DefaultToPtr(self.Repair, false)
+	if self.Interval == nil {
+		interval := 5 * time.Minute
+		self.Interval = &interval
+	}
+	if *self.Interval <= 0 {
+		return errors.Errorf("invalid policy drift interval %s", *self.Interval)
+	}
+	return nil
+}
+
+func (self *CalicoVppPolicyDriftConfigType) String() string {
+	b, _ := json.MarshalIndent(self, "", "  ")
+	return string(b)
+}
+
 type CalicoVppSrv6ConfigType struct {
 	LocalsidPool string `json:"localsidPool"`
 	PolicyPool   string `json:"policyPool"`
@@ -429,6 +508,11 @@ type CalicoVppInitialConfigConfigType struct { //out of agent and vppmanager
 	// PrometheusRecordMetricInterval is the interval at which we update the
 	// prometheus stats polling VPP stats segment. Default to 5 seconds
 	PrometheusRecordMetricInterval *time.Duration `json:"prometheusRecordMetricInterval"`
+	// PodGCInterval is the interval at which the pods of the CNI state that
+	// don't exist anymore are deleted, along with the orphan pod interfaces
+	// of VPP. This is also done on startup. Default to 5 minutes, 0 disables
+	// the periodic run
+	PodGCInterval *time.Duration `json:"podGCInterval"`
 }
 
 func (self *CalicoVppInitialConfigConfigType) Validate() (err error) {
@@ -439,6 +523,10 @@ func (self *CalicoVppInitialConfigConfigType) Validate() (err error) {
 		prometheusRecordMetricInterval := 5 * time.Second
 		self.PrometheusRecordMetricInterval = &prometheusRecordMetricInterval
 	}
+	if self.PodGCInterval == nil {
+		podGCInterval := 5 * time.Minute
+		self.PodGCInterval = &podGCInterval
+	}
 	return nil
 }
 func (self *CalicoVppInitialConfigConfigType) GetDefaultGWs() (gws []net.IP, err error) {
@@ -549,6 +637,8 @@ type VppManagerInfo struct {
 	Status         vppManagerStatus
 	UplinkStatuses map[string]UplinkStatus
 	PhysicalNets   map[string]PhysicalNetwork
+	// VppPid is the PID of VPP, in the host PID namespace
+	VppPid int
 }
 
 func (i *VppManagerInfo) GetMainSwIfIndex() uint32 {
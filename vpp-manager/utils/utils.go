@@ -205,6 +205,7 @@ func ClearVppManagerFiles() error {
 	config.Info.Status = config.Starting
 	config.Info.UplinkStatuses = make(map[string]config.UplinkStatus, 0)
 	config.Info.PhysicalNets = make(map[string]config.PhysicalNetwork, 0)
+	config.Info.VppPid = 0
 	return WriteInfoFile()
 }
 
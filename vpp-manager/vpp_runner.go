@@ -902,6 +902,7 @@ func (v *VppRunner) runVpp() (err error) {
 	}
 
 	config.Info.Status = config.Ready
+	config.Info.VppPid = vppProcess.Pid
 	err = utils.WriteInfoFile()
 	if err != nil {
 		log.Errorf("Error writing vpp manager file: %v", err)
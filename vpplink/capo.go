@@ -17,8 +17,11 @@ package vpplink
 
 import (
 	"fmt"
+	"io"
 	"net"
 
+	"go.fd.io/govpp/api"
+
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
 )
@@ -151,6 +154,53 @@ func (v *VppLink) RuleDelete(ruleId uint32) error {
 	return nil
 }
 
+// RulesCreate creates the rules with pipelined requests, and returns their IDs in
+// the same order. On error, the IDs of the rules that could not be created are
+// types.InvalidID.
+func (v *VppLink) RulesCreate(rules []*types.Rule) (ruleIDs []uint32, err error) {
+	requests := make([]api.Message, 0, len(rules))
+	ruleIDs = make([]uint32, 0, len(rules))
+	for _, rule := range rules {
+		requests = append(requests, &capo.CapoRuleCreate{Rule: types.ToCapoRule(rule)})
+		ruleIDs = append(ruleIDs, types.InvalidID)
+	}
+	err = pipelineRequests(v.GetContext(), v.GetConnection(), requests, func(i int, msg api.Message) error {
+		reply, ok := msg.(*capo.CapoRuleCreateReply)
+		if !ok {
+			return fmt.Errorf("unexpected reply %s", msg.GetMessageName())
+		}
+		err := api.RetvalToVPPApiError(reply.Retval)
+		if err != nil {
+			return err
+		}
+		ruleIDs[i] = reply.RuleID
+		return nil
+	})
+	if err != nil {
+		return ruleIDs, fmt.Errorf("CapoRuleCreate failed: %w", err)
+	}
+	return ruleIDs, nil
+}
+
+// RulesDelete deletes the rules with pipelined requests
+func (v *VppLink) RulesDelete(ruleIDs []uint32) error {
+	requests := make([]api.Message, 0, len(ruleIDs))
+	for _, ruleID := range ruleIDs {
+		requests = append(requests, &capo.CapoRuleDelete{RuleID: ruleID})
+	}
+	err := pipelineRequests(v.GetContext(), v.GetConnection(), requests, func(i int, msg api.Message) error {
+		reply, ok := msg.(*capo.CapoRuleDeleteReply)
+		if !ok {
+			return fmt.Errorf("unexpected reply %s", msg.GetMessageName())
+		}
+		return api.RetvalToVPPApiError(reply.Retval)
+	})
+	if err != nil {
+		return fmt.Errorf("CapoRuleDelete failed: %w", err)
+	}
+	return nil
+}
+
 func (v *VppLink) PolicyCreate(policy *types.Policy) (policyId uint32, err error) {
 	client := capo.NewServiceClient(v.GetConnection())
 
@@ -188,6 +238,72 @@ func (v *VppLink) PolicyDelete(policyId uint32) error {
 	return nil
 }
 
+// PolicySetAudit puts the policy in audit mode, where its matching rules are
+// counted but never decide, or back in enforce mode
+func (v *VppLink) PolicySetAudit(policyId uint32, audit bool) error {
+	client := capo.NewServiceClient(v.GetConnection())
+
+	mode := capo.CAPO_POLICY_ENFORCE
+	if audit {
+		mode = capo.CAPO_POLICY_AUDIT
+	}
+	_, err := client.CapoPolicySetMode(v.GetContext(), &capo.CapoPolicySetMode{
+		PolicyID: policyId,
+		Mode:     mode,
+	})
+	if err != nil {
+		return fmt.Errorf("CapoPolicySetMode failed: %w", err)
+	}
+	return nil
+}
+
+// PoliciesCreate creates the policies with pipelined requests, and returns their
+// IDs in the same order. On error, the IDs of the policies that could not be
+// created are types.InvalidID.
+func (v *VppLink) PoliciesCreate(policies []*types.Policy) (policyIDs []uint32, err error) {
+	requests := make([]api.Message, 0, len(policies))
+	policyIDs = make([]uint32, 0, len(policies))
+	for _, policy := range policies {
+		requests = append(requests, &capo.CapoPolicyCreate{Rules: types.ToCapoPolicy(policy)})
+		policyIDs = append(policyIDs, types.InvalidID)
+	}
+	err = pipelineRequests(v.GetContext(), v.GetConnection(), requests, func(i int, msg api.Message) error {
+		reply, ok := msg.(*capo.CapoPolicyCreateReply)
+		if !ok {
+			return fmt.Errorf("unexpected reply %s", msg.GetMessageName())
+		}
+		err := api.RetvalToVPPApiError(reply.Retval)
+		if err != nil {
+			return err
+		}
+		policyIDs[i] = reply.PolicyID
+		return nil
+	})
+	if err != nil {
+		return policyIDs, fmt.Errorf("CapoPolicyCreate failed: %w", err)
+	}
+	return policyIDs, nil
+}
+
+// PoliciesDelete deletes the policies with pipelined requests
+func (v *VppLink) PoliciesDelete(policyIDs []uint32) error {
+	requests := make([]api.Message, 0, len(policyIDs))
+	for _, policyID := range policyIDs {
+		requests = append(requests, &capo.CapoPolicyDelete{PolicyID: policyID})
+	}
+	err := pipelineRequests(v.GetContext(), v.GetConnection(), requests, func(i int, msg api.Message) error {
+		reply, ok := msg.(*capo.CapoPolicyDeleteReply)
+		if !ok {
+			return fmt.Errorf("unexpected reply %s", msg.GetMessageName())
+		}
+		return api.RetvalToVPPApiError(reply.Retval)
+	})
+	if err != nil {
+		return fmt.Errorf("CapoPolicyDelete failed: %w", err)
+	}
+	return nil
+}
+
 func (v *VppLink) ConfigurePolicies(swIfIndex uint32, conf *types.InterfaceConfig, invertRxTx uint8) error {
 	client := capo.NewServiceClient(v.GetConnection())
 
@@ -212,3 +328,202 @@ func (v *VppLink) ConfigurePolicies(swIfIndex uint32, conf *types.InterfaceConfi
 	}
 	return nil
 }
+
+// ConfigureStages sets the policies evaluated on an interface before cnat and
+// the acl-plugin sessions. An empty config removes the stages.
+func (v *VppLink) ConfigureStages(swIfIndex uint32, conf *types.StagesConfig, invertRxTx uint8) error {
+	client := capo.NewServiceClient(v.GetConnection())
+
+	// As in ConfigurePolicies, rx and tx are reversed in VPP
+	rxPolicyIDs := conf.UntrackedEgressPolicyIDs
+	txPolicyIDs := conf.UntrackedIngressPolicyIDs
+
+	// preDNAT policies only apply to received packets, they come last
+	ids := append(append([]uint32{}, rxPolicyIDs...), txPolicyIDs...)
+	ids = append(ids, conf.PreDnatPolicyIDs...)
+	_, err := client.CapoConfigureStages(v.GetContext(), &capo.CapoConfigureStages{
+		SwIfIndex:              swIfIndex,
+		NumUntrackedRxPolicies: uint32(len(rxPolicyIDs)),
+		NumUntrackedTxPolicies: uint32(len(txPolicyIDs)),
+		TotalIds:               uint32(len(ids)),
+		PolicyIds:              ids,
+		InvertRxTx:             invertRxTx,
+	})
+	if err != nil {
+		return fmt.Errorf("CapoConfigureStages failed: %w", err)
+	}
+	return nil
+}
+
+func (v *VppLink) capoIPSetsDump(client capo.RPCService) (map[uint32]*types.CapoIPSetDump, error) {
+	stream, err := client.CapoIpsetsDump(v.GetContext(), &capo.CapoIpsetsDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump capo ipsets: %w", err)
+	}
+	ipsets := make(map[uint32]*types.CapoIPSetDump)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump capo ipsets: %w", err)
+		}
+		ipset := &types.CapoIPSetDump{
+			ID:      response.SetID,
+			Type:    types.IpsetType(response.Type),
+			Members: make(map[string]bool),
+		}
+		for i := range response.Members {
+			ipset.Members[types.FormatCapoIPSetMember(ipset.Type, &response.Members[i])] = true
+		}
+		ipsets[ipset.ID] = ipset
+	}
+	return ipsets, nil
+}
+
+func (v *VppLink) capoRulesDump(client capo.RPCService) (map[uint32]*types.CapoRuleDump, error) {
+	stream, err := client.CapoRulesDump(v.GetContext(), &capo.CapoRulesDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump capo rules: %w", err)
+	}
+	rules := make(map[uint32]*types.CapoRuleDump)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump capo rules: %w", err)
+		}
+		rules[response.RuleID] = &types.CapoRuleDump{
+			ID:   response.RuleID,
+			Rule: types.FromCapoRule(&response.Rule),
+		}
+	}
+	return rules, nil
+}
+
+func (v *VppLink) capoPoliciesDump(client capo.RPCService) (map[uint32]*types.CapoPolicyDump, error) {
+	stream, err := client.CapoPoliciesDump(v.GetContext(), &capo.CapoPoliciesDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump capo policies: %w", err)
+	}
+	policies := make(map[uint32]*types.CapoPolicyDump)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump capo policies: %w", err)
+		}
+		policy := &types.CapoPolicyDump{
+			ID:    response.PolicyID,
+			Audit: response.Mode == capo.CAPO_POLICY_AUDIT,
+		}
+		for _, item := range response.Rules {
+			if item.IsInbound {
+				policy.RxRuleIDs = append(policy.RxRuleIDs, item.RuleID)
+			} else {
+				policy.TxRuleIDs = append(policy.TxRuleIDs, item.RuleID)
+			}
+		}
+		policies[policy.ID] = policy
+	}
+	return policies, nil
+}
+
+// splitPolicyIDs splits the policy IDs of an interface in the first n1, the
+// next n2 and the remaining ones
+func splitPolicyIDs(ids []uint32, n1 uint32, n2 uint32) ([]uint32, []uint32, []uint32, error) {
+	if uint64(n1)+uint64(n2) > uint64(len(ids)) {
+		return nil, nil, nil, fmt.Errorf("%d+%d policies out of %d", n1, n2, len(ids))
+	}
+	return ids[:n1], ids[n1 : n1+n2], ids[n1+n2:], nil
+}
+
+func (v *VppLink) capoInterfacesDump(client capo.RPCService) (map[uint32]*types.CapoInterfaceDump, error) {
+	stream, err := client.CapoInterfacesDump(v.GetContext(), &capo.CapoInterfacesDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump capo interfaces: %w", err)
+	}
+	interfaces := make(map[uint32]*types.CapoInterfaceDump)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump capo interfaces: %w", err)
+		}
+		intf := &types.CapoInterfaceDump{
+			SwIfIndex:  response.SwIfIndex,
+			InvertRxTx: response.InvertRxTx != 0,
+		}
+		intf.RxPolicyIDs, intf.TxPolicyIDs, intf.ProfileIDs, err = splitPolicyIDs(
+			response.PolicyIds, response.NumRxPolicies, response.NumTxPolicies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capo interface %d: %w", response.SwIfIndex, err)
+		}
+		interfaces[intf.SwIfIndex] = intf
+	}
+	return interfaces, nil
+}
+
+func (v *VppLink) capoStagesDump(client capo.RPCService) (map[uint32]*types.CapoStagesDump, error) {
+	stream, err := client.CapoStagesDump(v.GetContext(), &capo.CapoStagesDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump capo stages: %w", err)
+	}
+	stages := make(map[uint32]*types.CapoStagesDump)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump capo stages: %w", err)
+		}
+		stage := &types.CapoStagesDump{
+			SwIfIndex:  response.SwIfIndex,
+			InvertRxTx: response.InvertRxTx != 0,
+		}
+		stage.UntrackedRxPolicyIDs, stage.UntrackedTxPolicyIDs, stage.PreDnatPolicyIDs, err = splitPolicyIDs(
+			response.PolicyIds, response.NumUntrackedRxPolicies, response.NumUntrackedTxPolicies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capo stages on interface %d: %w", response.SwIfIndex, err)
+		}
+		stages[stage.SwIfIndex] = stage
+	}
+	return stages, nil
+}
+
+// CapoDump returns the ipsets, rules, policies, and the policies and stages
+// of the interfaces configured in capo
+func (v *VppLink) CapoDump() (dump *types.CapoDump, err error) {
+	client := capo.NewServiceClient(v.GetConnection())
+
+	dump = &types.CapoDump{}
+	dump.IPSets, err = v.capoIPSetsDump(client)
+	if err != nil {
+		return nil, err
+	}
+	dump.Rules, err = v.capoRulesDump(client)
+	if err != nil {
+		return nil, err
+	}
+	dump.Policies, err = v.capoPoliciesDump(client)
+	if err != nil {
+		return nil, err
+	}
+	dump.Interfaces, err = v.capoInterfacesDump(client)
+	if err != nil {
+		return nil, err
+	}
+	dump.Stages, err = v.capoStagesDump(client)
+	if err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
@@ -17,6 +17,7 @@ package vpplink
 
 import (
 	"fmt"
+	"io"
 	"net"
 
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/cnat"
@@ -60,13 +61,15 @@ func (v *VppLink) CnatTranslateAdd(tr *types.CnatTranslateEntry) (uint32, error)
 
 	response, err := client.CnatTranslationUpdate(v.GetContext(), &cnat.CnatTranslationUpdate{
 		Translation: cnat.CnatTranslation{
-			Vip:            types.ToCnatEndpoint(tr.Endpoint),
-			IPProto:        types.ToVppIPProto(tr.Proto),
-			Paths:          paths,
-			IsRealIP:       BoolToU8(tr.IsRealIP),
-			Flags:          uint8(cnat.CNAT_TRANSLATION_ALLOC_PORT),
-			LbType:         cnat.CnatLbType(tr.LbType),
-			FlowHashConfig: ip.IPFlowHashConfigV2(tr.HashConfig),
+			Vip:             types.ToCnatEndpoint(tr.Endpoint),
+			IPProto:         types.ToVppIPProto(tr.Proto),
+			Paths:           paths,
+			IsRealIP:        BoolToU8(tr.IsRealIP),
+			Flags:           uint8(cnat.CNAT_TRANSLATION_ALLOC_PORT),
+			LbType:          cnat.CnatLbType(tr.LbType),
+			FlowHashConfig:  ip.IPFlowHashConfigV2(tr.HashConfig),
+			Affinity:        cnat.CnatAffinity(tr.Affinity),
+			AffinityTimeout: tr.AffinityTimeout,
 		},
 	})
 	if err != nil {
@@ -90,6 +93,46 @@ func (v *VppLink) CnatTranslateDel(id uint32) error {
 	return nil
 }
 
+// CnatTranslateDump returns the cnat translations, indexed by their ID
+func (v *VppLink) CnatTranslateDump() (map[uint32]*types.CnatTranslateEntry, error) {
+	client := cnat.NewServiceClient(v.GetConnection())
+
+	stream, err := client.CnatTranslationDump(v.GetContext(), &cnat.CnatTranslationDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump cnat translations: %w", err)
+	}
+	entries := make(map[uint32]*types.CnatTranslateEntry)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump cnat translations: %w", err)
+		}
+		tr := response.Translation
+		backends := make([]types.CnatEndpointTuple, 0, len(tr.Paths))
+		for _, path := range tr.Paths {
+			backends = append(backends, types.CnatEndpointTuple{
+				SrcEndpoint: types.FromCnatEndpoint(path.SrcEp),
+				DstEndpoint: types.FromCnatEndpoint(path.DstEp),
+				Flags:       path.Flags,
+			})
+		}
+		entries[tr.ID] = &types.CnatTranslateEntry{
+			Endpoint:        types.FromCnatEndpoint(tr.Vip),
+			Backends:        backends,
+			Proto:           types.IPProto(tr.IPProto),
+			IsRealIP:        tr.IsRealIP != 0,
+			LbType:          types.CnatLbType(tr.LbType),
+			HashConfig:      types.IPFlowHash(tr.FlowHashConfig),
+			Affinity:        types.CnatAffinity(tr.Affinity),
+			AffinityTimeout: tr.AffinityTimeout,
+		}
+	}
+	return entries, nil
+}
+
 func (v *VppLink) CnatSetSnatAddresses(v4, v6 net.IP) error {
 	client := cnat.NewServiceClient(v.GetConnection())
 
@@ -3,10 +3,10 @@
 // Package capo contains generated bindings for API file capo.api.
 //
 // Contents:
-// -  4 enums
+// -  5 enums
 // -  8 structs
 // -  2 unions
-// - 24 messages
+// - 38 messages
 package capo
 
 import (
@@ -93,6 +93,33 @@ func (x CapoIpsetType) String() string {
 	return "CapoIpsetType(" + strconv.Itoa(int(x)) + ")"
 }
 
+// CapoPolicyMode defines enum 'capo_policy_mode'.
+type CapoPolicyMode uint8
+
+const (
+	CAPO_POLICY_ENFORCE CapoPolicyMode = 0
+	CAPO_POLICY_AUDIT   CapoPolicyMode = 1
+)
+
+var (
+	CapoPolicyMode_name = map[uint8]string{
+		0: "CAPO_POLICY_ENFORCE",
+		1: "CAPO_POLICY_AUDIT",
+	}
+	CapoPolicyMode_value = map[string]uint8{
+		"CAPO_POLICY_ENFORCE": 0,
+		"CAPO_POLICY_AUDIT":   1,
+	}
+)
+
+func (x CapoPolicyMode) String() string {
+	s, ok := CapoPolicyMode_name[uint8(x)]
+	if ok {
+		return s
+	}
+	return "CapoPolicyMode(" + strconv.Itoa(int(x)) + ")"
+}
+
 // CapoRuleAction defines enum 'capo_rule_action'.
 type CapoRuleAction uint8
 
@@ -427,6 +454,101 @@ func (m *CapoConfigurePoliciesReply) Unmarshal(b []byte) error {
 	return nil
 }
 
+// CapoConfigureStages defines message 'capo_configure_stages'.
+type CapoConfigureStages struct {
+	SwIfIndex              uint32   `binapi:"u32,name=sw_if_index" json:"sw_if_index,omitempty"`
+	NumUntrackedRxPolicies uint32   `binapi:"u32,name=num_untracked_rx_policies" json:"num_untracked_rx_policies,omitempty"`
+	NumUntrackedTxPolicies uint32   `binapi:"u32,name=num_untracked_tx_policies" json:"num_untracked_tx_policies,omitempty"`
+	TotalIds               uint32   `binapi:"u32,name=total_ids" json:"-"`
+	InvertRxTx             uint8    `binapi:"u8,name=invert_rx_tx" json:"invert_rx_tx,omitempty"`
+	PolicyIds              []uint32 `binapi:"u32[total_ids],name=policy_ids" json:"policy_ids,omitempty"`
+}
+
+func (m *CapoConfigureStages) Reset()               { *m = CapoConfigureStages{} }
+func (*CapoConfigureStages) GetMessageName() string { return "capo_configure_stages" }
+func (*CapoConfigureStages) GetCrcString() string   { return "13871798" }
+func (*CapoConfigureStages) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoConfigureStages) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4                    // m.SwIfIndex
+	size += 4                    // m.NumUntrackedRxPolicies
+	size += 4                    // m.NumUntrackedTxPolicies
+	size += 4                    // m.TotalIds
+	size += 1                    // m.InvertRxTx
+	size += 4 * len(m.PolicyIds) // m.PolicyIds
+	return size
+}
+func (m *CapoConfigureStages) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.SwIfIndex)
+	buf.EncodeUint32(m.NumUntrackedRxPolicies)
+	buf.EncodeUint32(m.NumUntrackedTxPolicies)
+	buf.EncodeUint32(uint32(len(m.PolicyIds)))
+	buf.EncodeUint8(m.InvertRxTx)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		var x uint32
+		if i < len(m.PolicyIds) {
+			x = uint32(m.PolicyIds[i])
+		}
+		buf.EncodeUint32(x)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoConfigureStages) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.SwIfIndex = buf.DecodeUint32()
+	m.NumUntrackedRxPolicies = buf.DecodeUint32()
+	m.NumUntrackedTxPolicies = buf.DecodeUint32()
+	m.TotalIds = buf.DecodeUint32()
+	m.InvertRxTx = buf.DecodeUint8()
+	m.PolicyIds = make([]uint32, m.TotalIds)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		m.PolicyIds[i] = buf.DecodeUint32()
+	}
+	return nil
+}
+
+// CapoConfigureStagesReply defines message 'capo_configure_stages_reply'.
+type CapoConfigureStagesReply struct {
+	Retval int32 `binapi:"i32,name=retval" json:"retval,omitempty"`
+}
+
+func (m *CapoConfigureStagesReply) Reset()               { *m = CapoConfigureStagesReply{} }
+func (*CapoConfigureStagesReply) GetMessageName() string { return "capo_configure_stages_reply" }
+func (*CapoConfigureStagesReply) GetCrcString() string   { return "e8d4e804" }
+func (*CapoConfigureStagesReply) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoConfigureStagesReply) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.Retval
+	return size
+}
+func (m *CapoConfigureStagesReply) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeInt32(m.Retval)
+	return buf.Bytes(), nil
+}
+func (m *CapoConfigureStagesReply) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.Retval = buf.DecodeInt32()
+	return nil
+}
+
 // Control ping from client to api server request
 // CapoControlPing defines message 'capo_control_ping'.
 type CapoControlPing struct{}
@@ -569,6 +691,99 @@ func (m *CapoGetVersionReply) Unmarshal(b []byte) error {
 	return nil
 }
 
+// Policies of an interface, as passed to capo_configure_policies.
+// The ids of deleted policies are kept.
+//
+// CapoInterfacesDetails defines message 'capo_interfaces_details'.
+type CapoInterfacesDetails struct {
+	SwIfIndex     uint32   `binapi:"u32,name=sw_if_index" json:"sw_if_index,omitempty"`
+	NumRxPolicies uint32   `binapi:"u32,name=num_rx_policies" json:"num_rx_policies,omitempty"`
+	NumTxPolicies uint32   `binapi:"u32,name=num_tx_policies" json:"num_tx_policies,omitempty"`
+	TotalIds      uint32   `binapi:"u32,name=total_ids" json:"-"`
+	InvertRxTx    uint8    `binapi:"u8,name=invert_rx_tx" json:"invert_rx_tx,omitempty"`
+	PolicyIds     []uint32 `binapi:"u32[total_ids],name=policy_ids" json:"policy_ids,omitempty"`
+}
+
+func (m *CapoInterfacesDetails) Reset()               { *m = CapoInterfacesDetails{} }
+func (*CapoInterfacesDetails) GetMessageName() string { return "capo_interfaces_details" }
+func (*CapoInterfacesDetails) GetCrcString() string   { return "d590d8bb" }
+func (*CapoInterfacesDetails) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoInterfacesDetails) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4                    // m.SwIfIndex
+	size += 4                    // m.NumRxPolicies
+	size += 4                    // m.NumTxPolicies
+	size += 4                    // m.TotalIds
+	size += 1                    // m.InvertRxTx
+	size += 4 * len(m.PolicyIds) // m.PolicyIds
+	return size
+}
+func (m *CapoInterfacesDetails) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.SwIfIndex)
+	buf.EncodeUint32(m.NumRxPolicies)
+	buf.EncodeUint32(m.NumTxPolicies)
+	buf.EncodeUint32(uint32(len(m.PolicyIds)))
+	buf.EncodeUint8(m.InvertRxTx)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		var x uint32
+		if i < len(m.PolicyIds) {
+			x = uint32(m.PolicyIds[i])
+		}
+		buf.EncodeUint32(x)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoInterfacesDetails) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.SwIfIndex = buf.DecodeUint32()
+	m.NumRxPolicies = buf.DecodeUint32()
+	m.NumTxPolicies = buf.DecodeUint32()
+	m.TotalIds = buf.DecodeUint32()
+	m.InvertRxTx = buf.DecodeUint8()
+	m.PolicyIds = make([]uint32, m.TotalIds)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		m.PolicyIds[i] = buf.DecodeUint32()
+	}
+	return nil
+}
+
+// Dump the policies configured on the interfaces
+// CapoInterfacesDump defines message 'capo_interfaces_dump'.
+type CapoInterfacesDump struct{}
+
+func (m *CapoInterfacesDump) Reset()               { *m = CapoInterfacesDump{} }
+func (*CapoInterfacesDump) GetMessageName() string { return "capo_interfaces_dump" }
+func (*CapoInterfacesDump) GetCrcString() string   { return "51077d14" }
+func (*CapoInterfacesDump) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoInterfacesDump) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	return size
+}
+func (m *CapoInterfacesDump) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	return buf.Bytes(), nil
+}
+func (m *CapoInterfacesDump) Unmarshal(b []byte) error {
+	return nil
+}
+
 // CapoIpsetAddDelMembers defines message 'capo_ipset_add_del_members'.
 type CapoIpsetAddDelMembers struct {
 	SetID   uint32            `binapi:"u32,name=set_id" json:"set_id,omitempty"`
@@ -801,6 +1016,199 @@ func (m *CapoIpsetDeleteReply) Unmarshal(b []byte) error {
 	return nil
 }
 
+// Details of an ipset
+//   - set_id - id of the ipset
+//   - type - type of the ipset members
+//   - len - number of members
+//   - members - members of the ipset
+//
+// CapoIpsetsDetails defines message 'capo_ipsets_details'.
+type CapoIpsetsDetails struct {
+	SetID   uint32            `binapi:"u32,name=set_id" json:"set_id,omitempty"`
+	Type    CapoIpsetType     `binapi:"capo_ipset_type,name=type" json:"type,omitempty"`
+	Len     uint32            `binapi:"u32,name=len" json:"-"`
+	Members []CapoIpsetMember `binapi:"capo_ipset_member[len],name=members" json:"members,omitempty"`
+}
+
+func (m *CapoIpsetsDetails) Reset()               { *m = CapoIpsetsDetails{} }
+func (*CapoIpsetsDetails) GetMessageName() string { return "capo_ipsets_details" }
+func (*CapoIpsetsDetails) GetCrcString() string   { return "6d7260ce" }
+func (*CapoIpsetsDetails) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoIpsetsDetails) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.SetID
+	size += 1 // m.Type
+	size += 4 // m.Len
+	for j1 := 0; j1 < len(m.Members); j1++ {
+		var s1 CapoIpsetMember
+		_ = s1
+		if j1 < len(m.Members) {
+			s1 = m.Members[j1]
+		}
+		size += 1 * 20 // s1.Val
+	}
+	return size
+}
+func (m *CapoIpsetsDetails) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.SetID)
+	buf.EncodeUint8(uint8(m.Type))
+	buf.EncodeUint32(uint32(len(m.Members)))
+	for j0 := 0; j0 < len(m.Members); j0++ {
+		var v0 CapoIpsetMember // Members
+		if j0 < len(m.Members) {
+			v0 = m.Members[j0]
+		}
+		buf.EncodeBytes(v0.Val.XXX_UnionData[:], 20)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoIpsetsDetails) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.SetID = buf.DecodeUint32()
+	m.Type = CapoIpsetType(buf.DecodeUint8())
+	m.Len = buf.DecodeUint32()
+	m.Members = make([]CapoIpsetMember, m.Len)
+	for j0 := 0; j0 < len(m.Members); j0++ {
+		copy(m.Members[j0].Val.XXX_UnionData[:], buf.DecodeBytes(20))
+	}
+	return nil
+}
+
+// Dump the ipsets
+// CapoIpsetsDump defines message 'capo_ipsets_dump'.
+type CapoIpsetsDump struct{}
+
+func (m *CapoIpsetsDump) Reset()               { *m = CapoIpsetsDump{} }
+func (*CapoIpsetsDump) GetMessageName() string { return "capo_ipsets_dump" }
+func (*CapoIpsetsDump) GetCrcString() string   { return "51077d14" }
+func (*CapoIpsetsDump) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoIpsetsDump) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	return size
+}
+func (m *CapoIpsetsDump) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	return buf.Bytes(), nil
+}
+func (m *CapoIpsetsDump) Unmarshal(b []byte) error {
+	return nil
+}
+
+// Details of a policy. The ids of deleted rules are kept.
+//   - policy_id - id of the policy
+//   - mode - enforce or audit
+//   - num_items - number of rules
+//   - rules - inbound rules, then outbound rules
+//
+// CapoPoliciesDetails defines message 'capo_policies_details'.
+type CapoPoliciesDetails struct {
+	PolicyID uint32           `binapi:"u32,name=policy_id" json:"policy_id,omitempty"`
+	Mode     CapoPolicyMode   `binapi:"capo_policy_mode,name=mode" json:"mode,omitempty"`
+	NumItems uint32           `binapi:"u32,name=num_items" json:"-"`
+	Rules    []CapoPolicyItem `binapi:"capo_policy_item[num_items],name=rules" json:"rules,omitempty"`
+}
+
+func (m *CapoPoliciesDetails) Reset()               { *m = CapoPoliciesDetails{} }
+func (*CapoPoliciesDetails) GetMessageName() string { return "capo_policies_details" }
+func (*CapoPoliciesDetails) GetCrcString() string   { return "766e65a8" }
+func (*CapoPoliciesDetails) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoPoliciesDetails) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.PolicyID
+	size += 1 // m.Mode
+	size += 4 // m.NumItems
+	for j1 := 0; j1 < len(m.Rules); j1++ {
+		var s1 CapoPolicyItem
+		_ = s1
+		if j1 < len(m.Rules) {
+			s1 = m.Rules[j1]
+		}
+		size += 1 // s1.IsInbound
+		size += 4 // s1.RuleID
+	}
+	return size
+}
+func (m *CapoPoliciesDetails) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.PolicyID)
+	buf.EncodeUint8(uint8(m.Mode))
+	buf.EncodeUint32(uint32(len(m.Rules)))
+	for j0 := 0; j0 < len(m.Rules); j0++ {
+		var v0 CapoPolicyItem // Rules
+		if j0 < len(m.Rules) {
+			v0 = m.Rules[j0]
+		}
+		buf.EncodeBool(v0.IsInbound)
+		buf.EncodeUint32(v0.RuleID)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoPoliciesDetails) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.PolicyID = buf.DecodeUint32()
+	m.Mode = CapoPolicyMode(buf.DecodeUint8())
+	m.NumItems = buf.DecodeUint32()
+	m.Rules = make([]CapoPolicyItem, m.NumItems)
+	for j0 := 0; j0 < len(m.Rules); j0++ {
+		m.Rules[j0].IsInbound = buf.DecodeBool()
+		m.Rules[j0].RuleID = buf.DecodeUint32()
+	}
+	return nil
+}
+
+// Dump the policies
+// CapoPoliciesDump defines message 'capo_policies_dump'.
+type CapoPoliciesDump struct{}
+
+func (m *CapoPoliciesDump) Reset()               { *m = CapoPoliciesDump{} }
+func (*CapoPoliciesDump) GetMessageName() string { return "capo_policies_dump" }
+func (*CapoPoliciesDump) GetCrcString() string   { return "51077d14" }
+func (*CapoPoliciesDump) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoPoliciesDump) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	return size
+}
+func (m *CapoPoliciesDump) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	return buf.Bytes(), nil
+}
+func (m *CapoPoliciesDump) Unmarshal(b []byte) error {
+	return nil
+}
+
 // CapoPolicyCreate defines message 'capo_policy_create'.
 type CapoPolicyCreate struct {
 	NumItems uint32           `binapi:"u32,name=num_items" json:"-"`
@@ -960,6 +1368,76 @@ func (m *CapoPolicyDeleteReply) Unmarshal(b []byte) error {
 	return nil
 }
 
+// CapoPolicySetMode defines message 'capo_policy_set_mode'.
+type CapoPolicySetMode struct {
+	PolicyID uint32         `binapi:"u32,name=policy_id" json:"policy_id,omitempty"`
+	Mode     CapoPolicyMode `binapi:"capo_policy_mode,name=mode" json:"mode,omitempty"`
+}
+
+func (m *CapoPolicySetMode) Reset()               { *m = CapoPolicySetMode{} }
+func (*CapoPolicySetMode) GetMessageName() string { return "capo_policy_set_mode" }
+func (*CapoPolicySetMode) GetCrcString() string   { return "e6be285a" }
+func (*CapoPolicySetMode) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoPolicySetMode) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.PolicyID
+	size += 1 // m.Mode
+	return size
+}
+func (m *CapoPolicySetMode) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.PolicyID)
+	buf.EncodeUint8(uint8(m.Mode))
+	return buf.Bytes(), nil
+}
+func (m *CapoPolicySetMode) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.PolicyID = buf.DecodeUint32()
+	m.Mode = CapoPolicyMode(buf.DecodeUint8())
+	return nil
+}
+
+// CapoPolicySetModeReply defines message 'capo_policy_set_mode_reply'.
+type CapoPolicySetModeReply struct {
+	Retval int32 `binapi:"i32,name=retval" json:"retval,omitempty"`
+}
+
+func (m *CapoPolicySetModeReply) Reset()               { *m = CapoPolicySetModeReply{} }
+func (*CapoPolicySetModeReply) GetMessageName() string { return "capo_policy_set_mode_reply" }
+func (*CapoPolicySetModeReply) GetCrcString() string   { return "e8d4e804" }
+func (*CapoPolicySetModeReply) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoPolicySetModeReply) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.Retval
+	return size
+}
+func (m *CapoPolicySetModeReply) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeInt32(m.Retval)
+	return buf.Bytes(), nil
+}
+func (m *CapoPolicySetModeReply) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.Retval = buf.DecodeInt32()
+	return nil
+}
+
 // CapoPolicyUpdate defines message 'capo_policy_update'.
 type CapoPolicyUpdate struct {
 	PolicyID uint32           `binapi:"u32,name=policy_id" json:"policy_id,omitempty"`
@@ -1358,24 +1836,247 @@ func (m *CapoRuleUpdateReply) Unmarshal(b []byte) error {
 	return nil
 }
 
+// Details of a rule. The address family of the rules is not
+// stored, it is always zero. The filters are returned as configured, the
+// matches are grouped by category.
+//   - rule_id - id of the rule
+//   - rule - the rule
+//
+// CapoRulesDetails defines message 'capo_rules_details'.
+type CapoRulesDetails struct {
+	RuleID uint32   `binapi:"u32,name=rule_id" json:"rule_id,omitempty"`
+	Rule   CapoRule `binapi:"capo_rule,name=rule" json:"rule,omitempty"`
+}
+
+func (m *CapoRulesDetails) Reset()               { *m = CapoRulesDetails{} }
+func (*CapoRulesDetails) GetMessageName() string { return "capo_rules_details" }
+func (*CapoRulesDetails) GetCrcString() string   { return "caee2255" }
+func (*CapoRulesDetails) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoRulesDetails) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4 // m.RuleID
+	size += 1 // m.Rule.Af
+	size += 1 // m.Rule.Action
+	for j2 := 0; j2 < 3; j2++ {
+		size += 4 // m.Rule.Filters[j2].Value
+		size += 1 // m.Rule.Filters[j2].Type
+		size += 1 // m.Rule.Filters[j2].ShouldMatch
+	}
+	size += 4 // m.Rule.NumEntries
+	for j2 := 0; j2 < len(m.Rule.Matches); j2++ {
+		var s2 CapoRuleEntry
+		_ = s2
+		if j2 < len(m.Rule.Matches) {
+			s2 = m.Rule.Matches[j2]
+		}
+		size += 1      // s2.IsSrc
+		size += 1      // s2.IsNot
+		size += 1      // s2.Type
+		size += 1 * 18 // s2.Data
+	}
+	return size
+}
+func (m *CapoRulesDetails) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.RuleID)
+	buf.EncodeUint8(uint8(m.Rule.Af))
+	buf.EncodeUint8(uint8(m.Rule.Action))
+	for j1 := 0; j1 < 3; j1++ {
+		buf.EncodeUint32(m.Rule.Filters[j1].Value)
+		buf.EncodeUint8(uint8(m.Rule.Filters[j1].Type))
+		buf.EncodeUint8(m.Rule.Filters[j1].ShouldMatch)
+	}
+	buf.EncodeUint32(uint32(len(m.Rule.Matches)))
+	for j1 := 0; j1 < len(m.Rule.Matches); j1++ {
+		var v1 CapoRuleEntry // Matches
+		if j1 < len(m.Rule.Matches) {
+			v1 = m.Rule.Matches[j1]
+		}
+		buf.EncodeBool(v1.IsSrc)
+		buf.EncodeBool(v1.IsNot)
+		buf.EncodeUint8(uint8(v1.Type))
+		buf.EncodeBytes(v1.Data.XXX_UnionData[:], 18)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoRulesDetails) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.RuleID = buf.DecodeUint32()
+	m.Rule.Af = ip_types.AddressFamily(buf.DecodeUint8())
+	m.Rule.Action = CapoRuleAction(buf.DecodeUint8())
+	for j1 := 0; j1 < 3; j1++ {
+		m.Rule.Filters[j1].Value = buf.DecodeUint32()
+		m.Rule.Filters[j1].Type = CapoRuleFilterType(buf.DecodeUint8())
+		m.Rule.Filters[j1].ShouldMatch = buf.DecodeUint8()
+	}
+	m.Rule.NumEntries = buf.DecodeUint32()
+	m.Rule.Matches = make([]CapoRuleEntry, m.Rule.NumEntries)
+	for j1 := 0; j1 < len(m.Rule.Matches); j1++ {
+		m.Rule.Matches[j1].IsSrc = buf.DecodeBool()
+		m.Rule.Matches[j1].IsNot = buf.DecodeBool()
+		m.Rule.Matches[j1].Type = CapoEntryType(buf.DecodeUint8())
+		copy(m.Rule.Matches[j1].Data.XXX_UnionData[:], buf.DecodeBytes(18))
+	}
+	return nil
+}
+
+// Dump the rules
+// CapoRulesDump defines message 'capo_rules_dump'.
+type CapoRulesDump struct{}
+
+func (m *CapoRulesDump) Reset()               { *m = CapoRulesDump{} }
+func (*CapoRulesDump) GetMessageName() string { return "capo_rules_dump" }
+func (*CapoRulesDump) GetCrcString() string   { return "51077d14" }
+func (*CapoRulesDump) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoRulesDump) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	return size
+}
+func (m *CapoRulesDump) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	return buf.Bytes(), nil
+}
+func (m *CapoRulesDump) Unmarshal(b []byte) error {
+	return nil
+}
+
+// Stages of an interface, as passed to capo_configure_stages.
+// The ids of deleted policies are kept.
+//
+// CapoStagesDetails defines message 'capo_stages_details'.
+type CapoStagesDetails struct {
+	SwIfIndex              uint32   `binapi:"u32,name=sw_if_index" json:"sw_if_index,omitempty"`
+	NumUntrackedRxPolicies uint32   `binapi:"u32,name=num_untracked_rx_policies" json:"num_untracked_rx_policies,omitempty"`
+	NumUntrackedTxPolicies uint32   `binapi:"u32,name=num_untracked_tx_policies" json:"num_untracked_tx_policies,omitempty"`
+	TotalIds               uint32   `binapi:"u32,name=total_ids" json:"-"`
+	InvertRxTx             uint8    `binapi:"u8,name=invert_rx_tx" json:"invert_rx_tx,omitempty"`
+	PolicyIds              []uint32 `binapi:"u32[total_ids],name=policy_ids" json:"policy_ids,omitempty"`
+}
+
+func (m *CapoStagesDetails) Reset()               { *m = CapoStagesDetails{} }
+func (*CapoStagesDetails) GetMessageName() string { return "capo_stages_details" }
+func (*CapoStagesDetails) GetCrcString() string   { return "ab78fd2b" }
+func (*CapoStagesDetails) GetMessageType() api.MessageType {
+	return api.ReplyMessage
+}
+
+func (m *CapoStagesDetails) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	size += 4                    // m.SwIfIndex
+	size += 4                    // m.NumUntrackedRxPolicies
+	size += 4                    // m.NumUntrackedTxPolicies
+	size += 4                    // m.TotalIds
+	size += 1                    // m.InvertRxTx
+	size += 4 * len(m.PolicyIds) // m.PolicyIds
+	return size
+}
+func (m *CapoStagesDetails) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	buf.EncodeUint32(m.SwIfIndex)
+	buf.EncodeUint32(m.NumUntrackedRxPolicies)
+	buf.EncodeUint32(m.NumUntrackedTxPolicies)
+	buf.EncodeUint32(uint32(len(m.PolicyIds)))
+	buf.EncodeUint8(m.InvertRxTx)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		var x uint32
+		if i < len(m.PolicyIds) {
+			x = uint32(m.PolicyIds[i])
+		}
+		buf.EncodeUint32(x)
+	}
+	return buf.Bytes(), nil
+}
+func (m *CapoStagesDetails) Unmarshal(b []byte) error {
+	buf := codec.NewBuffer(b)
+	m.SwIfIndex = buf.DecodeUint32()
+	m.NumUntrackedRxPolicies = buf.DecodeUint32()
+	m.NumUntrackedTxPolicies = buf.DecodeUint32()
+	m.TotalIds = buf.DecodeUint32()
+	m.InvertRxTx = buf.DecodeUint8()
+	m.PolicyIds = make([]uint32, m.TotalIds)
+	for i := 0; i < len(m.PolicyIds); i++ {
+		m.PolicyIds[i] = buf.DecodeUint32()
+	}
+	return nil
+}
+
+// Dump the stages configured on the interfaces
+// CapoStagesDump defines message 'capo_stages_dump'.
+type CapoStagesDump struct{}
+
+func (m *CapoStagesDump) Reset()               { *m = CapoStagesDump{} }
+func (*CapoStagesDump) GetMessageName() string { return "capo_stages_dump" }
+func (*CapoStagesDump) GetCrcString() string   { return "51077d14" }
+func (*CapoStagesDump) GetMessageType() api.MessageType {
+	return api.RequestMessage
+}
+
+func (m *CapoStagesDump) Size() (size int) {
+	if m == nil {
+		return 0
+	}
+	return size
+}
+func (m *CapoStagesDump) Marshal(b []byte) ([]byte, error) {
+	if b == nil {
+		b = make([]byte, m.Size())
+	}
+	buf := codec.NewBuffer(b)
+	return buf.Bytes(), nil
+}
+func (m *CapoStagesDump) Unmarshal(b []byte) error {
+	return nil
+}
+
 func init() { file_capo_binapi_init() }
 func file_capo_binapi_init() {
 	api.RegisterMessage((*CapoConfigurePolicies)(nil), "capo_configure_policies_743e3c30")
 	api.RegisterMessage((*CapoConfigurePoliciesReply)(nil), "capo_configure_policies_reply_e8d4e804")
+	api.RegisterMessage((*CapoConfigureStages)(nil), "capo_configure_stages_13871798")
+	api.RegisterMessage((*CapoConfigureStagesReply)(nil), "capo_configure_stages_reply_e8d4e804")
 	api.RegisterMessage((*CapoControlPing)(nil), "capo_control_ping_51077d14")
 	api.RegisterMessage((*CapoControlPingReply)(nil), "capo_control_ping_reply_f6b0b8ca")
 	api.RegisterMessage((*CapoGetVersion)(nil), "capo_get_version_51077d14")
 	api.RegisterMessage((*CapoGetVersionReply)(nil), "capo_get_version_reply_9b32cf86")
+	api.RegisterMessage((*CapoInterfacesDetails)(nil), "capo_interfaces_details_d590d8bb")
+	api.RegisterMessage((*CapoInterfacesDump)(nil), "capo_interfaces_dump_51077d14")
 	api.RegisterMessage((*CapoIpsetAddDelMembers)(nil), "capo_ipset_add_del_members_e7056d10")
 	api.RegisterMessage((*CapoIpsetAddDelMembersReply)(nil), "capo_ipset_add_del_members_reply_e8d4e804")
 	api.RegisterMessage((*CapoIpsetCreate)(nil), "capo_ipset_create_69150c8a")
 	api.RegisterMessage((*CapoIpsetCreateReply)(nil), "capo_ipset_create_reply_6a43f193")
 	api.RegisterMessage((*CapoIpsetDelete)(nil), "capo_ipset_delete_ceacdbcb")
 	api.RegisterMessage((*CapoIpsetDeleteReply)(nil), "capo_ipset_delete_reply_e8d4e804")
+	api.RegisterMessage((*CapoIpsetsDetails)(nil), "capo_ipsets_details_6d7260ce")
+	api.RegisterMessage((*CapoIpsetsDump)(nil), "capo_ipsets_dump_51077d14")
+	api.RegisterMessage((*CapoPoliciesDetails)(nil), "capo_policies_details_766e65a8")
+	api.RegisterMessage((*CapoPoliciesDump)(nil), "capo_policies_dump_51077d14")
 	api.RegisterMessage((*CapoPolicyCreate)(nil), "capo_policy_create_f7ed31a8")
 	api.RegisterMessage((*CapoPolicyCreateReply)(nil), "capo_policy_create_reply_90f27405")
 	api.RegisterMessage((*CapoPolicyDelete)(nil), "capo_policy_delete_ad833868")
 	api.RegisterMessage((*CapoPolicyDeleteReply)(nil), "capo_policy_delete_reply_e8d4e804")
+	api.RegisterMessage((*CapoPolicySetMode)(nil), "capo_policy_set_mode_e6be285a")
+	api.RegisterMessage((*CapoPolicySetModeReply)(nil), "capo_policy_set_mode_reply_e8d4e804")
 	api.RegisterMessage((*CapoPolicyUpdate)(nil), "capo_policy_update_e2097dd0")
 	api.RegisterMessage((*CapoPolicyUpdateReply)(nil), "capo_policy_update_reply_e8d4e804")
 	api.RegisterMessage((*CapoRuleCreate)(nil), "capo_rule_create_0a2d5fd6")
@@ -1384,6 +2085,10 @@ func file_capo_binapi_init() {
 	api.RegisterMessage((*CapoRuleDeleteReply)(nil), "capo_rule_delete_reply_e8d4e804")
 	api.RegisterMessage((*CapoRuleUpdate)(nil), "capo_rule_update_a0535ee2")
 	api.RegisterMessage((*CapoRuleUpdateReply)(nil), "capo_rule_update_reply_e8d4e804")
+	api.RegisterMessage((*CapoRulesDetails)(nil), "capo_rules_details_caee2255")
+	api.RegisterMessage((*CapoRulesDump)(nil), "capo_rules_dump_51077d14")
+	api.RegisterMessage((*CapoStagesDetails)(nil), "capo_stages_details_ab78fd2b")
+	api.RegisterMessage((*CapoStagesDump)(nil), "capo_stages_dump_51077d14")
 }
 
 // Messages returns list of all messages in this module.
@@ -1391,20 +2096,30 @@ func AllMessages() []api.Message {
 	return []api.Message{
 		(*CapoConfigurePolicies)(nil),
 		(*CapoConfigurePoliciesReply)(nil),
+		(*CapoConfigureStages)(nil),
+		(*CapoConfigureStagesReply)(nil),
 		(*CapoControlPing)(nil),
 		(*CapoControlPingReply)(nil),
 		(*CapoGetVersion)(nil),
 		(*CapoGetVersionReply)(nil),
+		(*CapoInterfacesDetails)(nil),
+		(*CapoInterfacesDump)(nil),
 		(*CapoIpsetAddDelMembers)(nil),
 		(*CapoIpsetAddDelMembersReply)(nil),
 		(*CapoIpsetCreate)(nil),
 		(*CapoIpsetCreateReply)(nil),
 		(*CapoIpsetDelete)(nil),
 		(*CapoIpsetDeleteReply)(nil),
+		(*CapoIpsetsDetails)(nil),
+		(*CapoIpsetsDump)(nil),
+		(*CapoPoliciesDetails)(nil),
+		(*CapoPoliciesDump)(nil),
 		(*CapoPolicyCreate)(nil),
 		(*CapoPolicyCreateReply)(nil),
 		(*CapoPolicyDelete)(nil),
 		(*CapoPolicyDeleteReply)(nil),
+		(*CapoPolicySetMode)(nil),
+		(*CapoPolicySetModeReply)(nil),
 		(*CapoPolicyUpdate)(nil),
 		(*CapoPolicyUpdateReply)(nil),
 		(*CapoRuleCreate)(nil),
@@ -1413,5 +2128,9 @@ func AllMessages() []api.Message {
 		(*CapoRuleDeleteReply)(nil),
 		(*CapoRuleUpdate)(nil),
 		(*CapoRuleUpdateReply)(nil),
+		(*CapoRulesDetails)(nil),
+		(*CapoRulesDump)(nil),
+		(*CapoStagesDetails)(nil),
+		(*CapoStagesDump)(nil),
 	}
 }
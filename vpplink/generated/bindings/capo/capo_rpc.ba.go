@@ -4,24 +4,34 @@ package capo
 
 import (
 	"context"
+	"fmt"
+	"io"
 
+	memclnt "github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/memclnt"
 	api "go.fd.io/govpp/api"
 )
 
 // RPCService defines RPC service capo.
 type RPCService interface {
 	CapoConfigurePolicies(ctx context.Context, in *CapoConfigurePolicies) (*CapoConfigurePoliciesReply, error)
+	CapoConfigureStages(ctx context.Context, in *CapoConfigureStages) (*CapoConfigureStagesReply, error)
 	CapoControlPing(ctx context.Context, in *CapoControlPing) (*CapoControlPingReply, error)
 	CapoGetVersion(ctx context.Context, in *CapoGetVersion) (*CapoGetVersionReply, error)
+	CapoInterfacesDump(ctx context.Context, in *CapoInterfacesDump) (RPCService_CapoInterfacesDumpClient, error)
 	CapoIpsetAddDelMembers(ctx context.Context, in *CapoIpsetAddDelMembers) (*CapoIpsetAddDelMembersReply, error)
 	CapoIpsetCreate(ctx context.Context, in *CapoIpsetCreate) (*CapoIpsetCreateReply, error)
 	CapoIpsetDelete(ctx context.Context, in *CapoIpsetDelete) (*CapoIpsetDeleteReply, error)
+	CapoIpsetsDump(ctx context.Context, in *CapoIpsetsDump) (RPCService_CapoIpsetsDumpClient, error)
+	CapoPoliciesDump(ctx context.Context, in *CapoPoliciesDump) (RPCService_CapoPoliciesDumpClient, error)
 	CapoPolicyCreate(ctx context.Context, in *CapoPolicyCreate) (*CapoPolicyCreateReply, error)
 	CapoPolicyDelete(ctx context.Context, in *CapoPolicyDelete) (*CapoPolicyDeleteReply, error)
+	CapoPolicySetMode(ctx context.Context, in *CapoPolicySetMode) (*CapoPolicySetModeReply, error)
 	CapoPolicyUpdate(ctx context.Context, in *CapoPolicyUpdate) (*CapoPolicyUpdateReply, error)
 	CapoRuleCreate(ctx context.Context, in *CapoRuleCreate) (*CapoRuleCreateReply, error)
 	CapoRuleDelete(ctx context.Context, in *CapoRuleDelete) (*CapoRuleDeleteReply, error)
 	CapoRuleUpdate(ctx context.Context, in *CapoRuleUpdate) (*CapoRuleUpdateReply, error)
+	CapoRulesDump(ctx context.Context, in *CapoRulesDump) (RPCService_CapoRulesDumpClient, error)
+	CapoStagesDump(ctx context.Context, in *CapoStagesDump) (RPCService_CapoStagesDumpClient, error)
 }
 
 type serviceClient struct {
@@ -41,6 +51,15 @@ func (c *serviceClient) CapoConfigurePolicies(ctx context.Context, in *CapoConfi
 	return out, api.RetvalToVPPApiError(out.Retval)
 }
 
+func (c *serviceClient) CapoConfigureStages(ctx context.Context, in *CapoConfigureStages) (*CapoConfigureStagesReply, error) {
+	out := new(CapoConfigureStagesReply)
+	err := c.conn.Invoke(ctx, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, api.RetvalToVPPApiError(out.Retval)
+}
+
 func (c *serviceClient) CapoControlPing(ctx context.Context, in *CapoControlPing) (*CapoControlPingReply, error) {
 	out := new(CapoControlPingReply)
 	err := c.conn.Invoke(ctx, in, out)
@@ -59,6 +78,49 @@ func (c *serviceClient) CapoGetVersion(ctx context.Context, in *CapoGetVersion)
 	return out, nil
 }
 
+func (c *serviceClient) CapoInterfacesDump(ctx context.Context, in *CapoInterfacesDump) (RPCService_CapoInterfacesDumpClient, error) {
+	stream, err := c.conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceClient_CapoInterfacesDumpClient{stream}
+	if err := x.Stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.Stream.SendMsg(&memclnt.ControlPing{}); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RPCService_CapoInterfacesDumpClient interface {
+	Recv() (*CapoInterfacesDetails, error)
+	api.Stream
+}
+
+type serviceClient_CapoInterfacesDumpClient struct {
+	api.Stream
+}
+
+func (c *serviceClient_CapoInterfacesDumpClient) Recv() (*CapoInterfacesDetails, error) {
+	msg, err := c.Stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *CapoInterfacesDetails:
+		return m, nil
+	case *memclnt.ControlPingReply:
+		err = c.Stream.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected message: %T %v", m, m)
+	}
+}
+
 func (c *serviceClient) CapoIpsetAddDelMembers(ctx context.Context, in *CapoIpsetAddDelMembers) (*CapoIpsetAddDelMembersReply, error) {
 	out := new(CapoIpsetAddDelMembersReply)
 	err := c.conn.Invoke(ctx, in, out)
@@ -86,6 +148,92 @@ func (c *serviceClient) CapoIpsetDelete(ctx context.Context, in *CapoIpsetDelete
 	return out, api.RetvalToVPPApiError(out.Retval)
 }
 
+func (c *serviceClient) CapoIpsetsDump(ctx context.Context, in *CapoIpsetsDump) (RPCService_CapoIpsetsDumpClient, error) {
+	stream, err := c.conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceClient_CapoIpsetsDumpClient{stream}
+	if err := x.Stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.Stream.SendMsg(&memclnt.ControlPing{}); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RPCService_CapoIpsetsDumpClient interface {
+	Recv() (*CapoIpsetsDetails, error)
+	api.Stream
+}
+
+type serviceClient_CapoIpsetsDumpClient struct {
+	api.Stream
+}
+
+func (c *serviceClient_CapoIpsetsDumpClient) Recv() (*CapoIpsetsDetails, error) {
+	msg, err := c.Stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *CapoIpsetsDetails:
+		return m, nil
+	case *memclnt.ControlPingReply:
+		err = c.Stream.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected message: %T %v", m, m)
+	}
+}
+
+func (c *serviceClient) CapoPoliciesDump(ctx context.Context, in *CapoPoliciesDump) (RPCService_CapoPoliciesDumpClient, error) {
+	stream, err := c.conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceClient_CapoPoliciesDumpClient{stream}
+	if err := x.Stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.Stream.SendMsg(&memclnt.ControlPing{}); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RPCService_CapoPoliciesDumpClient interface {
+	Recv() (*CapoPoliciesDetails, error)
+	api.Stream
+}
+
+type serviceClient_CapoPoliciesDumpClient struct {
+	api.Stream
+}
+
+func (c *serviceClient_CapoPoliciesDumpClient) Recv() (*CapoPoliciesDetails, error) {
+	msg, err := c.Stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *CapoPoliciesDetails:
+		return m, nil
+	case *memclnt.ControlPingReply:
+		err = c.Stream.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected message: %T %v", m, m)
+	}
+}
+
 func (c *serviceClient) CapoPolicyCreate(ctx context.Context, in *CapoPolicyCreate) (*CapoPolicyCreateReply, error) {
 	out := new(CapoPolicyCreateReply)
 	err := c.conn.Invoke(ctx, in, out)
@@ -104,6 +252,15 @@ func (c *serviceClient) CapoPolicyDelete(ctx context.Context, in *CapoPolicyDele
 	return out, api.RetvalToVPPApiError(out.Retval)
 }
 
+func (c *serviceClient) CapoPolicySetMode(ctx context.Context, in *CapoPolicySetMode) (*CapoPolicySetModeReply, error) {
+	out := new(CapoPolicySetModeReply)
+	err := c.conn.Invoke(ctx, in, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, api.RetvalToVPPApiError(out.Retval)
+}
+
 func (c *serviceClient) CapoPolicyUpdate(ctx context.Context, in *CapoPolicyUpdate) (*CapoPolicyUpdateReply, error) {
 	out := new(CapoPolicyUpdateReply)
 	err := c.conn.Invoke(ctx, in, out)
@@ -139,3 +296,89 @@ func (c *serviceClient) CapoRuleUpdate(ctx context.Context, in *CapoRuleUpdate)
 	}
 	return out, api.RetvalToVPPApiError(out.Retval)
 }
+
+func (c *serviceClient) CapoRulesDump(ctx context.Context, in *CapoRulesDump) (RPCService_CapoRulesDumpClient, error) {
+	stream, err := c.conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceClient_CapoRulesDumpClient{stream}
+	if err := x.Stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.Stream.SendMsg(&memclnt.ControlPing{}); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RPCService_CapoRulesDumpClient interface {
+	Recv() (*CapoRulesDetails, error)
+	api.Stream
+}
+
+type serviceClient_CapoRulesDumpClient struct {
+	api.Stream
+}
+
+func (c *serviceClient_CapoRulesDumpClient) Recv() (*CapoRulesDetails, error) {
+	msg, err := c.Stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *CapoRulesDetails:
+		return m, nil
+	case *memclnt.ControlPingReply:
+		err = c.Stream.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected message: %T %v", m, m)
+	}
+}
+
+func (c *serviceClient) CapoStagesDump(ctx context.Context, in *CapoStagesDump) (RPCService_CapoStagesDumpClient, error) {
+	stream, err := c.conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	x := &serviceClient_CapoStagesDumpClient{stream}
+	if err := x.Stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err = x.Stream.SendMsg(&memclnt.ControlPing{}); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RPCService_CapoStagesDumpClient interface {
+	Recv() (*CapoStagesDetails, error)
+	api.Stream
+}
+
+type serviceClient_CapoStagesDumpClient struct {
+	api.Stream
+}
+
+func (c *serviceClient_CapoStagesDumpClient) Recv() (*CapoStagesDetails, error) {
+	msg, err := c.Stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *CapoStagesDetails:
+		return m, nil
+	case *memclnt.ControlPingReply:
+		err = c.Stream.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected message: %T %v", m, m)
+	}
+}
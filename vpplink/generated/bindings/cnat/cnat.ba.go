@@ -3,7 +3,7 @@
 // Package cnat contains generated bindings for API file cnat.api.
 //
 // Contents:
-// -  5 enums
+// -  6 enums
 // -  4 structs
 // - 20 messages
 package cnat
@@ -33,6 +33,33 @@ const (
 	VersionCrc = 0xce7be3ad
 )
 
+// CnatAffinity defines enum 'cnat_affinity'.
+type CnatAffinity uint8
+
+const (
+	CNAT_AFFINITY_NONE      CnatAffinity = 0
+	CNAT_AFFINITY_CLIENT_IP CnatAffinity = 1
+)
+
+var (
+	CnatAffinity_name = map[uint8]string{
+		0: "CNAT_AFFINITY_NONE",
+		1: "CNAT_AFFINITY_CLIENT_IP",
+	}
+	CnatAffinity_value = map[string]uint8{
+		"CNAT_AFFINITY_NONE":      0,
+		"CNAT_AFFINITY_CLIENT_IP": 1,
+	}
+)
+
+func (x CnatAffinity) String() string {
+	s, ok := CnatAffinity_name[uint8(x)]
+	if ok {
+		return s
+	}
+	return "CnatAffinity(" + strconv.Itoa(int(x)) + ")"
+}
+
 // CnatEndpointTupleFlags defines enum 'cnat_endpoint_tuple_flags'.
 type CnatEndpointTupleFlags uint8
 
@@ -239,15 +266,17 @@ type CnatSession struct {
 
 // CnatTranslation defines type 'cnat_translation'.
 type CnatTranslation struct {
-	Vip            CnatEndpoint          `binapi:"cnat_endpoint,name=vip" json:"vip,omitempty"`
-	ID             uint32                `binapi:"u32,name=id" json:"id,omitempty"`
-	IPProto        ip_types.IPProto      `binapi:"ip_proto,name=ip_proto" json:"ip_proto,omitempty"`
-	IsRealIP       uint8                 `binapi:"u8,name=is_real_ip" json:"is_real_ip,omitempty"`
-	Flags          uint8                 `binapi:"u8,name=flags" json:"flags,omitempty"`
-	LbType         CnatLbType            `binapi:"cnat_lb_type,name=lb_type" json:"lb_type,omitempty"`
-	NPaths         uint32                `binapi:"u32,name=n_paths" json:"-"`
-	FlowHashConfig ip.IPFlowHashConfigV2 `binapi:"ip_flow_hash_config_v2,name=flow_hash_config" json:"flow_hash_config,omitempty"`
-	Paths          []CnatEndpointTuple   `binapi:"cnat_endpoint_tuple[n_paths],name=paths" json:"paths,omitempty"`
+	Vip             CnatEndpoint          `binapi:"cnat_endpoint,name=vip" json:"vip,omitempty"`
+	ID              uint32                `binapi:"u32,name=id" json:"id,omitempty"`
+	IPProto         ip_types.IPProto      `binapi:"ip_proto,name=ip_proto" json:"ip_proto,omitempty"`
+	IsRealIP        uint8                 `binapi:"u8,name=is_real_ip" json:"is_real_ip,omitempty"`
+	Flags           uint8                 `binapi:"u8,name=flags" json:"flags,omitempty"`
+	LbType          CnatLbType            `binapi:"cnat_lb_type,name=lb_type" json:"lb_type,omitempty"`
+	NPaths          uint32                `binapi:"u32,name=n_paths" json:"-"`
+	FlowHashConfig  ip.IPFlowHashConfigV2 `binapi:"ip_flow_hash_config_v2,name=flow_hash_config" json:"flow_hash_config,omitempty"`
+	Affinity        CnatAffinity          `binapi:"cnat_affinity,name=affinity" json:"affinity,omitempty"`
+	AffinityTimeout uint32                `binapi:"u32,name=affinity_timeout" json:"affinity_timeout,omitempty"`
+	Paths           []CnatEndpointTuple   `binapi:"cnat_endpoint_tuple[n_paths],name=paths" json:"paths,omitempty"`
 }
 
 // CnatGetSnatAddresses defines message 'cnat_get_snat_addresses'.
@@ -867,7 +896,7 @@ type CnatTranslationDetails struct {
 
 func (m *CnatTranslationDetails) Reset()               { *m = CnatTranslationDetails{} }
 func (*CnatTranslationDetails) GetMessageName() string { return "cnat_translation_details" }
-func (*CnatTranslationDetails) GetCrcString() string   { return "1a5140b7" }
+func (*CnatTranslationDetails) GetCrcString() string   { return "c748072e" }
 func (*CnatTranslationDetails) GetMessageType() api.MessageType {
 	return api.ReplyMessage
 }
@@ -888,6 +917,8 @@ func (m *CnatTranslationDetails) Size() (size int) {
 	size += 1      // m.Translation.LbType
 	size += 4      // m.Translation.NPaths
 	size += 4      // m.Translation.FlowHashConfig
+	size += 1      // m.Translation.Affinity
+	size += 4      // m.Translation.AffinityTimeout
 	for j2 := 0; j2 < len(m.Translation.Paths); j2++ {
 		var s2 CnatEndpointTuple
 		_ = s2
@@ -925,6 +956,8 @@ func (m *CnatTranslationDetails) Marshal(b []byte) ([]byte, error) {
 	buf.EncodeUint8(uint8(m.Translation.LbType))
 	buf.EncodeUint32(uint32(len(m.Translation.Paths)))
 	buf.EncodeUint32(uint32(m.Translation.FlowHashConfig))
+	buf.EncodeUint8(uint8(m.Translation.Affinity))
+	buf.EncodeUint32(m.Translation.AffinityTimeout)
 	for j1 := 0; j1 < len(m.Translation.Paths); j1++ {
 		var v1 CnatEndpointTuple // Paths
 		if j1 < len(m.Translation.Paths) {
@@ -958,6 +991,8 @@ func (m *CnatTranslationDetails) Unmarshal(b []byte) error {
 	m.Translation.LbType = CnatLbType(buf.DecodeUint8())
 	m.Translation.NPaths = buf.DecodeUint32()
 	m.Translation.FlowHashConfig = ip.IPFlowHashConfigV2(buf.DecodeUint32())
+	m.Translation.Affinity = CnatAffinity(buf.DecodeUint8())
+	m.Translation.AffinityTimeout = buf.DecodeUint32()
 	m.Translation.Paths = make([]CnatEndpointTuple, m.Translation.NPaths)
 	for j1 := 0; j1 < len(m.Translation.Paths); j1++ {
 		m.Translation.Paths[j1].DstEp.Addr.Af = ip_types.AddressFamily(buf.DecodeUint8())
@@ -1013,7 +1048,7 @@ type CnatTranslationUpdate struct {
 
 func (m *CnatTranslationUpdate) Reset()               { *m = CnatTranslationUpdate{} }
 func (*CnatTranslationUpdate) GetMessageName() string { return "cnat_translation_update" }
-func (*CnatTranslationUpdate) GetCrcString() string   { return "f8d40bc5" }
+func (*CnatTranslationUpdate) GetCrcString() string   { return "af7a37a0" }
 func (*CnatTranslationUpdate) GetMessageType() api.MessageType {
 	return api.RequestMessage
 }
@@ -1034,6 +1069,8 @@ func (m *CnatTranslationUpdate) Size() (size int) {
 	size += 1      // m.Translation.LbType
 	size += 4      // m.Translation.NPaths
 	size += 4      // m.Translation.FlowHashConfig
+	size += 1      // m.Translation.Affinity
+	size += 4      // m.Translation.AffinityTimeout
 	for j2 := 0; j2 < len(m.Translation.Paths); j2++ {
 		var s2 CnatEndpointTuple
 		_ = s2
@@ -1071,6 +1108,8 @@ func (m *CnatTranslationUpdate) Marshal(b []byte) ([]byte, error) {
 	buf.EncodeUint8(uint8(m.Translation.LbType))
 	buf.EncodeUint32(uint32(len(m.Translation.Paths)))
 	buf.EncodeUint32(uint32(m.Translation.FlowHashConfig))
+	buf.EncodeUint8(uint8(m.Translation.Affinity))
+	buf.EncodeUint32(m.Translation.AffinityTimeout)
 	for j1 := 0; j1 < len(m.Translation.Paths); j1++ {
 		var v1 CnatEndpointTuple // Paths
 		if j1 < len(m.Translation.Paths) {
@@ -1104,6 +1143,8 @@ func (m *CnatTranslationUpdate) Unmarshal(b []byte) error {
 	m.Translation.LbType = CnatLbType(buf.DecodeUint8())
 	m.Translation.NPaths = buf.DecodeUint32()
 	m.Translation.FlowHashConfig = ip.IPFlowHashConfigV2(buf.DecodeUint32())
+	m.Translation.Affinity = CnatAffinity(buf.DecodeUint8())
+	m.Translation.AffinityTimeout = buf.DecodeUint32()
 	m.Translation.Paths = make([]CnatEndpointTuple, m.Translation.NPaths)
 	for j1 := 0; j1 < len(m.Translation.Paths); j1++ {
 		m.Translation.Paths[j1].DstEp.Addr.Af = ip_types.AddressFamily(buf.DecodeUint8())
@@ -1176,9 +1217,9 @@ func file_cnat_binapi_init() {
 	api.RegisterMessage((*CnatSnatPolicyAddDelIfReply)(nil), "cnat_snat_policy_add_del_if_reply_e8d4e804")
 	api.RegisterMessage((*CnatTranslationDel)(nil), "cnat_translation_del_3a91bde5")
 	api.RegisterMessage((*CnatTranslationDelReply)(nil), "cnat_translation_del_reply_e8d4e804")
-	api.RegisterMessage((*CnatTranslationDetails)(nil), "cnat_translation_details_1a5140b7")
+	api.RegisterMessage((*CnatTranslationDetails)(nil), "cnat_translation_details_c748072e")
 	api.RegisterMessage((*CnatTranslationDump)(nil), "cnat_translation_dump_51077d14")
-	api.RegisterMessage((*CnatTranslationUpdate)(nil), "cnat_translation_update_f8d40bc5")
+	api.RegisterMessage((*CnatTranslationUpdate)(nil), "cnat_translation_update_af7a37a0")
 	api.RegisterMessage((*CnatTranslationUpdateReply)(nil), "cnat_translation_update_reply_e2fc8294")
 }
 
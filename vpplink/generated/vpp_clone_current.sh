@@ -109,3 +109,13 @@ git_apply_private 0002-cnat-WIP-no-k8s-maglev-from-pods.patch
 git_apply_private 0003-acl-acl-plugin-custom-policies.patch
 git_apply_private 0004-capo-Calico-Policies-plugin.patch
 git_apply_private 0005-partial-revert-arthur-gso.patch
+git_apply_private 0006-capo-count-rule-matches-and-default-deny-drops.patch
+git_apply_private 0007-capo-continue-after-log-rules-and-add-a-policy-audit-mode.patch
+git_apply_private 0008-capo-add-an-untracked-policy-stage.patch
+git_apply_private 0009-capo-add-a-preDNAT-policy-stage.patch
+git_apply_private 0010-acl-pass-the-buffer-to-the-custom-access-policies.patch
+git_apply_private 0011-capo-count-the-bytes-matched-by-the-rules-and-default-deny.patch
+git_apply_private 0012-capo-punt-the-packets-matching-log-rules.patch
+git_apply_private 0013-capo-punt-the-packets-matching-the-rules-of-audited-policies.patch
+git_apply_private 0014-capo-add-dump-messages-for-the-ipsets-rules-policies-and-interfaces.patch
+git_apply_private 0015-cnat-add-a-client-IP-affinity-to-the-translations.patch
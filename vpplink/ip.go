@@ -169,3 +169,69 @@ func (v *VppLink) PuntAllL4(isIPv6 bool) (err error) {
 	}
 	return nil
 }
+
+// PuntReasonID returns the ID of the punt exception reason registered in VPP under the given name
+func (v *VppLink) PuntReasonID(name string) (id uint32, err error) {
+	client := punt.NewServiceClient(v.GetConnection())
+
+	stream, err := client.PuntReasonDump(v.GetContext(), &punt.PuntReasonDump{
+		Reason: punt.PuntReason{Name: name},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to dump punt reasons: %w", err)
+	}
+	found := false
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to dump punt reasons: %w", err)
+		}
+		if response.Reason.Name == name {
+			id = response.Reason.ID
+			found = true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("punt reason %s not found", name)
+	}
+	return id, nil
+}
+
+func puntException(reasonID uint32) punt.Punt {
+	return punt.Punt{
+		Type: punt.PUNT_API_TYPE_EXCEPTION,
+		Punt: punt.PuntUnionException(punt.PuntException{ID: reasonID}),
+	}
+}
+
+// PuntSocketRegister sends the packets punted for the given exception reason to
+// the unix datagram socket at pathname. Each packet is prefixed with the
+// punt_packet_desc_t header (rx sw_if_index and action).
+func (v *VppLink) PuntSocketRegister(reasonID uint32, pathname string) error {
+	client := punt.NewServiceClient(v.GetConnection())
+
+	_, err := client.PuntSocketRegister(v.GetContext(), &punt.PuntSocketRegister{
+		HeaderVersion: 1,
+		Punt:          puntException(reasonID),
+		Pathname:      pathname,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register punt socket %s in VPP: %w", pathname, err)
+	}
+	return nil
+}
+
+func (v *VppLink) PuntSocketDeregister(reasonID uint32) error {
+	client := punt.NewServiceClient(v.GetConnection())
+
+	_, err := client.PuntSocketDeregister(v.GetContext(), &punt.PuntSocketDeregister{
+		Punt: puntException(reasonID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deregister punt socket in VPP: %w", err)
+	}
+	return nil
+}
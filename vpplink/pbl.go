@@ -17,6 +17,7 @@ package vpplink
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/pbl"
 	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
@@ -62,3 +63,38 @@ func (v *VppLink) DelPblClient(id uint32) error {
 	}
 	return nil
 }
+
+func (v *VppLink) ListPblClients() ([]*types.PblClient, error) {
+	client := pbl.NewServiceClient(v.GetConnection())
+
+	stream, err := client.PblClientDump(v.GetContext(), &pbl.PblClientDump{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump Pbl Clients: %w", err)
+	}
+	pblClients := make([]*types.PblClient, 0)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump Pbl Clients: %w", err)
+		}
+		portRanges := make([]types.PblPortRange, 0, len(response.Client.PortRanges))
+		for _, r := range response.Client.PortRanges {
+			portRanges = append(portRanges, types.PblPortRange{
+				Start: r.Start,
+				End:   r.End,
+				Proto: types.IPProto(r.Iproto),
+			})
+		}
+		pblClients = append(pblClients, &types.PblClient{
+			ID:         response.Client.ID,
+			TableId:    response.Client.TableID,
+			Addr:       types.FromVppAddress(response.Client.Addr),
+			Path:       types.FromFibPath(response.Client.Paths),
+			PortRanges: portRanges,
+		})
+	}
+	return pblClients, nil
+}
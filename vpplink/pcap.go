@@ -0,0 +1,97 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpplink
+
+import (
+	"fmt"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/bpf_trace_filter"
+	interfaces "github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/interface"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/interface_types"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+const (
+	// BpfPcapFilterFunction is the pcap filter function of the
+	// bpf_trace_filter plugin
+	BpfPcapFilterFunction = "bpf_trace_filter"
+)
+
+func (v *VppLink) PcapTraceOn(trace *types.PcapTrace) error {
+	client := interfaces.NewServiceClient(v.GetConnection())
+
+	_, err := client.PcapTraceOn(v.GetContext(), &interfaces.PcapTraceOn{
+		CaptureRx:         trace.CaptureRx,
+		CaptureTx:         trace.CaptureTx,
+		CaptureDrop:       trace.CaptureDrop,
+		Filter:            trace.UseFilter,
+		MaxPackets:        trace.MaxPackets,
+		MaxBytesPerPacket: trace.MaxBytesPerPacket,
+		SwIfIndex:         interface_types.InterfaceIndex(trace.SwIfIndex),
+		Filename:          trace.Filename,
+	})
+	if err != nil {
+		return fmt.Errorf("PcapTraceOn failed: %w", err)
+	}
+	return nil
+}
+
+// PcapTraceOff stops the capture, VPP then writes the captured packets
+func (v *VppLink) PcapTraceOff() error {
+	client := interfaces.NewServiceClient(v.GetConnection())
+
+	_, err := client.PcapTraceOff(v.GetContext(), &interfaces.PcapTraceOff{})
+	if err != nil {
+		return fmt.Errorf("PcapTraceOff failed: %w", err)
+	}
+	return nil
+}
+
+func (v *VppLink) SetPcapFilterFunction(name string) error {
+	client := interfaces.NewServiceClient(v.GetConnection())
+
+	_, err := client.PcapSetFilterFunction(v.GetContext(), &interfaces.PcapSetFilterFunction{
+		FilterFunctionName: name,
+	})
+	if err != nil {
+		return fmt.Errorf("PcapSetFilterFunction %s failed: %w", name, err)
+	}
+	return nil
+}
+
+func (v *VppLink) setBpfTraceFilter(filter string, isAdd bool) error {
+	client := bpf_trace_filter.NewServiceClient(v.GetConnection())
+
+	_, err := client.BpfTraceFilterSetV2(v.GetContext(), &bpf_trace_filter.BpfTraceFilterSetV2{
+		IsAdd:    isAdd,
+		Optimize: true,
+		Filter:   filter,
+	})
+	if err != nil {
+		return fmt.Errorf("BpfTraceFilterSetV2 %q (add:%t) failed: %w", filter, isAdd, err)
+	}
+	return nil
+}
+
+// SetBpfTraceFilter sets the filter, in pcap-filter syntax, used by the
+// BpfPcapFilterFunction
+func (v *VppLink) SetBpfTraceFilter(filter string) error {
+	return v.setBpfTraceFilter(filter, true)
+}
+
+func (v *VppLink) DelBpfTraceFilter() error {
+	return v.setBpfTraceFilter("", false)
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpplink
+
+import (
+	"context"
+	"fmt"
+
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/core"
+)
+
+// PipelineDepth is the maximum number of requests sent to VPP
+// by pipelineRequests before waiting for their replies, 1 sends
+// the requests serially
+var PipelineDepth = 64
+
+// pipelineRequests sends the requests to VPP on a single stream, without waiting
+// for a reply before sending the next request (up to PipelineDepth requests in
+// flight). VPP processes and replies to the requests in order, onReply is called
+// with the index of the request for each reply. Sending stops at the first error,
+// the requests already sent are still waited for.
+func pipelineRequests(ctx context.Context, conn api.Connection, requests []api.Message, onReply func(i int, reply api.Message) error) (err error) {
+	if len(requests) == 0 {
+		return nil
+	}
+	stream, err := conn.NewStream(ctx, core.WithRequestSize(PipelineDepth), core.WithReplySize(PipelineDepth))
+	if err != nil {
+		return fmt.Errorf("cannot create stream: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	sent := 0
+	for received := 0; received < len(requests); received++ {
+		for err == nil && sent < len(requests) && sent-received < PipelineDepth {
+			sendErr := stream.SendMsg(requests[sent])
+			if sendErr != nil {
+				err = fmt.Errorf("cannot send %s: %w", requests[sent].GetMessageName(), sendErr)
+				break
+			}
+			sent++
+		}
+		if received == sent {
+			break
+		}
+		reply, recvErr := stream.RecvMsg()
+		if recvErr != nil {
+			// We cannot tell which replies are still in flight anymore
+			return fmt.Errorf("cannot receive %s reply: %w", requests[received].GetMessageName(), recvErr)
+		}
+		replyErr := onReply(received, reply)
+		if replyErr != nil && err == nil {
+			err = replyErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpplink
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestVpplink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "vpplink tests")
+}
+
+type delayedMsg struct {
+	deadline time.Time
+	clientID uint32
+	data     []byte
+}
+
+// latencyAdapter is a mock VPP replying to each message after a fixed delay,
+// in the order the messages were sent, as VPP does over the API socket
+type latencyAdapter struct {
+	*mock.VppAdapter
+	latency time.Duration
+	queue   chan delayedMsg
+}
+
+func newLatencyAdapter(latency time.Duration) *latencyAdapter {
+	a := &latencyAdapter{
+		VppAdapter: mock.NewVppAdapter(),
+		latency:    latency,
+		queue:      make(chan delayedMsg, 1024),
+	}
+	go func() {
+		for msg := range a.queue {
+			time.Sleep(time.Until(msg.deadline))
+			_ = a.VppAdapter.SendMsg(msg.clientID, msg.data)
+		}
+	}()
+	return a
+}
+
+func (a *latencyAdapter) SendMsg(clientID uint32, data []byte) error {
+	a.queue <- delayedMsg{
+		deadline: time.Now().Add(a.latency),
+		clientID: clientID,
+		data:     append([]byte{}, data...),
+	}
+	return nil
+}
+
+func (a *latencyAdapter) Disconnect() error {
+	close(a.queue)
+	return a.VppAdapter.Disconnect()
+}
+
+// mockCapo replies to capo rule creations with increasing rule IDs, and
+// fails the creation of rules with failingAction
+func mockCapo(a *latencyAdapter, failingAction capo.CapoRuleAction) {
+	var nextRuleID uint32
+	a.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		switch request.MsgName {
+		case "capo_rule_create":
+			req := &capo.CapoRuleCreate{}
+			reply := &capo.CapoRuleCreateReply{}
+			// skip the message ID and client context
+			err := req.Unmarshal(request.Data[10:])
+			if err != nil || req.Rule.Action == failingAction {
+				reply.Retval = int32(api.INVALID_VALUE)
+			} else {
+				reply.RuleID = atomic.AddUint32(&nextRuleID, 1)
+			}
+			msgID, err := a.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+			Expect(err).ToNot(HaveOccurred())
+			data, err := codec.DefaultCodec.EncodeMsg(reply, msgID)
+			Expect(err).ToNot(HaveOccurred())
+			binary.BigEndian.PutUint32(data[2:6], request.ClientID)
+			return data, msgID, true
+		}
+		return nil, 0, false
+	})
+}
+
+func newMockConnection(latency time.Duration, failingAction capo.CapoRuleAction) (*core.Connection, *latencyAdapter) {
+	a := newLatencyAdapter(latency)
+	mockCapo(a, failingAction)
+	conn, err := core.Connect(a)
+	Expect(err).ToNot(HaveOccurred())
+	return conn, a
+}
+
+// syntheticRules returns n rules matching a destination port on a /24
+func syntheticRules(n int) []*types.Rule {
+	rules := make([]*types.Rule, 0, n)
+	for i := 0; i < n; i++ {
+		rules = append(rules, &types.Rule{
+			Action:        types.ActionAllow,
+			AddressFamily: types.FAMILY_V4,
+			Filters:       []types.RuleFilter{{Type: types.CapoFilterProto, Value: int(types.TCP), ShouldMatch: true}},
+			DstNet:        []net.IPNet{{IP: net.IPv4(10, byte(i>>8), byte(i), 0), Mask: net.CIDRMask(24, 32)}},
+			DstPortRange:  []types.PortRange{{First: uint16(i), Last: uint16(i)}},
+		})
+	}
+	return rules
+}
+
+func ruleCreateRequests(rules []*types.Rule) []api.Message {
+	requests := make([]api.Message, 0, len(rules))
+	for _, rule := range rules {
+		requests = append(requests, &capo.CapoRuleCreate{Rule: types.ToCapoRule(rule)})
+	}
+	return requests
+}
+
+var _ = Describe("Pipelined requests", func() {
+	It("should match replies to requests in order", func() {
+		conn, _ := newMockConnection(time.Microsecond, capo.CAPO_DENY)
+		defer conn.Disconnect()
+
+		requests := ruleCreateRequests(syntheticRules(3 * PipelineDepth))
+		ruleIDs := make([]uint32, len(requests))
+		err := pipelineRequests(context.Background(), conn, requests, func(i int, msg api.Message) error {
+			reply, ok := msg.(*capo.CapoRuleCreateReply)
+			Expect(ok).To(BeTrue())
+			ruleIDs[i] = reply.RuleID
+			return api.RetvalToVPPApiError(reply.Retval)
+		})
+		Expect(err).ToNot(HaveOccurred())
+		for i, ruleID := range ruleIDs {
+			Expect(ruleID).To(Equal(uint32(i + 1)))
+		}
+	})
+
+	It("should stop sending requests after an error", func() {
+		conn, _ := newMockConnection(time.Microsecond, capo.CAPO_DENY)
+		defer conn.Disconnect()
+
+		rules := syntheticRules(3 * PipelineDepth)
+		rules[10].Action = types.ActionDeny
+		replies := 0
+		err := pipelineRequests(context.Background(), conn, ruleCreateRequests(rules), func(i int, msg api.Message) error {
+			replies++
+			return api.RetvalToVPPApiError(msg.(*capo.CapoRuleCreateReply).Retval)
+		})
+		Expect(err).To(HaveOccurred())
+		// the requests in flight when the error was received still get a reply
+		Expect(replies).To(BeNumerically(">", 10))
+		Expect(replies).To(BeNumerically("<=", 11+PipelineDepth))
+	})
+})
+
+// BenchmarkRuleCreate compares creating 10k rules with one request at a time,
+// as RuleCreate does, and with pipelined requests, as RulesCreate does, with
+// a VPP replying after 20µs
+func BenchmarkRuleCreate(b *testing.B) {
+	RegisterTestingT(b)
+	rules := syntheticRules(10000)
+
+	b.Run("serial", func(b *testing.B) {
+		conn, _ := newMockConnection(20*time.Microsecond, capo.CAPO_DENY)
+		defer conn.Disconnect()
+		client := capo.NewServiceClient(conn)
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for _, rule := range rules {
+				_, err := client.CapoRuleCreate(context.Background(), &capo.CapoRuleCreate{Rule: types.ToCapoRule(rule)})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("pipelined", func(b *testing.B) {
+		conn, _ := newMockConnection(20*time.Microsecond, capo.CAPO_DENY)
+		defer conn.Disconnect()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			err := pipelineRequests(context.Background(), conn, ruleCreateRequests(rules), func(i int, msg api.Message) error {
+				return api.RetvalToVPPApiError(msg.(*capo.CapoRuleCreateReply).Retval)
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
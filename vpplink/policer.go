@@ -0,0 +1,111 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpplink
+
+import (
+	"fmt"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/interface_types"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/policer"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/policer_types"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+func (v *VppLink) AddPolicer(name string, p *types.Policer) (uint32, error) {
+	client := policer.NewServiceClient(v.GetConnection())
+
+	response, err := client.PolicerAdd(v.GetContext(), &policer.PolicerAdd{
+		Name: name,
+		Infos: policer_types.PolicerConfig{
+			Cir:       p.CIR,
+			Cb:        p.CB,
+			RateType:  policer_types.SSE2_QOS_RATE_API_KBPS,
+			RoundType: policer_types.SSE2_QOS_ROUND_API_TO_CLOSEST,
+			Type:      policer_types.SSE2_QOS_POLICER_TYPE_API_1R2C,
+			ConformAction: policer_types.Sse2QosAction{
+				Type: policer_types.SSE2_QOS_ACTION_API_TRANSMIT,
+			},
+			ExceedAction: policer_types.Sse2QosAction{
+				Type: policer_types.SSE2_QOS_ACTION_API_DROP,
+			},
+			ViolateAction: policer_types.Sse2QosAction{
+				Type: policer_types.SSE2_QOS_ACTION_API_DROP,
+			},
+		},
+	})
+	if err != nil {
+		return InvalidID, fmt.Errorf("failed to add policer %s: %w", name, err)
+	}
+	return response.PolicerIndex, nil
+}
+
+func (v *VppLink) DelPolicer(policerIndex uint32) error {
+	client := policer.NewServiceClient(v.GetConnection())
+
+	_, err := client.PolicerDel(v.GetContext(), &policer.PolicerDel{
+		PolicerIndex: policerIndex,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete policer %d: %w", policerIndex, err)
+	}
+	return nil
+}
+
+func (v *VppLink) policerInput(policerIndex, swIfIndex uint32, apply bool) error {
+	client := policer.NewServiceClient(v.GetConnection())
+
+	_, err := client.PolicerInputV2(v.GetContext(), &policer.PolicerInputV2{
+		PolicerIndex: policerIndex,
+		SwIfIndex:    interface_types.InterfaceIndex(swIfIndex),
+		Apply:        apply,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set policer %d input on %d (apply=%t): %w", policerIndex, swIfIndex, apply, err)
+	}
+	return nil
+}
+
+// EnablePolicerInput applies the policer to the packets received on the interface
+func (v *VppLink) EnablePolicerInput(policerIndex, swIfIndex uint32) error {
+	return v.policerInput(policerIndex, swIfIndex, true)
+}
+
+func (v *VppLink) DisablePolicerInput(policerIndex, swIfIndex uint32) error {
+	return v.policerInput(policerIndex, swIfIndex, false)
+}
+
+func (v *VppLink) policerOutput(policerIndex, swIfIndex uint32, apply bool) error {
+	client := policer.NewServiceClient(v.GetConnection())
+
+	_, err := client.PolicerOutputV2(v.GetContext(), &policer.PolicerOutputV2{
+		PolicerIndex: policerIndex,
+		SwIfIndex:    interface_types.InterfaceIndex(swIfIndex),
+		Apply:        apply,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set policer %d output on %d (apply=%t): %w", policerIndex, swIfIndex, apply, err)
+	}
+	return nil
+}
+
+// EnablePolicerOutput applies the policer to the packets sent on the interface
+func (v *VppLink) EnablePolicerOutput(policerIndex, swIfIndex uint32) error {
+	return v.policerOutput(policerIndex, swIfIndex, true)
+}
+
+func (v *VppLink) DisablePolicerOutput(policerIndex, swIfIndex uint32) error {
+	return v.policerOutput(policerIndex, swIfIndex, false)
+}
@@ -54,3 +54,51 @@ func (v *VppLink) GetBufferStats() (available uint32, cached uint32, used uint32
 	}
 	return response.AvailableBuffers, response.CachedBuffers, response.UsedBuffers, nil
 }
+
+const (
+	// CapoRuleStats are the per rule packets and bytes counters maintained by
+	// capo, indexed by capo rule ID
+	CapoRuleStats = "/capo/rules"
+	// CapoDefaultDenyStats are the per interface counters of the packets and
+	// bytes dropped by capo because no rule matched, indexed by sw_if_index
+	CapoDefaultDenyStats = "/capo/default-deny"
+)
+
+// GetCapoStats returns the capo rules and default deny packets and bytes
+// counters, summed across workers.
+func GetCapoStats(sc *statsclient.StatsClient) (rules map[uint32]adapter.CombinedCounter, defaultDeny map[uint32]adapter.CombinedCounter, err error) {
+	rules, err = getCombinedCountersByIndex(sc, CapoRuleStats)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaultDeny, err = getCombinedCountersByIndex(sc, CapoDefaultDenyStats)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, defaultDeny, nil
+}
+
+func getCombinedCountersByIndex(sc *statsclient.StatsClient, name string) (counters map[uint32]adapter.CombinedCounter, err error) {
+	dumpStats, err := sc.DumpStats("^" + name + "$")
+	if err != nil {
+		return nil, fmt.Errorf("dump stats %s failed: %w", name, err)
+	}
+	counters = make(map[uint32]adapter.CombinedCounter)
+	for _, sta := range dumpStats {
+		data, ok := sta.Data.(adapter.CombinedCounterStat)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a combined counter vector: %v", name, sta.Data)
+		}
+		for worker := range data {
+			for idx, counter := range data[worker] {
+				if counter.Packets() != 0 {
+					sum := counters[uint32(idx)]
+					sum[0] += counter.Packets()
+					sum[1] += counter.Bytes()
+					counters[uint32(idx)] = sum
+				}
+			}
+		}
+	}
+	return counters, nil
+}
@@ -26,6 +26,9 @@ import (
 
 const InvalidID uint32 = ^uint32(0)
 
+// CapoMaxRuleFilters is the number of filters a single capo rule can hold
+const CapoMaxRuleFilters = 3
+
 type IpsetType uint8
 
 const (
@@ -336,6 +339,23 @@ func NewInterfaceConfig() *InterfaceConfig {
 	}
 }
 
+// StagesConfig are the policies evaluated on an interface before cnat and
+// before the acl-plugin sessions. Untracked policies are stateless, and
+// preDNAT policies only end the stage when they allow a packet.
+type StagesConfig struct {
+	UntrackedIngressPolicyIDs []uint32
+	UntrackedEgressPolicyIDs  []uint32
+	PreDnatPolicyIDs          []uint32
+}
+
+func NewStagesConfig() *StagesConfig {
+	return &StagesConfig{
+		UntrackedIngressPolicyIDs: make([]uint32, 0),
+		UntrackedEgressPolicyIDs:  make([]uint32, 0),
+		PreDnatPolicyIDs:          make([]uint32, 0),
+	}
+}
+
 func toCapoFilter(f *RuleFilter) capo.CapoRuleFilter {
 	return capo.CapoRuleFilter{
 		Value:       uint32(f.Value),
@@ -363,9 +383,9 @@ func toCapoPortRange(pr PortRange) capo.CapoPortRange {
 }
 
 func ToCapoRule(r *Rule) (cr capo.CapoRule) {
-	var filters [3]capo.CapoRuleFilter
+	var filters [CapoMaxRuleFilters]capo.CapoRuleFilter
 	for i, f := range r.Filters {
-		if i == 3 {
+		if i == CapoMaxRuleFilters {
 			break
 		}
 		filters[i] = toCapoFilter(&f)
@@ -469,6 +489,67 @@ func ToCapoRule(r *Rule) (cr capo.CapoRule) {
 	return cr
 }
 
+func fromCapoFilter(f *capo.CapoRuleFilter) RuleFilter {
+	return RuleFilter{
+		ShouldMatch: f.ShouldMatch != 0,
+		Type:        CapoFilterType(f.Type),
+		Value:       int(f.Value),
+	}
+}
+
+func fromCapoPortRange(pr capo.CapoPortRange) PortRange {
+	return PortRange{
+		First: pr.Start,
+		Last:  pr.End,
+	}
+}
+
+// FromCapoRule converts a rule dumped from capo. Capo does not store the
+// address family of the rules, so it is left unset. The filters of type
+// none are skipped, and the DstIPPortSet entries are returned in
+// DstIPPortIPSet, as capo does not tell them apart.
+func FromCapoRule(cr *capo.CapoRule) *Rule {
+	r := &Rule{Action: RuleAction(cr.Action)}
+	for i := range cr.Filters {
+		if CapoFilterType(cr.Filters[i].Type) != CapoFilterTypeNone {
+			r.Filters = append(r.Filters, fromCapoFilter(&cr.Filters[i]))
+		}
+	}
+
+	// Indexed by 2*IsSrc + IsNot
+	nets := [4]*[]net.IPNet{&r.DstNet, &r.DstNotNet, &r.SrcNet, &r.SrcNotNet}
+	portRanges := [4]*[]PortRange{&r.DstPortRange, &r.DstNotPortRange, &r.SrcPortRange, &r.SrcNotPortRange}
+	ipPortIPSets := [4]*[]uint32{&r.DstIPPortIPSet, &r.DstNotIPPortIPSet, &r.SrcIPPortIPSet, &r.SrcNotIPPortIPSet}
+	ipSets := [4]*[]uint32{&r.DstIPSet, &r.DstNotIPSet, &r.SrcIPSet, &r.SrcNotIPSet}
+	for _, entry := range cr.Matches {
+		i := 0
+		if entry.IsSrc {
+			i += 2
+		}
+		if entry.IsNot {
+			i++
+		}
+		switch entry.Type {
+		case capo.CAPO_CIDR:
+			*nets[i] = append(*nets[i], *FromVppPrefix(entry.Data.GetCidr()))
+		case capo.CAPO_PORT_RANGE:
+			*portRanges[i] = append(*portRanges[i], fromCapoPortRange(entry.Data.GetPortRange()))
+		case capo.CAPO_PORT_IP_SET:
+			*ipPortIPSets[i] = append(*ipPortIPSets[i], entry.Data.GetSetID().SetID)
+		case capo.CAPO_IP_SET:
+			*ipSets[i] = append(*ipSets[i], entry.Data.GetSetID().SetID)
+		}
+	}
+	return r
+}
+
+// NormalizeCapoRule returns the rule as FromCapoRule returns it once it is
+// configured in capo, so that it can be compared with the dumped rules
+func NormalizeCapoRule(r *Rule) *Rule {
+	cr := ToCapoRule(r)
+	return FromCapoRule(&cr)
+}
+
 func ToCapoPolicy(p *Policy) (items []capo.CapoPolicyItem) {
 	items = make([]capo.CapoPolicyItem, 0, len(p.InboundRuleIDs)+len(p.OutboundRuleIDs))
 	for _, rid := range p.InboundRuleIDs {
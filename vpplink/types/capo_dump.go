@@ -0,0 +1,101 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
+)
+
+// CapoRuleDump is a rule as configured in capo, as returned by FromCapoRule
+type CapoRuleDump struct {
+	ID   uint32
+	Rule *Rule
+}
+
+// CapoPolicyDump is a policy as configured in capo. The IDs of the rules
+// deleted since the policy was configured are kept.
+type CapoPolicyDump struct {
+	ID        uint32
+	RxRuleIDs []uint32
+	TxRuleIDs []uint32
+	// Audit is set for policies in audit mode
+	Audit bool
+}
+
+// CapoIPSetDump is an ipset as configured in capo, its members are
+// formatted with FormatCapoIPSetMember
+type CapoIPSetDump struct {
+	ID      uint32
+	Type    IpsetType
+	Members map[string]bool
+}
+
+// CapoInterfaceDump is the policies configuration of an interface in capo, as
+// passed to ConfigurePolicies. The IDs of the policies deleted since the
+// interface was configured are kept.
+type CapoInterfaceDump struct {
+	SwIfIndex   uint32
+	InvertRxTx  bool
+	RxPolicyIDs []uint32
+	TxPolicyIDs []uint32
+	ProfileIDs  []uint32
+}
+
+// CapoStagesDump is the stages configuration of an interface in capo, as
+// passed to ConfigureStages
+type CapoStagesDump struct {
+	SwIfIndex            uint32
+	InvertRxTx           bool
+	UntrackedRxPolicyIDs []uint32
+	UntrackedTxPolicyIDs []uint32
+	PreDnatPolicyIDs     []uint32
+}
+
+// CapoDump is the state of the capo plugin
+type CapoDump struct {
+	Rules      map[uint32]*CapoRuleDump
+	Policies   map[uint32]*CapoPolicyDump
+	IPSets     map[uint32]*CapoIPSetDump
+	Interfaces map[uint32]*CapoInterfaceDump
+	Stages     map[uint32]*CapoStagesDump
+}
+
+// FormatCapoIPPort formats an ip+port ipset member as FormatCapoIPSetMember
+func FormatCapoIPPort(ipp IPPort) string {
+	return fmt.Sprintf("%s %s;%d", IPProto(ipp.L4Proto), ipp.Addr, ipp.Port)
+}
+
+// FormatCapoIPSetMember formats a member of an ipset dumped from capo, so that
+// it can be compared with the expected members. Addresses and prefixes are
+// formatted as by net.IP and net.IPNet, ip+port members by FormatCapoIPPort.
+func FormatCapoIPSetMember(ipsetType IpsetType, member *capo.CapoIpsetMember) string {
+	switch ipsetType {
+	case IpsetTypeIP:
+		return FromVppAddress(member.Val.GetAddress()).String()
+	case IpsetTypeIPPort:
+		tuple := member.Val.GetTuple()
+		return FormatCapoIPPort(IPPort{
+			Addr:    FromVppAddress(tuple.Address),
+			L4Proto: tuple.L4Proto,
+			Port:    tuple.Port,
+		})
+	case IpsetTypeNet:
+		return FromVppPrefix(member.Val.GetPrefix()).String()
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/capo"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capo dump conversion", func() {
+	It("should convert the dumped rules back", func() {
+		_, dstNet, _ := net.ParseCIDR("10.0.0.0/24")
+		_, srcNotNet, _ := net.ParseCIDR("fd00::/64")
+		rule := &Rule{
+			Action:            ActionDeny,
+			Filters:           []RuleFilter{{ShouldMatch: true, Type: CapoFilterProto, Value: int(TCP)}},
+			DstNet:            []net.IPNet{*dstNet},
+			SrcNotNet:         []net.IPNet{*srcNotNet},
+			DstPortRange:      []PortRange{{First: 80, Last: 80}, {First: 443, Last: 443}},
+			SrcIPSet:          []uint32{4, 2},
+			DstNotIPPortIPSet: []uint32{7},
+		}
+		cr := ToCapoRule(rule)
+		Expect(FromCapoRule(&cr)).To(Equal(rule))
+	})
+
+	It("should skip the filters of type none and drop the address family", func() {
+		rule := NormalizeCapoRule(&Rule{
+			Action:        ActionAllow,
+			AddressFamily: 1,
+			Filters: []RuleFilter{
+				{Type: CapoFilterTypeNone},
+				{ShouldMatch: true, Type: CapoFilterICMPType, Value: 8},
+			},
+		})
+		Expect(rule.AddressFamily).To(Equal(0))
+		Expect(rule.Filters).To(Equal([]RuleFilter{{ShouldMatch: true, Type: CapoFilterICMPType, Value: 8}}))
+	})
+
+	It("should return the ip+port sets in DstIPPortIPSet", func() {
+		rule := NormalizeCapoRule(&Rule{
+			Action:         ActionAllow,
+			DstIPPortIPSet: []uint32{1},
+			DstIPPortSet:   []uint32{3},
+		})
+		Expect(rule.DstIPPortIPSet).To(Equal([]uint32{1, 3}))
+		Expect(rule.DstIPPortSet).To(BeEmpty())
+	})
+
+	It("should format the ipset members", func() {
+		var member capo.CapoIpsetMember
+		member.Val.SetAddress(ToVppAddress(net.ParseIP("fd00::1")))
+		Expect(FormatCapoIPSetMember(IpsetTypeIP, &member)).To(Equal("fd00::1"))
+
+		_, prefix, _ := net.ParseCIDR("10.1.0.0/16")
+		member.Val.SetPrefix(ToVppPrefix(prefix))
+		Expect(FormatCapoIPSetMember(IpsetTypeNet, &member)).To(Equal(prefix.String()))
+
+		ipp := IPPort{Addr: net.ParseIP("10.0.0.1"), L4Proto: uint8(TCP), Port: 80}
+		member.Val.SetTuple(capo.CapoThreeTuple{
+			Address: ToVppAddress(ipp.Addr),
+			L4Proto: ipp.L4Proto,
+			Port:    ipp.Port,
+		})
+		Expect(FormatCapoIPSetMember(IpsetTypeIPPort, &member)).To(Equal(FormatCapoIPPort(ipp)))
+		Expect(FormatCapoIPPort(ipp)).To(Equal("TCP 10.0.0.1;80"))
+	})
+})
@@ -34,6 +34,24 @@ const (
 	MaglevLB  = CnatLbType(cnat.CNAT_LB_TYPE_MAGLEV)
 )
 
+type CnatAffinity uint8
+
+const (
+	NoAffinity       = CnatAffinity(cnat.CNAT_AFFINITY_NONE)
+	ClientIPAffinity = CnatAffinity(cnat.CNAT_AFFINITY_CLIENT_IP)
+)
+
+func (a CnatAffinity) String() string {
+	switch a {
+	case NoAffinity:
+		return "None"
+	case ClientIPAffinity:
+		return "ClientIP"
+	default:
+		return "???"
+	}
+}
+
 type CnatEndpoint struct {
 	IP   net.IP
 	Port uint16
@@ -75,6 +93,10 @@ type CnatTranslateEntry struct {
 	IsRealIP   bool
 	LbType     CnatLbType
 	HashConfig IPFlowHash
+	Affinity   CnatAffinity
+	// AffinityTimeout is the time in seconds a client keeps its backend
+	// after its last connection, 0 for the cnat default of 3 hours
+	AffinityTimeout uint32
 }
 
 func (n *CnatTranslateEntry) String() string {
@@ -82,13 +104,15 @@ func (n *CnatTranslateEntry) String() string {
 	for _, e := range n.Backends {
 		strLst = append(strLst, e.String())
 	}
-	return fmt.Sprintf("[%s real=%t lbtyp=%d vip=%s rw=%s hashc=%+v]",
+	return fmt.Sprintf("[%s real=%t lbtyp=%d vip=%s rw=%s hashc=%+v affinity=%s/%ds]",
 		n.Proto.String(),
 		n.IsRealIP,
 		n.LbType,
 		n.Endpoint.String(),
 		strings.Join(strLst, ", "),
 		n.HashConfig,
+		n.Affinity,
+		n.AffinityTimeout,
 	)
 }
 
@@ -123,6 +147,12 @@ func (n *CnatTranslateEntry) Equal(oldService *CnatTranslateEntry) ObjEqualitySt
 	if n.LbType != oldService.LbType {
 		return CanUpdateObj
 	}
+	if n.HashConfig != oldService.HashConfig {
+		return CanUpdateObj
+	}
+	if n.Affinity != oldService.Affinity || n.AffinityTimeout != oldService.AffinityTimeout {
+		return CanUpdateObj
+	}
 	if len(n.Backends) != len(oldService.Backends) {
 		return CanUpdateObj
 	}
@@ -138,6 +168,13 @@ func (n *CnatTranslateEntry) Equal(oldService *CnatTranslateEntry) ObjEqualitySt
 	return AreEqualObj
 }
 
+func FromCnatEndpoint(ep cnat.CnatEndpoint) CnatEndpoint {
+	return CnatEndpoint{
+		Port: ep.Port,
+		IP:   FromVppAddress(ep.Addr),
+	}
+}
+
 func ToCnatEndpoint(ep CnatEndpoint) cnat.CnatEndpoint {
 	return cnat.CnatEndpoint{
 		Port:      ep.Port,
@@ -38,17 +38,18 @@ const (
 )
 
 func (mode *IPProto) UnmarshalText(text []byte) error {
-	switch string(text) {
-	case "tcp":
-		*mode = TCP
-	case "udp":
-		*mode = UDP
-	default:
-		*mode = TCP
+	proto, err := UnformatProto(string(text))
+	if err != nil {
+		proto = TCP
 	}
+	*mode = proto
 	return nil
 }
 
+func (mode IPProto) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(mode.String())), nil
+}
+
 type IPFlowHash uint8
 
 const (
@@ -0,0 +1,32 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// PcapTrace describes a pcap capture. VPP only allows one capture at a time,
+// on a single interface or on all of them, and writes it to /tmp/<Filename>
+type PcapTrace struct {
+	CaptureRx   bool
+	CaptureTx   bool
+	CaptureDrop bool
+	// UseFilter restricts the capture to the packets matched by the pcap
+	// filter function, e.g. the BPF filter set with SetBpfTraceFilter
+	UseFilter         bool
+	MaxPackets        uint32
+	MaxBytesPerPacket uint32
+	// SwIfIndex is the captured interface, 0 captures all the interfaces
+	SwIfIndex uint32
+	Filename  string
+}
@@ -0,0 +1,33 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+)
+
+// Policer is a single rate two color policer: packets within the committed
+// rate and burst are transmitted, the others are dropped
+type Policer struct {
+	// CIR is the committed information rate in kbits per second
+	CIR uint32
+	// CB is the committed burst in bytes
+	CB uint64
+}
+
+func (p *Policer) String() string {
+	return fmt.Sprintf("cir=%dkbps cb=%dB", p.CIR, p.CB)
+}
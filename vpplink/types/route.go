@@ -28,6 +28,8 @@ type RoutePath struct {
 	SwIfIndex  uint32
 	Table      uint32
 	IsAttached bool
+	// IsDrop makes the path drop the packets
+	IsDrop     bool
 	Preference uint8
 	RpfID      uint32
 }
@@ -60,6 +62,7 @@ func FromFibPath(vppPath fib_types.FibPath) RoutePath {
 		Gw:        FromVppIpAddressUnion(vppPath.Nh.Address, vppPath.Proto == fib_types.FIB_API_PATH_NH_PROTO_IP6),
 		Table:     vppPath.TableID,
 		SwIfIndex: vppPath.SwIfIndex,
+		IsDrop:    vppPath.Type == fib_types.FIB_API_PATH_TYPE_DROP,
 	}
 }
 
@@ -91,6 +94,9 @@ func (p *RoutePath) ToFibPath(isIP6 bool) fib_types.FibPath {
 	if p.IsAttached {
 		fibPath.Flags |= fib_types.FIB_API_PATH_FLAG_RESOLVE_VIA_ATTACHED
 	}
+	if p.IsDrop {
+		fibPath.Type = fib_types.FIB_API_PATH_TYPE_DROP
+	}
 	if p.Gw != nil {
 		fibPath.Nh.Address = ToVppAddress(p.Gw).Un
 	}
@@ -113,6 +119,9 @@ func (p *RoutePath) gwString() string {
 }
 
 func (p *RoutePath) String() string {
+	if p.IsDrop {
+		return "drop"
+	}
 	return fmt.Sprintf("%s%s%s", p.tableString(), p.gwString(), p.swIfIndexString())
 }
 
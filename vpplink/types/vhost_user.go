@@ -0,0 +1,33 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net"
+)
+
+type VhostUser struct {
+	SockFilename string
+	IsServer     bool
+	EnableGso    bool
+	MacAddress   net.HardwareAddr
+	Tag          string
+	SwIfIndex    uint32
+	// Only set when listing
+	InterfaceName string
+	NumRegions    uint32
+	SockErrno     int32
+}
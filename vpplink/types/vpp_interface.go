@@ -30,6 +30,10 @@ import (
 
 type RxMode uint32
 
+func (mode RxMode) MarshalText() ([]byte, error) {
+	return []byte(FormatRxMode(mode)), nil
+}
+
 func (mode *RxMode) UnmarshalText(text []byte) error {
 	switch string(text) {
 	case "interrupt":
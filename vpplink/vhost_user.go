@@ -0,0 +1,88 @@
+// Copyright (C) 2025 Cisco Systems Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpplink
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/interface_types"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/generated/bindings/vhost_user"
+	"github.com/projectcalico/vpp-dataplane/v3/vpplink/types"
+)
+
+func (v *VppLink) CreateVhostUser(vhost *types.VhostUser) error {
+	client := vhost_user.NewServiceClient(v.GetConnection())
+
+	request := &vhost_user.CreateVhostUserIfV2{
+		IsServer:     vhost.IsServer,
+		SockFilename: vhost.SockFilename,
+		EnableGso:    vhost.EnableGso,
+		Tag:          vhost.Tag,
+	}
+	if vhost.MacAddress != nil {
+		request.UseCustomMac = true
+		request.MacAddress = types.MacAddress(vhost.MacAddress)
+	}
+	response, err := client.CreateVhostUserIfV2(v.GetContext(), request)
+	if err != nil {
+		return fmt.Errorf("CreateVhostUserIfV2 failed: %w", err)
+	}
+	vhost.SwIfIndex = uint32(response.SwIfIndex)
+	return nil
+}
+
+func (v *VppLink) DeleteVhostUser(swIfIndex uint32) error {
+	client := vhost_user.NewServiceClient(v.GetConnection())
+
+	_, err := client.DeleteVhostUserIf(v.GetContext(), &vhost_user.DeleteVhostUserIf{
+		SwIfIndex: interface_types.InterfaceIndex(swIfIndex),
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteVhostUserIf failed: %w", err)
+	}
+	return nil
+}
+
+func (v *VppLink) ListVhostUserInterfaces() ([]*types.VhostUser, error) {
+	client := vhost_user.NewServiceClient(v.GetConnection())
+
+	stream, err := client.SwInterfaceVhostUserDump(v.GetContext(), &vhost_user.SwInterfaceVhostUserDump{
+		SwIfIndex: interface_types.InterfaceIndex(INVALID_SW_IF_INDEX),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump vhost-user interfaces: %w", err)
+	}
+	vhosts := make([]*types.VhostUser, 0)
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump vhost-user interfaces: %w", err)
+		}
+		vhosts = append(vhosts, &types.VhostUser{
+			SwIfIndex:     uint32(response.SwIfIndex),
+			SockFilename:  response.SockFilename,
+			IsServer:      response.IsServer,
+			InterfaceName: response.InterfaceName,
+			NumRegions:    response.NumRegions,
+			SockErrno:     response.SockErrno,
+		})
+	}
+	return vhosts, nil
+}